@@ -0,0 +1,179 @@
+package api_server
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"WifiPcapAnalyzer/logger"
+	"WifiPcapAnalyzer/state_manager"
+	"bytes"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	prometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// handleMetrics serves the snapshot and the parse pipeline counters for Prometheus, in the
+// text exposition format, or in OpenMetrics when the scraper asks for it.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	mw := &metricsWriter{openMetrics: strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")}
+	writeMetrics(mw, s.stateMgr.GetSnapshot(), frame_parser.GetPipelineStats(), s.metrics.MaxBSSs, s.metrics.MaxSTAs)
+
+	contentType := prometheusContentType
+	if mw.openMetrics {
+		contentType = openMetricsContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write(mw.buf.Bytes()); err != nil {
+		logger.Log.Warn().Err(err).Msg("Error writing metrics response")
+	}
+}
+
+// writeMetrics writes every metric family. Only the maxBSSs BSSs and maxSTAs STAs seen most
+// recently get their own series, which bounds the label cardinality in busy environments;
+// the totals still count all of them.
+func writeMetrics(mw *metricsWriter, snapshot state_manager.Snapshot, pipeline frame_parser.PipelineStats, maxBSSs, maxSTAs int) {
+	bsss, omittedBSSs := mostRecent(snapshot.BSSs, maxBSSs,
+		func(b *state_manager.BSSInfo) int64 { return b.LastSeen }, func(b *state_manager.BSSInfo) string { return b.BSSID })
+	stas, omittedSTAs := mostRecent(snapshot.STAs, maxSTAs,
+		func(s *state_manager.STAInfo) int64 { return s.LastSeen }, func(s *state_manager.STAInfo) string { return s.MACAddress })
+
+	mw.family("wifipcap_frames_parsed_total", "counter", "Frames parsed, live or from files.")
+	mw.sample("wifipcap_frames_parsed_total", float64(pipeline.FramesParsed))
+	mw.family("wifipcap_frame_parse_errors_total", "counter", "Frames that could not be parsed.")
+	mw.sample("wifipcap_frame_parse_errors_total", float64(pipeline.ParseErrors))
+	mw.family("wifipcap_frames_dropped_total", "counter",
+		"Frames lost by the capture, from pcapng interface statistics. Classic pcap streams, such as live captures from the agent, report no drops.")
+	mw.sample("wifipcap_frames_dropped_total", float64(pipeline.FramesDropped))
+
+	mw.family("wifipcap_bsss", "gauge", "BSSs in the current state.")
+	mw.sample("wifipcap_bsss", float64(len(snapshot.BSSs)))
+	mw.family("wifipcap_stas", "gauge", "STAs in the current state.")
+	mw.sample("wifipcap_stas", float64(len(snapshot.STAs)))
+	mw.family("wifipcap_series_omitted", "gauge", "BSSs and STAs without their own series because of the configured limits.")
+	mw.sample("wifipcap_series_omitted", float64(omittedBSSs), "kind", "bss")
+	mw.sample("wifipcap_series_omitted", float64(omittedSTAs), "kind", "sta")
+
+	bssGauges := []struct {
+		name, help string
+		value      func(*state_manager.BSSInfo) float64
+	}{
+		{"wifipcap_bss_channel_utilization_percent", "Channel utilization by the BSS, in percent.",
+			func(b *state_manager.BSSInfo) float64 { return b.ChannelUtilization }},
+		{"wifipcap_bss_throughput_bps", "Throughput of the BSS, in bits per second.",
+			func(b *state_manager.BSSInfo) float64 { return float64(b.Throughput) }},
+		{"wifipcap_bss_stations", "STAs associated with the BSS.",
+			func(b *state_manager.BSSInfo) float64 { return float64(len(b.AssociatedSTAs)) }},
+		{"wifipcap_bss_rssi_dbm", "Signal strength of the AP, in dBm.",
+			func(b *state_manager.BSSInfo) float64 { return float64(b.SignalStrength) }},
+	}
+	for _, g := range bssGauges {
+		mw.family(g.name, "gauge", g.help)
+		for _, bss := range bsss {
+			mw.sample(g.name, g.value(bss), "bssid", bss.BSSID, "ssid", bss.SSID, "channel", strconv.Itoa(bss.Channel))
+		}
+	}
+
+	staMetrics := []struct {
+		name, kind, help string
+		value            func(*state_manager.STAInfo) float64
+	}{
+		{"wifipcap_sta_rssi_dbm", "gauge", "Signal strength of the STA, in dBm.",
+			func(s *state_manager.STAInfo) float64 { return float64(s.SignalStrength) }},
+		{"wifipcap_sta_bitrate_mbps", "gauge", "PHY rate of the STA's latest frame, in Mbit/s.",
+			func(s *state_manager.STAInfo) float64 { return s.BitRate }},
+		{"wifipcap_sta_uplink_throughput_bps", "gauge", "Uplink throughput of the STA, in bits per second.",
+			func(s *state_manager.STAInfo) float64 { return float64(s.UplinkThroughput) }},
+		{"wifipcap_sta_downlink_throughput_bps", "gauge", "Downlink throughput of the STA, in bits per second.",
+			func(s *state_manager.STAInfo) float64 { return float64(s.DownlinkThroughput) }},
+		{"wifipcap_sta_tx_packets_total", "counter", "Frames sent by the STA.",
+			func(s *state_manager.STAInfo) float64 { return float64(s.TxPackets) }},
+		{"wifipcap_sta_rx_packets_total", "counter", "Frames received by the STA.",
+			func(s *state_manager.STAInfo) float64 { return float64(s.RxPackets) }},
+		{"wifipcap_sta_tx_retries_total", "counter", "Retransmitted frames sent by the STA.",
+			func(s *state_manager.STAInfo) float64 { return float64(s.TxRetries) }},
+		{"wifipcap_sta_rx_retries_total", "counter", "Retransmitted frames received by the STA.",
+			func(s *state_manager.STAInfo) float64 { return float64(s.RxRetries) }},
+	}
+	for _, m := range staMetrics {
+		mw.family(m.name, m.kind, m.help)
+		for _, sta := range stas {
+			mw.sample(m.name, m.value(sta), "mac", sta.MACAddress, "bssid", sta.AssociatedBSSID)
+		}
+	}
+	mw.end()
+}
+
+// mostRecent returns the max most recently seen items, sorted by key so that scrapes list
+// series in a stable order, and how many items were left out.
+func mostRecent[T any](items []T, max int, lastSeen func(T) int64, key func(T) string) ([]T, int) {
+	kept := append([]T(nil), items...)
+	if len(kept) > max {
+		sort.Slice(kept, func(i, j int) bool {
+			if lastSeen(kept[i]) != lastSeen(kept[j]) {
+				return lastSeen(kept[i]) > lastSeen(kept[j])
+			}
+			return key(kept[i]) < key(kept[j])
+		})
+		kept = kept[:max]
+	}
+	sort.Slice(kept, func(i, j int) bool { return key(kept[i]) < key(kept[j]) })
+	return kept, len(items) - len(kept)
+}
+
+// metricsWriter writes the Prometheus text format (version 0.0.4), or OpenMetrics 1.0, which
+// differs in naming counter families without "_total" and in ending with "# EOF".
+type metricsWriter struct {
+	buf         bytes.Buffer
+	openMetrics bool
+}
+
+func (m *metricsWriter) family(name, kind, help string) {
+	if m.openMetrics && kind == "counter" {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	m.buf.WriteString("# HELP " + name + " " + help + "\n")
+	m.buf.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// sample writes one sample; labels are name, value pairs.
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.buf.WriteString(name)
+	if len(labels) > 0 {
+		m.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+			m.buf.WriteString(labels[i] + `="` + labelValueEscaper.Replace(strings.ToValidUTF8(labels[i+1], "\uFFFD")) + `"`)
+		}
+		m.buf.WriteByte('}')
+	}
+	m.buf.WriteByte(' ')
+	m.buf.WriteString(formatSampleValue(value))
+	m.buf.WriteByte('\n')
+}
+
+func (m *metricsWriter) end() {
+	if m.openMetrics {
+		m.buf.WriteString("# EOF\n")
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatSampleValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
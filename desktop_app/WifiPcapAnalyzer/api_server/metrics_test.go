@@ -0,0 +1,96 @@
+package api_server
+
+import (
+	"WifiPcapAnalyzer/config"
+	"WifiPcapAnalyzer/frame_parser"
+	"WifiPcapAnalyzer/state_manager"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Metrics(t *testing.T) {
+	sm := state_manager.NewStateManager(time.Second, 5)
+	bssid, _ := net.ParseMAC("02:00:00:00:00:aa")
+	staMAC, _ := net.ParseMAC("02:00:00:00:00:01")
+	sm.UpdateBSS(bssid, `lab "5G"`, 36, -45, "WPA2-Personal", time.Now())
+	sm.UpdateSTA(staMAC, bssid, -51, time.Now())
+	srv := httptest.NewServer(NewServer("127.0.0.1:0", config.APIConfig{}, config.MetricsConfig{}, sm, &fakeController{}).Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, prometheusContentType, resp.Header.Get("Content-Type"))
+	text := string(body)
+	assert.Contains(t, text, "# TYPE wifipcap_frames_parsed_total counter\n")
+	assert.Contains(t, text, `wifipcap_bss_rssi_dbm{bssid="02:00:00:00:00:aa",ssid="lab \"5G\"",channel="36"} -45`+"\n")
+	assert.Contains(t, text, `wifipcap_bss_stations{bssid="02:00:00:00:00:aa",ssid="lab \"5G\"",channel="36"} 1`+"\n")
+	assert.Contains(t, text, `wifipcap_sta_rssi_dbm{mac="02:00:00:00:00:01",bssid="02:00:00:00:00:aa"} -51`+"\n")
+	assert.Contains(t, text, `wifipcap_series_omitted{kind="sta"} 0`+"\n")
+	assert.NotContains(t, text, "# EOF")
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, openMetricsContentType, resp.Header.Get("Content-Type"))
+	text = string(body)
+	assert.Contains(t, text, "# TYPE wifipcap_sta_tx_retries counter\n", "OpenMetrics counter families have no _total suffix")
+	assert.Contains(t, text, `wifipcap_sta_tx_retries_total{mac="02:00:00:00:00:01",bssid="02:00:00:00:00:aa"} 0`+"\n")
+	assert.True(t, strings.HasSuffix(text, "# EOF\n"))
+
+	// The series limits come from the server's metrics config.
+	otherMAC, _ := net.ParseMAC("02:00:00:00:00:02")
+	sm.UpdateSTA(otherMAC, bssid, -60, time.Now())
+	limited := httptest.NewServer(NewServer("127.0.0.1:0", config.APIConfig{}, config.MetricsConfig{MaxSTAs: 1}, sm, &fakeController{}).Handler())
+	defer limited.Close()
+	resp, err = http.Get(limited.URL + "/metrics")
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `wifipcap_series_omitted{kind="sta"} 1`+"\n")
+	assert.Contains(t, string(body), `wifipcap_series_omitted{kind="bss"} 0`+"\n")
+}
+
+func TestWriteMetrics_SeriesLimits(t *testing.T) {
+	snapshot := state_manager.Snapshot{
+		BSSs: []*state_manager.BSSInfo{
+			{BSSID: "02:00:00:00:00:aa", SSID: "old", Channel: 1, LastSeen: 1000},
+			{BSSID: "02:00:00:00:00:bb", SSID: "new", Channel: 6, LastSeen: 3000, ChannelUtilization: 12.5},
+			{BSSID: "02:00:00:00:00:cc", SSID: "newer", Channel: 11, LastSeen: 4000},
+		},
+		STAs: []*state_manager.STAInfo{
+			{MACAddress: "02:00:00:00:00:02", LastSeen: 2000},
+			{MACAddress: "02:00:00:00:00:01", LastSeen: 2000, BitRate: 866.7},
+		},
+	}
+	mw := &metricsWriter{}
+	writeMetrics(mw, snapshot, frame_parser.PipelineStats{FramesParsed: 10, ParseErrors: 2, FramesDropped: 3}, 2, 1)
+	text := mw.buf.String()
+
+	assert.Contains(t, text, "wifipcap_frames_parsed_total 10\n")
+	assert.Contains(t, text, "wifipcap_frame_parse_errors_total 2\n")
+	assert.Contains(t, text, "wifipcap_frames_dropped_total 3\n")
+	assert.Contains(t, text, "wifipcap_bsss 3\n", "totals count every BSS")
+	assert.Contains(t, text, `wifipcap_series_omitted{kind="bss"} 1`)
+	assert.Contains(t, text, `wifipcap_series_omitted{kind="sta"} 1`)
+	assert.NotContains(t, text, `ssid="old"`, "the least recently seen BSS is left out")
+	assert.Contains(t, text, `wifipcap_bss_channel_utilization_percent{bssid="02:00:00:00:00:bb",ssid="new",channel="6"} 12.5`)
+	assert.Less(t, strings.Index(text, `ssid="new"`), strings.Index(text, `ssid="newer"`), "series are sorted by BSSID")
+	assert.Contains(t, text, `wifipcap_sta_bitrate_mbps{mac="02:00:00:00:00:01",bssid=""} 866.7`, "ties are broken by MAC")
+	assert.NotContains(t, text, "02:00:00:00:00:02")
+}
@@ -0,0 +1,305 @@
+// Package api_server serves the analyzer state over HTTP, for browsers and dashboards
+// besides the embedded Wails frontend:
+//
+//	GET  /api/snapshot        Current snapshot (confirmed BSSs and STAs)
+//	GET  /api/bss/{bssid}     One BSS, with its associated STAs
+//	GET  /api/sta/{mac}       One STA
+//	POST /api/capture/start   Start a live capture: {"interface", "channel", "bandwidth", "filter"}
+//	POST /api/capture/stop    Stop the live capture
+//	POST /api/file            Process a capture file in the capture directory: {"path"}
+//	GET  /ws                  WebSocket pushing state_snapshot deltas
+//	GET  /metrics             BSS, STA and parse pipeline metrics for Prometheus
+//
+// The POST endpoints require the configured token as "Authorization: Bearer <token>", and
+// browsers may only call the API from the configured origins (see config.APIConfig).
+package api_server
+
+import (
+	"WifiPcapAnalyzer/config"
+	"WifiPcapAnalyzer/logger"
+	"WifiPcapAnalyzer/state_manager"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Controller starts and stops captures for the API; the desktop App implements it.
+type Controller interface {
+	StartCapture(interfaceName string, channel int32, bandwidth string, bpfFilter string) error
+	StopCapture() error
+	ProcessPcapFile(filePath string) (string, error)
+}
+
+// Server is the HTTP/WebSocket API server.
+type Server struct {
+	stateMgr   *state_manager.StateManager
+	controller Controller
+	api        config.APIConfig
+	metrics    config.MetricsConfig
+	hub        *snapshotHub
+	httpServer *http.Server
+}
+
+// NewServer returns a Server for addr (host:port), secured as api configures, whose /metrics
+// series are limited as metrics configures (zero limits take the defaults). It does not
+// listen until ListenAndServe.
+func NewServer(addr string, api config.APIConfig, metrics config.MetricsConfig, stateMgr *state_manager.StateManager, controller Controller) *Server {
+	if metrics.MaxBSSs <= 0 {
+		metrics.MaxBSSs = config.DefaultConfig.Metrics.MaxBSSs
+	}
+	if metrics.MaxSTAs <= 0 {
+		metrics.MaxSTAs = config.DefaultConfig.Metrics.MaxSTAs
+	}
+	s := &Server{
+		stateMgr:   stateMgr,
+		controller: controller,
+		api:        api,
+		metrics:    metrics,
+		hub:        newSnapshotHub(stateMgr, snapshotPushInterval),
+	}
+	s.httpServer = &http.Server{Addr: addr, Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	return s
+}
+
+// Handler returns the API routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/snapshot", s.handleSnapshot)
+	mux.HandleFunc("GET /api/bss/{bssid}", s.handleBSS)
+	mux.HandleFunc("GET /api/sta/{mac}", s.handleSTA)
+	mux.HandleFunc("POST /api/capture/start", s.requireToken(s.handleStartCapture))
+	mux.HandleFunc("POST /api/capture/stop", s.requireToken(s.handleStopCapture))
+	mux.HandleFunc("POST /api/file", s.requireToken(s.handleFile))
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.Handle("GET /ws", websocket.Server{Handler: s.hub.serveClient, Handshake: s.checkWebSocketOrigin})
+	return s.withCORS(mux)
+}
+
+// ListenAndServe serves the API until Shutdown. Like http.Server, it returns
+// http.ErrServerClosed after a Shutdown.
+func (s *Server) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	go s.hub.run()
+	logger.Log.Info().Str("address", ln.Addr().String()).Msg("API server listening.")
+	return s.httpServer.Serve(ln)
+}
+
+// Shutdown stops the server and disconnects the WebSocket clients.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.hub.stop()
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.stateMgr.GetSnapshot())
+}
+
+func (s *Server) handleBSS(w http.ResponseWriter, r *http.Request) {
+	bss, ok := s.stateMgr.GetBSS(normalizeMAC(r.PathValue("bssid")))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("BSS not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, bss)
+}
+
+func (s *Server) handleSTA(w http.ResponseWriter, r *http.Request) {
+	sta, ok := s.stateMgr.GetSTA(normalizeMAC(r.PathValue("mac")))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("STA not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, sta)
+}
+
+type startCaptureRequest struct {
+	Interface string `json:"interface"`
+	Channel   int32  `json:"channel"`
+	Bandwidth string `json:"bandwidth"`
+	Filter    string `json:"filter"`
+}
+
+func (s *Server) handleStartCapture(w http.ResponseWriter, r *http.Request) {
+	var req startCaptureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.controller.StartCapture(req.Interface, req.Channel, req.Bandwidth, req.Filter); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statusResponse{Status: "capturing"})
+}
+
+func (s *Server) handleStopCapture(w http.ResponseWriter, r *http.Request) {
+	if err := s.controller.StopCapture(); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, statusResponse{Status: "stopped"})
+}
+
+type fileRequest struct {
+	Path string `json:"path"`
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	var req fileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Path == "" {
+		writeError(w, http.StatusBadRequest, errors.New("path is required"))
+		return
+	}
+	path, status, err := capturePath(s.api.CaptureDir, req.Path)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	message, err := s.controller.ProcessPcapFile(path)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, statusResponse{Status: "processing_file", Message: message})
+}
+
+type statusResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Log.Warn().Err(err).Msg("Error writing API response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// capturePath resolves a path from /api/file, relative to the capture directory unless
+// absolute, and refuses files outside that directory, through symbolic links too. On error it
+// also returns the HTTP status to answer with.
+func capturePath(captureDir, path string) (string, int, error) {
+	if captureDir == "" {
+		return "", http.StatusForbidden, errors.New("loading capture files is disabled: no capture directory is configured")
+	}
+	dir, err := filepath.Abs(captureDir)
+	var realDir string
+	if err == nil {
+		realDir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		logger.Log.Error().Err(err).Str("capture_dir", captureDir).Msg("Capture directory is not usable")
+		return "", http.StatusInternalServerError, errors.New("capture directory is not usable")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	// Checked before touching the file system, so that nothing outside can be probed, and
+	// again once symbolic links are resolved
+	errOutside := errors.New("path is outside the capture directory")
+	if path = filepath.Clean(path); !inDir(dir, path) && !inDir(realDir, path) {
+		return "", http.StatusForbidden, errOutside
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", http.StatusBadRequest, errors.New("capture file not found")
+	}
+	if !inDir(realDir, resolved) {
+		return "", http.StatusForbidden, errOutside
+	}
+	return resolved, 0, nil
+}
+
+// inDir reports whether the clean, absolute path names something below dir.
+func inDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// requireToken refuses requests without the configured bearer token, and every request when
+// no token is configured.
+func (s *Server) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.api.Token == "" {
+			writeError(w, http.StatusForbidden, errors.New("capture control is disabled: no API token is configured"))
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.api.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="wifipcap"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid API token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// originAllowed reports whether browsers on origin may call the API.
+func (s *Server) originAllowed(origin string) bool {
+	return origin != "" && slices.Contains(s.api.AllowedOrigins, origin)
+}
+
+// withCORS lets browser dashboards served from the allowed origins call the API.
+func (s *Server) withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		allowed := s.originAllowed(origin)
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if r.Method == http.MethodOptions {
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkWebSocketOrigin accepts WebSocket clients from the allowed origins, and clients that
+// send no Origin (which browsers always do).
+func (s *Server) checkWebSocketOrigin(cfg *websocket.Config, r *http.Request) error {
+	if origin := r.Header.Get("Origin"); origin != "" && !s.originAllowed(origin) {
+		return fmt.Errorf("origin %q is not allowed", origin)
+	}
+	return nil
+}
+
+// normalizeMAC turns a MAC address from a URL into the form state keys use
+// (lower case, colon separated), accepting dashes as separators.
+func normalizeMAC(mac string) string {
+	if hw, err := net.ParseMAC(mac); err == nil {
+		return hw.String()
+	}
+	return strings.ToLower(mac)
+}
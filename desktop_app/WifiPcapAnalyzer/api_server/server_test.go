@@ -0,0 +1,259 @@
+package api_server
+
+import (
+	"WifiPcapAnalyzer/config"
+	"WifiPcapAnalyzer/state_manager"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+type fakeController struct {
+	started []string
+	stopped int
+	files   []string
+}
+
+func (c *fakeController) StartCapture(interfaceName string, channel int32, bandwidth string, bpfFilter string) error {
+	if interfaceName == "" {
+		return errors.New("interface name cannot be empty")
+	}
+	c.started = append(c.started, interfaceName)
+	return nil
+}
+
+func (c *fakeController) StopCapture() error {
+	c.stopped++
+	return nil
+}
+
+func (c *fakeController) ProcessPcapFile(filePath string) (string, error) {
+	c.files = append(c.files, filePath)
+	return "Processing pcap file: " + filePath, nil
+}
+
+func TestServer_REST(t *testing.T) {
+	sm := state_manager.NewStateManager(time.Second, 5)
+	bssid, _ := net.ParseMAC("02:00:00:00:00:aa")
+	staMAC, _ := net.ParseMAC("02:00:00:00:00:01")
+	sm.UpdateBSS(bssid, "lab", 36, -45, "WPA2-Personal", time.Now())
+	sm.UpdateSTA(staMAC, bssid, -51, time.Now())
+	captureDir := newCaptureDir(t)
+	controller := &fakeController{}
+	api := config.APIConfig{Token: "s3cret", AllowedOrigins: []string{"http://dashboard.example"}, CaptureDir: captureDir}
+	srv := httptest.NewServer(NewServer("127.0.0.1:0", api, config.MetricsConfig{}, sm, controller).Handler())
+	defer srv.Close()
+
+	get := func(path string, v interface{}) int {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Origin", "http://dashboard.example")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "http://dashboard.example", resp.Header.Get("Access-Control-Allow-Origin"))
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		return resp.StatusCode
+	}
+	post := func(path, body string) (int, map[string]string) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer s3cret")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return resp.StatusCode, out
+	}
+
+	var snapshot state_manager.Snapshot
+	assert.Equal(t, http.StatusOK, get("/api/snapshot", &snapshot))
+	assert.Len(t, snapshot.BSSs, 1)
+	assert.Len(t, snapshot.STAs, 1)
+
+	var bss state_manager.BSSInfo
+	assert.Equal(t, http.StatusOK, get("/api/bss/02-00-00-00-00-AA", &bss), "dashes and upper case are accepted")
+	assert.Equal(t, "lab", bss.SSID)
+	assert.Contains(t, bss.AssociatedSTAs, "02:00:00:00:00:01")
+
+	var sta state_manager.STAInfo
+	assert.Equal(t, http.StatusOK, get("/api/sta/02:00:00:00:00:01", &sta))
+	assert.Equal(t, "02:00:00:00:00:aa", sta.AssociatedBSSID)
+	var notFound errorResponse
+	assert.Equal(t, http.StatusNotFound, get("/api/sta/02:00:00:00:00:02", &notFound))
+	assert.Equal(t, "STA not found", notFound.Error)
+
+	status, body := post("/api/capture/start", `{"interface":"wlan0","channel":36,"bandwidth":"80MHz"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "capturing", body["status"])
+	assert.Equal(t, []string{"wlan0"}, controller.started)
+	status, body = post("/api/capture/start", `{"channel":36}`)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "interface name cannot be empty", body["error"])
+
+	status, _ = post("/api/capture/stop", ``)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, controller.stopped)
+
+	status, _ = post("/api/file", `{}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, body = post("/api/file", `{"path":"incident.pcapng"}`)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Equal(t, "processing_file", body["status"])
+	assert.Equal(t, []string{filepath.Join(captureDir, "incident.pcapng")}, controller.files)
+}
+
+// newCaptureDir returns a capture directory holding incident.pcapng, with the symbolic links
+// resolved, and a file outside it reachable through the link escape.pcapng.
+func newCaptureDir(t *testing.T) string {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	dir := filepath.Join(root, "captures")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "incident.pcapng"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret.pcapng"), nil, 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(root, "secret.pcapng"), filepath.Join(dir, "escape.pcapng")))
+	return dir
+}
+
+func TestServer_Security(t *testing.T) {
+	sm := state_manager.NewStateManager(time.Second, 5)
+	captureDir := newCaptureDir(t)
+	secured := config.APIConfig{Token: "s3cret", AllowedOrigins: []string{"http://dashboard.example"}, CaptureDir: captureDir}
+
+	tests := []struct {
+		name   string
+		api    config.APIConfig
+		method string
+		path   string
+		header map[string]string
+		body   string
+		status int
+		cors   string // Access-Control-Allow-Origin expected
+	}{
+		{name: "no token configured", method: http.MethodPost, path: "/api/capture/stop",
+			header: map[string]string{"Authorization": "Bearer "}, status: http.StatusForbidden},
+		{name: "missing token", api: secured, method: http.MethodPost, path: "/api/capture/stop", status: http.StatusUnauthorized},
+		{name: "wrong token", api: secured, method: http.MethodPost, path: "/api/capture/stop",
+			header: map[string]string{"Authorization": "Bearer guess"}, status: http.StatusUnauthorized},
+		{name: "token", api: secured, method: http.MethodPost, path: "/api/capture/stop",
+			header: map[string]string{"Authorization": "Bearer s3cret"}, status: http.StatusOK},
+		{name: "reads need no token", api: secured, method: http.MethodGet, path: "/api/snapshot", status: http.StatusOK},
+		{name: "allowed origin", api: secured, method: http.MethodGet, path: "/api/snapshot",
+			header: map[string]string{"Origin": "http://dashboard.example"}, status: http.StatusOK, cors: "http://dashboard.example"},
+		{name: "other origin", api: secured, method: http.MethodGet, path: "/api/snapshot",
+			header: map[string]string{"Origin": "http://evil.example"}, status: http.StatusOK},
+		{name: "preflight from other origin", api: secured, method: http.MethodOptions, path: "/api/capture/start",
+			header: map[string]string{"Origin": "http://evil.example"}, status: http.StatusNoContent},
+		{name: "no capture directory configured", api: config.APIConfig{Token: "s3cret"}, method: http.MethodPost, path: "/api/file",
+			header: map[string]string{"Authorization": "Bearer s3cret"}, body: `{"path":"incident.pcapng"}`, status: http.StatusForbidden},
+		{name: "path traversal", api: secured, method: http.MethodPost, path: "/api/file",
+			header: map[string]string{"Authorization": "Bearer s3cret"}, body: `{"path":"../secret.pcapng"}`, status: http.StatusForbidden},
+		{name: "absolute path outside", api: secured, method: http.MethodPost, path: "/api/file",
+			header: map[string]string{"Authorization": "Bearer s3cret"}, body: `{"path":"/etc/passwd"}`, status: http.StatusForbidden},
+		{name: "symbolic link out", api: secured, method: http.MethodPost, path: "/api/file",
+			header: map[string]string{"Authorization": "Bearer s3cret"}, body: `{"path":"escape.pcapng"}`, status: http.StatusForbidden},
+		{name: "missing file", api: secured, method: http.MethodPost, path: "/api/file",
+			header: map[string]string{"Authorization": "Bearer s3cret"}, body: `{"path":"missing.pcapng"}`, status: http.StatusBadRequest},
+		{name: "absolute path inside", api: secured, method: http.MethodPost, path: "/api/file",
+			header: map[string]string{"Authorization": "Bearer s3cret"}, body: `{"path":"` + filepath.Join(captureDir, "incident.pcapng") + `"}`, status: http.StatusAccepted},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := &fakeController{}
+			srv := httptest.NewServer(NewServer("127.0.0.1:0", tc.api, config.MetricsConfig{}, sm, controller).Handler())
+			defer srv.Close()
+			req, err := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.status, resp.StatusCode)
+			assert.Equal(t, tc.cors, resp.Header.Get("Access-Control-Allow-Origin"))
+			assert.Empty(t, resp.Header.Get("Access-Control-Allow-Methods"))
+			if tc.status >= 400 {
+				assert.Zero(t, controller.stopped)
+				assert.Empty(t, controller.files)
+			}
+		})
+	}
+
+	srv := httptest.NewServer(NewServer("127.0.0.1:0", secured, config.MetricsConfig{}, sm, &fakeController{}).Handler())
+	defer srv.Close()
+	req, err := http.NewRequest(http.MethodOptions, srv.URL+"/api/capture/start", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "http://dashboard.example")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "http://dashboard.example", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "Authorization")
+}
+
+func TestServer_WebSocketPushesDeltas(t *testing.T) {
+	sm := state_manager.NewStateManager(time.Second, 5)
+	bssid, _ := net.ParseMAC("02:00:00:00:00:aa")
+	staMAC, _ := net.ParseMAC("02:00:00:00:00:01")
+	otherMAC, _ := net.ParseMAC("02:00:00:00:00:02")
+	now := time.Now()
+	sm.UpdateBSS(bssid, "lab", 6, -45, "", now)
+	sm.UpdateSTA(staMAC, bssid, -51, now)
+	sm.UpdateSTA(otherMAC, bssid, -60, now.Add(-time.Hour))
+	s := NewServer("127.0.0.1:0", config.APIConfig{AllowedOrigins: []string{"http://dashboard.example"}}, config.MetricsConfig{}, sm, &fakeController{})
+	s.hub = newSnapshotHub(sm, 10*time.Millisecond)
+	go s.hub.run()
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	defer s.hub.stop()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+	_, err := websocket.Dial(wsURL, "", "http://evil.example")
+	require.Error(t, err, "pages on other origins may not read the state")
+	ws, err := websocket.Dial(wsURL, "", "http://dashboard.example")
+	require.NoError(t, err)
+	defer ws.Close()
+	receive := func() map[string]json.RawMessage {
+		require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+		var msg map[string]json.RawMessage
+		require.NoError(t, websocket.JSON.Receive(ws, &msg))
+		return msg
+	}
+
+	first := receive()
+	assert.JSONEq(t, `"state_snapshot"`, string(first["type"]))
+	assert.JSONEq(t, `true`, string(first["full"]))
+	var stas []map[string]any
+	require.NoError(t, json.Unmarshal(first["added_stas"], &stas))
+	assert.Len(t, stas, 2)
+
+	// Unchanged state sends nothing; the next message holds only what changed
+	time.Sleep(50 * time.Millisecond)
+	sm.UpdateBSS(bssid, "", 36, -45, "", now)
+	sm.PruneOldEntries(2 * time.Minute)
+	delta := receive()
+	assert.JSONEq(t, `false`, string(delta["full"]))
+	var bsss []map[string]any
+	require.NoError(t, json.Unmarshal(delta["updated_bsss"], &bsss))
+	require.Len(t, bsss, 1)
+	assert.EqualValues(t, 36, bsss[0]["channel"])
+	assert.NotContains(t, bsss[0], "ssid", "unchanged fields are left out")
+	assert.JSONEq(t, `["02:00:00:00:00:02"]`, string(delta["removed_stas"]))
+	assert.NotContains(t, delta, "added_stas")
+}
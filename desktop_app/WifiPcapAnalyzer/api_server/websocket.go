@@ -0,0 +1,151 @@
+package api_server
+
+import (
+	"WifiPcapAnalyzer/logger"
+	"WifiPcapAnalyzer/state_manager"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// snapshotPushInterval is how often WebSocket clients get changes, as the Wails frontend does.
+const snapshotPushInterval = 500 * time.Millisecond
+
+// snapshotResyncInterval is how often WebSocket clients get the full state rather than a delta.
+const snapshotResyncInterval = 30 * time.Second
+
+// clientQueueLength is how many messages may wait for a slow client. When its queue is full,
+// the client misses deltas and gets the full state once there is room again.
+const clientQueueLength = 8
+
+// snapshotMessage is a "state_snapshot" WebSocket message: a state_manager.StateDelta. A full
+// delta replaces the client's state; otherwise it holds the entities added, the fields that
+// changed and the entities removed since the client's previous message.
+type snapshotMessage struct {
+	Type string `json:"type"`
+	state_manager.StateDelta
+}
+
+// wsClient is one connected WebSocket client.
+type wsClient struct {
+	send chan []byte
+	sub  *state_manager.DeltaSubscriber // Starts with the full state
+}
+
+// snapshotHub sends each connected client, every interval, the changes since its previous
+// message.
+type snapshotHub struct {
+	stateMgr *state_manager.StateManager
+	interval time.Duration
+
+	mutex   sync.Mutex
+	clients map[*wsClient]bool
+	done    chan struct{}
+	stopped sync.Once
+}
+
+func newSnapshotHub(stateMgr *state_manager.StateManager, interval time.Duration) *snapshotHub {
+	return &snapshotHub{
+		stateMgr: stateMgr,
+		interval: interval,
+		clients:  make(map[*wsClient]bool),
+		done:     make(chan struct{}),
+	}
+}
+
+func (h *snapshotHub) run() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.broadcast()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *snapshotHub) stop() {
+	h.stopped.Do(func() {
+		close(h.done)
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+		for c := range h.clients {
+			close(c.send)
+			delete(h.clients, c)
+		}
+	})
+}
+
+// broadcast sends one round of messages.
+func (h *snapshotHub) broadcast() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for c := range h.clients {
+		delta, ok := c.sub.Next()
+		if !ok {
+			continue // Nothing changed
+		}
+		msg, err := json.Marshal(snapshotMessage{Type: "state_snapshot", StateDelta: delta})
+		if err != nil {
+			logger.Log.Error().Err(err).Msg("Error encoding state delta for WebSocket client")
+			c.sub.Resync()
+			continue
+		}
+		select {
+		case c.send <- msg:
+		default:
+			c.sub.Resync() // Slow client: it gets the full state when it catches up
+		}
+	}
+}
+
+// serveClient is the WebSocket handler: it registers the client and writes its messages
+// until the connection or the hub closes.
+func (h *snapshotHub) serveClient(ws *websocket.Conn) {
+	c := &wsClient{send: make(chan []byte, clientQueueLength), sub: h.stateMgr.NewDeltaSubscriber(snapshotResyncInterval)}
+	h.mutex.Lock()
+	select {
+	case <-h.done:
+		h.mutex.Unlock()
+		ws.Close()
+		return
+	default:
+	}
+	h.clients[c] = true
+	h.mutex.Unlock()
+	logger.Log.Info().Str("remote", ws.Request().RemoteAddr).Msg("WebSocket client connected.")
+
+	// Clients only listen; reading notices when they go away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discard string
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	defer func() {
+		h.mutex.Lock()
+		delete(h.clients, c)
+		h.mutex.Unlock()
+		ws.Close()
+		logger.Log.Info().Str("remote", ws.Request().RemoteAddr).Msg("WebSocket client disconnected.")
+	}()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			if !ok {
+				return
+			}
+			if err := websocket.Message.Send(ws, string(msg)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
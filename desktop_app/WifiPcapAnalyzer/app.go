@@ -2,12 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"WifiPcapAnalyzer/api_server"
 	"WifiPcapAnalyzer/config"
 	"WifiPcapAnalyzer/frame_parser"
 	"WifiPcapAnalyzer/grpc_client"
@@ -15,8 +19,6 @@ import (
 	router_agent_pb "WifiPcapAnalyzer/router_agent_pb"
 	"WifiPcapAnalyzer/state_manager"
 
-	"github.com/google/gopacket"        // For NewPacketSource
-	"github.com/google/gopacket/pcapgo" // For NewReader to handle io.Reader stream
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
@@ -33,8 +35,20 @@ type App struct {
 	captureStreamMutex  sync.Mutex
 	isCaptureActive     atomic.Bool
 	isConnected         atomic.Bool
+	agentAddress        string                          // Address of the connected capture agent
+	captureAnnotations  frame_parser.CaptureAnnotations // Agent, interface and channel of the current capture
+	exportMutex         sync.Mutex
+	exporter            *frame_parser.PcapngExporter // Non-nil while a pcapng export is running
+	exportFile          *os.File
+	replayMutex         sync.Mutex
+	replayer            *frame_parser.Replayer         // Non-nil while a capture file is being replayed
+	apiServer           *api_server.Server             // HTTP/WebSocket API on WebSocketAddress
+	stateSubscriber     *state_manager.DeltaSubscriber // Follows the state for the "state_delta" events
 }
 
+// stateResyncInterval is how often the frontend gets the full state rather than a delta
+const stateResyncInterval = 30 * time.Second
+
 // NewApp creates a new App application struct
 func NewApp() *App {
 	return &App{}
@@ -49,7 +63,7 @@ func (a *App) startup(ctx context.Context) {
 	// Configuration is already loaded in main.go before logger initialization
 	// We can access it via config.GlobalConfig or pass it to NewApp if needed
 	a.appConfig = config.GlobalConfig
-	logger.Log.Info().Interface("config", a.appConfig).Msg("Configuration loaded")
+	logger.Log.Info().Interface("config", a.appConfig.Redacted()).Msg("Configuration loaded")
 
 	// Initialize State Manager with metrics calculation parameters
 	metricsInterval := 1 * time.Second // Calculate metrics every second
@@ -66,30 +80,18 @@ func (a *App) startup(ctx context.Context) {
 			// Log before calling StateManager updates
 			// Note: ProcessParsedFrame handles both BSS and STA updates internally.
 			a.stateMgr.ProcessParsedFrame(frame)
+			a.exportFrame(frame)
 			// Snapshot broadcasting will be handled by a ticker using Wails events
 		}
 	}
 
 	// Initialize PCAP Stream Handler
 	a.pcapStreamHandler = func(pcapStream io.Reader) {
-		logger.Log.Info().Msg("Wails pcapStreamHandler invoked, attempting to process with gopacket/pcapgo.")
-
-		// Use pcapgo.NewReader to handle the io.Reader stream.
-		// pcapgo.Reader implements gopacket.PacketDataSource.
-		r, pcapErr := pcapgo.NewReader(pcapStream)
-		if pcapErr != nil {
-			logger.Log.Error().Err(pcapErr).Msg("Error creating pcapgo.Reader from stream")
-			runtime.EventsEmit(a.ctx, "error", fmt.Sprintf("Error creating pcapgo.Reader: %v", pcapErr))
-			return
-		}
+		logger.Log.Info().Msg("Wails pcapStreamHandler invoked, processing pcap/pcapng stream.")
 
-		// Create a PacketSource from the pcapgo.Reader.
-		// The LinkType comes from the pcapgo.Reader itself after parsing the header.
-		packetSource := gopacket.NewPacketSource(r, r.LinkType())
-
-		// Call the modified ProcessPcapStream which now expects a *gopacket.PacketSource
-		// The second argument (formerly tsharkPath) is now unused by ProcessPcapStream.
-		processErr := frame_parser.ProcessPcapStream(packetSource, "", a.packetInfoHandler)
+		// ProcessCaptureStream detects pcap vs. pcapng from the stream's magic number;
+		// pcapng streams keep per-interface link types and packet comments.
+		processErr := frame_parser.ProcessCaptureStream(pcapStream, a.packetInfoHandler)
 		if processErr != nil {
 			logger.Log.Error().Err(processErr).Msg("Error processing pcap stream with gopacket")
 			runtime.EventsEmit(a.ctx, "error", fmt.Sprintf("Error processing pcap stream: %v", processErr))
@@ -100,7 +102,8 @@ func (a *App) startup(ctx context.Context) {
 	// 设置连接状态为未连接
 	a.isConnected.Store(false)
 
-	// Goroutine to periodically send state snapshot to WebSocket clients via Wails events
+	// Goroutine to periodically send state changes to the frontend via Wails events
+	a.stateSubscriber = a.stateMgr.NewDeltaSubscriber(stateResyncInterval)
 	snapshotTicker := time.NewTicker(500 * time.Millisecond) // Send updates every 500 milliseconds
 	go func() {
 		defer snapshotTicker.Stop()
@@ -108,8 +111,9 @@ func (a *App) startup(ctx context.Context) {
 			select {
 			case <-snapshotTicker.C:
 				if a.isCaptureActive.Load() {
-					snapshot := a.stateMgr.GetSnapshot()
-					runtime.EventsEmit(a.ctx, "state_snapshot", snapshot)
+					if delta, ok := a.stateSubscriber.Next(); ok {
+						runtime.EventsEmit(a.ctx, "state_delta", delta)
+					}
 				}
 			case <-a.ctx.Done(): // App is shutting down
 				logger.Log.Info().Msg("Snapshot ticker stopping due to app context done.")
@@ -154,6 +158,17 @@ func (a *App) startup(ctx context.Context) {
 	}()
 	logger.Log.Info().Msg("Metrics calculation goroutine started.")
 
+	// HTTP/WebSocket API for browsers and dashboards
+	if a.appConfig.WebSocketAddress != "" {
+		a.apiServer = api_server.NewServer(a.appConfig.WebSocketAddress, *a.appConfig.API, *a.appConfig.Metrics, a.stateMgr, a)
+		go func() {
+			if err := a.apiServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Log.Error().Err(err).Str("address", a.appConfig.WebSocketAddress).Msg("API server failed")
+				runtime.EventsEmit(a.ctx, "error", fmt.Sprintf("API server on %s failed: %v", a.appConfig.WebSocketAddress, err))
+			}
+		}()
+	}
+
 	logger.Log.Info().Msg("Wails App startup complete.")
 }
 
@@ -179,6 +194,7 @@ func (a *App) ConnectToAgent(serverAddr string) error {
 	}
 
 	// 更新连接状态
+	a.agentAddress = serverAddr
 	a.isConnected.Store(true)
 	logger.Log.Info().Str("address", serverAddr).Msg("gRPC client connected successfully.")
 	runtime.EventsEmit(a.ctx, "connection_status", "connected")
@@ -225,6 +241,17 @@ func (a *App) shutdown(ctx context.Context) {
 		logger.Log.Info().Msg("Capture stream cancelled.")
 	}
 	a.captureStreamMutex.Unlock()
+	if err := a.StopPcapngExport(); err != nil {
+		logger.Log.Error().Err(err).Msg("Error closing pcapng export on shutdown.")
+	}
+	a.closeReplay()
+	if a.apiServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := a.apiServer.Shutdown(shutdownCtx); err != nil {
+			logger.Log.Error().Err(err).Msg("Error shutting down API server.")
+		}
+		cancel()
+	}
 	logger.Log.Info().Msg("Wails App shutdown complete.")
 }
 
@@ -262,11 +289,22 @@ func (a *App) StartCapture(interfaceName string, channel int32, bandwidth string
 		return fmt.Errorf("failed to send START_CAPTURE command: %w", err)
 	}
 	logger.Log.Info().Msg("Successfully sent START_CAPTURE gRPC command.")
+	a.exportMutex.Lock()
+	a.captureAnnotations = frame_parser.CaptureAnnotations{
+		Agent:     a.agentAddress,
+		Interface: interfaceName,
+		Channel:   int(channel),
+		Bandwidth: bandwidth,
+	}
+	a.exportMutex.Unlock()
+
+	a.closeReplay()
 
 	// Clear existing state before starting a new capture session
 	if a.stateMgr != nil {
 		logger.Log.Info().Msg("Clearing previous BSS/STA state before starting new capture.")
 		a.stateMgr.ClearState()
+		a.stateMgr.SetClock(state_manager.WallClock{})
 	}
 
 	a.captureStreamMutex.Lock()
@@ -357,11 +395,30 @@ func (a *App) GetCurrentSnapshot() state_manager.Snapshot {
 	return a.stateMgr.GetSnapshot()
 }
 
+// QueryState returns the page of BSSs or STAs matching query, for views that cannot hold the
+// whole snapshot (thousands of STAs).
+// Exposed to the frontend.
+func (a *App) QueryState(query state_manager.Query) (state_manager.QueryResult, error) {
+	if a.stateMgr == nil {
+		return state_manager.QueryResult{}, fmt.Errorf("state manager not initialized")
+	}
+	return a.stateMgr.Query(query)
+}
+
+// ResyncState makes the next "state_delta" event carry the full state, for a frontend that
+// (re)loaded and has none.
+// Exposed to the frontend.
+func (a *App) ResyncState() {
+	if a.stateSubscriber != nil {
+		a.stateSubscriber.Resync()
+	}
+}
+
 func (a *App) SelectPcapFileAndProcess() (string, error) {
 	filePath, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
 		Title: "Select Pcap File",
 		Filters: []runtime.FileFilter{
-			{DisplayName: "Pcap Files (*.pcap, *.cap)", Pattern: "*.pcap;*.cap"},
+			{DisplayName: "Capture Files (*.pcap, *.pcapng, *.cap)", Pattern: "*.pcap;*.pcapng;*.cap"},
 		},
 	})
 	if err != nil {
@@ -374,11 +431,23 @@ func (a *App) SelectPcapFileAndProcess() (string, error) {
 	}
 
 	logger.Log.Info().Str("filePath", filePath).Msg("Pcap file selected for processing.")
+	return a.ProcessPcapFile(filePath)
+}
+
+// ProcessPcapFile processes a capture file as fast as possible, in the background.
+// Exposed to the frontend and the API server.
+func (a *App) ProcessPcapFile(filePath string) (string, error) {
+	if _, err := os.Stat(filePath); err != nil {
+		return "", fmt.Errorf("pcap file: %w", err)
+	}
+	a.closeReplay()
 
 	// Clear existing state before processing a new file
 	if a.stateMgr != nil {
 		logger.Log.Info().Msg("Clearing previous BSS/STA state before processing new file.")
 		a.stateMgr.ClearState()
+		// Time in the file is the capture time of its frames, however fast it is read
+		a.stateMgr.SetClock(state_manager.NewVirtualClock())
 	}
 	a.isCaptureActive.Store(true) // Treat file processing like an active capture for UI
 	runtime.EventsEmit(a.ctx, "capture_status", "processing_file")
@@ -401,3 +470,237 @@ func (a *App) SelectPcapFileAndProcess() (string, error) {
 
 	return fmt.Sprintf("Processing pcap file: %s", filePath), nil
 }
+
+// SelectPcapFileAndReplay asks for a capture file and replays it in capture time, with
+// playback controls, instead of processing it as fast as possible.
+// Exposed to the frontend.
+func (a *App) SelectPcapFileAndReplay() (string, error) {
+	filePath, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Select Pcap File to Replay",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Capture Files (*.pcap, *.pcapng, *.cap)", Pattern: "*.pcap;*.pcapng;*.cap"},
+		},
+	})
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("Error selecting pcap file for replay")
+		return "", fmt.Errorf("file selection error: %w", err)
+	}
+	if filePath == "" {
+		logger.Log.Info().Msg("No pcap file selected for replay.")
+		return "", nil // No file selected is not an error
+	}
+	if err := a.StartReplay(filePath); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Replaying pcap file: %s", filePath), nil
+}
+
+// StartReplay loads a capture file and starts playing it at 1x. Progress is emitted as
+// "replay_progress" events, and state snapshots keep being emitted at replay time.
+// Exposed to the frontend.
+func (a *App) StartReplay(filePath string) error {
+	a.closeReplay()
+
+	// Time during the replay is the capture time of the replayed frames
+	resetState := func() {
+		a.stateMgr.ClearState()
+		a.stateMgr.SetClock(state_manager.NewVirtualClock())
+	}
+	resetState()
+	replayer, err := frame_parser.NewReplayer(filePath, a.packetInfoHandler, resetState, func(status frame_parser.ReplayStatus) {
+		runtime.EventsEmit(a.ctx, "replay_progress", status)
+	})
+	if err != nil {
+		logger.Log.Error().Err(err).Str("filePath", filePath).Msg("Error loading pcap file for replay")
+		return fmt.Errorf("loading pcap file for replay: %w", err)
+	}
+
+	a.replayMutex.Lock()
+	a.replayer = replayer
+	a.replayMutex.Unlock()
+	a.isCaptureActive.Store(true) // Keeps the state snapshots flowing
+	runtime.EventsEmit(a.ctx, "capture_status", "replaying")
+	logger.Log.Info().Str("filePath", filePath).Msg("Started pcap file replay.")
+	replayer.Play()
+	return nil
+}
+
+// PlayReplay starts or resumes the replay; at its end, it plays again from the start.
+// Exposed to the frontend.
+func (a *App) PlayReplay() error {
+	replayer, err := a.currentReplay()
+	if err != nil {
+		return err
+	}
+	replayer.Play()
+	return nil
+}
+
+// PauseReplay pauses the replay.
+// Exposed to the frontend.
+func (a *App) PauseReplay() error {
+	replayer, err := a.currentReplay()
+	if err != nil {
+		return err
+	}
+	replayer.Pause()
+	return nil
+}
+
+// SeekReplay moves the replay to a capture time, in Unix milliseconds.
+// Exposed to the frontend.
+func (a *App) SeekReplay(positionMs int64) error {
+	replayer, err := a.currentReplay()
+	if err != nil {
+		return err
+	}
+	replayer.Seek(time.UnixMilli(positionMs))
+	return nil
+}
+
+// SetReplaySpeed sets the replay speed, from 0.5x to 100x capture time.
+// Exposed to the frontend.
+func (a *App) SetReplaySpeed(speed float64) error {
+	replayer, err := a.currentReplay()
+	if err != nil {
+		return err
+	}
+	return replayer.SetSpeed(speed)
+}
+
+// StepReplay pauses the replay and advances it by one frame.
+// Exposed to the frontend.
+func (a *App) StepReplay() error {
+	replayer, err := a.currentReplay()
+	if err != nil {
+		return err
+	}
+	replayer.Step()
+	return nil
+}
+
+// GetReplayStatus returns the state and position of the replay.
+// Exposed to the frontend.
+func (a *App) GetReplayStatus() (frame_parser.ReplayStatus, error) {
+	replayer, err := a.currentReplay()
+	if err != nil {
+		return frame_parser.ReplayStatus{}, err
+	}
+	return replayer.Status(), nil
+}
+
+// StopReplay ends the replay. The state it built stays visible until the next capture.
+// Exposed to the frontend.
+func (a *App) StopReplay() error {
+	if !a.closeReplay() {
+		return fmt.Errorf("no replay running")
+	}
+	a.isCaptureActive.Store(false)
+	runtime.EventsEmit(a.ctx, "capture_status", "replay_stopped")
+	return nil
+}
+
+func (a *App) currentReplay() (*frame_parser.Replayer, error) {
+	a.replayMutex.Lock()
+	defer a.replayMutex.Unlock()
+	if a.replayer == nil {
+		return nil, fmt.Errorf("no replay running")
+	}
+	return a.replayer, nil
+}
+
+// closeReplay stops the replay, if any, and reports whether there was one.
+func (a *App) closeReplay() bool {
+	a.replayMutex.Lock()
+	replayer := a.replayer
+	a.replayer = nil
+	a.replayMutex.Unlock()
+	if replayer == nil {
+		return false
+	}
+	replayer.Close()
+	logger.Log.Info().Msg("Pcap file replay stopped.")
+	return true
+}
+
+// SelectPcapngExportFile asks for a file and starts writing every processed frame to it as
+// pcapng, annotated with the capture agent, the capture channel and analyzer comments.
+// Exposed to the frontend.
+func (a *App) SelectPcapngExportFile() (string, error) {
+	filePath, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Annotated Capture",
+		DefaultFilename: fmt.Sprintf("capture-%s.pcapng", time.Now().Format("20060102-150405")),
+		Filters: []runtime.FileFilter{
+			{DisplayName: "pcapng Files (*.pcapng)", Pattern: "*.pcapng"},
+		},
+	})
+	if err != nil {
+		logger.Log.Error().Err(err).Msg("Error selecting pcapng export file")
+		return "", fmt.Errorf("file selection error: %w", err)
+	}
+	if filePath == "" {
+		logger.Log.Info().Msg("No pcapng export file selected.")
+		return "", nil // No file selected is not an error
+	}
+	if err := a.StartPcapngExport(filePath); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Exporting capture to: %s", filePath), nil
+}
+
+// StartPcapngExport starts writing processed frames to filePath, replacing any running export.
+func (a *App) StartPcapngExport(filePath string) error {
+	if err := a.StopPcapngExport(); err != nil {
+		logger.Log.Warn().Err(err).Msg("Error closing previous pcapng export.")
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	a.exportMutex.Lock()
+	defer a.exportMutex.Unlock()
+	exporter, err := frame_parser.NewPcapngExporter(f, a.captureAnnotations)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to start pcapng export: %w", err)
+	}
+	a.exporter = exporter
+	a.exportFile = f
+	logger.Log.Info().Str("filePath", filePath).Msg("pcapng export started.")
+	runtime.EventsEmit(a.ctx, "export_status", "started")
+	return nil
+}
+
+// StopPcapngExport flushes and closes the running pcapng export, if any.
+// Exposed to the frontend.
+func (a *App) StopPcapngExport() error {
+	a.exportMutex.Lock()
+	defer a.exportMutex.Unlock()
+	if a.exporter == nil {
+		return nil
+	}
+	flushErr := a.exporter.Flush()
+	closeErr := a.exportFile.Close()
+	logger.Log.Info().Int("frames", a.exporter.Frames()).Str("filePath", a.exportFile.Name()).Msg("pcapng export stopped.")
+	a.exporter = nil
+	a.exportFile = nil
+	runtime.EventsEmit(a.ctx, "export_status", "stopped")
+	if flushErr != nil {
+		return fmt.Errorf("failed to flush pcapng export: %w", flushErr)
+	}
+	return closeErr
+}
+
+// exportFrame writes frame to the running pcapng export, if any. The lock is held while
+// writing so that StopPcapngExport cannot flush and close the file under a frame.
+func (a *App) exportFrame(frame *frame_parser.ParsedFrameInfo) {
+	a.exportMutex.Lock()
+	defer a.exportMutex.Unlock()
+	if a.exporter == nil {
+		return
+	}
+	if err := a.exporter.Export(frame); err != nil {
+		logger.Log.Error().Err(err).Msg("Error writing frame to pcapng export")
+	}
+}
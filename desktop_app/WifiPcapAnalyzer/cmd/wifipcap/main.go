@@ -0,0 +1,235 @@
+// Command wifipcap analyses a capture file or a live capture agent stream without the GUI,
+// and writes the resulting BSS/STA snapshot as JSON, CSV or a text table.
+//
+//	wifipcap -file capture.pcapng -format json -out result.json
+//	wifipcap -interface wlan0 -channel 36 -bandwidth 80MHz -duration 5m -interval 30s
+//
+// With -interval, snapshots are also written periodically: every interval of capture time
+// for a file, every interval of wall time for a live capture.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log" // Silenced unless -v: the state manager logs every frame through it
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"WifiPcapAnalyzer/config"
+	"WifiPcapAnalyzer/frame_parser"
+	"WifiPcapAnalyzer/grpc_client"
+	"WifiPcapAnalyzer/logger"
+	router_agent_pb "WifiPcapAnalyzer/router_agent_pb"
+	"WifiPcapAnalyzer/state_manager"
+)
+
+// Same metrics parameters as the GUI
+const (
+	metricsInterval = 1 * time.Second
+	historyPoints   = 60
+)
+
+type options struct {
+	file       string
+	agent      string
+	iface      string
+	channel    int
+	bandwidth  string
+	filter     string
+	duration   time.Duration
+	format     string
+	out        string
+	interval   time.Duration
+	configPath string
+	logLevel   string
+	verbose    bool
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.file, "file", "", "pcap or pcapng file to analyse")
+	flag.StringVar(&opts.agent, "agent", "", "capture agent address for a live capture (default: grpc_server_address from the config)")
+	flag.StringVar(&opts.iface, "interface", "", "agent interface to capture on; selects a live capture")
+	flag.IntVar(&opts.channel, "channel", 0, "channel to capture on")
+	flag.StringVar(&opts.bandwidth, "bandwidth", "", "channel bandwidth, e.g. 20MHz or 80MHz")
+	flag.StringVar(&opts.filter, "filter", "", "BPF filter for the agent")
+	flag.DurationVar(&opts.duration, "duration", 0, "stop a live capture after this long (default: on interrupt)")
+	flag.StringVar(&opts.format, "format", formatTable, "output format: table, json or csv")
+	flag.StringVar(&opts.out, "out", "", "output file (default: standard output)")
+	flag.DurationVar(&opts.interval, "interval", 0, "also write a snapshot every interval (default: final snapshot only)")
+	flag.StringVar(&opts.configPath, "config", "", "config file, for decryption keys and parser settings")
+	flag.StringVar(&opts.logLevel, "log-level", "warn", "log level on standard error")
+	flag.BoolVar(&opts.verbose, "v", false, "also print the state manager's per-frame debug log")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -file <capture> | -interface <name> [options]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintf(os.Stderr, "wifipcap: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) error {
+	if (opts.file == "") == (opts.iface == "") {
+		flag.Usage()
+		return fmt.Errorf("exactly one of -file and -interface is required")
+	}
+
+	config.LoadConfig(opts.configPath) // Defaults when no path is given
+	console := true
+	logger.InitLogger(&config.LoggingConfig{Level: opts.logLevel, Console: &console})
+	if !opts.verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	out := io.Writer(os.Stdout)
+	if opts.out != "" {
+		f, err := os.Create(opts.out)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	writer, err := newSnapshotWriter(out, opts.format)
+	if err != nil {
+		return err
+	}
+
+	stateMgr := state_manager.NewStateManager(metricsInterval, historyPoints)
+	if opts.file != "" {
+		return analyseFile(opts, stateMgr, writer)
+	}
+	return analyseAgent(opts, stateMgr, writer)
+}
+
+// analyseFile processes a capture file as fast as possible, on a virtual clock.
+func analyseFile(opts options, stateMgr *state_manager.StateManager, writer *snapshotWriter) error {
+	clock := state_manager.NewVirtualClock()
+	stateMgr.SetClock(clock)
+
+	f, err := os.Open(opts.file)
+	if err != nil {
+		return fmt.Errorf("opening capture file: %w", err)
+	}
+	defer f.Close()
+
+	var nextSnapshot time.Time
+	var writeErr error
+	frames := 0
+	handler := func(frame *frame_parser.ParsedFrameInfo) {
+		frames++
+		// Periodic snapshots cover the frames captured before each interval boundary
+		if opts.interval > 0 && !frame.Timestamp.IsZero() && writeErr == nil {
+			if nextSnapshot.IsZero() {
+				nextSnapshot = frame.Timestamp.Add(opts.interval)
+			}
+			if !frame.Timestamp.Before(nextSnapshot) {
+				writeErr = writer.Write(nextSnapshot, false, stateMgr.GetSnapshot())
+				for !frame.Timestamp.Before(nextSnapshot) {
+					nextSnapshot = nextSnapshot.Add(opts.interval)
+				}
+			}
+		}
+		stateMgr.ProcessParsedFrame(frame)
+	}
+
+	// Read with the pure Go pcap/pcapng readers, so no libpcap is needed on build servers
+	processErr := frame_parser.ProcessCaptureStream(f, handler)
+	if writeErr != nil {
+		return fmt.Errorf("writing snapshot: %w", writeErr)
+	}
+	if processErr != nil {
+		if frames == 0 {
+			return fmt.Errorf("processing %s: %w", opts.file, processErr)
+		}
+		// Frames that failed to parse are skipped; the snapshot of the rest is still useful
+		logger.Log.Warn().Err(processErr).Str("file", opts.file).Msg("Capture file processed with errors")
+	}
+	if err := writer.Write(clock.Now(), true, stateMgr.GetSnapshot()); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// analyseAgent starts a capture on the agent and processes its stream until -duration has
+// passed or the command is interrupted.
+func analyseAgent(opts options, stateMgr *state_manager.StateManager, writer *snapshotWriter) error {
+	agent := opts.agent
+	if agent == "" {
+		agent = config.GlobalConfig.GRPCServerAddress
+	}
+	client, err := grpc_client.Connect(agent)
+	if err != nil {
+		return fmt.Errorf("connecting to agent %s: %w", agent, err)
+	}
+	defer client.Close()
+
+	req := &router_agent_pb.ControlRequest{
+		CommandType:   router_agent_pb.ControlCommandType_START_CAPTURE,
+		InterfaceName: opts.iface,
+		Channel:       int32(opts.channel),
+		Bandwidth:     opts.bandwidth,
+		BpfFilter:     opts.filter,
+	}
+	if _, err := client.SendControlCommand(context.Background(), req); err != nil {
+		return fmt.Errorf("starting capture: %w", err)
+	}
+	defer func() {
+		stop := &router_agent_pb.ControlRequest{CommandType: router_agent_pb.ControlCommandType_STOP_CAPTURE, InterfaceName: opts.iface}
+		if _, err := client.SendControlCommand(context.Background(), stop); err != nil {
+			logger.Log.Error().Err(err).Msg("Error sending STOP_CAPTURE command")
+		}
+	}()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if opts.duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	processed := make(chan error, 1)
+	err = client.StreamPackets(ctx, req, func(stream io.Reader) {
+		processed <- frame_parser.ProcessCaptureStream(stream, stateMgr.ProcessParsedFrame)
+	})
+	if err != nil {
+		return fmt.Errorf("starting packet stream: %w", err)
+	}
+	logger.Log.Info().Str("agent", agent).Str("interface", opts.iface).Msg("Live capture started")
+
+	var ticks <-chan time.Time
+	if opts.interval > 0 {
+		ticker := time.NewTicker(opts.interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	metricsTicker := time.NewTicker(metricsInterval)
+	defer metricsTicker.Stop()
+	for {
+		select {
+		case <-metricsTicker.C:
+			stateMgr.PeriodicallyCalculateMetrics()
+		case now := <-ticks:
+			if err := writer.Write(now, false, stateMgr.GetSnapshot()); err != nil {
+				return fmt.Errorf("writing snapshot: %w", err)
+			}
+		case err := <-processed:
+			// The stream ends when ctx is done, or when the agent closes it
+			if err != nil && ctx.Err() == nil {
+				logger.Log.Warn().Err(err).Msg("Capture stream processed with errors")
+			}
+			if err := writer.Write(time.Now(), true, stateMgr.GetSnapshot()); err != nil {
+				return fmt.Errorf("writing snapshot: %w", err)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"WifiPcapAnalyzer/state_manager"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// csvHeader is the header of the CSV output: one row per BSS and per STA of every snapshot.
+// Columns that don't apply to a row's type are left empty.
+var csvHeader = []string{
+	"time", "final", "type", "mac", "bssid", "ssid", "channel", "security", "signal_dbm",
+	"channel_utilization", "throughput_bps", "uplink_bps", "downlink_bps",
+	"tx_bytes", "rx_bytes", "tx_packets", "rx_packets", "last_seen",
+}
+
+// snapshotRecord is one snapshot in the JSON output, which has one record per line.
+type snapshotRecord struct {
+	Time  time.Time `json:"time"`
+	Final bool      `json:"final"`
+	state_manager.Snapshot
+}
+
+// snapshotWriter writes snapshots in one of the output formats. It is safe for concurrent use.
+type snapshotWriter struct {
+	mutex         sync.Mutex
+	w             io.Writer
+	format        string
+	csv           *csv.Writer
+	headerWritten bool // CSV header, written before the first snapshot
+}
+
+func newSnapshotWriter(w io.Writer, format string) (*snapshotWriter, error) {
+	switch format {
+	case formatTable, formatJSON, formatCSV:
+		return &snapshotWriter{w: w, format: format, csv: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (want %s, %s or %s)", format, formatTable, formatJSON, formatCSV)
+}
+
+// Write writes the snapshot taken at the given time. final marks the snapshot at the end of
+// the analysis, after any periodic ones.
+func (sw *snapshotWriter) Write(at time.Time, final bool, snapshot state_manager.Snapshot) error {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+
+	sort.Slice(snapshot.BSSs, func(i, j int) bool { return snapshot.BSSs[i].BSSID < snapshot.BSSs[j].BSSID })
+	sort.Slice(snapshot.STAs, func(i, j int) bool { return snapshot.STAs[i].MACAddress < snapshot.STAs[j].MACAddress })
+	switch sw.format {
+	case formatJSON:
+		return json.NewEncoder(sw.w).Encode(snapshotRecord{Time: at.UTC(), Final: final, Snapshot: snapshot})
+	case formatCSV:
+		return sw.writeCSV(at, final, snapshot)
+	}
+	return sw.writeTable(at, final, snapshot)
+}
+
+func (sw *snapshotWriter) writeCSV(at time.Time, final bool, snapshot state_manager.Snapshot) error {
+	if !sw.headerWritten {
+		sw.csv.Write(csvHeader)
+		sw.headerWritten = true
+	}
+	timeStr, finalStr := at.UTC().Format(time.RFC3339Nano), strconv.FormatBool(final)
+
+	ssids := make(map[string]string, len(snapshot.BSSs))
+	for _, bss := range snapshot.BSSs {
+		ssids[bss.BSSID] = bss.SSID
+		sw.csv.Write([]string{
+			timeStr, finalStr, "bss", bss.BSSID, bss.BSSID, bss.SSID, strconv.Itoa(bss.Channel), bss.Security,
+			strconv.Itoa(bss.SignalStrength), strconv.FormatFloat(bss.ChannelUtilization, 'f', 2, 64),
+			strconv.FormatInt(bss.Throughput, 10), "", "", "", "", "", "", formatMillis(bss.LastSeen),
+		})
+	}
+	for _, sta := range snapshot.STAs {
+		sw.csv.Write([]string{
+			timeStr, finalStr, "sta", sta.MACAddress, sta.AssociatedBSSID, ssids[sta.AssociatedBSSID], "", "",
+			strconv.Itoa(sta.SignalStrength), strconv.FormatFloat(sta.ChannelUtilization, 'f', 2, 64),
+			strconv.FormatInt(sta.UplinkThroughput+sta.DownlinkThroughput, 10),
+			strconv.FormatInt(sta.UplinkThroughput, 10), strconv.FormatInt(sta.DownlinkThroughput, 10),
+			strconv.FormatInt(sta.TxBytes, 10), strconv.FormatInt(sta.RxBytes, 10),
+			strconv.FormatInt(sta.TxPackets, 10), strconv.FormatInt(sta.RxPackets, 10), formatMillis(sta.LastSeen),
+		})
+	}
+	sw.csv.Flush()
+	return sw.csv.Error()
+}
+
+func (sw *snapshotWriter) writeTable(at time.Time, final bool, snapshot state_manager.Snapshot) error {
+	title := "Snapshot"
+	if final {
+		title = "Final snapshot"
+	}
+	tw := tabwriter.NewWriter(sw.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "== %s at %s: %d BSSs, %d STAs ==\n\n", title, at.UTC().Format(time.RFC3339), len(snapshot.BSSs), len(snapshot.STAs))
+
+	fmt.Fprintln(tw, "BSSID\tSSID\tCH\tSECURITY\tSIGNAL\tUTIL\tTHROUGHPUT\tSTAS\tLAST SEEN")
+	for _, bss := range snapshot.BSSs {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d dBm\t%.1f%%\t%s\t%d\t%s\n", bss.BSSID, bss.SSID, bss.Channel, bss.Security,
+			bss.SignalStrength, bss.ChannelUtilization, formatBitrate(bss.Throughput), len(bss.AssociatedSTAs), formatMillis(bss.LastSeen))
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "STA\tBSSID\tHOSTNAME\tSIGNAL\tUTIL\tUPLINK\tDOWNLINK\tTX PKTS\tRX PKTS\tLAST SEEN")
+	for _, sta := range snapshot.STAs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d dBm\t%.1f%%\t%s\t%s\t%d\t%d\t%s\n", sta.MACAddress, sta.AssociatedBSSID, sta.Hostname,
+			sta.SignalStrength, sta.ChannelUtilization, formatBitrate(sta.UplinkThroughput), formatBitrate(sta.DownlinkThroughput),
+			sta.TxPackets, sta.RxPackets, formatMillis(sta.LastSeen))
+	}
+	fmt.Fprintln(tw)
+
+	if len(snapshot.ChannelFrames) > 0 {
+		fmt.Fprintln(tw, "BAND\tCH\tFRAMES\tBAD FCS\tPHY ERRORS")
+		for _, ch := range snapshot.ChannelFrames {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.2f%%\n", ch.Band, ch.Channel, ch.Frames, ch.BadFCSFrames, ch.PHYErrorRate*100)
+		}
+		fmt.Fprintln(tw)
+	}
+	if len(snapshot.DisconnectReasons) > 0 {
+		fmt.Fprintln(tw, "REASON\tCOUNT\tBY AP\tBY STA")
+		for _, r := range snapshot.DisconnectReasons {
+			fmt.Fprintf(tw, "%d %s\t%d\t%d\t%d\n", r.ReasonCode, r.Reason, r.Count, r.APInitiated, r.STAInitiated)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// formatMillis formats a Unix millisecond timestamp, or returns "" for zero.
+func formatMillis(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
+
+// formatBitrate formats bits per second with a metric prefix.
+func formatBitrate(bps int64) string {
+	switch {
+	case bps >= 1_000_000_000:
+		return fmt.Sprintf("%.1f Gbps", float64(bps)/1e9)
+	case bps >= 1_000_000:
+		return fmt.Sprintf("%.1f Mbps", float64(bps)/1e6)
+	case bps >= 1_000:
+		return fmt.Sprintf("%.1f kbps", float64(bps)/1e3)
+	}
+	return fmt.Sprintf("%d bps", bps)
+}
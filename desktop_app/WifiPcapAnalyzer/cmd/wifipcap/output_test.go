@@ -0,0 +1,86 @@
+package main
+
+import (
+	"WifiPcapAnalyzer/state_manager"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSnapshot() state_manager.Snapshot {
+	sta := &state_manager.STAInfo{MACAddress: "02:00:00:00:00:01", AssociatedBSSID: "02:00:00:00:00:aa", SignalStrength: -51,
+		UplinkThroughput: 1_500_000, DownlinkThroughput: 250_000, TxPackets: 12, RxPackets: 3}
+	return state_manager.Snapshot{
+		BSSs: []*state_manager.BSSInfo{
+			{BSSID: "02:00:00:00:00:bb", SSID: "guest", Channel: 1, Security: "Open", SignalStrength: -70},
+			{BSSID: "02:00:00:00:00:aa", SSID: "lab", Channel: 36, Security: "WPA2-Personal", SignalStrength: -45,
+				ChannelUtilization: 12.5, Throughput: 2_000_000, AssociatedSTAs: map[string]*state_manager.STAInfo{sta.MACAddress: sta}},
+		},
+		STAs:          []*state_manager.STAInfo{sta},
+		ChannelFrames: []state_manager.ChannelFrameStats{{Band: "5GHz", Channel: 36, Frames: 1000, BadFCSFrames: 10, PHYErrorRate: 0.01}},
+	}
+}
+
+func TestSnapshotWriter_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	sw, err := newSnapshotWriter(&buf, formatCSV)
+	require.NoError(t, err)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, sw.Write(at, false, testSnapshot()))
+	require.NoError(t, sw.Write(at.Add(time.Minute), true, testSnapshot()))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 1+2*3, "one header, then two BSSs and one STA per snapshot")
+	assert.Equal(t, csvHeader, rows[0])
+	assert.Equal(t, []string{"2024-05-01T12:00:00Z", "false", "bss", "02:00:00:00:00:aa", "02:00:00:00:00:aa", "lab", "36",
+		"WPA2-Personal", "-45", "12.50", "2000000", "", "", "", "", "", "", ""}, rows[1], "BSSs sorted by BSSID")
+	assert.Equal(t, []string{"2024-05-01T12:00:00Z", "false", "sta", "02:00:00:00:00:01", "02:00:00:00:00:aa", "lab", "", "",
+		"-51", "0.00", "1750000", "1500000", "250000", "0", "0", "12", "3", ""}, rows[3])
+	assert.Equal(t, "true", rows[4][1])
+}
+
+func TestSnapshotWriter_JSONAndTable(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	sw, err := newSnapshotWriter(&buf, formatJSON)
+	require.NoError(t, err)
+	require.NoError(t, sw.Write(at, false, testSnapshot()))
+	require.NoError(t, sw.Write(at, true, testSnapshot()))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2, "one JSON record per line")
+	var record struct {
+		Time  time.Time                         `json:"time"`
+		Final bool                              `json:"final"`
+		BSSs  []state_manager.BSSInfo           `json:"bsss"`
+		STAs  []state_manager.STAInfo           `json:"stas"`
+		Chans []state_manager.ChannelFrameStats `json:"channel_frames"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.True(t, record.Final)
+	assert.Equal(t, at, record.Time)
+	require.Len(t, record.BSSs, 2)
+	assert.Equal(t, "lab", record.BSSs[0].SSID)
+	assert.Len(t, record.STAs, 1)
+	assert.Len(t, record.Chans, 1)
+
+	buf.Reset()
+	sw, err = newSnapshotWriter(&buf, formatTable)
+	require.NoError(t, err)
+	require.NoError(t, sw.Write(at, true, testSnapshot()))
+	table := buf.String()
+	assert.Contains(t, table, "Final snapshot at 2024-05-01T12:00:00Z: 2 BSSs, 1 STAs")
+	assert.Regexp(t, `02:00:00:00:00:aa\s+lab\s+36\s+WPA2-Personal\s+-45 dBm\s+12.5%\s+2.0 Mbps\s+1`, table)
+	assert.Regexp(t, `02:00:00:00:00:01\s+02:00:00:00:00:aa\s+-51 dBm\s+0.0%\s+1.5 Mbps\s+250.0 kbps\s+12\s+3`, table)
+	assert.Regexp(t, `5GHz\s+36\s+1000\s+10\s+1.00%`, table)
+
+	_, err = newSnapshotWriter(&buf, "xml")
+	assert.Error(t, err)
+}
@@ -9,12 +9,17 @@ import (
 
 // AppConfig holds the application configuration.
 type AppConfig struct {
-	GRPCServerAddress  string         `json:"grpc_server_address"`
-	WebSocketAddress   string         `json:"websocket_address"`
-	LogFile            string         `json:"log_file"`  // Deprecated by LoggingConfig
-	LogLevel           string         `json:"log_level"` // Deprecated by LoggingConfig
-	MinBSSCreationRSSI int            `json:"min_bss_creation_rssi"`
-	Logging            *LoggingConfig `json:"logging,omitempty"`
+	GRPCServerAddress  string            `json:"grpc_server_address"`
+	WebSocketAddress   string            `json:"websocket_address"`
+	LogFile            string            `json:"log_file"`  // Deprecated by LoggingConfig
+	LogLevel           string            `json:"log_level"` // Deprecated by LoggingConfig
+	MinBSSCreationRSSI int               `json:"min_bss_creation_rssi"`
+	Logging            *LoggingConfig    `json:"logging,omitempty"`
+	Decryption         *DecryptionConfig `json:"decryption,omitempty"`
+	ParseWorkers       int               `json:"parse_workers,omitempty"` // Frame parse workers; 0 or 1 parses inline, -1 means one per CPU
+	Parser             string            `json:"parser,omitempty"`        // Frame parser: "gopacket" (default) or "fast"
+	Metrics            *MetricsConfig    `json:"metrics,omitempty"`
+	API                *APIConfig        `json:"api,omitempty"`
 }
 
 // LoggingConfig holds the logging configuration.
@@ -24,16 +29,72 @@ type LoggingConfig struct {
 	Console *bool   `json:"console,omitempty"` // Optional: enable/disable console logging
 }
 
+// MetricsConfig limits the label cardinality of the /metrics endpoint. Beyond the limits,
+// only the most recently seen BSSs and STAs get their own series.
+type MetricsConfig struct {
+	MaxBSSs int `json:"max_bsss"` // BSSs with per-BSS series
+	MaxSTAs int `json:"max_stas"` // STAs with per-STA series
+}
+
+// APIConfig secures the HTTP/WebSocket API served on WebSocketAddress. Left empty, the API
+// only serves state to non-browser clients: capture control and file loading are refused.
+type APIConfig struct {
+	Token          string   `json:"token,omitempty"`           // Bearer token required to start or stop captures and load files
+	AllowedOrigins []string `json:"allowed_origins,omitempty"` // Browser origins (e.g. "http://dashboard.lan:3000") allowed to call the API
+	CaptureDir     string   `json:"capture_dir,omitempty"`     // Directory /api/file may load captures from
+}
+
+// DecryptionConfig holds the keys used to decrypt protected (WPA2/WPA3) data frames.
+type DecryptionConfig struct {
+	Keys []DecryptionKeyConfig `json:"keys"`
+}
+
+// DecryptionKeyConfig is one network key. Either Passphrase (WPA-Personal) or PMK
+// (hex, required for WPA3-SAE and 802.1X) must be set; an empty SSID matches any network.
+type DecryptionKeyConfig struct {
+	SSID       string `json:"ssid,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+	PMK        string `json:"pmk,omitempty"`
+}
+
 // DefaultConfig provides a default configuration.
 var DefaultConfig = AppConfig{
 	GRPCServerAddress:  "192.168.6.250:50051", // Default gRPC server address
-	WebSocketAddress:   "0.0.0.0:8080",        // Default WebSocket server address
+	WebSocketAddress:   "127.0.0.1:8080",      // Default WebSocket server address, local clients only
 	MinBSSCreationRSSI: -84,                   // Default minimum RSSI for BSS creation
 	Logging: &LoggingConfig{
 		Level:   "info",
 		Console: func(b bool) *bool { return &b }(true), // Default console to true
 		File:    nil,                                    // Default no file logging
 	},
+	Metrics: &MetricsConfig{
+		MaxBSSs: 256,
+		MaxSTAs: 1024,
+	},
+}
+
+// Redacted returns a copy of the configuration fit for logging, with the API token and the
+// decryption keys masked.
+func (c AppConfig) Redacted() AppConfig {
+	mask := func(secret string) string {
+		if secret == "" {
+			return ""
+		}
+		return "****"
+	}
+	if c.API != nil {
+		api := *c.API
+		api.Token = mask(api.Token)
+		c.API = &api
+	}
+	if c.Decryption != nil {
+		keys := make([]DecryptionKeyConfig, len(c.Decryption.Keys))
+		for i, key := range c.Decryption.Keys {
+			keys[i] = DecryptionKeyConfig{SSID: key.SSID, Passphrase: mask(key.Passphrase), PMK: mask(key.PMK)}
+		}
+		c.Decryption = &DecryptionConfig{Keys: keys}
+	}
+	return c
 }
 
 // GlobalConfig holds the global application configuration.
@@ -102,6 +163,19 @@ func LoadConfig(filePath string) AppConfig {
 		}
 		// File can be nil by default, so no specific default fill needed if it's missing, unless we want to force a default file path.
 	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = DefaultConfig.Metrics
+	} else {
+		if cfg.Metrics.MaxBSSs <= 0 {
+			cfg.Metrics.MaxBSSs = DefaultConfig.Metrics.MaxBSSs
+		}
+		if cfg.Metrics.MaxSTAs <= 0 {
+			cfg.Metrics.MaxSTAs = DefaultConfig.Metrics.MaxSTAs
+		}
+	}
+	if cfg.API == nil {
+		cfg.API = &APIConfig{}
+	}
 	// Deprecate old LogFile and LogLevel if new Logging is present
 	if cfg.Logging != nil {
 		if cfg.LogFile != "" {
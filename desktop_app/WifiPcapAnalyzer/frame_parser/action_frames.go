@@ -0,0 +1,557 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/logger"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Action frame categories (IEEE 802.11-2020, Table 9-51).
+const (
+	ActionCategorySpectrumManagement    uint8 = 0
+	ActionCategoryBlockAck              uint8 = 3
+	ActionCategoryPublic                uint8 = 4
+	ActionCategoryRadioMeasurement      uint8 = 5
+	ActionCategorySAQuery               uint8 = 8
+	ActionCategoryProtectedDualOfPublic uint8 = 9
+	ActionCategoryWNM                   uint8 = 10
+)
+
+// actionCategoryErrorBit is set in the Category field when a STA returns an action frame it does not support.
+const actionCategoryErrorBit uint8 = 0x80
+
+// Action codes decoded by the built-in decoders.
+const (
+	spectrumActionChannelSwitch uint8 = 4
+
+	blockAckActionADDBARequest  uint8 = 0
+	blockAckActionADDBAResponse uint8 = 1
+	blockAckActionDELBA         uint8 = 2
+
+	publicActionExtendedChannelSwitch uint8 = 4
+	publicActionGASInitialRequest     uint8 = 10
+	publicActionGASInitialResponse    uint8 = 11
+	publicActionGASComebackRequest    uint8 = 12
+	publicActionGASComebackResponse   uint8 = 13
+
+	saQueryActionRequest  uint8 = 0
+	saQueryActionResponse uint8 = 1
+)
+
+// Element IDs found in action frame bodies.
+const (
+	ieIDChannelSwitchAnnouncement uint8 = 37
+	ieIDAdvertisementProtocol     uint8 = 108
+)
+
+// anqpInfoIDQueryList is the ANQP element listing the Info IDs requested by a GAS Initial Request.
+const anqpInfoIDQueryList uint16 = 256
+
+// ActionFrameInfo is the decoded Category/Action header of an Action frame plus the typed body
+// produced by the category decoder. Radio Measurement and WNM roaming actions are stored in
+// ParsedFrameInfo.RoamingAction.
+type ActionFrameInfo struct {
+	Category      uint8               `json:"category"`
+	CategoryName  string              `json:"category_name"`
+	Action        uint8               `json:"action"`
+	ActionName    string              `json:"action_name"`
+	Protected     bool                `json:"protected"`       // Body encrypted (robust action frame under MFP); category and action are unknown
+	ErrorReturned bool                `json:"error_returned"`  // Category error bit set (frame returned as unsupported)
+	Error         string              `json:"error,omitempty"` // Decoder error, if any
+	ChannelSwitch *ChannelSwitchInfo  `json:"channel_switch,omitempty"`
+	GAS           *GASInfo            `json:"gas,omitempty"`
+	BlockAck      *BlockAckActionInfo `json:"block_ack,omitempty"`
+	SAQuery       *SAQueryInfo        `json:"sa_query,omitempty"`
+}
+
+// ChannelSwitchInfo describes a (Extended) Channel Switch Announcement.
+type ChannelSwitchInfo struct {
+	Extended          bool  `json:"extended"`            // Extended Channel Switch Announcement (carries the operating class)
+	BlockTx           bool  `json:"block_tx"`            // Channel Switch Mode 1: no transmissions until the switch
+	NewOperatingClass uint8 `json:"new_operating_class"` // Extended only
+	NewChannel        uint8 `json:"new_channel"`
+	Count             uint8 `json:"count"` // TBTTs until the switch
+}
+
+// GASInfo describes a GAS Initial/Comeback Request or Response (Public or Protected Dual of Public).
+type GASInfo struct {
+	DialogToken           uint8    `json:"dialog_token"`
+	AdvertisementProtocol string   `json:"advertisement_protocol,omitempty"` // e.g. "ANQP"
+	StatusCode            uint16   `json:"status_code"`                      // Responses only
+	Status                string   `json:"status,omitempty"`                 // Responses only
+	ComebackDelay         uint16   `json:"comeback_delay,omitempty"`         // In TUs
+	FragmentID            uint8    `json:"fragment_id,omitempty"`            // Comeback Response only
+	MoreFragments         bool     `json:"more_fragments,omitempty"`         // Comeback Response only
+	QueryLength           uint16   `json:"query_length"`
+	ANQPInfo              []string `json:"anqp_info,omitempty"` // ANQP elements requested (Query List) or returned
+}
+
+// BlockAckActionInfo describes an ADDBA Request/Response or DELBA frame.
+type BlockAckActionInfo struct {
+	DialogToken      uint8  `json:"dialog_token,omitempty"` // ADDBA only
+	TID              uint8  `json:"tid"`
+	BufferSize       uint16 `json:"buffer_size,omitempty"` // ADDBA only
+	AMSDUSupported   bool   `json:"amsdu_supported,omitempty"`
+	ImmediatePolicy  bool   `json:"immediate_policy,omitempty"`
+	Timeout          uint16 `json:"timeout,omitempty"`           // In TUs, 0 = disabled
+	StartingSequence uint16 `json:"starting_sequence,omitempty"` // ADDBA Request only
+	StatusCode       uint16 `json:"status_code,omitempty"`       // ADDBA Response only
+	Status           string `json:"status,omitempty"`            // ADDBA Response only
+	Initiator        bool   `json:"initiator,omitempty"`         // DELBA only: sent by the originator of the agreement
+	ReasonCode       uint16 `json:"reason_code,omitempty"`       // DELBA only
+	Reason           string `json:"reason,omitempty"`            // DELBA only
+}
+
+// SAQueryInfo describes an SA Query Request or Response.
+type SAQueryInfo struct {
+	TransactionID uint16 `json:"transaction_id"`
+}
+
+// ActionDecoder decodes the body of an action frame. body starts after the Category and
+// Action fields. A decoder stores its typed result on action or info and returns an error
+// for malformed bodies; the error is recorded in ActionFrameInfo.Error.
+type ActionDecoder func(info *ParsedFrameInfo, action *ActionFrameInfo, body []byte) error
+
+type actionDecoderEntry struct {
+	actionNames map[uint8]string
+	decode      ActionDecoder
+}
+
+// actionRegistry maps action frame categories to decoders.
+type actionRegistry struct {
+	mu         sync.RWMutex
+	byCategory map[uint8]actionDecoderEntry
+}
+
+// defaultActionRegistry is used by ParsePacket. Built-in decoders register themselves in init().
+var defaultActionRegistry = &actionRegistry{byCategory: make(map[uint8]actionDecoderEntry)}
+
+// RegisterActionDecoder registers a decoder for the action frames of one category.
+// actionNames maps action codes to display names. Registering the same category again
+// replaces the previous decoder.
+func RegisterActionDecoder(category uint8, actionNames map[uint8]string, decoder ActionDecoder) {
+	defaultActionRegistry.mu.Lock()
+	defer defaultActionRegistry.mu.Unlock()
+	defaultActionRegistry.byCategory[category] = actionDecoderEntry{actionNames: actionNames, decode: decoder}
+}
+
+func (r *actionRegistry) lookup(category uint8) (actionDecoderEntry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.byCategory[category]
+	return entry, ok
+}
+
+// actionCategoryNames maps action frame categories to their names (IEEE 802.11-2020, Table 9-51).
+var actionCategoryNames = map[uint8]string{
+	0:   "Spectrum Management",
+	1:   "QoS",
+	2:   "DLS",
+	3:   "Block Ack",
+	4:   "Public",
+	5:   "Radio Measurement",
+	6:   "Fast BSS Transition",
+	7:   "HT",
+	8:   "SA Query",
+	9:   "Protected Dual of Public Action",
+	10:  "WNM",
+	11:  "Unprotected WNM",
+	12:  "TDLS",
+	13:  "Mesh",
+	14:  "Multihop",
+	15:  "Self-protected",
+	16:  "DMG",
+	18:  "Fast Session Transfer",
+	19:  "Robust AV Streaming",
+	20:  "Unprotected DMG",
+	21:  "VHT",
+	22:  "Unprotected S1G",
+	23:  "S1G",
+	24:  "Flow Control",
+	25:  "Control Response MCS Negotiation",
+	26:  "FILS",
+	27:  "CDMG",
+	28:  "CMMG",
+	29:  "GLK",
+	30:  "HE",
+	31:  "Protected HE",
+	126: "Vendor-specific Protected",
+	127: "Vendor-specific",
+}
+
+// ActionCategoryString returns the name of an action frame category, e.g. "Block Ack".
+func ActionCategoryString(category uint8) string {
+	if name, ok := actionCategoryNames[category]; ok {
+		return name
+	}
+	return fmt.Sprintf("Reserved/unknown category (%d)", category)
+}
+
+var spectrumActionNames = map[uint8]string{
+	0: "Measurement Request",
+	1: "Measurement Report",
+	2: "TPC Request",
+	3: "TPC Report",
+	4: "Channel Switch Announcement",
+}
+
+var blockAckActionNames = map[uint8]string{
+	0: "ADDBA Request",
+	1: "ADDBA Response",
+	2: "DELBA",
+}
+
+var publicActionNames = map[uint8]string{
+	0:  "20/40 BSS Coexistence Management",
+	1:  "DSE Enablement",
+	2:  "DSE Deenablement",
+	3:  "DSE Registered Location Announcement",
+	4:  "Extended Channel Switch Announcement",
+	5:  "DSE Measurement Request",
+	6:  "DSE Measurement Report",
+	7:  "Measurement Pilot",
+	8:  "DSE Power Constraint",
+	9:  "Vendor Specific",
+	10: "GAS Initial Request",
+	11: "GAS Initial Response",
+	12: "GAS Comeback Request",
+	13: "GAS Comeback Response",
+	14: "TDLS Discovery Response",
+	15: "Location Track Notification",
+	32: "Fine Timing Measurement Request",
+	33: "Fine Timing Measurement",
+}
+
+var radioMeasurementActionNames = map[uint8]string{
+	0: "Radio Measurement Request",
+	1: "Radio Measurement Report",
+	2: "Link Measurement Request",
+	3: "Link Measurement Report",
+	4: "Neighbor Report Request",
+	5: "Neighbor Report Response",
+}
+
+var saQueryActionNames = map[uint8]string{
+	0: "SA Query Request",
+	1: "SA Query Response",
+}
+
+var wnmActionNames = map[uint8]string{
+	0:  "Event Request",
+	1:  "Event Report",
+	2:  "Diagnostic Request",
+	3:  "Diagnostic Report",
+	4:  "Location Configuration Request",
+	5:  "Location Configuration Response",
+	6:  "BSS Transition Management Query",
+	7:  "BSS Transition Management Request",
+	8:  "BSS Transition Management Response",
+	9:  "FMS Request",
+	10: "FMS Response",
+	11: "Collocated Interference Request",
+	12: "Collocated Interference Report",
+	13: "TFS Request",
+	14: "TFS Response",
+	15: "TFS Notify",
+	16: "WNM Sleep Mode Request",
+	17: "WNM Sleep Mode Response",
+	18: "TIM Broadcast Request",
+	19: "TIM Broadcast Response",
+	20: "QoS Traffic Capability Update",
+	21: "Channel Usage Request",
+	22: "Channel Usage Response",
+	23: "DMS Request",
+	24: "DMS Response",
+	25: "Timing Measurement Request",
+	26: "WNM Notification Request",
+	27: "WNM Notification Response",
+}
+
+// anqpInfoNames maps ANQP Info IDs (IEEE 802.11-2020, Table 9-331).
+var anqpInfoNames = map[uint16]string{
+	256:   "Query List",
+	257:   "Capability List",
+	258:   "Venue Name",
+	259:   "Emergency Call Number",
+	260:   "Network Authentication Type",
+	261:   "Roaming Consortium",
+	262:   "IP Address Type Availability",
+	263:   "NAI Realm",
+	264:   "3GPP Cellular Network",
+	265:   "AP Geospatial Location",
+	266:   "AP Civic Location",
+	267:   "AP Location Public Identifier URI",
+	268:   "Domain Name",
+	269:   "Emergency Alert Identifier URI",
+	270:   "TDLS Capability",
+	271:   "Emergency NAI",
+	272:   "Neighbor Report",
+	277:   "Venue URL",
+	278:   "Advice of Charge",
+	279:   "Local Content",
+	280:   "Network Authentication Type with Timestamp",
+	56797: "Vendor Specific",
+}
+
+var advertisementProtocolNames = map[uint8]string{
+	0:   "ANQP",
+	1:   "MIH Information Service",
+	2:   "MIH Command and Event Services Capability Discovery",
+	3:   "EAS",
+	4:   "RLQP",
+	221: "Vendor Specific",
+}
+
+func init() {
+	RegisterActionDecoder(ActionCategorySpectrumManagement, spectrumActionNames, decodeSpectrumManagementAction)
+	RegisterActionDecoder(ActionCategoryBlockAck, blockAckActionNames, decodeBlockAckAction)
+	RegisterActionDecoder(ActionCategoryPublic, publicActionNames, decodePublicAction)
+	RegisterActionDecoder(ActionCategoryProtectedDualOfPublic, publicActionNames, decodePublicAction)
+	RegisterActionDecoder(ActionCategoryRadioMeasurement, radioMeasurementActionNames, decodeRoamingAction)
+	RegisterActionDecoder(ActionCategoryWNM, wnmActionNames, decodeRoamingAction)
+	RegisterActionDecoder(ActionCategorySAQuery, saQueryActionNames, decodeSAQueryAction)
+}
+
+// parseActionFrame decodes the Category and Action fields of an Action (No Ack) frame body and
+// dispatches the rest to the decoder registered for the category. The body of a protected
+// robust action frame is encrypted, so only the fact that it is protected is recorded.
+func parseActionFrame(info *ParsedFrameInfo, body []byte, protected bool) {
+	parseActionFrameWith(defaultActionRegistry, info, body, protected)
+}
+
+func parseActionFrameWith(registry *actionRegistry, info *ParsedFrameInfo, body []byte, protected bool) {
+	if protected {
+		info.Action = &ActionFrameInfo{Protected: true, CategoryName: "Protected"}
+		return
+	}
+	if len(body) < 1 {
+		return
+	}
+	action := &ActionFrameInfo{Category: body[0] &^ actionCategoryErrorBit}
+	action.ErrorReturned = body[0]&actionCategoryErrorBit != 0
+	action.CategoryName = ActionCategoryString(action.Category)
+	info.Action = action
+	if len(body) < 2 {
+		action.Error = "action frame truncated before Action field"
+		return
+	}
+	action.Action = body[1]
+
+	entry, ok := registry.lookup(action.Category)
+	if name, named := entry.actionNames[action.Action]; ok && named {
+		action.ActionName = name
+	} else {
+		action.ActionName = fmt.Sprintf("Action %d", action.Action)
+	}
+	if !ok || entry.decode == nil || action.ErrorReturned {
+		return
+	}
+	if err := entry.decode(info, action, body[2:]); err != nil {
+		logger.Log.Debug().Err(err).Str("category", action.CategoryName).Str("action", action.ActionName).Msg("Action frame decoder failed.")
+		action.Error = err.Error()
+	}
+}
+
+// decodeSpectrumManagementAction decodes the Channel Switch Announcement action, whose body
+// carries a Channel Switch Announcement element.
+func decodeSpectrumManagementAction(info *ParsedFrameInfo, action *ActionFrameInfo, body []byte) error {
+	if action.Action != spectrumActionChannelSwitch {
+		return nil
+	}
+	for len(body) >= 2 && len(body) >= 2+int(body[1]) {
+		id, elem := body[0], body[2:2+int(body[1])]
+		body = body[2+len(elem):]
+		if id != ieIDChannelSwitchAnnouncement {
+			continue
+		}
+		if len(elem) < 3 {
+			return errors.New("Channel Switch Announcement element too short")
+		}
+		action.ChannelSwitch = &ChannelSwitchInfo{
+			BlockTx:    elem[0] == 1,
+			NewChannel: elem[1],
+			Count:      elem[2],
+		}
+		return nil
+	}
+	return errors.New("Channel Switch Announcement element missing")
+}
+
+// decodeBlockAckAction decodes ADDBA Request/Response and DELBA frames.
+func decodeBlockAckAction(info *ParsedFrameInfo, action *ActionFrameInfo, body []byte) error {
+	ba := &BlockAckActionInfo{}
+	switch action.Action {
+	case blockAckActionADDBARequest:
+		if len(body) < 7 { // Dialog Token, BA Parameter Set, BA Timeout, BA Starting Sequence Control
+			return errors.New("ADDBA Request too short")
+		}
+		ba.DialogToken = body[0]
+		setBlockAckParameters(ba, binary.LittleEndian.Uint16(body[1:3]))
+		ba.Timeout = binary.LittleEndian.Uint16(body[3:5])
+		ba.StartingSequence = binary.LittleEndian.Uint16(body[5:7]) >> 4
+	case blockAckActionADDBAResponse:
+		if len(body) < 7 { // Dialog Token, Status Code, BA Parameter Set, BA Timeout
+			return errors.New("ADDBA Response too short")
+		}
+		ba.DialogToken = body[0]
+		ba.StatusCode = binary.LittleEndian.Uint16(body[1:3])
+		ba.Status = StatusCodeString(ba.StatusCode)
+		setBlockAckParameters(ba, binary.LittleEndian.Uint16(body[3:5]))
+		ba.Timeout = binary.LittleEndian.Uint16(body[5:7])
+	case blockAckActionDELBA:
+		if len(body) < 4 { // DELBA Parameter Set, Reason Code
+			return errors.New("DELBA too short")
+		}
+		params := binary.LittleEndian.Uint16(body[0:2])
+		ba.Initiator = params&(1<<11) != 0
+		ba.TID = uint8(params >> 12)
+		ba.ReasonCode = binary.LittleEndian.Uint16(body[2:4])
+		ba.Reason = ReasonCodeString(ba.ReasonCode)
+	default:
+		return nil
+	}
+	action.BlockAck = ba
+	return nil
+}
+
+// setBlockAckParameters decodes the Block Ack Parameter Set field of ADDBA frames.
+func setBlockAckParameters(ba *BlockAckActionInfo, params uint16) {
+	ba.AMSDUSupported = params&0x0001 != 0
+	ba.ImmediatePolicy = params&0x0002 != 0
+	ba.TID = uint8(params>>2) & 0x0F
+	ba.BufferSize = params >> 6
+}
+
+// decodePublicAction decodes the Extended Channel Switch Announcement and GAS frames of the
+// Public and Protected Dual of Public Action categories.
+func decodePublicAction(info *ParsedFrameInfo, action *ActionFrameInfo, body []byte) error {
+	switch action.Action {
+	case publicActionExtendedChannelSwitch:
+		if len(body) < 4 { // Channel Switch Mode, New Operating Class, New Channel Number, Count
+			return errors.New("Extended Channel Switch Announcement too short")
+		}
+		action.ChannelSwitch = &ChannelSwitchInfo{
+			Extended:          true,
+			BlockTx:           body[0] == 1,
+			NewOperatingClass: body[1],
+			NewChannel:        body[2],
+			Count:             body[3],
+		}
+	case publicActionGASInitialRequest, publicActionGASInitialResponse,
+		publicActionGASComebackRequest, publicActionGASComebackResponse:
+		gas, err := parseGAS(action.Action, body)
+		if err != nil {
+			return err
+		}
+		action.GAS = gas
+	}
+	return nil
+}
+
+// parseGAS decodes the fixed fields of a GAS frame and lists the ANQP elements of its query.
+func parseGAS(code uint8, body []byte) (*GASInfo, error) {
+	if len(body) < 1 {
+		return nil, errors.New("GAS frame too short")
+	}
+	gas := &GASInfo{DialogToken: body[0]}
+	rest := body[1:]
+	request := code == publicActionGASInitialRequest
+
+	switch code {
+	case publicActionGASComebackRequest:
+		return gas, nil
+	case publicActionGASInitialResponse, publicActionGASComebackResponse:
+		fixed := 4 // Status Code, GAS Comeback Delay
+		if code == publicActionGASComebackResponse {
+			fixed = 5 // Status Code, GAS Query Response Fragment ID, GAS Comeback Delay
+		}
+		if len(rest) < fixed {
+			return nil, errors.New("GAS response too short")
+		}
+		gas.StatusCode = binary.LittleEndian.Uint16(rest[0:2])
+		gas.Status = StatusCodeString(gas.StatusCode)
+		if code == publicActionGASComebackResponse {
+			gas.FragmentID = rest[2] & 0x7F
+			gas.MoreFragments = rest[2]&0x80 != 0
+		}
+		gas.ComebackDelay = binary.LittleEndian.Uint16(rest[fixed-2 : fixed])
+		rest = rest[fixed:]
+	}
+
+	// Advertisement Protocol element
+	if len(rest) < 2 || rest[0] != ieIDAdvertisementProtocol || len(rest) < 2+int(rest[1]) {
+		if len(rest) == 0 {
+			return gas, nil // E.g. a failed response without a query
+		}
+		return nil, errors.New("GAS frame without a valid Advertisement Protocol element")
+	}
+	adv := rest[2 : 2+int(rest[1])]
+	rest = rest[2+len(adv):]
+	isANQP := false
+	if len(adv) >= 2 { // Query Response Info, Advertisement Protocol ID
+		if name, ok := advertisementProtocolNames[adv[1]]; ok {
+			gas.AdvertisementProtocol = name
+		} else {
+			gas.AdvertisementProtocol = fmt.Sprintf("Unknown(%d)", adv[1])
+		}
+		isANQP = adv[1] == 0
+	}
+
+	if len(rest) < 2 {
+		return gas, nil
+	}
+	gas.QueryLength = binary.LittleEndian.Uint16(rest[0:2])
+	query := rest[2:]
+	if int(gas.QueryLength) < len(query) {
+		query = query[:gas.QueryLength]
+	}
+	// Comeback fragments after the first do not start at an ANQP element boundary
+	if isANQP && gas.FragmentID == 0 {
+		gas.ANQPInfo = parseANQPInfo(query, request)
+	}
+	return gas, nil
+}
+
+// parseANQPInfo lists the ANQP elements in a query. For requests, the Info IDs inside the
+// Query List element are listed instead of the Query List itself.
+func parseANQPInfo(query []byte, request bool) []string {
+	var names []string
+	for len(query) >= 4 {
+		infoID := binary.LittleEndian.Uint16(query[0:2])
+		length := int(binary.LittleEndian.Uint16(query[2:4]))
+		if len(query) < 4+length {
+			break
+		}
+		payload := query[4 : 4+length]
+		query = query[4+length:]
+		if request && infoID == anqpInfoIDQueryList {
+			for i := 0; i+1 < len(payload); i += 2 {
+				names = append(names, anqpInfoName(binary.LittleEndian.Uint16(payload[i:i+2])))
+			}
+			continue
+		}
+		names = append(names, anqpInfoName(infoID))
+	}
+	return names
+}
+
+func anqpInfoName(infoID uint16) string {
+	if name, ok := anqpInfoNames[infoID]; ok {
+		return name
+	}
+	return fmt.Sprintf("Info ID %d", infoID)
+}
+
+// decodeSAQueryAction decodes the Transaction Identifier of SA Query Request/Response frames.
+func decodeSAQueryAction(info *ParsedFrameInfo, action *ActionFrameInfo, body []byte) error {
+	if action.Action != saQueryActionRequest && action.Action != saQueryActionResponse {
+		return nil
+	}
+	if len(body) < 2 {
+		return errors.New("SA Query frame too short")
+	}
+	action.SAQuery = &SAQueryInfo{TransactionID: binary.LittleEndian.Uint16(body[0:2])}
+	return nil
+}
@@ -0,0 +1,120 @@
+package frame_parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseActionFrame(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		expect *ActionFrameInfo
+	}{
+		{
+			name: "Channel Switch Announcement",
+			body: "00 04 25 03 01 24 05",
+			expect: &ActionFrameInfo{Category: 0, CategoryName: "Spectrum Management", Action: 4, ActionName: "Channel Switch Announcement",
+				ChannelSwitch: &ChannelSwitchInfo{BlockTx: true, NewChannel: 36, Count: 5}},
+		},
+		{
+			name: "Extended Channel Switch Announcement",
+			body: "04 04 00 80 95 0a",
+			expect: &ActionFrameInfo{Category: 4, CategoryName: "Public", Action: 4, ActionName: "Extended Channel Switch Announcement",
+				ChannelSwitch: &ChannelSwitchInfo{Extended: true, NewOperatingClass: 128, NewChannel: 149, Count: 10}},
+		},
+		{
+			name: "ADDBA Request",
+			body: "03 00 07 1710 0000 4006",
+			expect: &ActionFrameInfo{Category: 3, CategoryName: "Block Ack", Action: 0, ActionName: "ADDBA Request",
+				BlockAck: &BlockAckActionInfo{DialogToken: 7, TID: 5, BufferSize: 64, AMSDUSupported: true, ImmediatePolicy: true, StartingSequence: 100}},
+		},
+		{
+			name: "ADDBA Response declined",
+			body: "03 01 07 2500 1710 0000",
+			expect: &ActionFrameInfo{Category: 3, CategoryName: "Block Ack", Action: 1, ActionName: "ADDBA Response",
+				BlockAck: &BlockAckActionInfo{DialogToken: 7, TID: 5, BufferSize: 64, AMSDUSupported: true, ImmediatePolicy: true,
+					StatusCode: 37, Status: "The request has been declined"}},
+		},
+		{
+			name: "DELBA",
+			body: "03 02 0058 2700",
+			expect: &ActionFrameInfo{Category: 3, CategoryName: "Block Ack", Action: 2, ActionName: "DELBA",
+				BlockAck: &BlockAckActionInfo{TID: 5, Initiator: true, ReasonCode: 39, Reason: "Requested from peer STA due to timeout"}},
+		},
+		{
+			name: "GAS Initial Request with ANQP Query List",
+			body: "04 0a 01 6c 02 7f 00 0a00 0001 0600 0101 0201 0701",
+			expect: &ActionFrameInfo{Category: 4, CategoryName: "Public", Action: 10, ActionName: "GAS Initial Request",
+				GAS: &GASInfo{DialogToken: 1, AdvertisementProtocol: "ANQP", QueryLength: 10,
+					ANQPInfo: []string{"Capability List", "Venue Name", "NAI Realm"}}},
+		},
+		{
+			name: "GAS Initial Response",
+			body: "09 0b 01 0000 0000 6c 02 7f 00 0a00 0701 0600 000000000000",
+			expect: &ActionFrameInfo{Category: 9, CategoryName: "Protected Dual of Public Action", Action: 11, ActionName: "GAS Initial Response",
+				GAS: &GASInfo{DialogToken: 1, Status: "Success", AdvertisementProtocol: "ANQP", QueryLength: 10, ANQPInfo: []string{"NAI Realm"}}},
+		},
+		{
+			name: "GAS Comeback Response fragment",
+			body: "04 0d 01 0000 81 0000 6c 02 7f 00 0200 aabb",
+			expect: &ActionFrameInfo{Category: 4, CategoryName: "Public", Action: 13, ActionName: "GAS Comeback Response",
+				GAS: &GASInfo{DialogToken: 1, Status: "Success", FragmentID: 1, MoreFragments: true, AdvertisementProtocol: "ANQP", QueryLength: 2}},
+		},
+		{
+			name: "SA Query Request",
+			body: "08 00 3412",
+			expect: &ActionFrameInfo{Category: 8, CategoryName: "SA Query", Action: 0, ActionName: "SA Query Request",
+				SAQuery: &SAQueryInfo{TransactionID: 0x1234}},
+		},
+		{
+			name:   "Category without decoder",
+			body:   "0f 01 00",
+			expect: &ActionFrameInfo{Category: 15, CategoryName: "Self-protected", Action: 1, ActionName: "Action 1"},
+		},
+		{
+			name:   "Returned with error bit",
+			body:   "83 00 07 1710 0000 4006",
+			expect: &ActionFrameInfo{Category: 3, CategoryName: "Block Ack", Action: 0, ActionName: "ADDBA Request", ErrorReturned: true},
+		},
+		{
+			name:   "Truncated ADDBA Request",
+			body:   "03 00 07 1710",
+			expect: &ActionFrameInfo{Category: 3, CategoryName: "Block Ack", Action: 0, ActionName: "ADDBA Request", Error: "ADDBA Request too short"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &ParsedFrameInfo{}
+			parseActionFrame(info, mustHex(t, tc.body), false)
+			assert.Equal(t, tc.expect, info.Action)
+		})
+	}
+
+	t.Run("Protected", func(t *testing.T) {
+		info := &ParsedFrameInfo{}
+		parseActionFrame(info, mustHex(t, "0100 0020 0000 0000 0a07"), true)
+		require.NotNil(t, info.Action)
+		assert.True(t, info.Action.Protected)
+		assert.Nil(t, info.RoamingAction)
+	})
+}
+
+func TestRegisterActionDecoder(t *testing.T) {
+	registry := &actionRegistry{byCategory: make(map[uint8]actionDecoderEntry)}
+	registry.byCategory[127] = actionDecoderEntry{
+		actionNames: map[uint8]string{0x50: "Test"},
+		decode: func(info *ParsedFrameInfo, action *ActionFrameInfo, body []byte) error {
+			info.SSID = string(body)
+			return nil
+		},
+	}
+	info := &ParsedFrameInfo{}
+	parseActionFrameWith(registry, info, []byte{127, 0x50, 'o', 'k'}, false)
+	require.NotNil(t, info.Action)
+	assert.Equal(t, "Vendor-specific", info.Action.CategoryName)
+	assert.Equal(t, "Test", info.Action.ActionName)
+	assert.Equal(t, "ok", info.SSID)
+}
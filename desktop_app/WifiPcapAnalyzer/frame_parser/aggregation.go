@@ -0,0 +1,107 @@
+package frame_parser
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/google/gopacket/layers"
+)
+
+// AMPDUInfo is the radiotap A-MPDU status of a frame. All MPDUs of one A-MPDU share the
+// reference number.
+type AMPDUInfo struct {
+	Reference     uint32
+	LastKnown     bool // IsLast is valid
+	IsLast        bool // Last MPDU of the A-MPDU
+	DelimCRCError bool // The MPDU delimiter failed its CRC
+}
+
+// AMSDUSubframe is one MSDU of an A-MSDU.
+type AMSDUSubframe struct {
+	DA            net.HardwareAddr
+	SA            net.HardwareAddr
+	Length        int // MSDU length, LLC/SNAP header included
+	PayloadLength int // L4+ payload length like TransportPayloadLength, or the bytes after LLC/SNAP for non-IP MSDUs
+}
+
+// qosAMSDUPresent is the A-MSDU Present bit in the first octet of the QoS Control field.
+const qosAMSDUPresent = 0x80
+
+// amsduSubframeHeaderLen is DA, SA and Length.
+const amsduSubframeHeaderLen = 14
+
+func parseAMPDUStatus(info *ParsedFrameInfo, rt *layers.RadioTap) {
+	status := rt.AMPDUStatus
+	info.AMPDU = &AMPDUInfo{
+		Reference:     status.Reference,
+		LastKnown:     status.Flags.LastKnown(),
+		IsLast:        status.Flags.IsLast(),
+		DelimCRCError: status.Flags.DelimCRCKnown() && status.Flags.DelimCRCErr(),
+	}
+}
+
+// parseAMSDU sets AMSDU for QoS data frames with the A-MSDU Present bit and, unless the body
+// is encrypted, decodes the subframes. TransportPayloadLength becomes the sum over all MSDUs.
+func parseAMSDU(info *ParsedFrameInfo, dot11 *layers.Dot11) {
+	if dot11.QOS == nil {
+		return
+	}
+	// gopacket drops the A-MSDU Present bit; QoS Control precedes the optional HT Control.
+	qosOffset := len(dot11.Contents) - 2
+	if dot11.HTControl != nil {
+		qosOffset -= 4
+	}
+	if qosOffset < 0 || dot11.Contents[qosOffset]&qosAMSDUPresent == 0 {
+		return
+	}
+	info.AMSDU = true
+	if dot11.Flags.WEP() {
+		return // Subframes are encrypted
+	}
+
+	body := dot11.Payload
+	total := 0
+	for len(body) >= amsduSubframeHeaderLen {
+		length := int(binary.BigEndian.Uint16(body[12:14]))
+		end := amsduSubframeHeaderLen + length
+		if end > len(body) {
+			break // Truncated capture or malformed subframe
+		}
+		subframe := AMSDUSubframe{
+			DA:            append(net.HardwareAddr(nil), body[0:6]...),
+			SA:            append(net.HardwareAddr(nil), body[6:12]...),
+			Length:        length,
+			PayloadLength: msduPayloadLength(body[amsduSubframeHeaderLen:end]),
+		}
+		info.AMSDUSubframes = append(info.AMSDUSubframes, subframe)
+		total += subframe.PayloadLength
+		// Every subframe but the last is padded to a multiple of 4 octets.
+		end = (end + 3) &^ 3
+		if end >= len(body) {
+			break
+		}
+		body = body[end:]
+	}
+	info.TransportPayloadLength = total
+}
+
+// msduPayloadLength returns the L4+ payload length of an LLC/SNAP encapsulated MSDU.
+func msduPayloadLength(msdu []byte) int {
+	const snapLen = 8
+	if len(msdu) < snapLen || msdu[0] != 0xaa || msdu[1] != 0xaa || msdu[2] != 0x03 {
+		return len(msdu)
+	}
+	ip := msdu[snapLen:]
+	switch layers.EthernetType(binary.BigEndian.Uint16(msdu[6:8])) {
+	case layers.EthernetTypeIPv4:
+		if len(ip) >= 20 {
+			// A malformed Total Length shorter than the header counts as no payload.
+			return max(int(binary.BigEndian.Uint16(ip[2:4]))-int(ip[0]&0x0f)*4, 0)
+		}
+	case layers.EthernetTypeIPv6:
+		if len(ip) >= 6 {
+			return int(binary.BigEndian.Uint16(ip[4:6]))
+		}
+	}
+	return len(ip)
+}
@@ -0,0 +1,81 @@
+package frame_parser
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// amsduSubframe builds an A-MSDU subframe (DA, SA, Length, MSDU) padded to 4 octets.
+func amsduSubframe(t *testing.T, da, sa string, msdu []byte, pad bool) []byte {
+	b := append(mustHex(t, da), mustHex(t, sa)...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(msdu)))
+	b = append(b, msdu...)
+	for pad && len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestParsePacket_AMPDUStatusAndAMSDU(t *testing.T) {
+	// IPv4/UDP MSDU with 28 bytes of L4+ payload (total length 48).
+	ipv4 := append(mustHex(t, "aaaa03000000 0800 4500 0030 0000 0000 4011 0000 c0a80101 c0a80102"), make([]byte, 28)...)
+	// ARP MSDU: not IP, the bytes after LLC/SNAP count as payload.
+	arp := append(mustHex(t, "aaaa03000000 0806"), make([]byte, 28)...)
+	body := append(amsduSubframe(t, "020000000099", "020000000001", ipv4, true),
+		amsduSubframe(t, "020000000098", "020000000001", arp, false)...)
+
+	// QoS Data, To DS, A-MSDU Present.
+	header := mustHex(t, "8801 3000 0200000000aa 020000000001 020000000099 0000 8000")
+	// Radiotap with A-MPDU status: reference 42, last MPDU (last known).
+	radiotap := mustHex(t, "0000 1000 00001000 2a000000 0c00 00 00")
+
+	// gopacket decodes the first subframe header as LLC and fails, but the 802.11 layers are intact.
+	parse := func(data []byte) *ParsedFrameInfo {
+		packet := gopacket.NewPacket(data, layers.LinkTypeIEEE80211Radio, gopacket.Default)
+		info, err := (&GoPacketParser{}).ParsePacket(packet)
+		require.NoError(t, err)
+		return info
+	}
+
+	info := parse(append(append(append([]byte(nil), radiotap...), header...), body...))
+	require.NotNil(t, info.AMPDU)
+	assert.Equal(t, AMPDUInfo{Reference: 42, LastKnown: true, IsLast: true}, *info.AMPDU)
+	assert.True(t, info.IsQoSData)
+	assert.True(t, info.AMSDU)
+	require.Len(t, info.AMSDUSubframes, 2)
+	assert.Equal(t, "02:00:00:00:00:99", info.AMSDUSubframes[0].DA.String())
+	assert.Equal(t, "02:00:00:00:00:01", info.AMSDUSubframes[0].SA.String())
+	assert.Equal(t, len(ipv4), info.AMSDUSubframes[0].Length)
+	assert.Equal(t, 28, info.AMSDUSubframes[0].PayloadLength)
+	assert.Equal(t, "02:00:00:00:00:98", info.AMSDUSubframes[1].DA.String())
+	assert.Equal(t, 28, info.AMSDUSubframes[1].PayloadLength)
+	assert.Equal(t, 56, info.TransportPayloadLength)
+
+	// Protected A-MSDU: the subframes can't be read.
+	protected := append([]byte(nil), header...)
+	protected[1] |= 0x40
+	info = parse(append(append(append([]byte(nil), radiotap...), protected...), body...))
+	assert.True(t, info.AMSDU)
+	assert.Empty(t, info.AMSDUSubframes)
+
+	// A plain QoS Data frame is not an A-MSDU and keeps its IP payload length.
+	plain := append(mustHex(t, "8801 3000 0200000000aa 020000000001 020000000099 0000 0000"), ipv4...)
+	packet := gopacket.NewPacket(append(plain, 0, 0, 0, 0), layers.LayerTypeDot11, gopacket.Default)
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+	assert.Nil(t, info.AMPDU)
+	assert.False(t, info.AMSDU)
+	assert.Equal(t, 28, info.TransportPayloadLength)
+}
+
+func TestMSDUPayloadLength(t *testing.T) {
+	assert.Equal(t, 40, msduPayloadLength(append(mustHex(t, "aaaa03000000 86dd 60000000 0028"), make([]byte, 34)...)))
+	assert.Equal(t, 3, msduPayloadLength([]byte{1, 2, 3}))
+	assert.Equal(t, 4, msduPayloadLength(mustHex(t, "aaaa03000000 0800 45000030")), "truncated IPv4 header")
+	assert.Equal(t, 0, msduPayloadLength(append(mustHex(t, "aaaa03000000 0800 4f000010"), make([]byte, 56)...)), "Total Length shorter than IHL")
+}
@@ -0,0 +1,360 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/utils"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Link types for 802.11 capture headers that gopacket has no decoder for.
+const (
+	LinkTypePPI layers.LinkType = 192 // Per-Packet Information header (DLT_PPI)
+	LinkTypeAVS layers.LinkType = 163 // AVS WLAN monitor header (DLT_IEEE802_11_RADIO_AVS)
+)
+
+// Layer types for the PPI and AVS headers. The numbers only need to be unique within gopacket.
+var (
+	LayerTypePPI = gopacket.RegisterLayerType(100192, gopacket.LayerTypeMetadata{Name: "PPI", Decoder: gopacket.DecodeFunc(decodePPI)})
+	LayerTypeAVS = gopacket.RegisterLayerType(100163, gopacket.LayerTypeMetadata{Name: "AVS", Decoder: gopacket.DecodeFunc(decodeAVS)})
+)
+
+func init() {
+	layers.LinkTypeMetadata[LinkTypePPI] = layers.EnumMetadata{DecodeWith: LayerTypePPI, Name: "PPI"}
+	layers.LinkTypeMetadata[LinkTypeAVS] = layers.EnumMetadata{DecodeWith: LayerTypeAVS, Name: "AVS"}
+}
+
+// prismOrAVSDecoder decodes Prism link type packets. Some drivers write AVS headers with the
+// Prism link type; those are routed by their magic.
+var prismOrAVSDecoder = gopacket.DecodeFunc(decodePrismOrAVS)
+
+// linkTypeDecoder returns the decoder for the first layer of a packet captured with linkType.
+func linkTypeDecoder(linkType layers.LinkType) gopacket.Decoder {
+	if linkType == layers.LinkTypePrismHeader {
+		return prismOrAVSDecoder
+	}
+	return linkType
+}
+
+// --- PPI ---
+
+// PPI field types (PPI Header Format Specification, v1.0.10).
+const (
+	ppiField80211Common  = 2
+	ppiField80211NMACPHY = 4
+)
+
+// PPI 802.11-Common flags.
+const (
+	ppiCommonFlagFCS    = 1 << 0
+	ppiCommonFlagBadFCS = 1 << 2
+)
+
+// PPI 802.11n MAC+PHY flags.
+const (
+	ppiMACPHYFlag40MHz   = 1 << 1
+	ppiMACPHYFlagShortGI = 1 << 2
+)
+
+// PPICommon is the 802.11-Common field of a PPI header.
+type PPICommon struct {
+	TSFTimer     uint64
+	Flags        uint16
+	Rate         uint16 // In 500 kbps units
+	Frequency    uint16 // In MHz
+	ChannelFlags uint16
+	FHSSHopset   uint8
+	FHSSPattern  uint8
+	Signal       int8 // dBm
+	Noise        int8 // dBm
+}
+
+// PPIMACPHY is the subset of the 802.11n MAC+PHY field used by the parser.
+type PPIMACPHY struct {
+	Flags uint32
+	MCS   uint8
+}
+
+// PPI is the Per-Packet Information header (DLT 192).
+type PPI struct {
+	layers.BaseLayer
+	Version uint8
+	Flags   uint8
+	Length  uint16
+	DLT     layers.LinkType
+	Common  *PPICommon
+	MACPHY  *PPIMACPHY
+}
+
+func (p *PPI) LayerType() gopacket.LayerType { return LayerTypePPI }
+
+func (p *PPI) CanDecode() gopacket.LayerClass { return LayerTypePPI }
+
+func (p *PPI) NextLayerType() gopacket.LayerType {
+	switch p.DLT {
+	case layers.LinkTypeIEEE802_11:
+		return layers.LayerTypeDot11
+	case layers.LinkTypeIEEE80211Radio:
+		return layers.LayerTypeRadioTap
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the PPI header and the 802.11 fields it knows about. Fields are
+// little-endian and packed without alignment.
+func (p *PPI) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		df.SetTruncated()
+		return errors.New("PPI header too short")
+	}
+	p.Version = data[0]
+	p.Flags = data[1]
+	p.Length = binary.LittleEndian.Uint16(data[2:4])
+	p.DLT = layers.LinkType(binary.LittleEndian.Uint32(data[4:8]))
+	p.Common, p.MACPHY = nil, nil
+	if p.Version != 0 {
+		return fmt.Errorf("unsupported PPI version %d", p.Version)
+	}
+	if int(p.Length) < 8 || int(p.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("PPI header length %d out of range", p.Length)
+	}
+
+	for fields := data[8:p.Length]; len(fields) >= 4; {
+		fieldType := binary.LittleEndian.Uint16(fields[0:2])
+		fieldLen := int(binary.LittleEndian.Uint16(fields[2:4]))
+		if 4+fieldLen > len(fields) {
+			return fmt.Errorf("PPI field %d length %d exceeds header", fieldType, fieldLen)
+		}
+		field := fields[4 : 4+fieldLen]
+		switch {
+		case fieldType == ppiField80211Common && fieldLen >= 20:
+			p.Common = &PPICommon{
+				TSFTimer:     binary.LittleEndian.Uint64(field[0:8]),
+				Flags:        binary.LittleEndian.Uint16(field[8:10]),
+				Rate:         binary.LittleEndian.Uint16(field[10:12]),
+				Frequency:    binary.LittleEndian.Uint16(field[12:14]),
+				ChannelFlags: binary.LittleEndian.Uint16(field[14:16]),
+				FHSSHopset:   field[16],
+				FHSSPattern:  field[17],
+				Signal:       int8(field[18]),
+				Noise:        int8(field[19]),
+			}
+		case fieldType == ppiField80211NMACPHY && fieldLen >= 10:
+			p.MACPHY = &PPIMACPHY{
+				Flags: binary.LittleEndian.Uint32(field[0:4]),
+				MCS:   field[9],
+			}
+		}
+		fields = fields[4+fieldLen:]
+	}
+
+	p.BaseLayer = layers.BaseLayer{Contents: data[:p.Length], Payload: data[p.Length:]}
+	return nil
+}
+
+func decodePPI(data []byte, p gopacket.PacketBuilder) error {
+	ppi := &PPI{}
+	if err := ppi.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(ppi)
+	return p.NextDecoder(ppi.NextLayerType())
+}
+
+// --- AVS ---
+
+// avsMagic is the top 28 bits of the AVS header version field (0x80211001 for version 1).
+const avsMagic = 0x8021100
+
+// AVS SSI types.
+const (
+	AVSSSITypeNone       = 0
+	AVSSSITypeNormalized = 1
+	AVSSSITypeDBM        = 2
+	AVSSSITypeRaw        = 3
+)
+
+// AVS is the AVS WLAN monitor header (DLT 163). All fields are big-endian.
+type AVS struct {
+	layers.BaseLayer
+	Version   uint32
+	Length    uint32
+	MACTime   uint64
+	HostTime  uint64
+	PHYType   uint32
+	Channel   uint32
+	DataRate  uint32 // In 100 kbps units
+	Antenna   uint32
+	Priority  uint32
+	SSIType   uint32
+	SSISignal int32
+	SSINoise  int32
+	Preamble  uint32
+	Encoding  uint32
+}
+
+func (a *AVS) LayerType() gopacket.LayerType { return LayerTypeAVS }
+
+func (a *AVS) CanDecode() gopacket.LayerClass { return LayerTypeAVS }
+
+func (a *AVS) NextLayerType() gopacket.LayerType { return layers.LayerTypeDot11 }
+
+// DecodeFromBytes decodes an AVS header; the 802.11 frame starts Length bytes in.
+func (a *AVS) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 64 {
+		df.SetTruncated()
+		return errors.New("AVS header too short")
+	}
+	a.Version = binary.BigEndian.Uint32(data[0:4])
+	if a.Version>>4 != avsMagic {
+		return fmt.Errorf("invalid AVS header version %#x", a.Version)
+	}
+	a.Length = binary.BigEndian.Uint32(data[4:8])
+	if a.Length < 64 || int(a.Length) > len(data) {
+		df.SetTruncated()
+		return fmt.Errorf("AVS header length %d out of range", a.Length)
+	}
+	a.MACTime = binary.BigEndian.Uint64(data[8:16])
+	a.HostTime = binary.BigEndian.Uint64(data[16:24])
+	a.PHYType = binary.BigEndian.Uint32(data[24:28])
+	a.Channel = binary.BigEndian.Uint32(data[28:32])
+	a.DataRate = binary.BigEndian.Uint32(data[32:36])
+	a.Antenna = binary.BigEndian.Uint32(data[36:40])
+	a.Priority = binary.BigEndian.Uint32(data[40:44])
+	a.SSIType = binary.BigEndian.Uint32(data[44:48])
+	a.SSISignal = int32(binary.BigEndian.Uint32(data[48:52]))
+	a.SSINoise = int32(binary.BigEndian.Uint32(data[52:56]))
+	a.Preamble = binary.BigEndian.Uint32(data[56:60])
+	a.Encoding = binary.BigEndian.Uint32(data[60:64])
+	a.BaseLayer = layers.BaseLayer{Contents: data[:a.Length], Payload: data[a.Length:]}
+	return nil
+}
+
+func decodeAVS(data []byte, p gopacket.PacketBuilder) error {
+	avs := &AVS{}
+	if err := avs.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(avs)
+	return p.NextDecoder(avs.NextLayerType())
+}
+
+func decodePrismOrAVS(data []byte, p gopacket.PacketBuilder) error {
+	if len(data) >= 4 && binary.BigEndian.Uint32(data[0:4])>>4 == avsMagic {
+		return decodeAVS(data, p)
+	}
+	return layers.LayerTypePrismHeader.Decode(data, p)
+}
+
+// --- Applying capture headers to ParsedFrameInfo ---
+
+// applyCaptureHeader fills signal, noise, channel and rate from a PPI, Prism or AVS header.
+// It reports whether the packet had one of these headers.
+func applyCaptureHeader(info *ParsedFrameInfo, packet layerSource) bool {
+	if layer := packet.Layer(LayerTypePPI); layer != nil {
+		ppi := layer.(*PPI)
+		if c := ppi.Common; c != nil {
+			info.FCSPresent = c.Flags&ppiCommonFlagFCS != 0
+			info.BadFCS = c.Flags&ppiCommonFlagBadFCS != 0
+			// Signal and noise of -128 mean "not measured".
+			if c.Signal != -128 {
+				info.SignalStrength = int(c.Signal)
+			}
+			if c.Noise != -128 {
+				info.NoiseLevel = int(c.Noise)
+			}
+			if c.Frequency != 0 {
+				setChannelFromFrequency(info, int(c.Frequency))
+			}
+			if c.Rate != 0 {
+				info.RadiotapDataRate = float64(c.Rate) * 0.5
+			}
+		}
+		if m := ppi.MACPHY; m != nil {
+			info.RadiotapMCSIndex = m.MCS
+			info.IsShortGI = m.Flags&ppiMACPHYFlagShortGI != 0
+			if m.Flags&ppiMACPHYFlag40MHz != 0 {
+				info.RadiotapMCSBw = 1
+			}
+		}
+		return true
+	}
+
+	if layer := packet.Layer(layers.LayerTypePrismHeader); layer != nil {
+		prism := layer.(*layers.PrismHeader)
+		for _, v := range prism.Values {
+			// wlan-ng uses status 0 for "value present"; gopacket's IsSupplied has it inverted.
+			if v.Status != 0 || len(v.Data) < 4 {
+				continue
+			}
+			data := binary.LittleEndian.Uint32(v.Data)
+			switch v.DID {
+			case layers.PrismDIDType1Channel, layers.PrismDIDType2Channel:
+				setChannelFromNumber(info, int(data))
+			case layers.PrismDIDType1Signal, layers.PrismDIDType2Signal:
+				info.SignalStrength = int(int32(data))
+			case layers.PrismDIDType1Noise, layers.PrismDIDType2Noise:
+				info.NoiseLevel = int(int32(data))
+			case layers.PrismDIDType1Rate, layers.PrismDIDType2Rate:
+				info.RadiotapDataRate = float64(data) * 0.5
+			}
+		}
+		return true
+	}
+
+	if layer := packet.Layer(LayerTypeAVS); layer != nil {
+		avs := layer.(*AVS)
+		if avs.SSIType == AVSSSITypeDBM {
+			info.SignalStrength = int(avs.SSISignal)
+			info.NoiseLevel = int(avs.SSINoise)
+		}
+		setChannelFromNumber(info, int(avs.Channel))
+		if avs.DataRate != 0 {
+			info.RadiotapDataRate = float64(avs.DataRate) / 10
+		}
+		return true
+	}
+
+	return false
+}
+
+// setChannelFromFrequency sets Frequency, and Channel and Band when the frequency is on a known raster.
+func setChannelFromFrequency(info *ParsedFrameInfo, freqMHz int) {
+	info.Frequency = freqMHz
+	if ch, ok := utils.ChannelFromFrequency(freqMHz); ok {
+		info.Channel = ch.Number
+		info.Band = string(ch.Band)
+	}
+}
+
+// setChannelFromNumber sets Channel, Frequency and Band from a bare channel number. A known
+// frequency or band takes precedence. Otherwise, as Prism and AVS headers and the DS Parameter
+// Set carry no band, channels 1-14 are taken as 2.4 GHz and 5 GHz channels as 5 GHz; other
+// numbers (e.g. 6 GHz only channels) are kept without a band.
+func setChannelFromNumber(info *ParsedFrameInfo, channel int) {
+	if channel <= 0 {
+		return
+	}
+	if info.Frequency > 0 {
+		setChannelFromFrequency(info, info.Frequency)
+		return
+	}
+	info.Channel = channel
+	band := utils.Band(info.Band)
+	switch {
+	case band != utils.BandUnknown:
+	case channel <= 14:
+		band = utils.Band2_4GHz
+	case utils.IsValidPrimaryChannel(utils.Band5GHz, channel):
+		band = utils.Band5GHz
+	default:
+		return
+	}
+	if freq, ok := utils.ChannelToFrequency(band, channel); ok {
+		setChannelFromFrequency(info, freq)
+	}
+}
@@ -0,0 +1,228 @@
+package frame_parser
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseWithLinkType(t *testing.T, linkType layers.LinkType, data []byte) *ParsedFrameInfo {
+	t.Helper()
+	packet := gopacket.NewPacket(data, linkTypeDecoder(linkType), gopacket.Default)
+	require.Nil(t, packet.ErrorLayer(), "decode error: %v", packet.ErrorLayer())
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+	assert.Equal(t, linkType, linkTypeOfPacket(packet))
+	return info
+}
+
+func TestParsePacket_PPI(t *testing.T) {
+	// 802.11-Common: rate 54 Mbps, 5180 MHz, signal -52 dBm, noise -95 dBm.
+	common := binary.LittleEndian.AppendUint16(nil, ppiField80211Common)
+	common = binary.LittleEndian.AppendUint16(common, 20)
+	common = append(common, make([]byte, 10)...) // TSFT, flags
+	common = binary.LittleEndian.AppendUint16(common, 108)
+	common = binary.LittleEndian.AppendUint16(common, 5180)
+	common = append(common, 0x40, 0x01, 0, 0, byte(0xcc), byte(0xa1))
+	// 802.11n MAC+PHY: 40 MHz, short GI, MCS 7.
+	macphy := binary.LittleEndian.AppendUint16(nil, ppiField80211NMACPHY)
+	macphy = binary.LittleEndian.AppendUint16(macphy, 48)
+	macphy = binary.LittleEndian.AppendUint32(macphy, ppiMACPHYFlag40MHz|ppiMACPHYFlagShortGI)
+	macphy = append(macphy, make([]byte, 5)...)
+	macphy = append(macphy, 7)
+	macphy = append(macphy, make([]byte, 38)...)
+
+	header := []byte{0, 0}
+	header = binary.LittleEndian.AppendUint16(header, uint16(8+len(common)+len(macphy)))
+	header = binary.LittleEndian.AppendUint32(header, uint32(layers.LinkTypeIEEE802_11))
+	data := append(append(append(header, common...), macphy...), mustHex(t, pcapngTestDeauth)...)
+
+	info := parseWithLinkType(t, LinkTypePPI, data)
+	assert.Equal(t, "MgmtDeauthentication", info.FrameType)
+	assert.Equal(t, -52, info.SignalStrength)
+	assert.Equal(t, -95, info.NoiseLevel)
+	assert.Equal(t, 5180, info.Frequency)
+	assert.Equal(t, 36, info.Channel)
+	assert.Equal(t, "5GHz", info.Band)
+	assert.Equal(t, 54.0, info.RadiotapDataRate)
+	assert.Equal(t, uint8(7), info.RadiotapMCSIndex)
+	assert.Equal(t, uint8(1), info.RadiotapMCSBw)
+	assert.True(t, info.IsShortGI)
+}
+
+func TestParsePacket_Prism(t *testing.T) {
+	value := func(b []byte, did layers.PrismDID, status uint16, data uint32) []byte {
+		b = binary.LittleEndian.AppendUint32(b, uint32(did))
+		b = binary.LittleEndian.AppendUint16(b, status)
+		b = binary.LittleEndian.AppendUint16(b, 4)
+		return binary.LittleEndian.AppendUint32(b, data)
+	}
+	values := value(nil, layers.PrismDIDType1Channel, 0, 11)
+	values = value(values, layers.PrismDIDType1RSSI, 0, 40)
+	values = value(values, layers.PrismDIDType1Signal, 0, uint32(0xffffffbf)) // -65 dBm
+	values = value(values, layers.PrismDIDType1Noise, 1, 0)                   // Not supplied
+	values = value(values, layers.PrismDIDType1Rate, 0, 22)                   // 11 Mbps
+
+	header := binary.LittleEndian.AppendUint32(nil, 0x44)
+	header = binary.LittleEndian.AppendUint32(header, uint32(24+len(values)))
+	header = append(header, "wlan0"...)
+	header = append(header, make([]byte, 11)...)
+	data := append(append(header, values...), mustHex(t, pcapngTestDeauth)...)
+
+	info := parseWithLinkType(t, layers.LinkTypePrismHeader, data)
+	assert.Equal(t, "MgmtDeauthentication", info.FrameType)
+	assert.Equal(t, -65, info.SignalStrength)
+	assert.Equal(t, 0, info.NoiseLevel)
+	assert.Equal(t, 11, info.Channel)
+	assert.Equal(t, 2462, info.Frequency)
+	assert.Equal(t, "2.4GHz", info.Band)
+	assert.Equal(t, 11.0, info.RadiotapDataRate)
+}
+
+func avsHeader(channel, rate, ssiType uint32, signal, noise int32) []byte {
+	b := binary.BigEndian.AppendUint32(nil, 0x80211001)
+	b = binary.BigEndian.AppendUint32(b, 64)
+	b = append(b, make([]byte, 16)...)      // MAC time, host time
+	b = binary.BigEndian.AppendUint32(b, 7) // OFDM
+	b = binary.BigEndian.AppendUint32(b, channel)
+	b = binary.BigEndian.AppendUint32(b, rate)
+	b = append(b, make([]byte, 8)...) // Antenna, priority
+	b = binary.BigEndian.AppendUint32(b, ssiType)
+	b = binary.BigEndian.AppendUint32(b, uint32(signal))
+	b = binary.BigEndian.AppendUint32(b, uint32(noise))
+	return append(b, make([]byte, 8)...) // Preamble, encoding
+}
+
+func TestParsePacket_AVS(t *testing.T) {
+	data := append(avsHeader(149, 240, AVSSSITypeDBM, -70, -92), mustHex(t, pcapngTestDeauth)...)
+	info := parseWithLinkType(t, LinkTypeAVS, data)
+	assert.Equal(t, "MgmtDeauthentication", info.FrameType)
+	assert.Equal(t, -70, info.SignalStrength)
+	assert.Equal(t, -92, info.NoiseLevel)
+	assert.Equal(t, 149, info.Channel)
+	assert.Equal(t, 5745, info.Frequency)
+	assert.Equal(t, "5GHz", info.Band)
+	assert.Equal(t, 24.0, info.RadiotapDataRate)
+
+	// Raw SSI values are not dBm and are left out.
+	data = append(avsHeader(6, 10, AVSSSITypeRaw, 30, 2), mustHex(t, pcapngTestDeauth)...)
+	info = parseWithLinkType(t, LinkTypeAVS, data)
+	assert.Equal(t, 0, info.SignalStrength)
+	assert.Equal(t, 6, info.Channel)
+	assert.Equal(t, "2.4GHz", info.Band)
+	assert.Equal(t, 1.0, info.RadiotapDataRate)
+
+	// A channel number that only exists in 6 GHz is not labelled 5 GHz.
+	data = append(avsHeader(197, 10, AVSSSITypeDBM, -70, -92), mustHex(t, pcapngTestDeauth)...)
+	info = parseWithLinkType(t, LinkTypeAVS, data)
+	assert.Equal(t, 197, info.Channel)
+	assert.Equal(t, 0, info.Frequency)
+	assert.Equal(t, "", info.Band)
+}
+
+func TestSetChannelFromNumber(t *testing.T) {
+	cases := []struct {
+		name     string
+		info     ParsedFrameInfo
+		channel  int
+		wantCh   int
+		wantFreq int
+		wantBand string
+	}{
+		{"2.4 GHz", ParsedFrameInfo{}, 6, 6, 2437, "2.4GHz"},
+		{"5 GHz", ParsedFrameInfo{}, 36, 36, 5180, "5GHz"},
+		{"6 GHz only channel", ParsedFrameInfo{}, 233, 233, 0, ""},
+		{"frequency wins", ParsedFrameInfo{Frequency: 5975}, 5, 5, 5975, "6GHz"},
+		{"known band wins", ParsedFrameInfo{Band: "6GHz"}, 37, 37, 6135, "6GHz"},
+		{"no channel", ParsedFrameInfo{}, 0, 0, 0, ""},
+	}
+	for _, tc := range cases {
+		info := tc.info
+		setChannelFromNumber(&info, tc.channel)
+		assert.Equal(t, tc.wantCh, info.Channel, tc.name)
+		assert.Equal(t, tc.wantFreq, info.Frequency, tc.name)
+		assert.Equal(t, tc.wantBand, info.Band, tc.name)
+	}
+}
+
+func TestParsePacket_AVSUnderPrismLinkType(t *testing.T) {
+	data := append(avsHeader(1, 10, AVSSSITypeDBM, -40, -90), mustHex(t, pcapngTestDeauth)...)
+	packet := gopacket.NewPacket(data, linkTypeDecoder(layers.LinkTypePrismHeader), gopacket.Default)
+	require.Nil(t, packet.ErrorLayer())
+	require.NotNil(t, packet.Layer(LayerTypeAVS))
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+	assert.Equal(t, -40, info.SignalStrength)
+	assert.Equal(t, 2412, info.Frequency)
+
+	// gopacket's own Prism link type decoder is left alone.
+	packet = gopacket.NewPacket(data, layers.LinkTypePrismHeader, gopacket.Default)
+	assert.Nil(t, packet.Layer(LayerTypeAVS))
+}
+
+func TestParsePacket_Bare80211UsesDSParameterSet(t *testing.T) {
+	beacon := mustHex(t, "8000 0000 ffffffffffff 0200000000aa 0200000000aa 0000"+
+		"0000000000000000 6400 0104"+
+		"00 04 74657374 03 01 06 01 04 82848b96 00000000")
+	info := parseWithLinkType(t, layers.LinkTypeIEEE802_11, beacon)
+	assert.Equal(t, 6, info.Channel)
+	assert.Equal(t, 2437, info.Frequency)
+	assert.Equal(t, "2.4GHz", info.Band)
+}
+
+func TestPPIAndAVSRejectMalformedHeaders(t *testing.T) {
+	df := gopacket.NilDecodeFeedback
+	assert.Error(t, (&PPI{}).DecodeFromBytes([]byte{0, 0, 8}, df))
+	assert.Error(t, (&PPI{}).DecodeFromBytes([]byte{0, 0, 0x40, 0, 105, 0, 0, 0}, df))
+	assert.Error(t, (&PPI{}).DecodeFromBytes([]byte{0, 0, 12, 0, 105, 0, 0, 0, 2, 0, 20, 0}, df))
+
+	avs := avsHeader(1, 10, AVSSSITypeDBM, -40, -90)
+	assert.NoError(t, (&AVS{}).DecodeFromBytes(avs, df))
+	avs[0] = 0x12
+	assert.Error(t, (&AVS{}).DecodeFromBytes(avs, df))
+	assert.Error(t, (&AVS{}).DecodeFromBytes(avs[:32], df))
+}
+
+func TestParsePacket_FCSValidation(t *testing.T) {
+	frame := mustHex(t, pcapngTestDeauth)
+	frame = frame[:len(frame)-4]
+	fcs := binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(frame))
+	withFCS := append(append([]byte(nil), frame...), fcs...)
+
+	// Radiotap with only the Flags field.
+	radiotap := func(flags byte) []byte { return append(mustHex(t, "0000 0900 02000000"), flags) }
+
+	info := parseWithLinkType(t, layers.LinkTypeIEEE80211Radio, append(radiotap(0x10), withFCS...))
+	assert.True(t, info.FCSPresent)
+	assert.False(t, info.BadFCS)
+	assert.Equal(t, uint16(15), info.ReasonCode)
+
+	corrupted := append([]byte(nil), withFCS...)
+	corrupted[15] ^= 0xff // Flip the TA's last octet
+	info = parseWithLinkType(t, layers.LinkTypeIEEE80211Radio, append(radiotap(0x10), corrupted...))
+	assert.True(t, info.BadFCS)
+	assert.Equal(t, "MgmtDeauthentication", info.FrameType)
+	assert.Equal(t, "02:00:00:00:00:55", info.TA.String())
+	assert.Nil(t, info.SA, "addresses of bad frames are not interpreted")
+	assert.Equal(t, []string{"Bad FCS"}, FrameAnnotations(info))
+
+	// The driver flagged the frame as bad.
+	info = parseWithLinkType(t, layers.LinkTypeIEEE80211Radio, append(radiotap(0x50), withFCS...))
+	assert.True(t, info.BadFCS)
+
+	// Without an FCS the CRC can't be checked and the frame is taken as good.
+	info = parseWithLinkType(t, layers.LinkTypeIEEE80211Radio, append(radiotap(0x00), frame...))
+	assert.False(t, info.FCSPresent)
+	assert.False(t, info.BadFCS)
+
+	// Too short to decode as 802.11, but still reported as a bad frame.
+	packet := gopacket.NewPacket(append(radiotap(0x50), 0xc0, 0x00), layers.LinkTypeIEEE80211Radio, gopacket.Default)
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+	assert.True(t, info.BadFCS)
+}
@@ -0,0 +1,430 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/logger"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket/layers"
+)
+
+// Control frame subtypes that gopacket v1.1.19 does not define (type/subtype encoded like layers.Dot11Type).
+const (
+	dot11TypeCtrlTrigger layers.Dot11Type = 0x09 // Control, subtype 2 (802.11ax)
+	dot11TypeCtrlNDPA    layers.Dot11Type = 0x15 // Control, subtype 5 (VHT/HE NDP Announcement)
+)
+
+// ControlFrameInfo holds the decoded body of a control frame.
+type ControlFrameInfo struct {
+	Kind     string        `json:"kind"` // "RTS", "CTS", "ACK", "BlockAckReq", "BlockAck", "Trigger", "NDPA", "PS-Poll", "CF-End"
+	BlockAck *BlockAckInfo `json:"block_ack,omitempty"`
+	Trigger  *TriggerInfo  `json:"trigger,omitempty"`
+	NDPA     *NDPAInfo     `json:"ndpa,omitempty"`
+}
+
+// BlockAckInfo describes a BlockAckReq or BlockAck frame.
+type BlockAckInfo struct {
+	Request bool             `json:"request"` // true for BlockAckReq
+	Variant string           `json:"variant"` // "Basic", "Compressed", "Extended Compressed", "Multi-TID", "GCR", "GLK-GCR", "Multi-STA"
+	NoAck   bool             `json:"no_ack"`  // BAR/BA Ack Policy bit
+	Records []BlockAckRecord `json:"records"` // One per TID (or per AID/TID for Multi-STA)
+}
+
+// BlockAckRecord is one starting sequence number (and, for BlockAck, bitmap) of a BAR/BA.
+type BlockAckRecord struct {
+	AID              uint16 `json:"aid,omitempty"` // Multi-STA BlockAck only
+	TID              uint8  `json:"tid"`
+	StartingSequence uint16 `json:"starting_sequence"`
+	Bitmap           []byte `json:"bitmap,omitempty"`
+	AllAck           bool   `json:"all_ack,omitempty"` // Multi-STA "all ack" record without a bitmap
+	AckedMPDUs       int    `json:"acked_mpdus"`
+	MissingMPDUs     int    `json:"missing_mpdus"` // Holes below the highest acknowledged MPDU
+}
+
+// TriggerInfo describes an HE Trigger frame.
+type TriggerInfo struct {
+	Type        uint8             `json:"type"`
+	TypeName    string            `json:"type_name"` // e.g. "Basic", "MU-BAR", "MU-RTS", "BSRP"
+	ULLength    uint16            `json:"ul_length"`
+	MoreTF      bool              `json:"more_tf"`
+	CSRequired  bool              `json:"cs_required"`
+	ULBandwidth string            `json:"ul_bandwidth"` // "20MHz", "40MHz", "80MHz", "160MHz"
+	Users       []TriggerUserInfo `json:"users"`
+}
+
+// TriggerUserInfo is one User Info field of a Trigger frame.
+type TriggerUserInfo struct {
+	AID          uint16 `json:"aid"`
+	RUAllocation uint8  `json:"ru_allocation"` // Raw RU Allocation subfield
+	RU           string `json:"ru"`            // e.g. "106-tone RU 3"
+	RUTones      int    `json:"ru_tones"`
+	ULMCS        uint8  `json:"ul_mcs"`
+	StartingSS   uint8  `json:"starting_ss"`
+	NumSS        uint8  `json:"num_ss"`
+}
+
+// NDPAInfo describes a VHT/HE/EHT NDP Announcement frame.
+type NDPAInfo struct {
+	Variant     string       `json:"variant"` // "VHT", "HE", "EHT" or "Ranging"
+	DialogToken uint8        `json:"dialog_token"`
+	Targets     []NDPATarget `json:"targets"`
+}
+
+// NDPATarget is one STA Info field of an NDPA frame (a sounding target).
+type NDPATarget struct {
+	AID      uint16 `json:"aid"`
+	Feedback string `json:"feedback"` // "SU", "MU" or "CQI"
+	Nc       uint8  `json:"nc"`       // Number of columns requested (Nc index + 1)
+}
+
+var triggerTypeNames = map[uint8]string{
+	0: "Basic",
+	1: "BFRP",
+	2: "MU-BAR",
+	3: "MU-RTS",
+	4: "BSRP",
+	5: "GCR MU-BAR",
+	6: "BQRP",
+	7: "NFRP",
+}
+
+var blockAckVariantNames = map[uint8]string{
+	0:  "Basic",
+	1:  "Extended Compressed",
+	2:  "Compressed",
+	3:  "Multi-TID",
+	6:  "GCR",
+	10: "GLK-GCR",
+	11: "Multi-STA",
+}
+
+// parseControlFrame decodes the body of a control frame into info.Control.
+// gopacket only extracts the second address for RTS/PS-Poll/CF-End, so for
+// BAR/BA/Trigger/NDPA the transmitter address is read from the payload here.
+func parseControlFrame(info *ParsedFrameInfo, dot11 *layers.Dot11) {
+	payload := dot11.Payload
+	ctrl := &ControlFrameInfo{}
+	var err error
+
+	switch dot11.Type {
+	case layers.Dot11TypeCtrlRTS:
+		ctrl.Kind = "RTS"
+	case layers.Dot11TypeCtrlCTS:
+		ctrl.Kind = "CTS"
+	case layers.Dot11TypeCtrlAck:
+		ctrl.Kind = "ACK"
+	case layers.Dot11TypeCtrlPowersavePoll:
+		ctrl.Kind = "PS-Poll"
+	case layers.Dot11TypeCtrlCFEnd, layers.Dot11TypeCtrlCFEndAck:
+		ctrl.Kind = "CF-End"
+	case layers.Dot11TypeCtrlBlockAckReq, layers.Dot11TypeCtrlBlockAck:
+		ctrl.Kind = "BlockAck"
+		if dot11.Type == layers.Dot11TypeCtrlBlockAckReq {
+			ctrl.Kind = "BlockAckReq"
+		}
+		if payload, err = setControlTA(info, payload); err == nil {
+			ctrl.BlockAck, err = parseBlockAck(payload, dot11.Type == layers.Dot11TypeCtrlBlockAckReq)
+		}
+	case dot11TypeCtrlTrigger:
+		ctrl.Kind = "Trigger"
+		info.FrameType = "CtrlTrigger"
+		if payload, err = setControlTA(info, payload); err == nil {
+			ctrl.Trigger, err = parseTriggerFrame(payload)
+		}
+	case dot11TypeCtrlNDPA:
+		ctrl.Kind = "NDPA"
+		info.FrameType = "CtrlNDPA"
+		if payload, err = setControlTA(info, payload); err == nil {
+			ctrl.NDPA, err = parseNDPAFrame(payload)
+		}
+	default:
+		return
+	}
+	if err != nil {
+		logger.Log.Debug().Err(err).Str("kind", ctrl.Kind).Msg("Control frame body incomplete.")
+	}
+	info.Control = ctrl
+}
+
+// setControlTA reads the transmitter address at the start of a control frame payload.
+func setControlTA(info *ParsedFrameInfo, payload []byte) ([]byte, error) {
+	if len(payload) < 6 {
+		return nil, fmt.Errorf("control frame too short for TA (%d bytes)", len(payload))
+	}
+	info.TA = net.HardwareAddr(payload[0:6])
+	info.SA = info.TA
+	return payload[6:], nil
+}
+
+// parseBlockAck decodes the BAR/BA Control field and the BAR/BA Information field.
+// Reference: IEEE 802.11ax-2021, 9.3.1.7 and 9.3.1.8.
+func parseBlockAck(data []byte, request bool) (*BlockAckInfo, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("BAR/BA control field missing")
+	}
+	control := binary.LittleEndian.Uint16(data[0:2])
+	variant := uint8(control>>1) & 0x0F
+	tidInfo := uint8(control >> 12)
+	ba := &BlockAckInfo{Request: request, NoAck: control&0x1 != 0, Variant: blockAckVariantNames[variant]}
+	if ba.Variant == "" {
+		ba.Variant = fmt.Sprintf("Reserved(%d)", variant)
+	}
+	data = data[2:]
+
+	switch variant {
+	case 3: // Multi-TID: TID_INFO+1 records of Per TID Info, SSC and (for BA) an 8 octet bitmap
+		for i := 0; i <= int(tidInfo); i++ {
+			if len(data) < 4 {
+				return ba, fmt.Errorf("Multi-TID record %d truncated", i)
+			}
+			rec := BlockAckRecord{TID: uint8(binary.LittleEndian.Uint16(data[0:2]) >> 12), StartingSequence: binary.LittleEndian.Uint16(data[2:4]) >> 4}
+			data = data[4:]
+			if !request {
+				if len(data) < 8 {
+					return ba, fmt.Errorf("Multi-TID bitmap %d truncated", i)
+				}
+				rec.setBitmap(data[:8], false)
+				data = data[8:]
+			}
+			ba.Records = append(ba.Records, rec)
+		}
+	case 11: // Multi-STA BlockAck: AID TID Info records until the end of the frame
+		for len(data) >= 2 {
+			aidTID := binary.LittleEndian.Uint16(data[0:2])
+			rec := BlockAckRecord{AID: aidTID & 0x07FF, TID: uint8(aidTID >> 12)}
+			ackType := aidTID&0x0800 != 0
+			data = data[2:]
+			if rec.AID == 2045 { // Reserved + RA of an unassociated STA
+				if len(data) < 10 {
+					return ba, fmt.Errorf("Multi-STA unassociated record truncated")
+				}
+				data = data[10:]
+				continue
+			}
+			if ackType && rec.TID == 14 { // All Ack
+				rec.AllAck = true
+				ba.Records = append(ba.Records, rec)
+				continue
+			}
+			if len(data) < 2 {
+				return ba, fmt.Errorf("Multi-STA starting sequence truncated")
+			}
+			ssc := binary.LittleEndian.Uint16(data[0:2])
+			rec.StartingSequence = ssc >> 4
+			data = data[2:]
+			if !ackType {
+				n := multiSTABitmapLen(uint8(ssc))
+				if len(data) < n {
+					return ba, fmt.Errorf("Multi-STA bitmap truncated")
+				}
+				rec.setBitmap(data[:n], false)
+				data = data[n:]
+			}
+			ba.Records = append(ba.Records, rec)
+		}
+	default: // Basic, Compressed, Extended Compressed, GCR: one SSC (GCR adds a group address) and a bitmap
+		if len(data) < 2 {
+			return ba, fmt.Errorf("starting sequence control missing")
+		}
+		rec := BlockAckRecord{TID: tidInfo, StartingSequence: binary.LittleEndian.Uint16(data[0:2]) >> 4}
+		data = data[2:]
+		if variant == 6 && len(data) >= 6 {
+			data = data[6:]
+		}
+		if !request {
+			if variant == 1 && len(data) > 8 { // Extended Compressed: 8 octet bitmap + RBUFCAP
+				data = data[:8]
+			}
+			rec.setBitmap(data, variant == 0)
+		}
+		ba.Records = append(ba.Records, rec)
+	}
+	return ba, nil
+}
+
+// multiSTABitmapLen returns the bitmap length encoded in the Fragment Number subfield (B1-B2)
+// of a Multi-STA BlockAck record.
+func multiSTABitmapLen(fragment uint8) int {
+	switch (fragment >> 1) & 0x3 {
+	case 1:
+		return 16
+	case 2:
+		return 32
+	case 3:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// setBitmap stores the bitmap and counts acknowledged and missing MPDUs.
+// A basic BlockAck carries 16 fragment bits per MSDU; an MSDU counts as received if any fragment bit is set.
+func (r *BlockAckRecord) setBitmap(bitmap []byte, basic bool) {
+	r.Bitmap = append([]byte(nil), bitmap...)
+	received := func(i int) bool { return bitmap[i/8]&(1<<(uint(i)%8)) != 0 }
+	n := len(bitmap) * 8
+	if basic {
+		received = func(i int) bool { return binary.LittleEndian.Uint16(bitmap[2*i:2*i+2]) != 0 }
+		n = len(bitmap) / 2
+	}
+	highest := -1
+	for i := 0; i < n; i++ {
+		if received(i) {
+			r.AckedMPDUs++
+			highest = i
+		}
+	}
+	r.MissingMPDUs = highest + 1 - r.AckedMPDUs
+}
+
+// parseTriggerFrame decodes the Common Info field and the User Info list of a Trigger frame.
+// Reference: IEEE 802.11ax-2021, 9.3.1.22.
+func parseTriggerFrame(data []byte) (*TriggerInfo, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("trigger frame too short for Common Info (%d bytes)", len(data))
+	}
+	common := binary.LittleEndian.Uint64(data[0:8])
+	t := &TriggerInfo{
+		Type:       uint8(common & 0x0F),
+		ULLength:   uint16(common>>4) & 0x0FFF,
+		MoreTF:     common&(1<<16) != 0,
+		CSRequired: common&(1<<17) != 0,
+	}
+	t.TypeName = triggerTypeNames[t.Type]
+	if t.TypeName == "" {
+		t.TypeName = fmt.Sprintf("Reserved(%d)", t.Type)
+	}
+	t.ULBandwidth = [4]string{"20MHz", "40MHz", "80MHz", "160MHz"}[(common>>18)&0x3]
+	data = data[8:]
+
+	if t.Type == 7 { // NFRP uses a different User Info layout (starting AID range), not decoded.
+		return t, nil
+	}
+	for len(data) >= 5 {
+		raw := uint64(binary.LittleEndian.Uint32(data[0:4])) | uint64(data[4])<<32
+		aid := uint16(raw & 0x0FFF)
+		if aid == 4095 { // Start of padding
+			break
+		}
+		ru := uint8(raw >> 12)
+		u := TriggerUserInfo{
+			AID:          aid,
+			RUAllocation: ru,
+			ULMCS:        uint8(raw>>21) & 0x0F,
+			StartingSS:   uint8(raw>>26) & 0x07,
+			NumSS:        uint8(raw>>29)&0x07 + 1,
+		}
+		u.RU, u.RUTones = ruAllocationToString(ru)
+		t.Users = append(t.Users, u)
+		data = data[5:]
+
+		skip := triggerDependentUserInfoLen(t.Type, data)
+		if skip > len(data) {
+			return t, fmt.Errorf("trigger dependent user info truncated")
+		}
+		data = data[skip:]
+	}
+	return t, nil
+}
+
+// triggerDependentUserInfoLen returns the length of the Trigger Dependent User Info that follows each User Info field.
+func triggerDependentUserInfoLen(triggerType uint8, data []byte) int {
+	switch triggerType {
+	case 0, 1: // Basic, BFRP
+		return 1
+	case 2, 5: // MU-BAR, GCR MU-BAR: BAR Control + BAR Information
+		if len(data) < 2 {
+			return 2
+		}
+		control := binary.LittleEndian.Uint16(data[0:2])
+		if (control>>1)&0x0F == 3 { // Multi-TID
+			return 2 + 4*(int(control>>12)+1)
+		}
+		if triggerType == 5 {
+			return 2 + 2 + 6
+		}
+		return 2 + 2
+	default:
+		return 0
+	}
+}
+
+// ruAllocationToString decodes the RU Allocation subfield (B0: primary/secondary 80 MHz, B7-B1: RU index).
+func ruAllocationToString(ru uint8) (string, int) {
+	idx := int(ru >> 1)
+	var name string
+	var tones int
+	switch {
+	case idx <= 36:
+		name, tones = fmt.Sprintf("26-tone RU %d", idx+1), 26
+	case idx <= 52:
+		name, tones = fmt.Sprintf("52-tone RU %d", idx-36), 52
+	case idx <= 60:
+		name, tones = fmt.Sprintf("106-tone RU %d", idx-52), 106
+	case idx <= 64:
+		name, tones = fmt.Sprintf("242-tone RU %d", idx-60), 242
+	case idx <= 66:
+		name, tones = fmt.Sprintf("484-tone RU %d", idx-64), 484
+	case idx == 67:
+		name, tones = "996-tone RU", 996
+	case idx == 68:
+		return "2x996-tone RU", 1992
+	default:
+		return fmt.Sprintf("Reserved RU(%d)", ru), 0
+	}
+	if ru&0x1 != 0 {
+		name += " (secondary 80MHz)"
+	}
+	return name, tones
+}
+
+// parseNDPAFrame decodes the Sounding Dialog Token and STA Info list of an NDP Announcement.
+// Reference: IEEE 802.11ax-2021, 9.3.1.19 and 9.3.1.20.
+func parseNDPAFrame(data []byte) (*NDPAInfo, error) {
+	if len(data) < 1 {
+		return nil, fmt.Errorf("NDPA too short for Sounding Dialog Token")
+	}
+	n := &NDPAInfo{
+		Variant:     [4]string{"VHT", "Ranging", "HE", "EHT"}[data[0]&0x3],
+		DialogToken: data[0] >> 2,
+	}
+	data = data[1:]
+
+	if n.Variant == "VHT" { // 2 octet STA Info fields
+		for len(data) >= 2 {
+			v := binary.LittleEndian.Uint16(data[0:2])
+			target := NDPATarget{AID: v & 0x0FFF, Feedback: "SU", Nc: uint8(v>>13) + 1}
+			if v&0x1000 != 0 {
+				target.Feedback = "MU"
+			}
+			n.Targets = append(n.Targets, target)
+			data = data[2:]
+		}
+		return n, nil
+	}
+
+	// HE/EHT/Ranging: 4 octet STA Info fields
+	for len(data) >= 4 {
+		v := binary.LittleEndian.Uint32(data[0:4])
+		data = data[4:]
+		aid := uint16(v & 0x07FF)
+		if aid == 2047 || aid == 2043 { // Disallowed subchannel bitmap / special STA Info, not a sounding target
+			continue
+		}
+		target := NDPATarget{AID: aid, Nc: uint8(v>>29) + 1}
+		switch (v >> 25) & 0x3 { // Feedback Type And Ng
+		case 0, 1:
+			target.Feedback = "SU"
+		case 2:
+			target.Feedback = "MU"
+		case 3:
+			target.Feedback = "CQI"
+			if v&(1<<28) != 0 { // Codebook Size set: MU with Ng=16
+				target.Feedback = "MU"
+			}
+		}
+		n.Targets = append(n.Targets, target)
+	}
+	return n, nil
+}
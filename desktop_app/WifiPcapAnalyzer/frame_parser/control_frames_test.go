@@ -0,0 +1,131 @@
+package frame_parser
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseControlTestFrame runs a control frame (FC, duration, RA, then body; FCS appended) through ParsePacket.
+func parseControlTestFrame(t *testing.T, hexFrame string) *ParsedFrameInfo {
+	t.Helper()
+	data := append(mustHex(t, hexFrame), 0, 0, 0, 0)
+	packet := gopacket.NewPacket(data, layers.LayerTypeDot11, gopacket.Default)
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+	require.NotNil(t, info.Control)
+	return info
+}
+
+func TestParseControlFrame_CompressedBlockAck(t *testing.T) {
+	// BA from 02:00:00:00:00:02 to 02:00:00:00:00:01, TID 5, SSN 100,
+	// bitmap 0b1011_1111 0b0000_0001: 8 MPDUs acked, 1 hole below the highest acked one.
+	info := parseControlTestFrame(t, "9400 0000 020000000001 020000000002 0450 4006 bf01000000000000")
+
+	assert.Equal(t, "BlockAck", info.Control.Kind)
+	assert.Equal(t, "02:00:00:00:00:02", info.TA.String())
+	ba := info.Control.BlockAck
+	require.NotNil(t, ba)
+	assert.Equal(t, "Compressed", ba.Variant)
+	assert.False(t, ba.Request)
+	require.Len(t, ba.Records, 1)
+	assert.Equal(t, uint8(5), ba.Records[0].TID)
+	assert.Equal(t, uint16(100), ba.Records[0].StartingSequence)
+	assert.Equal(t, 8, ba.Records[0].AckedMPDUs)
+	assert.Equal(t, 1, ba.Records[0].MissingMPDUs)
+}
+
+func TestParseControlFrame_BlockAckReq(t *testing.T) {
+	info := parseControlTestFrame(t, "8400 0000 020000000001 020000000002 0430 1000")
+
+	assert.Equal(t, "BlockAckReq", info.Control.Kind)
+	ba := info.Control.BlockAck
+	require.NotNil(t, ba)
+	assert.True(t, ba.Request)
+	assert.Equal(t, "Compressed", ba.Variant)
+	require.Len(t, ba.Records, 1)
+	assert.Equal(t, uint8(3), ba.Records[0].TID)
+	assert.Equal(t, uint16(1), ba.Records[0].StartingSequence)
+	assert.Nil(t, ba.Records[0].Bitmap)
+}
+
+func TestParseControlFrame_BasicTrigger(t *testing.T) {
+	// Basic trigger, 80MHz UL BW, two users:
+	//   AID 1 on 106-tone RU 1 (index 53), MCS 7, 1 SS
+	//   AID 2 on 106-tone RU 2 (index 54), MCS 5, 2 SS
+	// Each User Info is followed by 1 octet of trigger dependent user info, then padding (AID 4095).
+	user1 := uint64(1) | uint64(53<<1)<<12 | uint64(7)<<21
+	user2 := uint64(2) | uint64(54<<1)<<12 | uint64(5)<<21 | uint64(1)<<29
+	body := []byte{}
+	common := uint64(0) | uint64(100)<<4 | uint64(2)<<18
+	for i := 0; i < 8; i++ {
+		body = append(body, byte(common>>(8*i)))
+	}
+	for _, u := range []uint64{user1, user2} {
+		for i := 0; i < 5; i++ {
+			body = append(body, byte(u>>(8*i)))
+		}
+		body = append(body, 0x00)
+	}
+	body = append(body, 0xff, 0xff)
+
+	frame := append(mustHex(t, "2400 0000 ffffffffffff 020000000002"), body...)
+	packet := gopacket.NewPacket(append(frame, 0, 0, 0, 0), layers.LayerTypeDot11, gopacket.Default)
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+	require.NotNil(t, info.Control)
+
+	assert.Equal(t, "CtrlTrigger", info.FrameType)
+	trig := info.Control.Trigger
+	require.NotNil(t, trig)
+	assert.Equal(t, "Basic", trig.TypeName)
+	assert.Equal(t, uint16(100), trig.ULLength)
+	assert.Equal(t, "80MHz", trig.ULBandwidth)
+	require.Len(t, trig.Users, 2)
+	assert.Equal(t, uint16(1), trig.Users[0].AID)
+	assert.Equal(t, "106-tone RU 1", trig.Users[0].RU)
+	assert.Equal(t, 106, trig.Users[0].RUTones)
+	assert.Equal(t, uint8(7), trig.Users[0].ULMCS)
+	assert.Equal(t, uint8(1), trig.Users[0].NumSS)
+	assert.Equal(t, uint16(2), trig.Users[1].AID)
+	assert.Equal(t, "106-tone RU 2", trig.Users[1].RU)
+	assert.Equal(t, uint8(2), trig.Users[1].NumSS)
+}
+
+func TestParseControlFrame_HENDPA(t *testing.T) {
+	// HE NDPA (token 5), targets AID 1 (SU) and AID 3 (MU, Nc index 1).
+	info := parseControlTestFrame(t, "5400 0000 ffffffffffff 020000000002 16 01000000 03000024")
+
+	assert.Equal(t, "CtrlNDPA", info.FrameType)
+	ndpa := info.Control.NDPA
+	require.NotNil(t, ndpa)
+	assert.Equal(t, "HE", ndpa.Variant)
+	assert.Equal(t, uint8(5), ndpa.DialogToken)
+	require.Len(t, ndpa.Targets, 2)
+	assert.Equal(t, NDPATarget{AID: 1, Feedback: "SU", Nc: 1}, ndpa.Targets[0])
+	assert.Equal(t, NDPATarget{AID: 3, Feedback: "MU", Nc: 2}, ndpa.Targets[1])
+}
+
+func TestRUAllocationToString(t *testing.T) {
+	cases := []struct {
+		ru    uint8
+		name  string
+		tones int
+	}{
+		{0 << 1, "26-tone RU 1", 26},
+		{36 << 1, "26-tone RU 37", 26},
+		{37 << 1, "52-tone RU 1", 52},
+		{61 << 1, "242-tone RU 1", 242},
+		{65<<1 | 1, "484-tone RU 1 (secondary 80MHz)", 484},
+		{67 << 1, "996-tone RU", 996},
+		{68 << 1, "2x996-tone RU", 1992},
+	}
+	for _, tc := range cases {
+		name, tones := ruAllocationToString(tc.ru)
+		assert.Equal(t, tc.name, name)
+		assert.Equal(t, tc.tones, tones)
+	}
+}
@@ -0,0 +1,695 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/config"
+	"WifiPcapAnalyzer/logger"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// DecryptionKey is a user supplied key for a protected network: either a passphrase
+// (WPA-Personal, the PMK is derived from passphrase and SSID) or a PMK (WPA3-SAE, 802.1X).
+// An empty SSID matches any network.
+type DecryptionKey struct {
+	SSID       string
+	Passphrase string
+	PMK        []byte
+}
+
+// Decryptor decrypts CCMP/GCMP protected data frames using user supplied keys.
+// Packets must be fed in capture order: the Decryptor learns SSIDs and RSN settings from
+// Beacons and (Re)Association Requests and derives the PTK/GTK from each STA's 4-way handshake.
+// Supported AKMs are PSK and 802.1X (SHA-1), PSK-SHA256, 802.1X-SHA256 and SAE; TKIP and WEP are not decrypted.
+// BSSs and STAs that have been idle for decryptorIdleTimeout of capture time are forgotten.
+type Decryptor struct {
+	mutex    sync.Mutex
+	keys     []DecryptionKey
+	pmkCache map[string][]byte // Key: SSID + "\x00" + passphrase
+
+	ssids        map[string]string              // Key: BSSID, learned from Beacon/Probe Response
+	groupCiphers map[string]string              // Key: BSSID, group data cipher from the RSN element
+	gtks         map[string]map[uint8]*groupKey // Key: BSSID, then Key ID
+	sessions     map[string]*decryptionSession  // Key: BSSID + "|" + STA MAC
+	bssLastSeen  map[string]time.Time           // Key: BSSID
+
+	now       time.Time // Capture time of the packet being processed
+	lastPrune time.Time
+}
+
+// The Decryptor prunes its state on the same schedule as the app prunes the state manager,
+// measured in capture time so that file replays are pruned too.
+const (
+	decryptorPruneInterval = 30 * time.Second
+	decryptorIdleTimeout   = 2 * time.Minute
+)
+
+// decryptionSession is the key state of one STA in one BSS.
+type decryptionSession struct {
+	akm            string // AKM from the (Re)Association Request, e.g. "PSK", "SAE"
+	pairwiseCipher string // Pairwise cipher from the (Re)Association Request
+	anonce         []byte
+	snonce         []byte
+	ptk            *pairwiseKey
+	prevPTK        *pairwiseKey // Kept during a rekey until the new TK is in use
+	lastSeen       time.Time
+}
+
+type pairwiseKey struct {
+	kck    []byte
+	kek    []byte
+	tk     []byte
+	cipher string
+}
+
+type groupKey struct {
+	key    []byte
+	cipher string
+}
+
+// NewDecryptor returns a Decryptor for the given keys.
+func NewDecryptor(keys []DecryptionKey) *Decryptor {
+	return &Decryptor{
+		keys:         keys,
+		pmkCache:     make(map[string][]byte),
+		ssids:        make(map[string]string),
+		groupCiphers: make(map[string]string),
+		gtks:         make(map[string]map[uint8]*groupKey),
+		sessions:     make(map[string]*decryptionSession),
+		bssLastSeen:  make(map[string]time.Time),
+	}
+}
+
+// newConfiguredDecryptor builds a Decryptor from config.GlobalConfig.Decryption.
+// It returns nil when no keys are configured.
+func newConfiguredDecryptor() *Decryptor {
+	cfg := config.GlobalConfig.Decryption
+	if cfg == nil || len(cfg.Keys) == 0 {
+		return nil
+	}
+	keys := make([]DecryptionKey, 0, len(cfg.Keys))
+	for i, k := range cfg.Keys {
+		key := DecryptionKey{SSID: k.SSID, Passphrase: k.Passphrase}
+		if k.PMK != "" {
+			pmk, err := hex.DecodeString(strings.ReplaceAll(k.PMK, ":", ""))
+			if err != nil || (len(pmk) != 32 && len(pmk) != 48) {
+				logger.Log.Warn().Int("index", i).Str("ssid", k.SSID).Msg("Ignoring decryption key: PMK must be 32 or 48 bytes of hex")
+				continue
+			}
+			key.PMK = pmk
+		} else if len(k.Passphrase) < 8 || len(k.Passphrase) > 63 {
+			logger.Log.Warn().Int("index", i).Str("ssid", k.SSID).Msg("Ignoring decryption key: passphrase must be 8 to 63 characters")
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	logger.Log.Info().Int("keys", len(keys)).Msg("Traffic decryption enabled")
+	return NewDecryptor(keys)
+}
+
+// Process inspects a packet and returns the decrypted packet when it is a protected
+// data frame for which keys are known; otherwise it returns the packet unchanged and false.
+func (d *Decryptor) Process(packet gopacket.Packet) (gopacket.Packet, bool) {
+	dot11, ok := packet.Layer(layers.LayerTypeDot11).(*layers.Dot11)
+	if !ok {
+		return packet, false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if ts := packet.Metadata().Timestamp; ts.After(d.now) {
+		d.now = ts
+		if d.lastPrune.IsZero() {
+			d.lastPrune = ts
+		} else if d.now.Sub(d.lastPrune) >= decryptorPruneInterval {
+			d.prune(decryptorIdleTimeout)
+			d.lastPrune = d.now
+		}
+	}
+
+	switch dot11.Type.MainType() {
+	case layers.Dot11TypeMgmt:
+		d.learnFromMgmt(dot11)
+		return packet, false
+	case layers.Dot11TypeData:
+	default:
+		return packet, false
+	}
+	if bssid, sta, ok := frameBSSIDAndSTA(dot11); ok {
+		d.bssLastSeen[bssid.String()] = d.now
+		if s := d.sessions[sessionKey(bssid, sta)]; s != nil {
+			s.lastSeen = d.now
+		}
+	}
+
+	decrypted := false
+	if dot11.Flags.WEP() {
+		out, err := d.decryptDataFrame(packet, dot11)
+		if err != nil {
+			logger.Log.Debug().Err(err).Str("ta", dot11.Address2.String()).Msg("Data frame not decrypted")
+			return packet, false
+		}
+		packet = out
+		decrypted = true
+		if dot11, ok = packet.Layer(layers.LayerTypeDot11).(*layers.Dot11); !ok {
+			return packet, decrypted
+		}
+	}
+	if eapol, ok := packet.Layer(layers.LayerTypeEAPOL).(*layers.EAPOL); ok && eapol.Type == layers.EAPOLTypeKey {
+		d.handleEAPOLKey(dot11, eapol)
+	}
+	return packet, decrypted
+}
+
+// prune forgets the keys and settings of BSSs and STAs not seen within timeout of the
+// newest packet.
+func (d *Decryptor) prune(timeout time.Duration) {
+	for key, s := range d.sessions {
+		if d.now.Sub(s.lastSeen) > timeout {
+			delete(d.sessions, key)
+		}
+	}
+	for bssid, lastSeen := range d.bssLastSeen {
+		if d.now.Sub(lastSeen) > timeout {
+			delete(d.ssids, bssid)
+			delete(d.groupCiphers, bssid)
+			delete(d.gtks, bssid)
+			delete(d.bssLastSeen, bssid)
+		}
+	}
+}
+
+// frameBSSIDAndSTA returns the BSSID and STA address of a frame exchanged between an AP and a STA.
+func frameBSSIDAndSTA(dot11 *layers.Dot11) (net.HardwareAddr, net.HardwareAddr, bool) {
+	toDS, fromDS := dot11.Flags.ToDS(), dot11.Flags.FromDS()
+	switch {
+	case fromDS && !toDS:
+		return dot11.Address2, dot11.Address1, true
+	case toDS && !fromDS:
+		return dot11.Address1, dot11.Address2, true
+	case !toDS && !fromDS:
+		if dot11.Address2.String() == dot11.Address3.String() {
+			return dot11.Address3, dot11.Address1, true
+		}
+		return dot11.Address3, dot11.Address2, true
+	}
+	return nil, nil, false
+}
+
+func sessionKey(bssid, sta net.HardwareAddr) string {
+	return bssid.String() + "|" + sta.String()
+}
+
+func (d *Decryptor) session(bssid, sta net.HardwareAddr) *decryptionSession {
+	key := sessionKey(bssid, sta)
+	s, ok := d.sessions[key]
+	if !ok {
+		s = &decryptionSession{}
+		d.sessions[key] = s
+	}
+	s.lastSeen = d.now
+	return s
+}
+
+// learnFromMgmt records the SSID and group cipher of a BSS, and the AKM and pairwise
+// cipher a STA selected in its (Re)Association Request.
+func (d *Decryptor) learnFromMgmt(dot11 *layers.Dot11) {
+	var ies []byte
+	body := dot11.Payload
+	switch dot11.Type {
+	case layers.Dot11TypeMgmtBeacon, layers.Dot11TypeMgmtProbeResp:
+		if len(body) >= 12 {
+			ies = body[12:]
+		}
+	case layers.Dot11TypeMgmtAssociationReq:
+		if len(body) >= 4 {
+			ies = body[4:]
+		}
+	case layers.Dot11TypeMgmtReassociationReq:
+		if len(body) >= 10 {
+			ies = body[10:]
+		}
+	default:
+		return
+	}
+
+	var ssid string
+	var rsn *SecurityProfile
+	for offset := 0; offset+2 <= len(ies); {
+		id, length := ies[offset], int(ies[offset+1])
+		if offset+2+length > len(ies) {
+			break
+		}
+		data := ies[offset+2 : offset+2+length]
+		switch id {
+		case 0:
+			ssid = string(data)
+		case 48:
+			rsn, _ = parseRSNElement(data)
+		}
+		offset += 2 + length
+	}
+
+	bssid := dot11.Address3.String()
+	d.bssLastSeen[bssid] = d.now
+	if dot11.Type == layers.Dot11TypeMgmtBeacon || dot11.Type == layers.Dot11TypeMgmtProbeResp {
+		if ssid != "" {
+			d.ssids[bssid] = ssid
+		}
+		if rsn != nil {
+			d.groupCiphers[bssid] = rsn.GroupCipher
+		}
+		return
+	}
+	if ssid != "" {
+		if _, known := d.ssids[bssid]; !known {
+			d.ssids[bssid] = ssid
+		}
+	}
+	if rsn != nil && len(rsn.AKMs) > 0 && len(rsn.PairwiseCiphers) > 0 {
+		s := d.session(dot11.Address3, dot11.Address2)
+		s.akm = rsn.AKMs[0]
+		s.pairwiseCipher = rsn.PairwiseCiphers[0]
+	}
+}
+
+// EAPOL-Key frame layout (IEEE 802.11-2020, 12.7.2), offsets within the EAPOL-Key body
+// for a 16 octet MIC.
+const (
+	eapolKeyNonceOffset   = 13
+	eapolKeyMICOffset     = 77
+	eapolKeyDataLenOffset = 93
+	eapolKeyDataOffset    = 95
+)
+
+// handleEAPOLKey follows the 4-way and group key handshakes of a STA.
+func (d *Decryptor) handleEAPOLKey(dot11 *layers.Dot11, eapol *layers.EAPOL) {
+	bssid, sta, ok := frameBSSIDAndSTA(dot11)
+	if !ok {
+		return
+	}
+	// MIC is computed over the whole EAPOL PDU (header and body, without padding).
+	raw := append(append([]byte(nil), eapol.Contents...), eapol.Payload...)
+	if int(eapol.Length)+4 <= len(raw) {
+		raw = raw[:int(eapol.Length)+4]
+	}
+	if len(raw) < 4+eapolKeyDataOffset {
+		return
+	}
+	body := raw[4:]
+	keyInfo := binary.BigEndian.Uint16(body[1:3])
+	key := &EAPOLKeyInfo{
+		DescriptorVersion: uint8(keyInfo & 0x07),
+		Pairwise:          keyInfo&0x0008 != 0,
+		Install:           keyInfo&0x0040 != 0,
+		Ack:               keyInfo&0x0080 != 0,
+		MIC:               keyInfo&0x0100 != 0,
+		Secure:            keyInfo&0x0200 != 0,
+		Request:           keyInfo&0x0800 != 0,
+		EncryptedKeyData:  keyInfo&0x1000 != 0,
+	}
+	nonce := body[eapolKeyNonceOffset : eapolKeyNonceOffset+32]
+	keyDataLen := int(binary.BigEndian.Uint16(body[eapolKeyDataLenOffset : eapolKeyDataLenOffset+2]))
+	var keyData []byte
+	if eapolKeyDataOffset+keyDataLen <= len(body) {
+		keyData = body[eapolKeyDataOffset : eapolKeyDataOffset+keyDataLen]
+	}
+	message, group := eapolKeyMessage(key, nonce)
+	s := d.session(bssid, sta)
+
+	if group {
+		if message == 1 && s.ptk != nil && key.EncryptedKeyData {
+			d.installGTK(bssid, s.ptk, keyData)
+		}
+		return
+	}
+	switch message {
+	case 1:
+		s.anonce = append([]byte(nil), nonce...)
+	case 2:
+		s.snonce = append([]byte(nil), nonce...)
+		if s.anonce != nil {
+			d.derivePairwiseKey(bssid, sta, s, key.DescriptorVersion, raw)
+		}
+	case 3:
+		if s.anonce == nil || !bytes.Equal(s.anonce, nonce) {
+			s.anonce = append([]byte(nil), nonce...)
+			if s.snonce != nil {
+				d.derivePairwiseKey(bssid, sta, s, key.DescriptorVersion, raw)
+			}
+		}
+		if s.ptk != nil && key.EncryptedKeyData {
+			d.installGTK(bssid, s.ptk, keyData)
+		}
+	}
+}
+
+// derivePairwiseKey tries every candidate PMK for the BSS and installs the PTK whose
+// KCK verifies the MIC of the given EAPOL-Key frame (M2 or M3).
+func (d *Decryptor) derivePairwiseKey(bssid, sta net.HardwareAddr, s *decryptionSession, descriptorVersion uint8, eapolFrame []byte) {
+	akm := s.akm
+	if akm == "" {
+		// Version 2 implies a SHA-1 based AKM and version 3 a SHA-256 based one. Version 0 means
+		// the AKM defines the algorithms, so without the (Re)Association Request it is unknown.
+		switch descriptorVersion {
+		case 2:
+			akm = "PSK"
+		case 3:
+			akm = "WPA-SHA256-PSK"
+		default:
+			logger.Log.Debug().Str("bssid", bssid.String()).Str("sta", sta.String()).Uint8("descriptor_version", descriptorVersion).
+				Msg("AKM unknown: no (Re)Association Request seen for the 4-way handshake")
+			return
+		}
+	}
+	cipherName := s.pairwiseCipher
+	if cipherName == "" {
+		cipherName = "CCMP-128"
+	}
+	tkLen := cipherKeyLength(cipherName)
+	if tkLen == 0 {
+		logger.Log.Debug().Str("cipher", cipherName).Msg("Pairwise cipher not supported for decryption")
+		return
+	}
+
+	micData := append([]byte(nil), eapolFrame...)
+	receivedMIC := append([]byte(nil), micData[4+eapolKeyMICOffset:4+eapolKeyMICOffset+16]...)
+	for i := range micData[4+eapolKeyMICOffset : 4+eapolKeyMICOffset+16] {
+		micData[4+eapolKeyMICOffset+i] = 0
+	}
+
+	for _, pmk := range d.candidatePMKs(bssid.String()) {
+		ptk := derivePTK(akm, pmk, bssid, sta, s.anonce, s.snonce, tkLen)
+		if ptk == nil {
+			return
+		}
+		var mic []byte
+		switch akm {
+		case "PSK", "802.1X":
+			mic = hmacSHA1MIC(ptk.kck, micData)
+		default:
+			mic, _ = aesCMAC(ptk.kck, micData)
+		}
+		if !bytes.Equal(mic, receivedMIC) {
+			continue
+		}
+		ptk.cipher = cipherName
+		if s.ptk != nil && !bytes.Equal(s.ptk.tk, ptk.tk) {
+			s.prevPTK = s.ptk
+		}
+		s.ptk = ptk
+		logger.Log.Info().Str("bssid", bssid.String()).Str("sta", sta.String()).Str("akm", akm).Msg("Derived PTK from 4-way handshake")
+		return
+	}
+	logger.Log.Debug().Str("bssid", bssid.String()).Str("sta", sta.String()).Msg("No configured key matches the 4-way handshake MIC")
+}
+
+// candidatePMKs returns the PMKs of all keys that may belong to the BSS.
+func (d *Decryptor) candidatePMKs(bssid string) [][]byte {
+	ssid := d.ssids[bssid]
+	var pmks [][]byte
+	for _, k := range d.keys {
+		if k.SSID != "" && ssid != "" && k.SSID != ssid {
+			continue
+		}
+		if k.PMK != nil {
+			pmks = append(pmks, k.PMK)
+			continue
+		}
+		keySSID := k.SSID
+		if keySSID == "" {
+			keySSID = ssid
+		}
+		if keySSID == "" {
+			continue
+		}
+		cacheKey := keySSID + "\x00" + k.Passphrase
+		pmk, ok := d.pmkCache[cacheKey]
+		if !ok {
+			pmk = pbkdf2SHA1([]byte(k.Passphrase), []byte(keySSID), 4096, 32)
+			d.pmkCache[cacheKey] = pmk
+		}
+		pmks = append(pmks, pmk)
+	}
+	return pmks
+}
+
+// derivePTK computes KCK, KEK and TK (IEEE 802.11-2020, 12.7.1.3).
+func derivePTK(akm string, pmk []byte, aa, spa net.HardwareAddr, anonce, snonce []byte, tkLen int) *pairwiseKey {
+	data := make([]byte, 0, 76)
+	if bytes.Compare(aa, spa) < 0 {
+		data = append(append(data, aa...), spa...)
+	} else {
+		data = append(append(data, spa...), aa...)
+	}
+	if bytes.Compare(anonce, snonce) < 0 {
+		data = append(append(data, anonce...), snonce...)
+	} else {
+		data = append(append(data, snonce...), anonce...)
+	}
+
+	bits := (16 + 16 + tkLen) * 8
+	var ptk []byte
+	switch akm {
+	case "PSK", "802.1X":
+		if len(pmk) < 32 {
+			return nil
+		}
+		ptk = prfSHA1(pmk[:32], "Pairwise key expansion", data, bits)
+	case "WPA-SHA256-PSK", "WPA-SHA256-802.1X", "SAE":
+		if len(pmk) < 32 {
+			return nil
+		}
+		ptk = kdfSHA256(pmk[:32], "Pairwise key expansion", data, bits)
+	default:
+		logger.Log.Debug().Str("akm", akm).Msg("AKM not supported for decryption")
+		return nil
+	}
+	return &pairwiseKey{kck: ptk[:16], kek: ptk[16:32], tk: ptk[32:]}
+}
+
+// installGTK unwraps the Key Data of M3 or a group key M1 and stores the GTK KDE.
+func (d *Decryptor) installGTK(bssid net.HardwareAddr, ptk *pairwiseKey, keyData []byte) {
+	plain, err := aesKeyUnwrap(ptk.kek, keyData)
+	if err != nil {
+		logger.Log.Debug().Err(err).Str("bssid", bssid.String()).Msg("Failed to unwrap EAPOL-Key data")
+		return
+	}
+	for offset := 0; offset+2 <= len(plain); {
+		id, length := plain[offset], int(plain[offset+1])
+		if id == 0xdd && length == 0 {
+			break // Padding
+		}
+		if offset+2+length > len(plain) {
+			break
+		}
+		kde := plain[offset+2 : offset+2+length]
+		// GTK KDE: OUI 00-0F-AC, data type 1, Key ID/Tx octet, reserved octet, GTK
+		if id == 0xdd && length >= 6 && kde[0] == 0x00 && kde[1] == 0x0F && kde[2] == 0xAC && kde[3] == 1 {
+			keyID := kde[4] & 0x03
+			cipherName := d.groupCiphers[bssid.String()]
+			if cipherName == "" {
+				cipherName = "CCMP-128"
+			}
+			if d.gtks[bssid.String()] == nil {
+				d.gtks[bssid.String()] = make(map[uint8]*groupKey)
+			}
+			d.gtks[bssid.String()][keyID] = &groupKey{key: append([]byte(nil), kde[6:]...), cipher: cipherName}
+			logger.Log.Info().Str("bssid", bssid.String()).Uint8("key_id", keyID).Msg("Installed GTK")
+		}
+		offset += 2 + length
+	}
+}
+
+func cipherKeyLength(cipherName string) int {
+	switch cipherName {
+	case "CCMP-128", "GCMP-128":
+		return 16
+	case "CCMP-256", "GCMP-256":
+		return 32
+	}
+	return 0
+}
+
+// decryptDataFrame decrypts a CCMP/GCMP protected data frame and re-decodes the result,
+// keeping the original link layer header (e.g. Radiotap) and capture metadata.
+func (d *Decryptor) decryptDataFrame(packet gopacket.Packet, dot11 *layers.Dot11) (gopacket.Packet, error) {
+	bssid, sta, ok := frameBSSIDAndSTA(dot11)
+	if !ok {
+		return nil, fmt.Errorf("frame is not between an AP and a STA")
+	}
+	body := dot11.Payload
+	if len(body) < 8 || body[3]&0x20 == 0 {
+		return nil, fmt.Errorf("no CCMP/GCMP header (ExtIV not set)")
+	}
+	keyID := body[3] >> 6
+
+	var tk []byte
+	var cipherName string
+	var fallback *pairwiseKey
+	if dot11.Address1[0]&0x01 != 0 {
+		gk := d.gtks[bssid.String()][keyID]
+		if gk == nil {
+			return nil, fmt.Errorf("no GTK for key ID %d", keyID)
+		}
+		tk, cipherName = gk.key, gk.cipher
+	} else {
+		s := d.sessions[sessionKey(bssid, sta)]
+		if s == nil || s.ptk == nil {
+			return nil, fmt.Errorf("no PTK for STA")
+		}
+		tk, cipherName, fallback = s.ptk.tk, s.ptk.cipher, s.prevPTK
+	}
+
+	plaintext, err := decryptMPDU(dot11.Contents, body, tk, cipherName)
+	if err != nil && fallback != nil {
+		plaintext, err = decryptMPDU(dot11.Contents, body, fallback.tk, fallback.cipher)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rebuildPacket(packet, dot11, plaintext)
+}
+
+// decryptMPDU decrypts the frame body following the MAC header (IEEE 802.11-2020, 12.5.3 and 12.5.5).
+func decryptMPDU(header, body, tk []byte, cipherName string) ([]byte, error) {
+	if len(header) < 24 {
+		return nil, fmt.Errorf("MAC header too short")
+	}
+	fc0, fc1 := header[0], header[1]
+	hasA4 := fc1&0x03 == 0x03
+	isQoS := fc0&0x80 != 0
+	a2 := header[10:16]
+
+	// AAD: masked Frame Control, A1-A3, masked Sequence Control, A4, masked QoS Control
+	aad := make([]byte, 0, 32)
+	aad = append(aad, fc0&0x8f, (fc1&^0x38)|0x40)
+	if isQoS {
+		aad[1] &^= 0x80
+	}
+	aad = append(aad, header[4:22]...)
+	aad = append(aad, header[22]&0x0f, 0)
+	qcOffset := 24
+	if hasA4 {
+		if len(header) < 30 {
+			return nil, fmt.Errorf("MAC header too short for A4")
+		}
+		aad = append(aad, header[24:30]...)
+		qcOffset = 30
+	}
+	var priority byte
+	if isQoS {
+		if len(header) < qcOffset+2 {
+			return nil, fmt.Errorf("MAC header too short for QoS Control")
+		}
+		priority = header[qcOffset] & 0x0f
+		aad = append(aad, priority, 0)
+	}
+
+	pn := []byte{body[7], body[6], body[5], body[4], body[1], body[0]}
+	block, err := aes.NewCipher(tk)
+	if err != nil {
+		return nil, err
+	}
+	payload := body[8:]
+	switch cipherName {
+	case "CCMP-128", "CCMP-256":
+		micLen := 8
+		if cipherName == "CCMP-256" {
+			micLen = 16
+		}
+		nonce := append(append([]byte{priority}, a2...), pn...)
+		return ccmOpen(block, nonce, aad, payload, micLen)
+	case "GCMP-128", "GCMP-256":
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		nonce := append(append([]byte(nil), a2...), pn...)
+		return gcm.Open(nil, nonce, payload, aad)
+	}
+	return nil, fmt.Errorf("cipher %s not supported", cipherName)
+}
+
+// rebuildPacket decodes a new packet from the original link layer header, the MAC header
+// with the Protected bit cleared and the decrypted body.
+func rebuildPacket(packet gopacket.Packet, dot11 *layers.Dot11, plaintext []byte) (gopacket.Packet, error) {
+	var prefix []byte
+	var first gopacket.LayerType
+	appendFCS := true
+	for _, l := range packet.Layers() {
+		if first == gopacket.LayerTypeZero {
+			first = l.LayerType()
+		}
+		if l.LayerType() == layers.LayerTypeDot11 {
+			break
+		}
+		prefix = append(prefix, l.LayerContents()...)
+		if rt, ok := l.(*layers.RadioTap); ok {
+			// Radiotap adds an FCS itself when the Flags field says there is none, and strips
+			// driver padding; the rebuilt frame has no padding and always carries an FCS.
+			if rt.Present.Flags() {
+				if off := radiotapFlagsOffset(prefix); off > 0 && off < len(prefix) {
+					prefix[off] = (prefix[off] | byte(layers.RadioTapFlagsFCS)) &^ byte(layers.RadioTapFlagsDatapad)
+				}
+			} else {
+				appendFCS = false
+			}
+		}
+	}
+
+	frame := make([]byte, 0, len(dot11.Contents)+len(plaintext)+4)
+	frame = append(frame, dot11.Contents...)
+	frame[1] &^= 0x40 // Protected
+	frame = append(frame, plaintext...)
+	if appendFCS {
+		var fcs [4]byte
+		binary.LittleEndian.PutUint32(fcs[:], crc32.ChecksumIEEE(frame))
+		frame = append(frame, fcs[:]...)
+	}
+
+	out := gopacket.NewPacket(append(prefix, frame...), first, gopacket.Default)
+	out.Metadata().CaptureInfo = packet.Metadata().CaptureInfo
+	if out.Layer(layers.LayerTypeDot11) == nil {
+		if errLayer := out.ErrorLayer(); errLayer != nil {
+			return nil, fmt.Errorf("decrypted frame does not decode: %v", errLayer.Error())
+		}
+		return nil, fmt.Errorf("decrypted frame does not decode")
+	}
+	return out, nil
+}
+
+// radiotapFlagsOffset returns the offset of the Flags field in a Radiotap header, or 0.
+// Flags follows the (8-byte aligned) TSFT field, after all presence bitmaps.
+func radiotapFlagsOffset(header []byte) int {
+	if len(header) < 8 {
+		return 0
+	}
+	present := binary.LittleEndian.Uint32(header[4:8])
+	offset := 8
+	for word := present; word&(1<<31) != 0; {
+		if offset+4 > len(header) {
+			return 0
+		}
+		word = binary.LittleEndian.Uint32(header[offset : offset+4])
+		offset += 4
+	}
+	if present&0x01 != 0 { // TSFT
+		offset = (offset+7)&^7 + 8
+	}
+	return offset
+}
@@ -0,0 +1,277 @@
+package frame_parser
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+// Key derivation and cipher primitives used by the Decryptor.
+// Only the standard library is used; CCM and AES Key Wrap are not provided by it.
+
+// pbkdf2SHA1 derives the PSK from a passphrase and SSID (IEEE 802.11-2020, J.4.1):
+// PMK = PBKDF2(HMAC-SHA1, passphrase, ssid, 4096, 256 bits).
+func pbkdf2SHA1(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	out := make([]byte, 0, numBlocks*hashLen)
+	var buf [4]byte
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:])
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
+
+// prfSHA1 is the PRF-n function of IEEE 802.11-2020, 12.7.1.2, used by the SHA-1 AKMs.
+func prfSHA1(key []byte, label string, data []byte, bits int) []byte {
+	n := (bits + 7) / 8
+	out := make([]byte, 0, n+sha1.Size)
+	mac := hmac.New(sha1.New, key)
+	for i := 0; len(out) < n; i++ {
+		mac.Reset()
+		mac.Write([]byte(label))
+		mac.Write([]byte{0})
+		mac.Write(data)
+		mac.Write([]byte{byte(i)})
+		out = mac.Sum(out)
+	}
+	return out[:n]
+}
+
+// kdfHash is the KDF-Hash-Length function of IEEE 802.11-2020, 12.7.1.6.2, used by the SHA-256 AKMs.
+func kdfHash(newHash func() hash.Hash, key []byte, label string, context []byte, bits int) []byte {
+	n := (bits + 7) / 8
+	mac := hmac.New(newHash, key)
+	out := make([]byte, 0, n+mac.Size())
+	var counter, length [2]byte
+	binary.LittleEndian.PutUint16(length[:], uint16(bits))
+	for i := 1; len(out) < n; i++ {
+		binary.LittleEndian.PutUint16(counter[:], uint16(i))
+		mac.Reset()
+		mac.Write(counter[:])
+		mac.Write([]byte(label))
+		mac.Write(context)
+		mac.Write(length[:])
+		out = mac.Sum(out)
+	}
+	return out[:n]
+}
+
+// kdfSHA256 is kdfHash with SHA-256.
+func kdfSHA256(key []byte, label string, context []byte, bits int) []byte {
+	return kdfHash(sha256.New, key, label, context, bits)
+}
+
+// hmacSHA1MIC computes the HMAC-SHA1-128 EAPOL-Key MIC (key descriptor version 2).
+func hmacSHA1MIC(kck, data []byte) []byte {
+	mac := hmac.New(sha1.New, kck)
+	mac.Write(data)
+	return mac.Sum(nil)[:16]
+}
+
+// aesCMAC computes AES-128-CMAC (RFC 4493), the EAPOL-Key MIC of key descriptor version 3
+// and of the SHA-256 based AKMs.
+func aesCMAC(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	const bs = aes.BlockSize
+	k1, k2 := cmacSubkeys(block)
+
+	n := (len(data) + bs - 1) / bs
+	complete := n > 0 && len(data)%bs == 0
+	if n == 0 {
+		n = 1
+	}
+	last := make([]byte, bs)
+	if complete {
+		copy(last, data[(n-1)*bs:])
+		xorBytes(last, k1)
+	} else {
+		rem := data[(n-1)*bs:]
+		copy(last, rem)
+		last[len(rem)] = 0x80
+		xorBytes(last, k2)
+	}
+
+	x := make([]byte, bs)
+	for i := 0; i < n-1; i++ {
+		xorBytes(x, data[i*bs:(i+1)*bs])
+		block.Encrypt(x, x)
+	}
+	xorBytes(x, last)
+	block.Encrypt(x, x)
+	return x, nil
+}
+
+func cmacSubkeys(block cipher.Block) ([]byte, []byte) {
+	l := make([]byte, aes.BlockSize)
+	block.Encrypt(l, l)
+	k1 := cmacShift(l)
+	k2 := cmacShift(k1)
+	return k1, k2
+}
+
+// cmacShift doubles a block in GF(2^128) as defined for CMAC subkey generation.
+func cmacShift(in []byte) []byte {
+	out := make([]byte, len(in))
+	var carry byte
+	for i := len(in) - 1; i >= 0; i-- {
+		out[i] = in[i]<<1 | carry
+		carry = in[i] >> 7
+	}
+	if in[0]&0x80 != 0 {
+		out[len(out)-1] ^= 0x87
+	}
+	return out
+}
+
+// aesKeyUnwrap implements the AES Key Unwrap algorithm (RFC 3394) used to protect
+// the Key Data field of EAPOL-Key frames.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errors.New("wrapped key data length must be a multiple of 8 and at least 24")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, n*8)
+	copy(r, wrapped[8:])
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+	for _, b := range a {
+		if b != 0xA6 {
+			return nil, errors.New("key unwrap integrity check failed")
+		}
+	}
+	return r, nil
+}
+
+// ccmOpen decrypts and authenticates an AES-CCM message with a 2-octet length field (L=2),
+// as used by CCMP (RFC 3610, IEEE 802.11-2020 12.5.3). ciphertext includes the MIC of micLen octets.
+func ccmOpen(block cipher.Block, nonce, aad, ciphertext []byte, micLen int) ([]byte, error) {
+	if len(nonce) != 13 {
+		return nil, errors.New("CCM nonce must be 13 octets")
+	}
+	if len(ciphertext) < micLen {
+		return nil, errors.New("CCM ciphertext shorter than MIC")
+	}
+	msgLen := len(ciphertext) - micLen
+	plaintext := make([]byte, msgLen)
+	ccmCTR(block, nonce, ciphertext[:msgLen], plaintext)
+
+	tag := ccmCBCMAC(block, nonce, aad, plaintext, micLen)
+	s0 := ccmCounterBlock(block, nonce, 0)
+	xorBytes(tag, s0[:micLen])
+	if subtle.ConstantTimeCompare(tag, ciphertext[msgLen:]) != 1 {
+		return nil, errors.New("CCM MIC mismatch")
+	}
+	return plaintext, nil
+}
+
+// ccmSeal encrypts and authenticates a message with AES-CCM (L=2) and appends the MIC.
+func ccmSeal(block cipher.Block, nonce, aad, plaintext []byte, micLen int) []byte {
+	tag := ccmCBCMAC(block, nonce, aad, plaintext, micLen)
+	s0 := ccmCounterBlock(block, nonce, 0)
+	xorBytes(tag, s0[:micLen])
+	out := make([]byte, len(plaintext), len(plaintext)+micLen)
+	ccmCTR(block, nonce, plaintext, out)
+	return append(out, tag...)
+}
+
+func ccmCounterBlock(block cipher.Block, nonce []byte, counter uint16) []byte {
+	a := make([]byte, aes.BlockSize)
+	a[0] = 1 // L-1
+	copy(a[1:14], nonce)
+	binary.BigEndian.PutUint16(a[14:], counter)
+	block.Encrypt(a, a)
+	return a
+}
+
+func ccmCTR(block cipher.Block, nonce, in, out []byte) {
+	for i := 0; i*aes.BlockSize < len(in); i++ {
+		s := ccmCounterBlock(block, nonce, uint16(i+1))
+		end := (i + 1) * aes.BlockSize
+		if end > len(in) {
+			end = len(in)
+		}
+		for j := i * aes.BlockSize; j < end; j++ {
+			out[j] = in[j] ^ s[j-i*aes.BlockSize]
+		}
+	}
+}
+
+func ccmCBCMAC(block cipher.Block, nonce, aad, plaintext []byte, micLen int) []byte {
+	const bs = aes.BlockSize
+	x := make([]byte, bs)
+	x[0] = byte((micLen-2)/2)<<3 | 1 // M' and L' (L=2)
+	if len(aad) > 0 {
+		x[0] |= 0x40
+	}
+	copy(x[1:14], nonce)
+	binary.BigEndian.PutUint16(x[14:], uint16(len(plaintext)))
+	block.Encrypt(x, x)
+
+	mac := func(data []byte) {
+		for i := 0; i < len(data); i += bs {
+			end := i + bs
+			if end > len(data) {
+				end = len(data)
+			}
+			xorBytes(x, data[i:end])
+			block.Encrypt(x, x)
+		}
+	}
+	if len(aad) > 0 {
+		encoded := make([]byte, 2+len(aad))
+		binary.BigEndian.PutUint16(encoded, uint16(len(aad)))
+		copy(encoded[2:], aad)
+		mac(encoded)
+	}
+	mac(plaintext)
+	return x[:micLen]
+}
+
+// xorBytes XORs src into dst (up to the shorter length).
+func xorBytes(dst, src []byte) {
+	for i := 0; i < len(dst) && i < len(src); i++ {
+		dst[i] ^= src[i]
+	}
+}
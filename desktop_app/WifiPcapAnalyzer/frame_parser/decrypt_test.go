@@ -0,0 +1,388 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/config"
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPBKDF2SHA1_PSKVectors(t *testing.T) {
+	// IEEE 802.11-2020, J.4.2
+	assert.Equal(t,
+		mustHex(t, "f42c6fc52df0ebef9ebb4b90b38a5f902e83fe1b135a70e23aed762e9710a12e"),
+		pbkdf2SHA1([]byte("password"), []byte("IEEE"), 4096, 32))
+	assert.Equal(t,
+		mustHex(t, "0dc0d6eb90555ed6419756b9a15ec3e3209b63df707dd508d14581f8982721af"),
+		pbkdf2SHA1([]byte("ThisIsAPassword"), []byte("ThisIsASSID"), 4096, 32))
+}
+
+func TestPRFSHA1(t *testing.T) {
+	// IEEE 802.11-2020, J.3.2 (test case 1)
+	key := mustHex(t, "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	expected := mustHex(t, "bcd4c650b30b9684951829e0d75f9d54b862175ed9f00606e17d8da35402ffee"+
+		"75df78c3d31e0f889f012120c0862beb67753e7439ae242edb8373698356cf5a")
+	assert.Equal(t, expected, prfSHA1(key, "prefix", []byte("Hi There"), 512))
+}
+
+func TestAESCMAC(t *testing.T) {
+	// RFC 4493, section 4
+	key := mustHex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	cases := []struct{ msg, mac string }{
+		{"", "bb1d6929e95937287fa37d129b756746"},
+		{"6bc1bee22e409f96e93d7e117393172a", "070a16b46b4d4144f79bdd9dd04a287c"},
+		{"6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411", "dfa66747de9ae63030ca32611497c827"},
+	}
+	for _, tc := range cases {
+		mac, err := aesCMAC(key, mustHex(t, tc.msg))
+		require.NoError(t, err)
+		assert.Equal(t, mustHex(t, tc.mac), mac)
+	}
+}
+
+func TestAESKeyUnwrap(t *testing.T) {
+	// RFC 3394, section 4.1
+	kek := mustHex(t, "000102030405060708090a0b0c0d0e0f")
+	plain, err := aesKeyUnwrap(kek, mustHex(t, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5"))
+	require.NoError(t, err)
+	assert.Equal(t, mustHex(t, "00112233445566778899aabbccddeeff"), plain)
+
+	_, err = aesKeyUnwrap(kek, mustHex(t, "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe6"))
+	assert.Error(t, err)
+}
+
+func TestDecryptMPDU_CCMPVector(t *testing.T) {
+	// IEEE 802.11-2020, J.6.4 (CCMP test vector)
+	tk := mustHex(t, "c97c1f67ce371185514a8a19f2bdd52f")
+	header := mustHex(t, "0848c32c0fd2e128a57c5030f1844408abaea5b8fcba8033")
+	encrypted := mustHex(t, "0ce70020769703b5 f3d0a2fe9a3dbf2342a643e43246e80c3c04d019 7845ce0b16f97623")
+	plaintext, err := decryptMPDU(header, encrypted, tk, "CCMP-128")
+	require.NoError(t, err)
+	assert.Equal(t, mustHex(t, "f8ba1a55d02f85ae967bb62fb6cda8eb7e78a050"), plaintext)
+
+	encrypted[len(encrypted)-1] ^= 0x01
+	_, err = decryptMPDU(header, encrypted, tk, "CCMP-128")
+	assert.Error(t, err)
+}
+
+// aesKeyWrap is the inverse of aesKeyUnwrap (RFC 3394), used to build test handshakes.
+func aesKeyWrap(t testing.TB, kek, plain []byte) []byte {
+	block, err := aes.NewCipher(kek)
+	require.NoError(t, err)
+	n := len(plain) / 8
+	a := []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+	r := append([]byte(nil), plain...)
+	buf := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, a)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Encrypt(buf, buf)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^uint64(n*j+i))
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+	return append(a, r...)
+}
+
+// eapolKeyFrame builds an LLC/SNAP encapsulated EAPOL-Key frame body.
+func eapolKeyFrame(keyInfo uint16, replay uint64, nonce, keyData []byte) []byte {
+	body := make([]byte, eapolKeyDataOffset, eapolKeyDataOffset+len(keyData))
+	body[0] = 2 // RSN key descriptor
+	binary.BigEndian.PutUint16(body[1:3], keyInfo)
+	binary.BigEndian.PutUint16(body[3:5], 16)
+	binary.BigEndian.PutUint64(body[5:13], replay)
+	copy(body[eapolKeyNonceOffset:], nonce)
+	binary.BigEndian.PutUint16(body[eapolKeyDataLenOffset:], uint16(len(keyData)))
+	body = append(body, keyData...)
+
+	eapol := []byte{0x02, 0x03, 0, 0}
+	binary.BigEndian.PutUint16(eapol[2:4], uint16(len(body)))
+	eapol = append(eapol, body...)
+	return append([]byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00, 0x88, 0x8e}, eapol...)
+}
+
+// setEAPOLMIC fills in the MIC of an EAPOL-Key frame built by eapolKeyFrame.
+func setEAPOLMIC(frame, kck []byte) {
+	eapol := frame[8:]
+	copy(eapol[4+eapolKeyMICOffset:], hmacSHA1MIC(kck, eapol))
+}
+
+func dataFrame(fc1 byte, a1, a2, a3 net.HardwareAddr, body []byte) []byte {
+	frame := append([]byte{0x08, fc1, 0, 0}, a1...)
+	frame = append(append(append(frame, a2...), a3...), 0x10, 0x00)
+	return append(append(frame, body...), 0, 0, 0, 0)
+}
+
+// wpa2Exchange is a WPA2-Personal (PSK, CCMP) BSS with one STA: a Beacon, the 4-way
+// handshake and helpers to build protected data frames.
+type wpa2Exchange struct {
+	ap, sta    net.HardwareAddr
+	ssid       string
+	passphrase string
+	ptk        *pairwiseKey
+	gtk        []byte
+	frames     [][]byte // Beacon, then M1 to M4
+}
+
+func newWPA2Exchange(t testing.TB, keyInfoVersion uint16) *wpa2Exchange {
+	ap, _ := net.ParseMAC("02:00:00:00:00:aa")
+	return newWPA2ExchangeWithAP(t, ap, keyInfoVersion)
+}
+
+func newWPA2ExchangeWithAP(t testing.TB, ap net.HardwareAddr, keyInfoVersion uint16) *wpa2Exchange {
+	x := &wpa2Exchange{ssid: "TestNet", passphrase: "correct horse battery", gtk: mustHex(t, "000102030405060708090a0b0c0d0e0f")}
+	x.ap = ap
+	x.sta, _ = net.ParseMAC("02:00:00:00:00:01")
+	broadcast, _ := net.ParseMAC("ff:ff:ff:ff:ff:ff")
+	anonce := mustHex(t, "1111111111111111111111111111111111111111111111111111111111111111")
+	snonce := mustHex(t, "2222222222222222222222222222222222222222222222222222222222222222")
+
+	// Beacon with SSID and RSN (WPA2-PSK, CCMP)
+	beacon := append([]byte{0x80, 0x00, 0, 0}, broadcast...)
+	beacon = append(append(append(beacon, x.ap...), x.ap...), 0, 0)
+	beacon = append(beacon, make([]byte, 12)...)
+	beacon = append(beacon, 0x00, byte(len(x.ssid)))
+	beacon = append(beacon, x.ssid...)
+	beacon = append(beacon, mustHex(t, "30 14 0100 000fac04 0100 000fac04 0100 000fac02 0000")...)
+
+	pmk := pbkdf2SHA1([]byte(x.passphrase), []byte(x.ssid), 4096, 32)
+	x.ptk = derivePTK("PSK", pmk, x.ap, x.sta, anonce, snonce, 16)
+	require.NotNil(t, x.ptk)
+
+	// Key Information without the descriptor version: M1 Pairwise|Ack, M2 Pairwise|MIC,
+	// M3 Pairwise|Install|Ack|MIC|Secure|Encrypted, M4 Pairwise|MIC|Secure.
+	gtkKDE := append(mustHex(t, "dd16 000fac01 0100"), x.gtk...)
+	m1 := eapolKeyFrame(0x0088|keyInfoVersion, 1, anonce, nil)
+	m2 := eapolKeyFrame(0x0108|keyInfoVersion, 1, snonce, nil)
+	setEAPOLMIC(m2, x.ptk.kck)
+	m3 := eapolKeyFrame(0x13c8|keyInfoVersion, 2, anonce, aesKeyWrap(t, x.ptk.kek, gtkKDE))
+	setEAPOLMIC(m3, x.ptk.kck)
+	m4 := eapolKeyFrame(0x0308|keyInfoVersion, 2, nil, nil)
+	setEAPOLMIC(m4, x.ptk.kck)
+	x.frames = [][]byte{
+		append(beacon, 0, 0, 0, 0),
+		dataFrame(0x02, x.sta, x.ap, x.ap, m1),
+		dataFrame(0x01, x.ap, x.sta, x.ap, m2),
+		dataFrame(0x02, x.sta, x.ap, x.ap, m3),
+		dataFrame(0x01, x.ap, x.sta, x.ap, m4),
+	}
+	return x
+}
+
+// protectedUDP returns a CCMP protected IPv4/UDP frame from the AP to a1 carrying payload.
+func (x *wpa2Exchange) protectedUDP(t testing.TB, a1 net.HardwareAddr, tk []byte, keyID byte, pn uint64, payload string) []byte {
+	ipPayload := gopacket.NewSerializeBuffer()
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{192, 168, 1, 1}, DstIP: net.IP{192, 168, 1, 2}}
+	udp := &layers.UDP{SrcPort: 53, DstPort: 40000}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ip))
+	require.NoError(t, gopacket.SerializeLayers(ipPayload, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		ip, udp, gopacket.Payload([]byte(payload))))
+	plain := append([]byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00, 0x08, 0x00}, ipPayload.Bytes()...)
+
+	header := dataFrame(0x42, a1, x.ap, x.ap, nil)
+	header = header[:len(header)-4]
+	block, err := aes.NewCipher(tk)
+	require.NoError(t, err)
+	ccmpHeader := []byte{byte(pn), byte(pn >> 8), 0, 0x20 | keyID<<6, byte(pn >> 16), byte(pn >> 24), byte(pn >> 32), byte(pn >> 40)}
+	aad := append([]byte{0x08, 0x42}, header[4:22]...)
+	aad = append(aad, 0, 0)
+	nonce := append(append([]byte{0}, x.ap...), byte(pn>>40), byte(pn>>32), byte(pn>>24), byte(pn>>16), byte(pn>>8), byte(pn))
+	body := append(ccmpHeader, ccmSeal(block, nonce, aad, plain, 8)...)
+	return append(append(header, body...), 0, 0, 0, 0)
+}
+
+func TestDecryptor_WPA2PersonalHandshakeAndData(t *testing.T) {
+	x := newWPA2Exchange(t, 2)
+	broadcast, _ := net.ParseMAC("ff:ff:ff:ff:ff:ff")
+
+	d := NewDecryptor([]DecryptionKey{
+		{SSID: x.ssid, Passphrase: "wrong passphrase"},
+		{SSID: x.ssid, Passphrase: x.passphrase},
+	})
+	process := func(frame []byte) (gopacket.Packet, bool) {
+		return d.Process(gopacket.NewPacket(frame, layers.LayerTypeDot11, gopacket.Default))
+	}
+	for _, frame := range x.frames {
+		_, decrypted := process(frame)
+		assert.False(t, decrypted)
+	}
+
+	s := d.sessions[sessionKey(x.ap, x.sta)]
+	require.NotNil(t, s)
+	require.NotNil(t, s.ptk, "PTK should be derived with the matching passphrase")
+	assert.Equal(t, x.ptk.tk, s.ptk.tk)
+	require.NotNil(t, d.gtks[x.ap.String()][1])
+	assert.Equal(t, x.gtk, d.gtks[x.ap.String()][1].key)
+
+	// Unicast IPv4/UDP from AP to STA, CCMP protected with the TK
+	packet, decrypted := process(x.protectedUDP(t, x.sta, s.ptk.tk, 0, 1, "hello"))
+	require.True(t, decrypted)
+	udpLayer, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	require.True(t, ok, "UDP should decode after decryption")
+	assert.Equal(t, []byte("hello"), udpLayer.Payload)
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+	assert.Equal(t, "02:00:00:00:00:01", info.RA.String())
+
+	// Broadcast frame protected with the GTK (Key ID 1)
+	packet, decrypted = process(x.protectedUDP(t, broadcast, x.gtk, 1, 1, "hello"))
+	require.True(t, decrypted)
+	assert.NotNil(t, packet.Layer(layers.LayerTypeIPv4))
+
+	// Unknown STA: left encrypted
+	other, _ := net.ParseMAC("02:00:00:00:00:02")
+	_, decrypted = process(x.protectedUDP(t, other, s.ptk.tk, 0, 2, "hello"))
+	assert.False(t, decrypted)
+}
+
+func TestDecryptor_DescriptorVersion0WithoutAKMIsNotGuessed(t *testing.T) {
+	x := newWPA2Exchange(t, 0)
+	d := NewDecryptor([]DecryptionKey{{SSID: x.ssid, Passphrase: x.passphrase}})
+	for _, frame := range x.frames {
+		d.Process(gopacket.NewPacket(frame, layers.LayerTypeDot11, gopacket.Default))
+	}
+	s := d.sessions[sessionKey(x.ap, x.sta)]
+	require.NotNil(t, s)
+	assert.Nil(t, s.ptk, "no AKM is known for descriptor version 0 without a (Re)Association Request")
+}
+
+func TestDecryptor_PrunesIdleBSSAndSTA(t *testing.T) {
+	x := newWPA2Exchange(t, 2)
+	d := NewDecryptor([]DecryptionKey{{SSID: x.ssid, Passphrase: x.passphrase}})
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	process := func(frame []byte, at time.Time) bool {
+		packet := gopacket.NewPacket(frame, layers.LayerTypeDot11, gopacket.Default)
+		packet.Metadata().Timestamp = at
+		_, decrypted := d.Process(packet)
+		return decrypted
+	}
+	for i, frame := range x.frames {
+		process(frame, ts.Add(time.Duration(i)*time.Millisecond))
+	}
+	require.NotNil(t, d.sessions[sessionKey(x.ap, x.sta)])
+
+	// Traffic within the idle timeout keeps the keys, even across prune runs.
+	ts = ts.Add(decryptorIdleTimeout - time.Second)
+	assert.True(t, process(x.protectedUDP(t, x.sta, x.ptk.tk, 0, 1, "hello"), ts))
+	ts = ts.Add(decryptorIdleTimeout - time.Second)
+	assert.True(t, process(x.protectedUDP(t, x.sta, x.ptk.tk, 0, 2, "hello"), ts))
+
+	// A frame of another BSS after the timeout prunes the idle BSS and its STA.
+	otherAP, _ := net.ParseMAC("02:00:00:00:00:bb")
+	otherSTA, _ := net.ParseMAC("02:00:00:00:00:02")
+	process(dataFrame(0x01, otherAP, otherSTA, otherAP, nil), ts.Add(decryptorIdleTimeout+decryptorPruneInterval))
+	assert.Empty(t, d.sessions)
+	assert.NotContains(t, d.ssids, x.ap.String())
+	assert.NotContains(t, d.groupCiphers, x.ap.String())
+	assert.NotContains(t, d.gtks, x.ap.String())
+	assert.Equal(t, []string{otherAP.String()}, keysOf(d.bssLastSeen))
+}
+
+func keysOf(m map[string]time.Time) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// withDecryptionKeys configures a decryption key for the parsers created by the test.
+func withDecryptionKeys(tb testing.TB, keys ...config.DecryptionKeyConfig) {
+	saved := config.GlobalConfig.Decryption
+	config.GlobalConfig.Decryption = &config.DecryptionConfig{Keys: keys}
+	tb.Cleanup(func() { config.GlobalConfig.Decryption = saved })
+}
+
+// writeWPA2Capture writes a pcap of a WPA2-Personal BSS: Beacon, 4-way handshake, a
+// protected unicast frame per payload and a protected broadcast frame.
+func writeWPA2Capture(t testing.TB, x *wpa2Exchange, payloads []string) []byte {
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	require.NoError(t, w.WriteFileHeader(65535, layers.LinkTypeIEEE802_11))
+	frames := append([][]byte(nil), x.frames...)
+	for i, payload := range payloads {
+		frames = append(frames, x.protectedUDP(t, x.sta, x.ptk.tk, 0, uint64(i+1), payload))
+	}
+	broadcast, _ := net.ParseMAC("ff:ff:ff:ff:ff:ff")
+	frames = append(frames, x.protectedUDP(t, broadcast, x.gtk, 1, 1, "to all"))
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, frame := range frames {
+		ci := gopacket.CaptureInfo{Timestamp: ts.Add(time.Duration(i) * time.Millisecond), CaptureLength: len(frame), Length: len(frame)}
+		require.NoError(t, w.WritePacket(ci, frame))
+	}
+	return buf.Bytes()
+}
+
+func TestProcessCaptureStream_DecryptsWPA2PersonalCapture(t *testing.T) {
+	x := newWPA2Exchange(t, 2)
+	withDecryptionKeys(t, config.DecryptionKeyConfig{SSID: x.ssid, Passphrase: x.passphrase})
+	capture := writeWPA2Capture(t, x, []string{"first", "second"})
+
+	// With several workers the beacon, the handshake and the broadcast frame would be parsed
+	// on different workers; decryption must still see them in capture order.
+	for _, workers := range []int{1, 4} {
+		withParseWorkers(t, workers)
+		var decrypted []int
+		var frames int
+		require.NoError(t, ProcessCaptureStream(bytes.NewReader(capture), func(info *ParsedFrameInfo) {
+			frames++
+			if info.Decrypted && info.Flow != nil {
+				assert.Equal(t, "UDP", info.Flow.Protocol)
+				decrypted = append(decrypted, info.TransportPayloadLength)
+			}
+		}))
+		assert.Equal(t, 8, frames, "workers=%d", workers)
+		assert.Equal(t, []int{8 + len("first"), 8 + len("second"), 8 + len("to all")}, decrypted,
+			"workers=%d: udp.length, including the UDP header", workers)
+	}
+}
+
+func TestProcessCaptureStream_DecryptsInCaptureOrderWithWorkers(t *testing.T) {
+	withDecryptionKeys(t, config.DecryptionKeyConfig{SSID: "TestNet", Passphrase: "correct horse battery"})
+	withParseWorkers(t, 4)
+
+	// Many BSSs, each sending a group-addressed frame right after M3 installed its GTK. The
+	// group frame is keyed to another worker than the handshake, so parsing it there could
+	// overtake M3.
+	const bsss = 64
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	require.NoError(t, w.WriteFileHeader(65535, layers.LinkTypeIEEE802_11))
+	broadcast, _ := net.ParseMAC("ff:ff:ff:ff:ff:ff")
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < bsss; i++ {
+		x := newWPA2ExchangeWithAP(t, net.HardwareAddr{0x02, 0, 0, 0, 0x01, byte(i)}, 2)
+		frames := append(append([][]byte(nil), x.frames[:4]...), x.protectedUDP(t, broadcast, x.gtk, 1, 1, "to all"), x.frames[4])
+		for _, frame := range frames {
+			ts = ts.Add(time.Millisecond)
+			require.NoError(t, w.WritePacket(gopacket.CaptureInfo{Timestamp: ts, CaptureLength: len(frame), Length: len(frame)}, frame))
+		}
+	}
+
+	decrypted := 0
+	require.NoError(t, ProcessCaptureStream(&buf, func(info *ParsedFrameInfo) {
+		if info.Decrypted {
+			decrypted++
+		}
+	}))
+	assert.Equal(t, bsss, decrypted)
+}
+
+func TestRadiotapFlagsOffset(t *testing.T) {
+	// Present: TSFT and Flags
+	assert.Equal(t, 16, radiotapFlagsOffset(mustHex(t, "0000 1100 03000000 0000000000000000 10")))
+	// Present: Flags only
+	assert.Equal(t, 8, radiotapFlagsOffset(mustHex(t, "0000 0900 02000000 10")))
+	// Extended presence bitmap, then TSFT
+	assert.Equal(t, 24, radiotapFlagsOffset(mustHex(t, "0000 1900 03000080 00000000 0000000000000000 0000000000000000 10")))
+}
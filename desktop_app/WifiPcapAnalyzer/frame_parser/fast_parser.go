@@ -0,0 +1,179 @@
+package frame_parser
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Parser names accepted by the "parser" config setting.
+const (
+	ParserGoPacket = "gopacket" // Default: full gopacket.Packet decoding
+	ParserFast     = "fast"     // FastParser
+)
+
+// FrameParser turns captured packet data into a ParsedFrameInfo. Implementations need not be
+// safe for concurrent use; every parse worker gets its own.
+type FrameParser interface {
+	ParseData(data []byte, ci gopacket.CaptureInfo, linkType layers.LinkType) (*ParsedFrameInfo, error)
+}
+
+var (
+	_ FrameParser = (*GoPacketParser)(nil)
+	_ FrameParser = (*FastParser)(nil)
+)
+
+// FastParser parses frames with a gopacket.DecodingLayerParser over preallocated layers,
+// instead of building a gopacket.Packet with a new set of layers for every frame. Decoding
+// stops at the management frame body: information elements are decoded once, by
+// parseInformationElements, rather than also as a chain of gopacket layers.
+//
+// It produces the same ParsedFrameInfo as GoPacketParser. The ParsedFrameInfo objects come
+// from a pool; see releaseParsedFrameInfo.
+type FastParser struct {
+	radiotap  layers.RadioTap
+	ppi       PPI
+	prism     layers.PrismHeader
+	avs       AVS
+	dot11     layers.Dot11
+	dot11Data layers.Dot11Data
+	beacon    layers.Dot11MgmtBeacon
+	probeReq  layers.Dot11MgmtProbeReq
+	probeResp layers.Dot11MgmtProbeResp
+	auth      layers.Dot11MgmtAuthentication
+	llc       layers.LLC
+	snap      layers.SNAP
+	eapol     layers.EAPOL
+	eapolKey  eapolKeyLayer
+	eap       layers.EAP
+	arp       layers.ARP
+	ipv4      layers.IPv4
+	ipv6      layers.IPv6
+	tcp       layers.TCP
+	udp       layers.UDP
+	dhcp      layers.DHCPv4
+	dns       layers.DNS
+
+	frame    decodedFrame
+	decoders map[gopacket.LayerType]gopacket.DecodingLayerFunc // By first layer
+	fallback GoPacketParser                                    // For link types without a fast path
+}
+
+// NewFastParser returns a FastParser for radiotap, PPI, Prism, AVS and bare 802.11 captures.
+// Other link types are handed to GoPacketParser.
+func NewFastParser() *FastParser {
+	f := &FastParser{decoders: make(map[gopacket.LayerType]gopacket.DecodingLayerFunc)}
+	dlc := gopacket.DecodingLayerContainer(gopacket.DecodingLayerMap{})
+	for _, d := range []gopacket.DecodingLayer{
+		&f.radiotap, &f.ppi, &f.prism, &f.avs, &f.dot11,
+		&f.dot11Data, &f.beacon, &f.probeReq, &f.probeResp, &f.auth,
+		&f.llc, &f.snap, &f.eapol, &f.eapolKey, &f.eap, &f.arp,
+		&f.ipv4, &f.ipv6, &f.tcp, &f.udp, &f.dhcp, &f.dns,
+	} {
+		dlc = dlc.Put(d)
+	}
+	f.frame.layers = dlc
+	f.frame.decoded = make([]gopacket.LayerType, 0, 16)
+	for _, first := range []gopacket.LayerType{layers.LayerTypeRadioTap, LayerTypePPI, layers.LayerTypePrismHeader, LayerTypeAVS, layers.LayerTypeDot11} {
+		f.decoders[first] = dlc.LayersDecoder(first, gopacket.NilDecodeFeedback)
+	}
+	return f
+}
+
+// ParseData decodes and parses one frame.
+func (f *FastParser) ParseData(data []byte, ci gopacket.CaptureInfo, linkType layers.LinkType) (*ParsedFrameInfo, error) {
+	first := firstLayerType(data, linkType)
+	decode, ok := f.decoders[first]
+	if !ok {
+		return f.fallback.ParseData(data, ci, linkType)
+	}
+
+	// Dot11 and RadioTap leave fields of absent parts (e.g. Address2 of an ACK, Flags) as
+	// they were, so they must not carry over from the previous frame.
+	f.dot11 = layers.Dot11{}
+	f.radiotap = layers.RadioTap{}
+	f.frame.metadata = gopacket.PacketMetadata{CaptureInfo: ci}
+	f.frame.decode(decode, data)
+
+	return parseFrame(&f.frame, newParsedFrameInfo())
+}
+
+// firstLayerType returns the layer a capture of the given link type starts with.
+func firstLayerType(data []byte, linkType layers.LinkType) gopacket.LayerType {
+	switch linkType {
+	case layers.LinkTypeIEEE80211Radio:
+		return layers.LayerTypeRadioTap
+	case layers.LinkTypeIEEE802_11:
+		return layers.LayerTypeDot11
+	case LinkTypePPI:
+		return LayerTypePPI
+	case LinkTypeAVS:
+		return LayerTypeAVS
+	case layers.LinkTypePrismHeader:
+		// Some drivers put an AVS header under the Prism link type.
+		if len(data) >= 4 && binary.BigEndian.Uint32(data[0:4])>>4 == avsMagic {
+			return LayerTypeAVS
+		}
+		return layers.LayerTypePrismHeader
+	}
+	return gopacket.LayerTypeZero
+}
+
+// decodedFrame gives parseFrame access to the layers a FastParser decoded for one frame.
+type decodedFrame struct {
+	layers   gopacket.DecodingLayerContainer
+	decoded  []gopacket.LayerType
+	metadata gopacket.PacketMetadata
+}
+
+// decode decodes as many layers as it can. Like gopacket.NewPacket, it keeps the layers
+// decoded before an error, so a bad element or upper layer doesn't lose the 802.11 header.
+func (d *decodedFrame) decode(decode gopacket.DecodingLayerFunc, data []byte) {
+	defer func() {
+		// A layer panicked on malformed data; keep what was decoded before it.
+		recover()
+	}()
+	d.decoded = d.decoded[:0]
+	decode(data, &d.decoded)
+}
+
+func (d *decodedFrame) Layer(t gopacket.LayerType) gopacket.Layer {
+	for _, decoded := range d.decoded {
+		if decoded == t {
+			layer, _ := d.layers.Decoder(t)
+			if key, ok := layer.(*eapolKeyLayer); ok {
+				return &key.EAPOLKey
+			}
+			return layer.(gopacket.Layer)
+		}
+	}
+	return nil
+}
+
+func (d *decodedFrame) Metadata() *gopacket.PacketMetadata { return &d.metadata }
+
+// eapolKeyLayer makes layers.EAPOLKey a gopacket.DecodingLayer; its CanDecode returns a
+// LayerType rather than a LayerClass.
+type eapolKeyLayer struct {
+	layers.EAPOLKey
+}
+
+func (k *eapolKeyLayer) CanDecode() gopacket.LayerClass { return layers.LayerTypeEAPOLKey }
+
+var parsedFrameInfoPool = sync.Pool{
+	New: func() interface{} { return &ParsedFrameInfo{} },
+}
+
+func newParsedFrameInfo() *ParsedFrameInfo {
+	return parsedFrameInfoPool.Get().(*ParsedFrameInfo)
+}
+
+// releaseParsedFrameInfo returns a ParsedFrameInfo to the pool once the handler is done
+// with it. Only the struct is reused; slices and pointers it held are left to their new
+// owners (e.g. the state manager keeps a BSS's parsed capabilities).
+func releaseParsedFrameInfo(info *ParsedFrameInfo) {
+	*info = ParsedFrameInfo{}
+	parsedFrameInfoPool.Put(info)
+}
@@ -0,0 +1,174 @@
+package frame_parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"WifiPcapAnalyzer/config"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type parserTestFrame struct {
+	name     string
+	linkType layers.LinkType
+	data     []byte
+}
+
+// parserCorpus returns frames covering every capture header, frame class and upper layer
+// the parsers handle, in an order that exposes state left over from the previous frame.
+func parserCorpus(t *testing.T) []parserTestFrame {
+	corpus := []parserTestFrame{}
+	r, err := pcapgo.NewReader(bytes.NewReader(writeSyntheticCapture(t, 8)))
+	require.NoError(t, err)
+	for i := 0; ; i++ {
+		data, _, err := r.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		corpus = append(corpus, parserTestFrame{"synthetic", layers.LinkTypeIEEE80211Radio, data})
+	}
+
+	deauth := mustHex(t, pcapngTestDeauth)
+	withFCS := append(append([]byte(nil), deauth[:len(deauth)-4]...), binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(deauth[:len(deauth)-4]))...)
+	corrupted := append([]byte(nil), withFCS...)
+	corrupted[15] ^= 0xff
+
+	ip, udp := udpLayers("0.0.0.0", "255.255.255.255", 68, 67)
+	dhcp := &layers.DHCPv4{Operation: layers.DHCPOpRequest, HardwareType: layers.LinkTypeEthernet, HardwareLen: 6,
+		ClientHWAddr: mustHex(t, "020000000001"),
+		Options: []layers.DHCPOption{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(layers.DHCPMsgTypeRequest)}),
+			layers.NewDHCPOption(layers.DHCPOptHostname, []byte("laptop")),
+		}}
+	dnsIP, dnsUDP := udpLayers("192.168.1.50", "192.168.1.1", 5000, 53)
+	dns := &layers.DNS{ID: 1, RD: true, Questions: []layers.DNSQuestion{{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}}}
+	tcpIP := &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.IP{192, 168, 1, 50}, DstIP: net.IP{1, 1, 1, 1}}
+	tcp := &layers.TCP{SrcPort: 40000, DstPort: 443, SYN: true, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(tcpIP)
+	arp := &layers.ARP{AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4, HwAddressSize: 6, ProtAddressSize: 4,
+		Operation: layers.ARPRequest, SourceHwAddress: mustHex(t, "020000000001"), SourceProtAddress: []byte{192, 168, 1, 50},
+		DstHwAddress: make([]byte, 6), DstProtAddress: []byte{192, 168, 1, 1}}
+	ap, _ := net.ParseMAC("02:00:00:00:00:aa")
+	sta, _ := net.ParseMAC("02:00:00:00:00:01")
+	eapolM1 := dataFrame(0x02, sta, ap, ap, eapolKeyFrame(0x008a, 1, bytes.Repeat([]byte{0x11}, 32), nil))
+
+	return append(corpus,
+		parserTestFrame{"radiotap deauth", layers.LinkTypeIEEE80211Radio, mustHex(t, pcapngTestRadiotapDeauth)},
+		parserTestFrame{"bare beacon", layers.LinkTypeIEEE802_11, mustHex(t, "8000 0000 ffffffffffff 0200000000aa 0200000000aa 0000"+
+			"0000000000000000 6400 0104 00 04 74657374 03 01 06 01 04 82848b96 00000000")},
+		parserTestFrame{"AVS", LinkTypeAVS, append(avsHeader(149, 240, AVSSSITypeDBM, -70, -92), deauth...)},
+		parserTestFrame{"AVS under Prism", layers.LinkTypePrismHeader, append(avsHeader(1, 10, AVSSSITypeDBM, -40, -90), deauth...)},
+		parserTestFrame{"good FCS", layers.LinkTypeIEEE80211Radio, append(mustHex(t, "0000 0900 02000000 10"), withFCS...)},
+		parserTestFrame{"bad FCS", layers.LinkTypeIEEE80211Radio, append(mustHex(t, "0000 0900 02000000 10"), corrupted...)},
+		parserTestFrame{"DHCP", layers.LinkTypeIEEE802_11, snapDataFrame(t, layers.EthernetTypeIPv4, ip, udp, dhcp)},
+		parserTestFrame{"DNS", layers.LinkTypeIEEE802_11, snapDataFrame(t, layers.EthernetTypeIPv4, dnsIP, dnsUDP, dns)},
+		parserTestFrame{"TCP", layers.LinkTypeIEEE802_11, snapDataFrame(t, layers.EthernetTypeIPv4, tcpIP, tcp)},
+		parserTestFrame{"ARP", layers.LinkTypeIEEE802_11, snapDataFrame(t, layers.EthernetTypeARP, arp)},
+		parserTestFrame{"EAPOL M1", layers.LinkTypeIEEE802_11, eapolM1},
+		parserTestFrame{"ACK after data", layers.LinkTypeIEEE802_11, mustHex(t, "d400 0000 020000000001 00000000")},
+		parserTestFrame{"truncated", layers.LinkTypeIEEE80211Radio, mustHex(t, syntheticRadiotap+"8801")},
+		parserTestFrame{"Ethernet", layers.LinkTypeEthernet, mustHex(t, "ffffffffffff 020000000001 0806")},
+	)
+}
+
+func TestFastParser_MatchesGoPacketParser(t *testing.T) {
+	fast := NewFastParser()
+	ci := gopacket.CaptureInfo{Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), CaptureLength: 100, Length: 120}
+	for _, frame := range parserCorpus(t) {
+		want, wantErr := (&GoPacketParser{}).ParseData(frame.data, ci, frame.linkType)
+		got, gotErr := fast.ParseData(frame.data, ci, frame.linkType)
+		assert.Equal(t, wantErr, gotErr, frame.name)
+		assert.Equal(t, want, got, frame.name)
+	}
+}
+
+func TestFastParser_DecodesUpperLayers(t *testing.T) {
+	// Spot checks that the layers behind the 802.11 header are decoded, not only skipped by both parsers.
+	fast := NewFastParser()
+	byName := map[string]*ParsedFrameInfo{}
+	for _, frame := range parserCorpus(t) {
+		info, err := fast.ParseData(frame.data, gopacket.CaptureInfo{}, frame.linkType)
+		if err == nil {
+			copied := *info
+			byName[frame.name] = &copied
+		}
+	}
+	require.NotNil(t, byName["DHCP"].DHCP)
+	assert.Equal(t, "laptop", byName["DHCP"].DHCP.Hostname)
+	require.NotNil(t, byName["DNS"].DNS)
+	assert.Equal(t, []string{"example.com"}, byName["DNS"].DNS.Queries)
+	require.NotNil(t, byName["EAPOL M1"].EAPOLKey)
+	assert.Equal(t, 1, byName["EAPOL M1"].EAPOLKey.Message)
+	assert.True(t, byName["bad FCS"].BadFCS)
+	assert.Nil(t, byName["ACK after data"].SA)
+	assert.Equal(t, 5745, byName["AVS"].Frequency)
+	assert.Equal(t, "test", byName["bare beacon"].SSID)
+}
+
+func TestProcessCaptureStream_FastParser(t *testing.T) {
+	capture := writeSyntheticCapture(t, 400)
+	collect := func() []ParsedFrameInfo {
+		var frames []ParsedFrameInfo
+		require.NoError(t, ProcessCaptureStream(bytes.NewReader(capture), func(info *ParsedFrameInfo) {
+			frames = append(frames, *info) // The fast parser reuses info after the handler returns
+		}))
+		return frames
+	}
+	withParseWorkers(t, 2)
+	want := collect()
+	withParser(t, ParserFast)
+	assert.Equal(t, want, collect())
+}
+
+func withParser(tb testing.TB, parser string) {
+	saved := config.GlobalConfig.Parser
+	config.GlobalConfig.Parser = parser
+	tb.Cleanup(func() { config.GlobalConfig.Parser = saved })
+}
+
+// BenchmarkFrameParser parses the synthetic beacon, data and ACK frames with each parser.
+func BenchmarkFrameParser(b *testing.B) {
+	r, err := pcapgo.NewReader(bytes.NewReader(writeSyntheticCapture(b, 4)))
+	require.NoError(b, err)
+	var frames [][]byte
+	for {
+		data, _, err := r.ReadPacketData()
+		if err != nil {
+			break
+		}
+		frames = append(frames, data)
+	}
+
+	for _, bc := range []struct {
+		name    string
+		parser  FrameParser
+		release bool
+	}{
+		{ParserGoPacket, &GoPacketParser{}, false},
+		{ParserFast, NewFastParser(), true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				info, err := bc.parser.ParseData(frames[i%len(frames)], gopacket.CaptureInfo{}, layers.LinkTypeIEEE80211Radio)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if bc.release {
+					releaseParsedFrameInfo(info)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/logger"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/google/gopacket/layers"
+)
+
+// Element ID Extension values (IEEE 802.11ax/be, Table 9-92).
+const (
+	ieExtIDHECapabilities uint8 = 35
+)
+
+// init registers the built-in information element decoders.
+func init() {
+	RegisterIEDecoder(uint8(layers.Dot11InformationElementIDSSID), "SSID", decodeSSIDIE)
+	RegisterIEDecoder(uint8(layers.Dot11InformationElementIDDSSet), "DS Parameter Set", decodeDSSetIE)
+	RegisterIEDecoder(uint8(layers.Dot11InformationElementIDTIM), "TIM", decodeTIMIE)
+	RegisterIEDecoder(uint8(layers.Dot11InformationElementIDHTCapabilities), "HT Capabilities", decodeHTCapabilitiesIE)
+	RegisterIEDecoder(uint8(layers.Dot11InformationElementIDRSNInfo), "RSN", decodeRSNIE)
+	RegisterIEDecoder(uint8(layers.Dot11InformationElementIDVHTCapabilities), "VHT Capabilities", decodeVHTCapabilitiesIE)
+	RegisterIEDecoder(uint8(layers.Dot11InformationElementIDHTInfo), "HT Operation", decodeHTOperationIE)         // 61
+	RegisterIEDecoder(uint8(layers.Dot11InformationElementIDVHTOperation), "VHT Operation", decodeVHTOperationIE) // 192
+	RegisterExtensionIEDecoder(ieExtIDHECapabilities, "HE Capabilities", decodeHECapabilitiesIE)
+}
+
+// decodeSSIDIE decodes the SSID element and sets info.SSID.
+func decodeSSIDIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) == 0 {
+		info.SSID = "<empty>"
+		return info.SSID, nil
+	}
+	isHidden := true
+	for _, b := range ieData {
+		if b != 0 {
+			isHidden = false
+			break
+		}
+	}
+	if isHidden {
+		info.SSID = "<hidden>"
+	} else if utf8.Valid(ieData) {
+		info.SSID = string(ieData)
+	} else {
+		hexSSID := fmt.Sprintf("%x", ieData)
+		logger.Log.Warn().Str("bssid", info.BSSID.String()).Str("ssid_hex", hexSSID).Msg("Non-UTF-8 SSID encountered, displaying as hex.")
+		info.SSID = fmt.Sprintf("<HEX:%s>", hexSSID)
+	}
+	return info.SSID, nil
+}
+
+// decodeDSSetIE decodes the DS Parameter Set element (current channel).
+func decodeDSSetIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) != 1 {
+		return nil, fmt.Errorf("invalid DS Parameter Set length %d", len(ieData))
+	}
+	info.DSSetChannel = ieData[0]
+	if info.Channel == 0 && info.DSSetChannel > 0 {
+		info.Channel = int(info.DSSetChannel)
+	}
+	return info.DSSetChannel, nil
+}
+
+// decodeTIMIE keeps a copy of the TIM element body.
+func decodeTIMIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	info.TIM = make([]byte, len(ieData))
+	copy(info.TIM, ieData)
+	return info.TIM, nil
+}
+
+func decodeHTCapabilitiesIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	parseHTCapabilitiesIE(info, ieData)
+	return info.ParsedHTCaps, nil
+}
+
+func decodeVHTCapabilitiesIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	parseVHTCapabilitiesIE(info, ieData)
+	return info.ParsedVHTCaps, nil
+}
+
+func decodeHTOperationIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	parseHTOperationIE(info, ieData)
+	return info.ParsedHTCaps, nil
+}
+
+func decodeVHTOperationIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	parseVHTOperationIE(info, ieData)
+	return info.ParsedVHTCaps, nil
+}
+
+// decodeRSNIE parses the RSN element and keeps the raw element (ID + length + body) in info.RSNRaw.
+func decodeRSNIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	err := parseRSNIE(info, ieData)
+	info.RSNRaw = make([]byte, 2+len(ieData))
+	info.RSNRaw[0] = byte(layers.Dot11InformationElementIDRSNInfo)
+	info.RSNRaw[1] = byte(len(ieData))
+	copy(info.RSNRaw[2:], ieData)
+	if err != nil {
+		return nil, err
+	}
+	return info.SecurityProfile, nil
+}
+
+// decodeHECapabilitiesIE marks the frame as HE capable. Detailed HE field parsing is not implemented yet.
+func decodeHECapabilitiesIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if info.ParsedHECaps == nil {
+		info.ParsedHECaps = &HECapabilityInfo{}
+	}
+	return info.ParsedHECaps, nil
+}
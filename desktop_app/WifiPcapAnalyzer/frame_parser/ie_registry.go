@@ -0,0 +1,201 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/logger"
+	"fmt"
+	"sync"
+
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	// ieIDExtension is the Element ID that carries an Element ID Extension in its first byte.
+	ieIDExtension uint8 = 255
+	// ieIDVendorSpecific is the Element ID of Vendor Specific elements (OUI + vendor type).
+	ieIDVendorSpecific uint8 = 221
+)
+
+// InformationElement is a single TLV element from a management frame body.
+// Decoded holds the typed result of a registered decoder; Raw holds the element
+// body for anything that no decoder handled (or whose decoder failed), so the UI
+// can still render an "all IEs" tree.
+type InformationElement struct {
+	ID          uint8       `json:"id"`
+	ExtensionID uint8       `json:"extension_id,omitempty"` // Only meaningful when ID == 255
+	OUI         string      `json:"oui,omitempty"`          // Only set when ID == 221, e.g. "00:50:F2"
+	VendorType  uint8       `json:"vendor_type,omitempty"`  // Only meaningful when ID == 221
+	Name        string      `json:"name"`
+	Length      int         `json:"length"`
+	Decoded     interface{} `json:"decoded,omitempty"`
+	Raw         []byte      `json:"raw,omitempty"`
+	Error       string      `json:"error,omitempty"` // Decoder error, if any
+}
+
+// IEDecoder decodes the body of an information element.
+// For extension elements the Element ID Extension byte has already been stripped,
+// and for vendor specific elements the OUI and vendor type bytes have been stripped.
+// A decoder may also populate the relevant convenience fields of info (e.g. SSID).
+// The returned value is stored in InformationElement.Decoded.
+type IEDecoder func(info *ParsedFrameInfo, data []byte) (interface{}, error)
+
+type ieDecoderEntry struct {
+	name   string
+	decode IEDecoder
+}
+
+type vendorIEKey struct {
+	oui        [3]byte
+	vendorType uint8
+}
+
+// ieRegistry maps element identifiers to decoders.
+type ieRegistry struct {
+	mu          sync.RWMutex
+	byID        map[uint8]ieDecoderEntry
+	byExtID     map[uint8]ieDecoderEntry
+	byVendor    map[vendorIEKey]ieDecoderEntry
+	byVendorOUI map[[3]byte]ieDecoderEntry // Fallback for vendors whose types share one decoder
+}
+
+func newIERegistry() *ieRegistry {
+	return &ieRegistry{
+		byID:        make(map[uint8]ieDecoderEntry),
+		byExtID:     make(map[uint8]ieDecoderEntry),
+		byVendor:    make(map[vendorIEKey]ieDecoderEntry),
+		byVendorOUI: make(map[[3]byte]ieDecoderEntry),
+	}
+}
+
+// defaultIERegistry is used by ParsePacket. Built-in decoders register themselves in init().
+var defaultIERegistry = newIERegistry()
+
+// RegisterIEDecoder registers a decoder for the element with the given Element ID.
+// Registering the same ID again replaces the previous decoder.
+func RegisterIEDecoder(id uint8, name string, decoder IEDecoder) {
+	defaultIERegistry.mu.Lock()
+	defer defaultIERegistry.mu.Unlock()
+	defaultIERegistry.byID[id] = ieDecoderEntry{name: name, decode: decoder}
+}
+
+// RegisterExtensionIEDecoder registers a decoder for an Element ID 255 element
+// with the given Element ID Extension.
+func RegisterExtensionIEDecoder(extID uint8, name string, decoder IEDecoder) {
+	defaultIERegistry.mu.Lock()
+	defer defaultIERegistry.mu.Unlock()
+	defaultIERegistry.byExtID[extID] = ieDecoderEntry{name: name, decode: decoder}
+}
+
+// RegisterVendorIEDecoder registers a decoder for a Vendor Specific (221) element
+// with the given OUI and vendor type.
+func RegisterVendorIEDecoder(oui [3]byte, vendorType uint8, name string, decoder IEDecoder) {
+	defaultIERegistry.mu.Lock()
+	defer defaultIERegistry.mu.Unlock()
+	defaultIERegistry.byVendor[vendorIEKey{oui: oui, vendorType: vendorType}] = ieDecoderEntry{name: name, decode: decoder}
+}
+
+// RegisterVendorOUIDecoder registers a fallback decoder for every Vendor Specific element
+// with the given OUI that has no type-specific decoder. Such decoders receive the body
+// starting at the vendor type byte, since they need it to tell the types apart.
+func RegisterVendorOUIDecoder(oui [3]byte, name string, decoder IEDecoder) {
+	defaultIERegistry.mu.Lock()
+	defer defaultIERegistry.mu.Unlock()
+	defaultIERegistry.byVendorOUI[oui] = ieDecoderEntry{name: name, decode: decoder}
+}
+
+// lookup resolves the decoder for an element and returns the body the decoder expects.
+func (r *ieRegistry) lookup(elem *InformationElement, body []byte) (ieDecoderEntry, []byte, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	switch elem.ID {
+	case ieIDExtension:
+		if len(body) < 1 {
+			return ieDecoderEntry{}, nil, false
+		}
+		elem.ExtensionID = body[0]
+		entry, ok := r.byExtID[elem.ExtensionID]
+		return entry, body[1:], ok
+	case ieIDVendorSpecific:
+		if len(body) < 4 {
+			return ieDecoderEntry{}, nil, false
+		}
+		var key vendorIEKey
+		copy(key.oui[:], body[0:3])
+		key.vendorType = body[3]
+		elem.OUI = fmt.Sprintf("%02X:%02X:%02X", key.oui[0], key.oui[1], key.oui[2])
+		elem.VendorType = key.vendorType
+		if entry, ok := r.byVendor[key]; ok {
+			return entry, body[4:], true
+		}
+		entry, ok := r.byVendorOUI[key.oui]
+		return entry, body[3:], ok
+	default:
+		entry, ok := r.byID[elem.ID]
+		return entry, body, ok
+	}
+}
+
+// parseInformationElements walks the TLV list in a management frame body,
+// dispatches every element to its registered decoder and records the results
+// in info.Elements.
+func parseInformationElements(info *ParsedFrameInfo, iePayload []byte) {
+	parseInformationElementsWith(defaultIERegistry, info, iePayload)
+}
+
+func parseInformationElementsWith(registry *ieRegistry, info *ParsedFrameInfo, iePayload []byte) {
+	currentIndex := 0
+	for currentIndex < len(iePayload) {
+		if currentIndex+2 > len(iePayload) { // Need at least ID and Length fields
+			logger.Log.Warn().Int("offset", currentIndex).Int("payload_len", len(iePayload)).Msg("IE parsing stopped: not enough data for ID/Length.")
+			break
+		}
+		ieID := iePayload[currentIndex]
+		ieLength := int(iePayload[currentIndex+1])
+
+		if currentIndex+2+ieLength > len(iePayload) { // Check if data for this IE is fully present
+			logger.Log.Warn().Stringer("ie_id", layers.Dot11InformationElementID(ieID)).Int("declared_len", ieLength).Int("remaining_payload", len(iePayload)-currentIndex-2).Msg("IE parsing stopped: declared length exceeds available payload.")
+			break
+		}
+		ieData := iePayload[currentIndex+2 : currentIndex+2+ieLength]
+		currentIndex += 2 + ieLength
+
+		elem := InformationElement{ID: ieID, Length: ieLength}
+		entry, body, ok := registry.lookup(&elem, ieData)
+		elem.Name = ieName(&elem, entry, ok)
+		if ok && entry.decode != nil {
+			decoded, err := entry.decode(info, body)
+			if err != nil {
+				logger.Log.Debug().Err(err).Str("ie", elem.Name).Msg("IE decoder failed, keeping raw element.")
+				elem.Error = err.Error()
+			} else {
+				elem.Decoded = decoded
+			}
+		}
+		if elem.Decoded == nil {
+			elem.Raw = make([]byte, len(ieData))
+			copy(elem.Raw, ieData)
+		}
+		info.Elements = append(info.Elements, elem)
+	}
+}
+
+// ieName returns a human readable name for an element, preferring the registered name.
+func ieName(elem *InformationElement, entry ieDecoderEntry, registered bool) string {
+	if registered && entry.name != "" {
+		return entry.name
+	}
+	switch elem.ID {
+	case ieIDExtension:
+		return fmt.Sprintf("Element ID Extension %d", elem.ExtensionID)
+	case ieIDVendorSpecific:
+		if elem.OUI != "" {
+			if vendor := VendorNameForOUI(elem.OUI); vendor != "" {
+				return fmt.Sprintf("Vendor Specific: %s (type %d)", vendor, elem.VendorType)
+			}
+			return fmt.Sprintf("Vendor Specific (%s type %d)", elem.OUI, elem.VendorType)
+		}
+		return "Vendor Specific"
+	default:
+		return layers.Dot11InformationElementID(elem.ID).String()
+	}
+}
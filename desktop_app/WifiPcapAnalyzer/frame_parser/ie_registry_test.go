@@ -0,0 +1,168 @@
+package frame_parser
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mustHex decodes a hex string, ignoring spaces used to separate fields.
+func mustHex(t testing.TB, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	require.NoError(t, err)
+	return b
+}
+
+func TestParseInformationElements_Dispatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ies     string
+		elemID  uint8
+		extID   uint8
+		oui     string
+		vType   uint8
+		elem    string
+		decoded interface{} // nil when the element is kept raw
+		raw     string
+		errText string
+	}{
+		{name: "by element ID", ies: "00 04 74657374", elemID: 0, elem: "SSID", decoded: "test"},
+		{name: "by extension ID", ies: "ff 03 23 0000", elemID: 255, extID: 35, elem: "HE Capabilities", decoded: &HECapabilityInfo{}},
+		{name: "unregistered element ID", ies: "c8 02 abcd", elemID: 200, elem: layers.Dot11InformationElementID(200).String(), raw: "abcd"},
+		{name: "unregistered extension ID", ies: "ff 02 7f 01", elemID: 255, extID: 127, elem: "Element ID Extension 127", raw: "7f01"},
+		{name: "extension element without extension ID", ies: "ff 00", elemID: 255, elem: "Element ID Extension 0", raw: ""},
+		{name: "unknown vendor", ies: "dd 05 aabbcc 01 ff", elemID: 221, oui: "AA:BB:CC", vType: 1, elem: "Vendor Specific (AA:BB:CC type 1)", raw: "aabbcc01ff"},
+		{name: "known vendor without decoder", ies: "dd 05 000b86 01 00", elemID: 221, oui: "00:0B:86", vType: 1, elem: "Vendor Specific: Aruba (type 1)", raw: "000b860100"},
+		{name: "vendor element shorter than OUI and type", ies: "dd 02 0050", elemID: 221, elem: "Vendor Specific", raw: "0050"},
+		{name: "malformed element keeps raw bytes", ies: "03 02 0101", elemID: 3, elem: "DS Parameter Set", raw: "0101", errText: "invalid DS Parameter Set length 2"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &ParsedFrameInfo{}
+			parseInformationElements(info, mustHex(t, tc.ies))
+			require.Len(t, info.Elements, 1)
+			elem := info.Elements[0]
+			assert.Equal(t, tc.elemID, elem.ID)
+			assert.Equal(t, tc.extID, elem.ExtensionID)
+			assert.Equal(t, tc.oui, elem.OUI)
+			assert.Equal(t, tc.vType, elem.VendorType)
+			assert.Equal(t, tc.elem, elem.Name)
+			assert.Equal(t, tc.errText, elem.Error)
+			assert.Equal(t, len(mustHex(t, tc.ies))-2, elem.Length)
+			if tc.decoded != nil {
+				assert.Equal(t, tc.decoded, elem.Decoded)
+				assert.Nil(t, elem.Raw, "decoded elements are not kept raw")
+			} else {
+				assert.Nil(t, elem.Decoded)
+				assert.Equal(t, mustHex(t, tc.raw), elem.Raw)
+			}
+		})
+	}
+}
+
+func TestParseInformationElements_VendorDispatch(t *testing.T) {
+	registry := newIERegistry()
+	oui := [3]byte{0x00, 0x11, 0x22}
+	var typed, fallback []byte
+	registry.byVendor[vendorIEKey{oui: oui, vendorType: 7}] = ieDecoderEntry{name: "Typed", decode: func(_ *ParsedFrameInfo, data []byte) (interface{}, error) {
+		typed = data
+		return "typed", nil
+	}}
+	registry.byVendorOUI[oui] = ieDecoderEntry{name: "Any type", decode: func(_ *ParsedFrameInfo, data []byte) (interface{}, error) {
+		fallback = data
+		if len(data) < 2 {
+			return nil, errors.New("too short")
+		}
+		return "fallback", nil
+	}}
+
+	info := &ParsedFrameInfo{}
+	parseInformationElementsWith(registry, info, mustHex(t, "dd 05 001122 07 aa  dd 05 001122 08 bb  dd 04 001122 09"))
+	require.Len(t, info.Elements, 3)
+
+	assert.Equal(t, "Typed", info.Elements[0].Name)
+	assert.Equal(t, "typed", info.Elements[0].Decoded)
+	assert.Equal(t, mustHex(t, "aa"), typed, "typed decoders get the body after the vendor type")
+
+	assert.Equal(t, "Any type", info.Elements[1].Name)
+	assert.Equal(t, "fallback", info.Elements[1].Decoded)
+	assert.Equal(t, uint8(8), info.Elements[1].VendorType)
+
+	assert.Equal(t, mustHex(t, "09"), fallback, "OUI decoders get the body from the vendor type on")
+	assert.Equal(t, "too short", info.Elements[2].Error)
+	assert.Equal(t, mustHex(t, "00112209"), info.Elements[2].Raw)
+}
+
+func TestParseInformationElements_Truncated(t *testing.T) {
+	tests := []struct {
+		name  string
+		ies   string
+		names []string
+	}{
+		{"empty payload", "", nil},
+		{"length exceeds payload", "00 04 74657374  03 05 01", []string{"SSID"}},
+		{"missing length byte", "00 04 74657374  03", []string{"SSID"}},
+		{"zero length element", "00 00  03 01 06", []string{"SSID", "DS Parameter Set"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &ParsedFrameInfo{}
+			parseInformationElements(info, mustHex(t, tc.ies))
+			var names []string
+			for _, elem := range info.Elements {
+				names = append(names, elem.Name)
+			}
+			assert.Equal(t, tc.names, names)
+		})
+	}
+}
+
+func TestDecodeHTOperationIE(t *testing.T) {
+	basicMCS := " 00000000000000000000000000000000"
+	tests := []struct {
+		name      string
+		body      string
+		primary   uint8
+		offset    string
+		at40MHz   bool
+		preserved bool // HT Capabilities fields set before are kept
+	}{
+		{name: "40 MHz, secondary above", body: "24 05 0000 0000" + basicMCS, primary: 36, offset: "Above", at40MHz: true},
+		{name: "40 MHz, secondary below", body: "28 07 0000 0000" + basicMCS, primary: 40, offset: "Below", at40MHz: true},
+		{name: "secondary offset without any-width STA", body: "24 01 0000 0000" + basicMCS, primary: 36, offset: "Above"},
+		{name: "20 MHz", body: "06 04 0000 0000" + basicMCS, primary: 6, offset: "None"},
+		{name: "reserved offset", body: "06 06 0000 0000", primary: 6, offset: "Reserved"},
+		{name: "primary channel only", body: "0b", primary: 11},
+		{name: "keeps HT Capabilities", body: "24 05", primary: 36, offset: "Above", at40MHz: true, preserved: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &ParsedFrameInfo{}
+			if tc.preserved {
+				info.ParsedHTCaps = &HTCapabilityInfo{ChannelWidth40MHz: true, ShortGI20MHz: true}
+			}
+			decoded, err := decodeHTOperationIE(info, mustHex(t, tc.body))
+			require.NoError(t, err)
+			ht, ok := decoded.(*HTCapabilityInfo)
+			require.True(t, ok)
+			assert.Same(t, info.ParsedHTCaps, ht)
+			assert.Equal(t, tc.primary, ht.PrimaryChannel)
+			assert.Equal(t, tc.offset, ht.SecondaryChannelOffset)
+			assert.Equal(t, tc.at40MHz, ht.OperatingAt40MHz)
+			assert.Equal(t, tc.preserved, ht.ChannelWidth40MHz)
+			assert.Equal(t, tc.preserved, ht.ShortGI20MHz)
+		})
+	}
+
+	info := &ParsedFrameInfo{}
+	parseInformationElements(info, mustHex(t, "3d 16 24 05 0000 0000"+basicMCS))
+	require.Len(t, info.Elements, 1)
+	assert.Equal(t, "HT Operation", info.Elements[0].Name)
+	assert.Equal(t, uint8(36), info.ParsedHTCaps.PrimaryChannel)
+}
@@ -0,0 +1,223 @@
+package frame_parser
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/gopacket/layers"
+)
+
+// Authentication algorithm numbers (IEEE 802.11-2020, 9.4.1.1).
+const (
+	authAlgorithmOpen      uint16 = 0
+	authAlgorithmSharedKey uint16 = 1
+	authAlgorithmFT        uint16 = 2
+	authAlgorithmSAE       uint16 = 3
+	authAlgorithmFILSSK    uint16 = 4
+	authAlgorithmFILSSKPFS uint16 = 5
+	authAlgorithmFILSPK    uint16 = 6
+	authAlgorithmPASN      uint16 = 7
+)
+
+var authAlgorithmNames = map[uint16]string{
+	authAlgorithmOpen:      "Open",
+	authAlgorithmSharedKey: "Shared Key",
+	authAlgorithmFT:        "FT",
+	authAlgorithmSAE:       "SAE",
+	authAlgorithmFILSSK:    "FILS-SK",
+	authAlgorithmFILSSKPFS: "FILS-SK-PFS",
+	authAlgorithmFILSPK:    "FILS-PK",
+	authAlgorithmPASN:      "PASN",
+}
+
+// AuthInfo holds the fixed fields of an 802.11 Authentication frame.
+type AuthInfo struct {
+	Algorithm     uint16 `json:"algorithm"`
+	AlgorithmName string `json:"algorithm_name"` // "Open", "SAE", "FT", ...
+	Sequence      uint16 `json:"sequence"`
+	StatusCode    uint16 `json:"status_code"`
+	Status        string `json:"status"`                // Meaning of StatusCode
+	SAEMessage    string `json:"sae_message,omitempty"` // "Commit" or "Confirm"
+	SAEGroup      uint16 `json:"sae_group,omitempty"`   // Finite cyclic group of an SAE Commit
+}
+
+// AssocResponseInfo holds the fixed fields of a (Re)Association Response frame.
+type AssocResponseInfo struct {
+	StatusCode uint16 `json:"status_code"`
+	Status     string `json:"status"` // Meaning of StatusCode
+	AID        uint16 `json:"aid"`
+}
+
+// EAPOLKeyInfo describes an EAPOL-Key frame of the 4-way or group key handshake.
+type EAPOLKeyInfo struct {
+	Message           int    `json:"message"`         // 1-4 for the 4-way handshake, 1-2 for the group key handshake, 0 if unknown
+	GroupHandshake    bool   `json:"group_handshake"` // Group key handshake instead of 4-way handshake
+	DescriptorType    uint8  `json:"descriptor_type"`
+	DescriptorVersion uint8  `json:"descriptor_version"`
+	KeyInfo           uint16 `json:"key_info"`
+	Pairwise          bool   `json:"pairwise"`
+	Install           bool   `json:"install"`
+	Ack               bool   `json:"ack"`
+	MIC               bool   `json:"mic"`
+	Secure            bool   `json:"secure"`
+	Error             bool   `json:"error"`
+	Request           bool   `json:"request"`
+	EncryptedKeyData  bool   `json:"encrypted_key_data"`
+	KeyLength         uint16 `json:"key_length"`
+	ReplayCounter     uint64 `json:"replay_counter"`
+	KeyDataLength     uint16 `json:"key_data_length"`
+}
+
+// EAPInfo describes an EAP packet carried in EAPOL (802.1X authentication).
+type EAPInfo struct {
+	Code     string `json:"code"` // "Request", "Response", "Success", "Failure"
+	ID       uint8  `json:"id"`
+	TypeName string `json:"type,omitempty"` // e.g. "Identity", "TLS", "PEAP"
+}
+
+// parseAuthFrame decodes an Authentication frame body, including the SAE message type.
+func parseAuthFrame(info *ParsedFrameInfo, packet layerSource) {
+	authLayer, ok := packet.Layer(layers.LayerTypeDot11MgmtAuthentication).(*layers.Dot11MgmtAuthentication)
+	if !ok {
+		return
+	}
+	auth := &AuthInfo{
+		Algorithm:  uint16(authLayer.Algorithm),
+		Sequence:   authLayer.Sequence,
+		StatusCode: uint16(authLayer.Status),
+	}
+	auth.Status = StatusCodeString(auth.StatusCode)
+	auth.AlgorithmName = authAlgorithmNames[auth.Algorithm]
+	if auth.AlgorithmName == "" {
+		auth.AlgorithmName = fmt.Sprintf("Unknown(%d)", auth.Algorithm)
+	}
+	if auth.Algorithm == authAlgorithmSAE {
+		switch auth.Sequence {
+		case 1:
+			auth.SAEMessage = "Commit"
+			// The group is present for successful commits and H2E/SAE-PK commits (status 126/127).
+			if body := authLayer.Payload; len(body) >= 2 && (auth.StatusCode == 0 || auth.StatusCode == 126 || auth.StatusCode == 127) {
+				auth.SAEGroup = binary.LittleEndian.Uint16(body[0:2])
+			}
+		case 2:
+			auth.SAEMessage = "Confirm"
+		}
+	}
+	info.Auth = auth
+}
+
+// parseAssocResponse decodes Capability Info, Status Code and AID of a (Re)Association Response.
+func parseAssocResponse(info *ParsedFrameInfo, body []byte) {
+	if len(body) < 6 {
+		return
+	}
+	info.AssocResponse = &AssocResponseInfo{
+		StatusCode: binary.LittleEndian.Uint16(body[2:4]),
+		AID:        binary.LittleEndian.Uint16(body[4:6]) & 0x3FFF,
+	}
+	info.AssocResponse.Status = StatusCodeString(info.AssocResponse.StatusCode)
+	info.AssociationID = info.AssocResponse.AID
+}
+
+// parseEAPOL records EAPOL-Key and EAP packets found in a data frame.
+func parseEAPOL(info *ParsedFrameInfo, packet layerSource) {
+	if ek, ok := packet.Layer(layers.LayerTypeEAPOLKey).(*layers.EAPOLKey); ok {
+		key := &EAPOLKeyInfo{
+			DescriptorType:    uint8(ek.KeyDescriptorType),
+			DescriptorVersion: uint8(ek.KeyDescriptorVersion),
+			Pairwise:          ek.KeyType == layers.EAPOLKeyTypePairwise,
+			Install:           ek.Install,
+			Ack:               ek.KeyACK,
+			MIC:               ek.KeyMIC,
+			Secure:            ek.Secure,
+			Error:             ek.MICError,
+			Request:           ek.Request,
+			EncryptedKeyData:  ek.HasEncryptedKeyData,
+			KeyLength:         ek.KeyLength,
+			ReplayCounter:     ek.ReplayCounter,
+			KeyDataLength:     ek.KeyDataLength,
+		}
+		if len(ek.Contents) >= 3 {
+			key.KeyInfo = binary.BigEndian.Uint16(ek.Contents[1:3])
+		}
+		key.Message, key.GroupHandshake = eapolKeyMessage(key, ek.Nonce)
+		info.EAPOLKey = key
+		return
+	}
+	if eap, ok := packet.Layer(layers.LayerTypeEAP).(*layers.EAP); ok {
+		e := &EAPInfo{ID: eap.Id}
+		switch eap.Code {
+		case layers.EAPCodeRequest:
+			e.Code = "Request"
+		case layers.EAPCodeResponse:
+			e.Code = "Response"
+		case layers.EAPCodeSuccess:
+			e.Code = "Success"
+		case layers.EAPCodeFailure:
+			e.Code = "Failure"
+		default:
+			e.Code = fmt.Sprintf("Unknown(%d)", eap.Code)
+		}
+		if eap.Code == layers.EAPCodeRequest || eap.Code == layers.EAPCodeResponse {
+			e.TypeName = eapTypeName(uint8(eap.Type))
+		}
+		info.EAP = e
+	}
+}
+
+// eapolKeyMessage infers the handshake message number from the Key Information bits.
+// M2 and M4 both carry MIC without ACK; M4 has Secure set (and a zero nonce) in RSN.
+func eapolKeyMessage(key *EAPOLKeyInfo, nonce []byte) (int, bool) {
+	if !key.Pairwise {
+		if key.Ack {
+			return 1, true
+		}
+		return 2, true
+	}
+	switch {
+	case key.Ack && !key.MIC:
+		return 1, false
+	case key.Ack && key.MIC && key.Install:
+		return 3, false
+	case !key.Ack && key.MIC && !key.Request:
+		if key.Secure || isZeroNonce(nonce) {
+			return 4, false
+		}
+		return 2, false
+	}
+	return 0, false
+}
+
+func isZeroNonce(nonce []byte) bool {
+	for _, b := range nonce {
+		if b != 0 {
+			return false
+		}
+	}
+	return len(nonce) > 0
+}
+
+func eapTypeName(t uint8) string {
+	switch t {
+	case 1:
+		return "Identity"
+	case 2:
+		return "Notification"
+	case 3:
+		return "NAK"
+	case 4:
+		return "MD5-Challenge"
+	case 13:
+		return "TLS"
+	case 21:
+		return "TTLS"
+	case 25:
+		return "PEAP"
+	case 43:
+		return "FAST"
+	case 52:
+		return "PWD"
+	default:
+		return fmt.Sprintf("Type(%d)", t)
+	}
+}
@@ -0,0 +1,68 @@
+package frame_parser
+
+import (
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEAPOLKeyMessage(t *testing.T) {
+	nonce := mustHex(t, "0102030405060708")
+	zero := make([]byte, 8)
+	cases := []struct {
+		name    string
+		key     EAPOLKeyInfo
+		nonce   []byte
+		message int
+		group   bool
+	}{
+		{"M1", EAPOLKeyInfo{Pairwise: true, Ack: true}, nonce, 1, false},
+		{"M2", EAPOLKeyInfo{Pairwise: true, MIC: true}, nonce, 2, false},
+		{"M3", EAPOLKeyInfo{Pairwise: true, Ack: true, MIC: true, Install: true, Secure: true}, nonce, 3, false},
+		{"M4", EAPOLKeyInfo{Pairwise: true, MIC: true, Secure: true}, zero, 4, false},
+		{"M4 without Secure (WPA1)", EAPOLKeyInfo{Pairwise: true, MIC: true}, zero, 4, false},
+		{"Group M1", EAPOLKeyInfo{Ack: true, MIC: true, Secure: true}, zero, 1, true},
+		{"Group M2", EAPOLKeyInfo{MIC: true, Secure: true}, zero, 2, true},
+		{"Request", EAPOLKeyInfo{Pairwise: true, MIC: true, Request: true}, zero, 0, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			message, group := eapolKeyMessage(&tc.key, tc.nonce)
+			assert.Equal(t, tc.message, message)
+			assert.Equal(t, tc.group, group)
+		})
+	}
+}
+
+func TestParseAssocResponse(t *testing.T) {
+	info := &ParsedFrameInfo{}
+	// Capability Info, Status Code 17 (AP unable to handle additional STAs), AID 5 with the top bits set
+	parseAssocResponse(info, mustHex(t, "1104 1100 05c0"))
+	require.NotNil(t, info.AssocResponse)
+	assert.Equal(t, uint16(17), info.AssocResponse.StatusCode)
+	assert.Equal(t, uint16(5), info.AssocResponse.AID)
+	assert.Equal(t, uint16(5), info.AssociationID)
+}
+
+func TestParsePacket_DeauthReasonCode(t *testing.T) {
+	// Deauthentication from AP 02:00:00:00:00:aa to STA 02:00:00:00:00:01, reason 15 (FCS appended)
+	data := append(mustHex(t, "c000 0000 020000000001 0200000000aa 0200000000aa 1000 0f00"), 0, 0, 0, 0)
+	packet := gopacket.NewPacket(data, layers.LayerTypeDot11, gopacket.Default)
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+
+	assert.Equal(t, "MgmtDeauthentication", info.FrameType)
+	assert.Equal(t, uint16(15), info.ReasonCode)
+	assert.Equal(t, "4-way handshake timeout", info.Reason)
+}
+
+func TestStatusAndReasonCodeStrings(t *testing.T) {
+	assert.Equal(t, "Success", StatusCodeString(0))
+	assert.Equal(t, "Anti-clogging token required", StatusCodeString(76))
+	assert.Equal(t, "Reserved/unknown status (65535)", StatusCodeString(65535))
+	assert.Equal(t, "Disassociated due to inactivity", ReasonCodeString(4))
+	assert.Equal(t, "Reserved/unknown reason (0)", ReasonCodeString(0))
+}
@@ -1,26 +1,27 @@
 package frame_parser
 
 import (
+	"WifiPcapAnalyzer/config"
 	"WifiPcapAnalyzer/logger"
-	"WifiPcapAnalyzer/utils"
-	"strings"
 
 	// "encoding/csv" // No longer needed after CSVParser removal
 	// "encoding/hex" // No longer needed
+	"bufio"
 	"fmt"
-	// "io" // No longer needed
+	"io"
 	"net"
+	"os"
 	// "os/exec" // No longer needed after TSharkExecutor removal
 	// "strconv" // No longer needed
 	// "strings" // No longer needed
 	"time"
-	"unicode/utf8"
 
 	"encoding/binary"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
+	"github.com/google/gopacket/pcapgo"
 )
 
 // GoPacketParser will use gopacket to parse 802.11 frames.
@@ -107,38 +108,83 @@ type HECapabilityInfo struct {
 
 // ParsedFrameInfo holds extracted information from a single 802.11 frame.
 type ParsedFrameInfo struct {
-	Timestamp              time.Time
-	FrameType              string // e.g., "Beacon", "ProbeResp", "Data", "QoSData" (derived from wlan.fc.type_subtype)
-	WlanFcType             uint8  // WLAN Frame Type (integer)
-	WlanFcSubtype          uint8  // WLAN Frame Subtype (integer)
-	BSSID                  net.HardwareAddr
-	SA                     net.HardwareAddr
-	DA                     net.HardwareAddr
-	RA                     net.HardwareAddr
-	TA                     net.HardwareAddr
-	Channel                int      // Derived from radiotap.channel.freq or wlan.ds.current_channel
-	Frequency              int      // radiotap.channel.freq
-	SignalStrength         int      // radiotap.dbm_antsignal
-	NoiseLevel             int      // radiotap.dbm_antnoise
-	Bandwidth              string   // Derived from HT/VHT/HE capabilities
-	SSID                   string   // wlan.ssid
-	SupportedRates         []string // From relevant IEs, if parsed
-	DSSetChannel           uint8    // wlan.ds.current_channel
-	TIM                    []byte   // wlan.tim (raw bytes or parsed structure)
-	RSNRaw                 []byte   // wlan.rsn.* (raw bytes or parsed structure)
-	Security               string   // Security information
-	IsQoSData              bool     // Derived from frame type/subtype
-	ParsedHTCaps           *HTCapabilityInfo
-	ParsedVHTCaps          *VHTCapabilityInfo
-	ParsedHECaps           *HECapabilityInfo // New
-	FrameLength            int               // frame.len (original frame length)
-	FrameCapLength         int               // frame.cap_len (captured frame length)
-	PHYRateMbps            float64           // Estimated PHY rate in Mbps
-	IsShortPreamble        bool              // Potentially from radiotap flags (if available) or inferred
-	IsShortGI              bool              // From Radiotap MCS/HT/VHT/HE flags or capabilities
-	TransportPayloadLength int               // L4+ payload length (ip.len, ipv6.plen, tcp.len, udp.length)
-	MACDurationID          uint16            // wlan.duration
-	RetryFlag              bool              // wlan.flags.retry
+	Timestamp       time.Time
+	FrameType       string // e.g., "Beacon", "ProbeResp", "Data", "QoSData" (derived from wlan.fc.type_subtype)
+	WlanFcType      uint8  // WLAN Frame Type (integer)
+	WlanFcSubtype   uint8  // WLAN Frame Subtype (integer)
+	BSSID           net.HardwareAddr
+	SA              net.HardwareAddr
+	DA              net.HardwareAddr
+	RA              net.HardwareAddr
+	TA              net.HardwareAddr
+	Channel         int              // Derived from radiotap.channel.freq or wlan.ds.current_channel
+	Frequency       int              // radiotap.channel.freq
+	Band            string           // Derived from radiotap.channel.freq, e.g. "2.4GHz", "5GHz", "6GHz"
+	SignalStrength  int              // radiotap.dbm_antsignal
+	NoiseLevel      int              // radiotap.dbm_antnoise
+	FCSPresent      bool             // Capture header says the frame ends with its FCS (radiotap/PPI flags)
+	BadFCS          bool             // FCS check failed, by the capture driver or by our own CRC check
+	Bandwidth       string           // Derived from HT/VHT/HE capabilities
+	SSID            string           // wlan.ssid
+	SupportedRates  []string         // From relevant IEs, if parsed
+	DSSetChannel    uint8            // wlan.ds.current_channel
+	TIM             []byte           // wlan.tim (raw bytes or parsed structure)
+	RSNRaw          []byte           // wlan.rsn.* (raw bytes or parsed structure)
+	Security        string           // Security information (SecurityProfile.Label when available)
+	SecurityProfile *SecurityProfile // Structured RSN/WPA security information
+	IsQoSData       bool             // Derived from frame type/subtype
+	ParsedHTCaps    *HTCapabilityInfo
+	ParsedVHTCaps   *VHTCapabilityInfo
+	ParsedHECaps    *HECapabilityInfo  // New
+	WPA             *WPAInfo           // Legacy WPA1 vendor element
+	OWETransition   bool               // Wi-Fi Alliance OWE Transition Mode element present
+	WMM             *WMMInfo           // WMM Information/Parameter vendor element
+	WPS             *WPSInfo           // Wi-Fi Protected Setup vendor element
+	Cisco           *CiscoInfo         // Cisco proprietary elements (AP name, CCX version)
+	Control         *ControlFrameInfo  // Decoded control frame body (BAR/BA, Trigger, NDPA, ...)
+	AssociationID   uint16             // AID from (Re)Association Response frames
+	AssocResponse   *AssocResponseInfo // Status code and AID of (Re)Association Response frames
+	Auth            *AuthInfo          // Authentication frame fields (algorithm, sequence, status, SAE message)
+	EAPOLKey        *EAPOLKeyInfo      // EAPOL-Key frame of the 4-way/group key handshake
+	EAP             *EAPInfo           // EAP packet (802.1X authentication)
+	ReasonCode      uint16             // Reason code of Deauthentication/Disassociation frames
+	Reason          string             // Meaning of ReasonCode (IEEE 802.11 Table 9-49)
+	// Load and regulatory elements
+	BSSLoad                *BSSLoadInfo                // BSS Load element (AP-reported station count and channel utilization)
+	Country                *CountryInfo                // Country element
+	PowerConstraintDB      uint8                       // Power Constraint element, 0 when absent
+	TransmitPowerEnvelopes []TransmitPowerEnvelopeInfo // Transmit Power Envelope elements
+	// 802.11k/v/r roaming support and exchanges
+	RMCapabilities         *RMCapabilitiesInfo       // RM Enabled Capabilities element (802.11k)
+	ExtendedCapabilities   *ExtendedCapabilitiesInfo // Extended Capabilities element (BSS Transition bit for 802.11v)
+	MobilityDomain         *MobilityDomainInfo       // Mobility Domain element (802.11r)
+	FastBSSTransition      *FTInfo                   // Fast BSS Transition element (802.11r)
+	RoamingAction          *RoamingActionInfo        // Neighbor Report / BSS Transition Management action frame
+	Action                 *ActionFrameInfo          // Category/Action and typed body of Action frames
+	FrameLength            int                       // frame.len (original frame length)
+	FrameCapLength         int                       // frame.cap_len (captured frame length)
+	PHYRateMbps            float64                   // Estimated PHY rate in Mbps
+	IsShortPreamble        bool                      // Potentially from radiotap flags (if available) or inferred
+	IsShortGI              bool                      // From Radiotap MCS/HT/VHT/HE flags or capabilities
+	TransportPayloadLength int                       // L4+ payload length (ip.len, ipv6.plen, tcp.len, udp.length)
+	MACDurationID          uint16                    // wlan.duration
+	RetryFlag              bool                      // wlan.flags.retry
+	Decrypted              bool                      // Frame body was decrypted with a configured key
+	AMPDU                  *AMPDUInfo                // radiotap.ampdu.*, nil when the frame was not part of an A-MPDU
+	AMSDU                  bool                      // QoS Control A-MSDU Present bit
+	AMSDUSubframes         []AMSDUSubframe           // Decoded A-MSDU subframes (empty if the body is encrypted)
+	// Upper layers of unencrypted (or decrypted) data frames
+	Flow *FlowInfo // IP addresses, protocol and TCP/UDP ports
+	DHCP *DHCPInfo
+	ARP  *ARPInfo
+	DNS  *DNSInfo // DNS or mDNS
+	SSDP *SSDPInfo
+	// Capture source details
+	LinkType       layers.LinkType // Link type the frame was decoded with
+	RawData        []byte          // Frame bytes as captured (before decryption), kept for export
+	InterfaceID    int             // pcapng interface (Interface Description Block index), 0 for pcap
+	InterfaceName  string          // pcapng if_name option
+	PacketComments []string        // pcapng opt_comment options of the packet
 	// Fields from radiotap.mcs.*, radiotap.vht.*, radiotap.he.* for PhyRateCalculator
 	RadiotapDataRate   float64 // radiotap.datarate (legacy)
 	RadiotapMCSIndex   uint8   // radiotap.mcs.index
@@ -154,20 +200,196 @@ type ParsedFrameInfo struct {
 	RadiotapHEGI       string  // radiotap.he.gi (e.g., "0.8us", "1.6us", "3.2us")
 	BitRate            float64 // STA BitRate
 
-	// Raw tshark fields for debugging or further processing if needed
-	// This field might be removed or re-purposed if not used by gopacket direct parsing.
-	RawFields map[string]string
+	// Elements holds every information element of a management frame body, in frame order.
+	// Decoded elements carry a typed result; unknown elements keep their raw bytes.
+	Elements []InformationElement
 }
 
-// PacketInfoHandler is a function that processes parsed frame information.
+// PacketInfoHandler is a function that processes parsed frame information. With the fast
+// parser the ParsedFrameInfo is reused once the handler returns, so handlers must not keep
+// the pointer; the slices and pointers it holds may be kept.
 type PacketInfoHandler func(info *ParsedFrameInfo)
 
+// frameProcessor runs the parser, and the configured decryptor, over a sequence of packets.
+// With more than one worker and no decryptor, packets are decoded and parsed by a worker pool
+// (see pipeline.go) and the handler is called from a single goroutine, in capture order.
+type frameProcessor struct {
+	parser        *GoPacketParser // For decoded packets and decryption
+	newParser     func() FrameParser
+	inlineParser  FrameParser // nil when parsing on workers
+	recycleFrames bool        // ParsedFrameInfo objects go back to the pool after the handler
+	decryptor     *Decryptor
+	handler       PacketInfoHandler
+	frameCount    int
+	errorCount    int
+	badFCSCount   int
+
+	workers    []chan *frameJob // Per-worker queues; nil when parsing inline
+	pending    chan *frameJob   // Jobs in capture order, consumed by the writer
+	writerDone chan struct{}
+}
+
+func newFrameProcessor(pktHandler PacketInfoHandler) *frameProcessor {
+	return newFrameProcessorWithWorkers(pktHandler, parseWorkers())
+}
+
+func newFrameProcessorWithWorkers(pktHandler PacketInfoHandler, workers int) *frameProcessor {
+	fp := &frameProcessor{
+		parser:    &GoPacketParser{},
+		decryptor: newConfiguredDecryptor(), // nil unless decryption keys are configured
+		handler:   pktHandler,
+	}
+	fp.newParser = func() FrameParser { return fp.parser }
+	switch config.GlobalConfig.Parser {
+	case "", ParserGoPacket:
+	case ParserFast:
+		if fp.decryptor != nil {
+			logger.Log.Info().Msg("Fast parser not used: decryption needs fully decoded packets")
+			break
+		}
+		fp.newParser = func() FrameParser { return NewFastParser() }
+		fp.recycleFrames = true
+	default:
+		logger.Log.Warn().Str("parser", config.GlobalConfig.Parser).Msg("Unknown parser in config, using gopacket")
+	}
+
+	if workers > 1 && fp.decryptor != nil {
+		logger.Log.Info().Int("workers", workers).Msg("Parsing inline: decryption needs frames in capture order")
+		workers = 1
+	}
+	if workers > 1 {
+		fp.startPipeline(workers)
+	} else {
+		fp.inlineParser = fp.newParser()
+	}
+	return fp
+}
+
+// process parses one decoded packet and hands the result to the handler. annotate, if not nil,
+// adds details about the capture source before the handler sees the frame.
+func (fp *frameProcessor) process(packet gopacket.Packet, linkType layers.LinkType, annotate func(info *ParsedFrameInfo)) {
+	job := newFrameJob()
+	job.packet = packet
+	job.data = packet.Data()
+	job.linkType = linkType
+	job.annotate = annotate
+	fp.submit(job)
+}
+
+// processData is process for packet data that has not been decoded yet.
+func (fp *frameProcessor) processData(data []byte, ci gopacket.CaptureInfo, linkType layers.LinkType, annotate func(info *ParsedFrameInfo)) {
+	job := newFrameJob()
+	job.data = data
+	job.ci = ci
+	job.linkType = linkType
+	job.annotate = annotate
+	fp.submit(job)
+}
+
+// parse decodes, decrypts and parses the packet of a job with the given parser. Without a
+// decryptor it only touches the job and the parser, so workers can run it concurrently.
+func (fp *frameProcessor) parse(job *frameJob, parser FrameParser) {
+	var parsedInfo *ParsedFrameInfo
+	var err error
+	decrypted := false
+	if job.packet == nil && fp.decryptor == nil {
+		// The data is owned by the job, so the decoded layers can point into it.
+		parsedInfo, err = parser.ParseData(job.data, job.ci, job.linkType)
+	} else {
+		packet := job.packet
+		if packet == nil {
+			packet = gopacket.NewPacket(job.data, linkTypeDecoder(job.linkType), gopacket.DecodeOptions{NoCopy: true})
+			packet.Metadata().CaptureInfo = job.ci
+		}
+		if fp.decryptor != nil {
+			packet, decrypted = fp.decryptor.Process(packet)
+		}
+		parsedInfo, err = fp.parser.ParsePacket(packet)
+	}
+	if err != nil {
+		// Log more detailed error, including packet dump if small enough or relevant parts
+		// logger.Log.Warn().Err(err).Int("frameNum", fp.frameCount).Msg("Error parsing packet")
+
+		// Consider logging a snippet of the packet data for debugging difficult cases.
+		// Example: logger.Log.Debug().Str("packet_data_snippet", hex.EncodeToString(packet.Data()[:min(32, len(packet.Data()))])).Msg("Packet data snippet on error")
+		job.err = err
+		return
+	}
+
+	if parsedInfo != nil {
+		parsedInfo.Decrypted = decrypted
+		parsedInfo.LinkType = job.linkType
+		parsedInfo.RawData = job.data // As captured, before decryption
+		if job.annotate != nil {
+			job.annotate(parsedInfo)
+		}
+	}
+	job.info = parsedInfo
+}
+
+// deliver counts the outcome of a parsed job and hands the frame to the handler.
+func (fp *frameProcessor) deliver(job *frameJob) {
+	if job.err != nil {
+		fp.errorCount++
+		pipelineCounters.parseErrors.Add(1)
+		// Continue processing other packets
+		return
+	}
+	if job.info != nil {
+		if job.info.BadFCS {
+			fp.badFCSCount++
+		}
+		pipelineCounters.framesParsed.Add(1)
+		fp.handler(job.info)
+		if fp.recycleFrames {
+			releaseParsedFrameInfo(job.info)
+		}
+	}
+}
+
+// finish waits for the frames still in the pipeline, logs the totals and reports parse errors.
+func (fp *frameProcessor) finish(source string) error {
+	fp.stopPipeline()
+	logger.Log.Info().
+		Int("totalFrames", fp.frameCount).
+		Int("errorCount", fp.errorCount).
+		Int("badFCSCount", fp.badFCSCount).
+		Msgf("INFO_PCAP_PROCESS: Finished processing packets from %s", source)
+
+	if fp.errorCount > 0 {
+		return fmt.Errorf("encountered %d errors during packet parsing", fp.errorCount)
+	}
+	return nil
+}
+
+// linkTypeOfPacket infers the capture link type from the first decoded layer, for packet
+// sources that do not expose it.
+func linkTypeOfPacket(packet gopacket.Packet) layers.LinkType {
+	pktLayers := packet.Layers()
+	if len(pktLayers) == 0 {
+		return layers.LinkTypeNull
+	}
+	switch pktLayers[0].LayerType() {
+	case layers.LayerTypeRadioTap:
+		return layers.LinkTypeIEEE80211Radio
+	case layers.LayerTypeDot11:
+		return layers.LinkTypeIEEE802_11
+	case layers.LayerTypePrismHeader:
+		return layers.LinkTypePrismHeader
+	case LayerTypePPI:
+		return LinkTypePPI
+	case LayerTypeAVS:
+		return LinkTypeAVS
+	case layers.LayerTypeEthernet:
+		return layers.LinkTypeEthernet
+	}
+	return layers.LinkTypeNull
+}
+
 // ProcessPacketSource is the main entry point for parsing pcap data
 // from a gopacket.PacketDataSource.
 func ProcessPacketSource(packetSource *gopacket.PacketSource, pktHandler PacketInfoHandler) error {
-	parser := &GoPacketParser{}
-	frameCount := 0
-	errorCount := 0
+	fp := newFrameProcessor(pktHandler)
 
 	logger.Log.Info().Msg("INFO_PCAP_PROCESS: Starting packet processing from gopacket.PacketSource")
 
@@ -176,51 +398,123 @@ func ProcessPacketSource(packetSource *gopacket.PacketSource, pktHandler PacketI
 			logger.Log.Warn().Msg("WARN_PCAP_PROCESS: Nil packet received from source, stopping.")
 			break // End of stream or error
 		}
-		frameCount++
+		fp.process(packet, linkTypeOfPacket(packet), nil)
+	}
 
-		// Log basic packet metadata
-		// logger.Log.Debug().
-		// 	Int("frameNum", frameCount).
-		// 	Time("timestamp", packet.Metadata().Timestamp).
-		// 	Int("length", packet.Metadata().Length).
-		// 	Msg("Processing packet")
+	return fp.finish("gopacket.PacketSource")
+}
 
-		parsedInfo, err := parser.ParsePacket(packet)
-		if err != nil {
-			errorCount++
-			// Log more detailed error, including packet dump if small enough or relevant parts
-			// logger.Log.Warn().Err(err).Int("frameNum", frameCount).Msg("Error parsing packet")
+// ProcessPcapngReader parses every packet of a pcapng stream. Each packet is decoded with the
+// link type of its own interface, and carries its interface and packet comments.
+func ProcessPcapngReader(reader *PcapngReader, pktHandler PacketInfoHandler) error {
+	fp := newFrameProcessor(pktHandler)
+	customBlocks := 0
+	var drops dropCounter
 
-			// Consider logging a snippet of the packet data for debugging difficult cases.
-			// Example: logger.Log.Debug().Str("packet_data_snippet", hex.EncodeToString(packet.Data()[:min(32, len(packet.Data()))])).Msg("Packet data snippet on error")
+	logger.Log.Info().
+		Str("application", reader.Section().Application).
+		Msg("INFO_PCAP_PROCESS: Starting packet processing from pcapng stream")
 
-			// Continue processing other packets
-			continue
+	var readErr error
+	for {
+		pkt, err := reader.Next()
+		drops.update(reader)
+		if err != nil {
+			if err != io.EOF {
+				logger.Log.Error().Err(err).Int("frameNum", fp.frameCount).Msg("Error reading pcapng stream")
+				readErr = err
+			}
+			break
+		}
+		for ; customBlocks < len(reader.CustomBlocks()); customBlocks++ {
+			cb := reader.CustomBlocks()[customBlocks]
+			logger.Log.Debug().Uint32("pen", cb.PEN).Int("length", len(cb.Data)).Msg("pcapng custom block")
 		}
+		intf, _ := reader.Interface(pkt.InterfaceID)
+		fp.processData(pkt.Data, pkt.CaptureInfo, intf.LinkType, pcapngAnnotation(pkt, intf))
+	}
+	if skipped := reader.SkippedCustomBlocks(); skipped > 0 {
+		logger.Log.Warn().Int("skipped", skipped).Msg("pcapng custom blocks beyond the limit were not kept")
+	}
 
-		if parsedInfo != nil {
-			// The RawFields map is not populated by GoPacketParser, initialize if nil to prevent panic
-			if parsedInfo.RawFields == nil {
-				parsedInfo.RawFields = make(map[string]string)
-			}
-			pktHandler(parsedInfo)
+	if err := fp.finish("pcapng stream"); err != nil {
+		return err
+	}
+	if readErr != nil {
+		return fmt.Errorf("reading pcapng stream: %w", readErr)
+	}
+	return nil
+}
+
+// pcapngAnnotation adds the interface and the comments of a pcapng packet to its frame.
+func pcapngAnnotation(pkt *PcapngPacket, intf PcapngInterface) func(info *ParsedFrameInfo) {
+	return func(info *ParsedFrameInfo) {
+		info.InterfaceID = pkt.InterfaceID
+		info.InterfaceName = intf.Name
+		info.PacketComments = pkt.Comments
+	}
+}
+
+// ProcessCaptureStream processes a pcap or pcapng stream, detected from its magic number.
+func ProcessCaptureStream(stream io.Reader, pktHandler PacketInfoHandler) error {
+	br := bufio.NewReader(stream)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return fmt.Errorf("reading capture stream header: %w", err)
+	}
+	if IsPcapngMagic(magic) {
+		reader, err := NewPcapngReader(br)
+		if err != nil {
+			return err
 		}
+		return ProcessPcapngReader(reader, pktHandler)
 	}
 
-	logger.Log.Info().
-		Int("totalFrames", frameCount).
-		Int("errorCount", errorCount).
-		Msg("INFO_PCAP_PROCESS: Finished processing packets from gopacket.PacketSource")
+	r, err := pcapgo.NewReader(br)
+	if err != nil {
+		return fmt.Errorf("creating pcapgo.Reader: %w", err)
+	}
+	return processPacketData(r, r.LinkType(), "pcap stream", pktHandler)
+}
+
+// processPacketData parses the packets of a pcap data source. Unlike ProcessPacketSource, the
+// packets are decoded by the parse workers rather than while reading.
+func processPacketData(src gopacket.PacketDataSource, linkType layers.LinkType, source string, pktHandler PacketInfoHandler) error {
+	fp := newFrameProcessor(pktHandler)
+
+	logger.Log.Info().Str("linkType", linkType.String()).Msgf("INFO_PCAP_PROCESS: Starting packet processing from %s", source)
 
-	if errorCount > 0 {
-		return fmt.Errorf("encountered %d errors during packet parsing", errorCount)
+	for {
+		data, ci, err := src.ReadPacketData()
+		if err != nil {
+			if err != io.EOF {
+				logger.Log.Warn().Err(err).Int("frameNum", fp.frameCount).Msgf("WARN_PCAP_PROCESS: Error reading %s, stopping.", source)
+			}
+			break
+		}
+		fp.processData(data, ci, linkType, nil)
 	}
-	return nil
+
+	return fp.finish(source)
 }
 
-// ProcessPcapFile processes a pcap file using gopacket.
+// ProcessPcapFile processes a pcap or pcapng file using gopacket.
 func ProcessPcapFile(pcapFilePath string, _ string /* tsharkPath (unused) */, pktHandler PacketInfoHandler) error {
 	logger.Log.Info().Str("filePath", pcapFilePath).Msg("INFO_PCAP_PROCESS: Opening pcap file for gopacket processing")
+	if isPcapngFile(pcapFilePath) {
+		f, err := os.Open(pcapFilePath)
+		if err != nil {
+			return fmt.Errorf("opening pcapng file: %w", err)
+		}
+		defer f.Close()
+		reader, err := NewPcapngReader(f)
+		if err != nil {
+			logger.Log.Error().Err(err).Str("filePath", pcapFilePath).Msg("Error opening pcapng file")
+			return err
+		}
+		return ProcessPcapngReader(reader, pktHandler)
+	}
+
 	handle, err := pcap.OpenOffline(pcapFilePath)
 	if err != nil {
 		logger.Log.Error().Err(err).Str("filePath", pcapFilePath).Msg("Error opening pcap file with gopacket")
@@ -228,12 +522,25 @@ func ProcessPcapFile(pcapFilePath string, _ string /* tsharkPath (unused) */, pk
 	}
 	defer handle.Close()
 
-	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
-	return ProcessPacketSource(packetSource, pktHandler)
+	return processPacketData(handle, handle.LinkType(), "pcap file", pktHandler)
+}
+
+func isPcapngFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return IsPcapngMagic(magic)
 }
 
 // ProcessPcapStream processes a pcap stream using gopacket.
 // The packetSource is now expected to be created by the caller (e.g., from pcapgo.NewReader).
+// Use ProcessCaptureStream for streams that may be pcapng.
 func ProcessPcapStream(packetSource *gopacket.PacketSource, _ string /* tsharkPath (unused) */, pktHandler PacketInfoHandler) error {
 	logger.Log.Info().Msg("INFO_PCAP_PROCESS: Starting pcap stream processing with gopacket")
 	return ProcessPacketSource(packetSource, pktHandler)
@@ -289,18 +596,40 @@ func CalculateFrameAirtime(frameLengthBytes int, phyRateMbps float64, isShortPre
 
 // ParsePacket uses gopacket to parse an 802.11 frame and extract information.
 func (p *GoPacketParser) ParsePacket(packet gopacket.Packet) (*ParsedFrameInfo, error) {
-	info := &ParsedFrameInfo{
-		Timestamp:      packet.Metadata().Timestamp,
-		FrameLength:    packet.Metadata().Length,
-		FrameCapLength: packet.Metadata().CaptureLength,
-		RawFields:      make(map[string]string),
-	}
+	return parseFrame(packet, &ParsedFrameInfo{})
+}
+
+// ParseData decodes packet data of the given link type and parses it with ParsePacket.
+func (p *GoPacketParser) ParseData(data []byte, ci gopacket.CaptureInfo, linkType layers.LinkType) (*ParsedFrameInfo, error) {
+	// The caller owns the data, so the decoded layers can point into it.
+	packet := gopacket.NewPacket(data, linkTypeDecoder(linkType), gopacket.DecodeOptions{NoCopy: true})
+	packet.Metadata().CaptureInfo = ci
+	return p.ParsePacket(packet)
+}
+
+// layerSource is the part of gopacket.Packet that parseFrame needs. FastParser provides it
+// over its preallocated layers.
+type layerSource interface {
+	Layer(gopacket.LayerType) gopacket.Layer
+	Metadata() *gopacket.PacketMetadata
+}
+
+// parseFrame extracts the frame information from the decoded layers into info, which must
+// be zeroed.
+func parseFrame(packet layerSource, info *ParsedFrameInfo) (*ParsedFrameInfo, error) {
+	info.Timestamp = packet.Metadata().Timestamp
+	info.FrameLength = packet.Metadata().Length
+	info.FrameCapLength = packet.Metadata().CaptureLength
 
 	if radiotapLayer := packet.Layer(layers.LayerTypeRadioTap); radiotapLayer != nil {
 		rt, ok := radiotapLayer.(*layers.RadioTap)
 		if !ok {
 			return nil, fmt.Errorf("failed to assert RadioTap layer")
 		}
+		if rt.Present.Flags() {
+			info.FCSPresent = rt.Flags.FCS()
+			info.BadFCS = rt.Flags.BadFCS()
+		}
 		if rt.Present.DBMAntennaSignal() {
 			info.SignalStrength = int(rt.DBMAntennaSignal)
 		}
@@ -308,8 +637,7 @@ func (p *GoPacketParser) ParsePacket(packet gopacket.Packet) (*ParsedFrameInfo,
 			info.NoiseLevel = int(rt.DBMAntennaNoise)
 		}
 		if rt.Present.Channel() {
-			info.Frequency = int(rt.ChannelFrequency)
-			info.Channel = utils.FrequencyToChannel(info.Frequency)
+			setChannelFromFrequency(info, int(rt.ChannelFrequency))
 		}
 		if rt.Present.Rate() {
 			info.RadiotapDataRate = float64(rt.Rate) * 0.5
@@ -336,21 +664,38 @@ func (p *GoPacketParser) ParsePacket(packet gopacket.Packet) (*ParsedFrameInfo,
 				info.RadiotapMCSBw = 0 // Defaulting to 20MHz for these cases, aligning with info.RadiotapMCSBw structure
 			}
 		}
+		if rt.Present.AMPDUStatus() {
+			parseAMPDUStatus(info, rt)
+		}
 		if rt.Present.VHT() {
 			// VHT parsing
 		}
-	} else {
-		logger.Log.Warn().Msg("No Radiotap layer found in packet")
+	} else if !applyCaptureHeader(info, packet) {
+		logger.Log.Debug().Msg("No Radiotap, PPI, Prism or AVS header found in packet")
 	}
 
 	dot11Layer := packet.Layer(layers.LayerTypeDot11)
 	if dot11Layer == nil {
+		if info.BadFCS {
+			// Corrupted beyond decoding, but still counts as a bad frame on this channel.
+			return info, nil
+		}
 		return nil, fmt.Errorf("no Dot11 layer found")
 	}
 	dot11, ok := dot11Layer.(*layers.Dot11)
 	if !ok {
 		return nil, fmt.Errorf("failed to assert Dot11 layer")
 	}
+	// gopacket strips the last 4 bytes as FCS; they are only the real FCS when the capture
+	// header says so (otherwise radiotap decoding appends a computed one).
+	if info.FCSPresent && !info.BadFCS && !dot11.ChecksumValid() {
+		info.BadFCS = true
+	}
+	if info.BadFCS {
+		info.FrameType = dot11.Type.String()
+		info.TA = dot11.Address2
+		return info, nil // Addresses and body may be garbage; leave the rest unparsed
+	}
 
 	mainType := dot11.Type.MainType()
 	info.WlanFcType = uint8(mainType)
@@ -431,121 +776,64 @@ func (p *GoPacketParser) ParsePacket(packet gopacket.Packet) (*ParsedFrameInfo,
 			} else {
 				logger.Log.Debug().Msg("Dot11MgmtProbeReq layer not found by direct type request.")
 			}
+		case layers.Dot11TypeMgmtAssociationReq:
+			if len(dot11.Payload) >= 4 { // Capability Info, Listen Interval
+				iePayload = dot11.Payload[4:]
+			}
+		case layers.Dot11TypeMgmtReassociationReq:
+			if len(dot11.Payload) >= 10 { // Capability Info, Listen Interval, Current AP Address
+				iePayload = dot11.Payload[10:]
+			}
+		case layers.Dot11TypeMgmtAssociationResp, layers.Dot11TypeMgmtReassociationResp:
+			parseAssocResponse(info, dot11.Payload)
+		case layers.Dot11TypeMgmtAuthentication:
+			parseAuthFrame(info, packet)
+		case layers.Dot11TypeMgmtDeauthentication, layers.Dot11TypeMgmtDisassociation:
+			// Reason Code; the body of protected (MFP) frames is encrypted
+			if len(dot11.Payload) >= 2 && !dot11.Flags.WEP() {
+				info.ReasonCode = binary.LittleEndian.Uint16(dot11.Payload[0:2])
+				info.Reason = ReasonCodeString(info.ReasonCode)
+			}
+		case layers.Dot11TypeMgmtAction, layers.Dot11TypeMgmtActionNoAck:
+			// Robust action frames are encrypted when MFP is in use
+			parseActionFrame(info, dot11.Payload, dot11.Flags.WEP())
 		default:
 			logger.Log.Debug().Stringer("mgmt_frame_type", dot11.Type).Msg("SSID parsing not specifically handled for this management frame subtype via specific layer.")
 		}
 
 		if iePayload != nil {
-			currentIndex := 0
-			for currentIndex < len(iePayload) {
-				if currentIndex+2 > len(iePayload) { // Need at least ID and Length fields
-					logger.Log.Warn().Int("offset", currentIndex).Int("payload_len", len(iePayload)).Msg("IE parsing stopped: not enough data for ID/Length.")
-					break
-				}
-				ieID := layers.Dot11InformationElementID(iePayload[currentIndex])
-				ieLength := int(iePayload[currentIndex+1])
-
-				if currentIndex+2+ieLength > len(iePayload) { // Check if data for this IE is fully present
-					logger.Log.Warn().Stringer("ie_id", ieID).Int("declared_len", ieLength).Int("remaining_payload", len(iePayload)-currentIndex-2).Msg("IE parsing stopped: declared length exceeds available payload.")
-					break
-				}
-				ieData := iePayload[currentIndex+2 : currentIndex+2+ieLength]
-
-				switch ieID {
-				case layers.Dot11InformationElementIDSSID:
-					if len(ieData) == 0 {
-						info.SSID = "<empty>"
-					} else {
-						isHidden := true
-						for _, b := range ieData {
-							if b != 0 {
-								isHidden = false
-								break
-							}
-						}
-						if isHidden {
-							info.SSID = "<hidden>"
-						} else {
-							if utf8.Valid(ieData) {
-								info.SSID = string(ieData)
-							} else {
-								hexSSID := fmt.Sprintf("%x", ieData)
-								logger.Log.Warn().Str("bssid", info.BSSID.String()).Str("ssid_hex", hexSSID).Msg("Non-UTF-8 SSID encountered, displaying as hex.")
-								info.SSID = fmt.Sprintf("<HEX:%s>", hexSSID)
-							}
-						}
-					}
-				case layers.Dot11InformationElementIDDSSet:
-					if len(ieData) == 1 {
-						info.DSSetChannel = ieData[0]
-						if info.Channel == 0 && info.DSSetChannel > 0 {
-							info.Channel = int(info.DSSetChannel)
-						}
-					}
-				case layers.Dot11InformationElementIDTIM:
-					info.TIM = make([]byte, len(ieData))
-					copy(info.TIM, ieData)
-
-				// Simplified stubs for capability IEs - assuming functions exist later
-				case layers.Dot11InformationElementIDHTCapabilities:
-					if info.ParsedHTCaps == nil {
-						info.ParsedHTCaps = &HTCapabilityInfo{}
-					} // Ensure not nil
-					parseHTCapabilitiesIE(info, ieData)
-				case layers.Dot11InformationElementIDRSNInfo:
-					// info.RSNRaw is currently just storing the raw IE.
-					// We will now call the dedicated parser instead/in addition.
-					parseRSNIE(info, ieData)
-					// Keep storing raw RSN for now, might be useful for debugging or if parsing fails
-					info.RSNRaw = make([]byte, 2+len(ieData))
-					info.RSNRaw[0] = byte(ieID)
-					info.RSNRaw[1] = byte(ieLength)
-					copy(info.RSNRaw[2:], ieData)
-				case layers.Dot11InformationElementIDVHTCapabilities:
-					if info.ParsedVHTCaps == nil {
-						info.ParsedVHTCaps = &VHTCapabilityInfo{}
-					}
-					parseVHTCapabilitiesIE(info, ieData)
-				case layers.Dot11InformationElementID(61): // HT Operation Element ID is 61
-					parseHTOperationIE(info, ieData)
-				case layers.Dot11InformationElementID(192): // VHT Operation Element ID is 192
-					parseVHTOperationIE(info, ieData)
-				case layers.Dot11InformationElementID(0xff): // Check for Extension (255), assuming layers.Dot11InformationElementIDExtension is not defined for linter
-					if len(ieData) > 0 {
-						extensionID := ieData[0]
-						const heCapabilitiesExtID uint8 = 35
-						if extensionID == heCapabilitiesExtID {
-							if info.ParsedHECaps == nil {
-								info.ParsedHECaps = &HECapabilityInfo{}
-							}
-							// extractHECapabilities(info, &layers.Dot11InformationElement{ID: ieID, Length: byte(ieLength), Info: ieData}, nil, nil)
-						}
-					}
-				}
-				currentIndex += 2 + ieLength
-			}
+			parseInformationElements(info, iePayload)
 			// Calls to parseSecurity and determineBandwidth would ideally be here, after loop
 			// parseSecurity(packet, info)
 			// determineBandwidth(info)
 		}
 	}
 
+	if dot11.Type.MainType() == layers.Dot11TypeCtrl {
+		parseControlFrame(info, dot11)
+	}
+
 	if dot11.Type.MainType() == layers.Dot11TypeData {
-		llcLayer := packet.Layer(layers.LayerTypeLLC)
-		if llcLayer != nil {
-			llc, _ := llcLayer.(*layers.LLC)
-			if llc.DSAP == 0xAA && llc.SSAP == 0xAA && llc.Control == 0x03 {
-				// SNAP packet
-			}
+		parseEAPOL(info, packet)
+		if packet.Layer(layers.LayerTypeSNAP) != nil {
+			parseUpperLayers(info, packet)
 		}
 	}
 
-	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
-		ipv4, _ := ipLayer.(*layers.IPv4)
-		info.TransportPayloadLength = int(ipv4.Length) - (int(ipv4.IHL) * 4)
-	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
-		ipv6, _ := ipLayer.(*layers.IPv6)
-		info.TransportPayloadLength = int(ipv6.Length)
+	if dot11.Type.MainType() == layers.Dot11TypeData {
+		parseAMSDU(info, dot11)
+	}
+
+	// For A-MSDUs gopacket decodes the first subframe header as LLC; parseAMSDU has already
+	// summed the payload of all MSDUs.
+	if !info.AMSDU {
+		if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+			ipv4, _ := ipLayer.(*layers.IPv4)
+			info.TransportPayloadLength = int(ipv4.Length) - (int(ipv4.IHL) * 4)
+		} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+			ipv6, _ := ipLayer.(*layers.IPv6)
+			info.TransportPayloadLength = int(ipv6.Length)
+		}
 	}
 
 	if info.RadiotapDataRate > 0 {
@@ -626,6 +914,15 @@ func (p *GoPacketParser) ParsePacket(packet gopacket.Packet) (*ParsedFrameInfo,
 	// 	info.Bandwidth = "20MHz"
 	// }
 
+	// Bare 802.11 (and headers without a frequency) only tell us the channel number, e.g.
+	// from the DS Parameter Set.
+	if info.Frequency == 0 && info.Channel > 0 {
+		setChannelFromNumber(info, info.Channel)
+	}
+
+	// Combine RSN, WPA1 and OWE transition elements into one security profile.
+	finalizeSecurityProfile(info)
+
 	if dot11.Flags.WEP() {
 		info.Security = "WEP"
 	} else if info.Security == "" {
@@ -777,142 +1074,6 @@ func parseVHTCapabilitiesIE(info *ParsedFrameInfo, ieData []byte) {
 	logger.Log.Debug().Interface("parsed_vht_caps", info.ParsedVHTCaps).Msg("VHT Capabilities IE Parsed")
 }
 
-// parseRSNIE parses the RSN (Robust Security Network) information element.
-// Reference: IEEE 802.11-2016, Section 9.4.2.25 (RSN element)
-// Reference: gopacket-80211.md for RSN structure and cipher/AKM suites
-func parseRSNIE(info *ParsedFrameInfo, ieData []byte) {
-	logger.Log.Debug().Int("ie_len", len(ieData)).Msg("Parsing RSN IE")
-	if len(ieData) < 2 { // Version (2 bytes)
-		logger.Log.Warn().Msg("RSN IE too short for Version.")
-		return
-	}
-	version := binary.LittleEndian.Uint16(ieData[0:2])
-	if version != 1 {
-		logger.Log.Warn().Uint16("rsn_version", version).Msg("Unsupported RSN version.")
-		return // Or handle differently if other versions become relevant
-	}
-
-	currentIndex := 2
-	var groupCipherStr string
-	pairwiseCiphers := []string{}
-	akms := []string{}
-	// var rsnCaps uint16 // For later if needed
-
-	// Group Data Cipher Suite (4 bytes: OUI[3] + Suite Type[1])
-	if currentIndex+4 <= len(ieData) {
-		groupCipherStr = ouiAndCipherSuiteToString(ieData[currentIndex:currentIndex+3], ieData[currentIndex+3])
-		currentIndex += 4
-	} else {
-		logger.Log.Warn().Msg("RSN IE too short for Group Cipher Suite.")
-		return
-	}
-
-	// Pairwise Cipher Suite Count (2 bytes)
-	if currentIndex+2 <= len(ieData) {
-		pairwiseCipherCount := int(binary.LittleEndian.Uint16(ieData[currentIndex : currentIndex+2]))
-		currentIndex += 2
-		for i := 0; i < pairwiseCipherCount; i++ {
-			if currentIndex+4 <= len(ieData) {
-				cipherStr := ouiAndCipherSuiteToString(ieData[currentIndex:currentIndex+3], ieData[currentIndex+3])
-				pairwiseCiphers = append(pairwiseCiphers, cipherStr)
-				currentIndex += 4
-			} else {
-				logger.Log.Warn().Int("expected_pairwise_count", pairwiseCipherCount).Int("parsed_count", i).Msg("RSN IE ended prematurely while parsing Pairwise Cipher Suites.")
-				break
-			}
-		}
-	} else {
-		logger.Log.Warn().Msg("RSN IE too short for Pairwise Cipher Suite Count.")
-		return
-	}
-
-	// AKM Suite Count (2 bytes)
-	if currentIndex+2 <= len(ieData) {
-		akmSuiteCount := int(binary.LittleEndian.Uint16(ieData[currentIndex : currentIndex+2]))
-		currentIndex += 2
-		for i := 0; i < akmSuiteCount; i++ {
-			if currentIndex+4 <= len(ieData) {
-				akmStr := ouiAndAKMSuiteToString(ieData[currentIndex:currentIndex+3], ieData[currentIndex+3])
-				akms = append(akms, akmStr)
-				currentIndex += 4
-			} else {
-				logger.Log.Warn().Int("expected_akm_count", akmSuiteCount).Int("parsed_count", i).Msg("RSN IE ended prematurely while parsing AKM Suites.")
-				break
-			}
-		}
-	} else {
-		logger.Log.Warn().Msg("RSN IE too short for AKM Suite Count.")
-		return
-	}
-
-	// (Optional) RSN Capabilities (2 bytes) - parse if present and needed
-	// if currentIndex+2 <= len(ieData) {
-	// 	rsnCaps = binary.LittleEndian.Uint16(ieData[currentIndex : currentIndex+2])
-	// 	currentIndex += 2
-	// 	// Process rsnCaps, e.g., Pre-Auth (bit 0), No Pairwise (bit 1), PTKSA Replay Counter (bits 2-3), etc.
-	// }
-
-	// Determine overall security string
-	// This is a simplified logic. Real determination can be more complex based on combinations.
-	var finalAKM, finalPairwise string
-	if len(akms) > 0 {
-		// Prioritize known strong AKMs if multiple are present
-		for _, akm := range akms {
-			if akm == "SAE" || akm == "PSK" || akm == "802.1X" { // Add other relevant AKMs like FT-PSK, FT-802.1X
-				finalAKM = akm
-				break
-			}
-		}
-		if finalAKM == "" {
-			finalAKM = akms[0]
-		} // Fallback to first listed
-	}
-
-	if len(pairwiseCiphers) > 0 {
-		// Prioritize known strong ciphers
-		for _, pc := range pairwiseCiphers {
-			if pc == "CCMP-128" || pc == "GCMP-256" || pc == "CCMP-256" || pc == "GCMP-128" {
-				finalPairwise = pc
-				break
-			}
-		}
-		if finalPairwise == "" {
-			finalPairwise = pairwiseCiphers[0]
-		} // Fallback to first listed
-	}
-
-	if finalAKM != "" && finalPairwise != "" {
-		// Construct a common security string, e.g., WPA2-PSK-CCMP, WPA3-SAE-CCMP
-		securityName := ""
-		switch finalAKM {
-		case "PSK":
-			if finalPairwise == "CCMP-128" {
-				securityName = "WPA2-PSK"
-			} // Common assumption
-			// Add WPA-PSK if TKIP is primary pairwise
-		case "802.1X":
-			if finalPairwise == "CCMP-128" {
-				securityName = "WPA2-Enterprise"
-			}
-		case "SAE":
-			securityName = "WPA3-Personal"
-		// Add more cases for FT, OWE, etc.
-		default:
-			securityName = finalAKM // Use raw AKM if no specific WPAx name
-		}
-		if securityName != "" {
-			info.Security = fmt.Sprintf("%s (%s)", securityName, finalPairwise)
-		} else {
-			info.Security = fmt.Sprintf("RSN AKM: %s, Pairwise: %s", strings.Join(akms, "/"), strings.Join(pairwiseCiphers, "/"))
-		}
-	} else if groupCipherStr != "" { // Fallback if somehow AKM/Pairwise are missing but group is there
-		info.Security = fmt.Sprintf("RSN Group: %s", groupCipherStr)
-	} else {
-		info.Security = "RSN (Unknown)" // Should not happen if RSN IE is valid
-	}
-	logger.Log.Info().Str("parsed_security", info.Security).Msg("RSN IE Parsed")
-}
-
 // parseHTOperationIE parses the HT Operation information element.
 // Reference: IEEE 802.11-2016, Section 9.4.2.57 (HT Operation element)
 func parseHTOperationIE(info *ParsedFrameInfo, ieData []byte) {
@@ -1088,7 +1249,7 @@ func ouiAndAKMSuiteToString(oui []byte, suiteType byte) string {
 	if len(oui) != 3 {
 		return "InvalidOUI"
 	}
-	// IEEE Std 802.11-2016, Table 9-134—AKM suite selectors
+	// IEEE Std 802.11-2020, Table 9-151—AKM suite selectors
 	ouiStr := fmt.Sprintf("%02X-%02X-%02X", oui[0], oui[1], oui[2])
 	if ouiStr == "00-0F-AC" { //检查OUI是否为IEEE分配的Cipher/AKM OUI
 		switch suiteType {
@@ -1111,13 +1272,36 @@ func ouiAndAKMSuiteToString(oui []byte, suiteType byte) string {
 			return "SAE" // Simultaneous Authentication of Equals (WPA3)
 		case 9:
 			return "FT-SAE"
+		case 10:
+			return "APPeerKey"
 		case 11:
-			return "OWE" // Opportunistic Wireless Encryption
+			return "802.1X-SuiteB-SHA256"
 		case 12:
-			return "FT-SUITEB-SHA256"
+			return "802.1X-SuiteB-SHA384" // WPA3-Enterprise 192-bit
 		case 13:
-			return "FT-SUITEB-SHA384"
-		// Other types exist for FILS, etc.
+			return "FT-802.1X-SHA384"
+		case 14:
+			return "FILS-SHA256"
+		case 15:
+			return "FILS-SHA384"
+		case 16:
+			return "FT-FILS-SHA256"
+		case 17:
+			return "FT-FILS-SHA384"
+		case 18:
+			return "OWE" // Opportunistic Wireless Encryption
+		case 19:
+			return "FT-PSK-SHA384"
+		case 20:
+			return "PSK-SHA384"
+		case 21:
+			return "PASN"
+		case 23:
+			return "802.1X-SHA384"
+		case 24:
+			return "SAE-EXT-KEY" // SAE with group-dependent hash (WPA3, Wi-Fi 7)
+		case 25:
+			return "FT-SAE-EXT-KEY"
 		default:
 			return fmt.Sprintf("AKM-Unknown(%d)", suiteType)
 		}
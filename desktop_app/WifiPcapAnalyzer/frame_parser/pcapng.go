@@ -0,0 +1,653 @@
+package frame_parser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// pcapng block types (draft-ietf-opsawg-pcapng).
+const (
+	pcapngBlockSectionHeader        uint32 = 0x0A0D0D0A
+	pcapngBlockInterfaceDescription uint32 = 0x00000001
+	pcapngBlockPacketObsolete       uint32 = 0x00000002
+	pcapngBlockSimplePacket         uint32 = 0x00000003
+	pcapngBlockInterfaceStatistics  uint32 = 0x00000005
+	pcapngBlockEnhancedPacket       uint32 = 0x00000006
+	pcapngBlockCustomCopyable       uint32 = 0x00000BAD
+	pcapngBlockCustomNoCopy         uint32 = 0x40000BAD
+	pcapngByteOrderMagic            uint32 = 0x1A2B3C4D
+	pcapngMaxBlockLength            uint32 = 16 * 1024 * 1024 // Sanity limit, far above any 802.11 frame
+)
+
+// pcapng option codes.
+const (
+	pcapngOptEndOfOpt     uint16 = 0
+	pcapngOptComment      uint16 = 1
+	pcapngOptSHBHardware  uint16 = 2
+	pcapngOptSHBOS        uint16 = 3
+	pcapngOptSHBUserAppl  uint16 = 4
+	pcapngOptIfName       uint16 = 2
+	pcapngOptIfDesc       uint16 = 3
+	pcapngOptIfTSResol    uint16 = 9
+	pcapngOptIfFCSLen     uint16 = 13
+	pcapngOptIfTSOffset   uint16 = 14
+	pcapngOptEPBFlags     uint16 = 2
+	pcapngOptISBIfDrop    uint16 = 5
+	pcapngOptISBOSDrop    uint16 = 7
+	pcapngDefaultTSResol  uint8  = 6 // Microseconds
+	pcapngWriterTSResol   uint8  = 9 // The writer always uses nanoseconds
+	pcapngUnknownFCSBytes int    = -1
+)
+
+// maxPcapngCustomBlocks bounds the Custom Blocks a PcapngReader keeps; later ones are
+// skipped and counted, so a long stream of custom blocks can't exhaust memory.
+const maxPcapngCustomBlocks = 1024
+
+// PcapngSection holds the Section Header Block options.
+type PcapngSection struct {
+	Hardware    string
+	OS          string
+	Application string
+	Comment     string
+}
+
+// PcapngInterface describes one Interface Description Block.
+type PcapngInterface struct {
+	LinkType    layers.LinkType
+	SnapLen     uint32
+	Name        string
+	Description string
+	Comment     string
+	FCSLen      int // Bytes of FCS at the end of each frame, -1 if not announced
+	// Packets lost by the interface and the OS (isb_ifdrop + isb_osdrop) since the capture
+	// started, from the latest Interface Statistics Block
+	Dropped uint64
+
+	tsUnitsPerSecond uint64
+	tsOffset         int64
+}
+
+// PcapngCustomBlock is a Custom Block: a Private Enterprise Number and opaque data.
+// The block does not record the data length, so Data read from a file includes any
+// padding and options that follow the custom data.
+type PcapngCustomBlock struct {
+	PEN      uint32
+	Data     []byte
+	Copyable bool // Block type 0x00000BAD; 0x40000BAD blocks must not be copied to other files
+}
+
+// PcapngPacket is one packet read from an Enhanced, Simple or (obsolete) Packet Block.
+type PcapngPacket struct {
+	InterfaceID int
+	CaptureInfo gopacket.CaptureInfo
+	Data        []byte
+	Comments    []string
+	Flags       uint32 // epb_flags (direction, reception type, FCS length, link-layer errors)
+}
+
+// IsPcapngMagic reports whether b starts with a pcapng Section Header Block.
+func IsPcapngMagic(b []byte) bool {
+	return len(b) >= 4 && binary.LittleEndian.Uint32(b) == pcapngBlockSectionHeader
+}
+
+// PcapngReader reads packets, interfaces, comments and custom blocks from a pcapng stream.
+// Unlike pcapgo.NgReader it keeps every interface's link type, so files mixing radiotap and
+// plain 802.11 interfaces can be decoded packet by packet.
+type PcapngReader struct {
+	r                   *bufio.Reader
+	order               binary.ByteOrder
+	section             PcapngSection
+	dropped             uint64 // Drops reported by interface statistics, over every section
+	interfaces          []PcapngInterface
+	customBlocks        []PcapngCustomBlock
+	skippedCustomBlocks int
+}
+
+// NewPcapngReader reads the first Section Header Block of r.
+func NewPcapngReader(r io.Reader) (*PcapngReader, error) {
+	reader := &PcapngReader{r: bufio.NewReader(r)}
+	blockType, body, err := reader.readBlock()
+	if err != nil {
+		return nil, fmt.Errorf("reading pcapng section header: %w", err)
+	}
+	if blockType != pcapngBlockSectionHeader {
+		return nil, fmt.Errorf("not a pcapng stream: first block type 0x%08x", blockType)
+	}
+	if err := reader.parseSectionHeader(body); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Section returns the options of the current section.
+func (r *PcapngReader) Section() PcapngSection {
+	return r.section
+}
+
+// Dropped returns the frames reported dropped by the interface statistics read so far,
+// summed over every interface of every section.
+func (r *PcapngReader) Dropped() uint64 {
+	return r.dropped
+}
+
+// Interfaces returns the interfaces of the current section seen so far.
+func (r *PcapngReader) Interfaces() []PcapngInterface {
+	return r.interfaces
+}
+
+// Interface returns the interface with the given ID in the current section.
+func (r *PcapngReader) Interface(id int) (PcapngInterface, bool) {
+	if id < 0 || id >= len(r.interfaces) {
+		return PcapngInterface{}, false
+	}
+	return r.interfaces[id], true
+}
+
+// CustomBlocks returns the Custom Blocks read so far, in file order. Only the first
+// maxPcapngCustomBlocks are kept.
+func (r *PcapngReader) CustomBlocks() []PcapngCustomBlock {
+	return r.customBlocks
+}
+
+// SkippedCustomBlocks returns the number of Custom Blocks read beyond maxPcapngCustomBlocks.
+func (r *PcapngReader) SkippedCustomBlocks() int {
+	return r.skippedCustomBlocks
+}
+
+// Next returns the next packet. Interface, statistics, section and custom blocks in between
+// are consumed and recorded. It returns io.EOF at the end of the stream.
+func (r *PcapngReader) Next() (*PcapngPacket, error) {
+	for {
+		blockType, body, err := r.readBlock()
+		if err != nil {
+			return nil, err
+		}
+		switch blockType {
+		case pcapngBlockSectionHeader:
+			if err := r.parseSectionHeader(body); err != nil {
+				return nil, err
+			}
+		case pcapngBlockInterfaceDescription:
+			if err := r.parseInterfaceDescription(body); err != nil {
+				return nil, err
+			}
+		case pcapngBlockInterfaceStatistics:
+			if err := r.parseInterfaceStatistics(body); err != nil {
+				return nil, err
+			}
+		case pcapngBlockEnhancedPacket:
+			return r.parseEnhancedPacket(body)
+		case pcapngBlockSimplePacket:
+			return r.parseSimplePacket(body)
+		case pcapngBlockPacketObsolete:
+			return r.parseObsoletePacket(body)
+		case pcapngBlockCustomCopyable, pcapngBlockCustomNoCopy:
+			if len(body) < 4 {
+				return nil, fmt.Errorf("pcapng custom block too short: %d bytes", len(body))
+			}
+			if len(r.customBlocks) >= maxPcapngCustomBlocks {
+				r.skippedCustomBlocks++
+				break
+			}
+			r.customBlocks = append(r.customBlocks, PcapngCustomBlock{
+				PEN:      r.order.Uint32(body[0:4]),
+				Data:     append([]byte(nil), body[4:]...),
+				Copyable: blockType == pcapngBlockCustomCopyable,
+			})
+		default:
+			// Name Resolution, Decryption Secrets, ... are not needed here
+		}
+	}
+}
+
+// readBlock reads one block and returns its type and body (without the trailing length).
+// The byte order of a Section Header Block is taken from its own byte-order magic.
+func (r *PcapngReader) readBlock() (uint32, []byte, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r.r, header[:8]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated pcapng block header: %w", err)
+		}
+		return 0, nil, err
+	}
+	blockType := binary.LittleEndian.Uint32(header[0:4]) // The SHB type is a palindrome
+	order := r.order
+	if blockType == pcapngBlockSectionHeader {
+		if _, err := io.ReadFull(r.r, header[8:12]); err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header: %w", err)
+		}
+		switch pcapngByteOrderMagic {
+		case binary.LittleEndian.Uint32(header[8:12]):
+			order = binary.LittleEndian
+		case binary.BigEndian.Uint32(header[8:12]):
+			order = binary.BigEndian
+		default:
+			return 0, nil, fmt.Errorf("invalid pcapng byte-order magic 0x%x", header[8:12])
+		}
+		r.order = order
+	} else if order == nil {
+		return 0, nil, errors.New("pcapng block before the section header")
+	} else {
+		blockType = order.Uint32(header[0:4])
+	}
+
+	totalLength := order.Uint32(header[4:8])
+	if totalLength < 12 || totalLength%4 != 0 || totalLength > pcapngMaxBlockLength {
+		return 0, nil, fmt.Errorf("invalid pcapng block length %d for block type 0x%08x", totalLength, blockType)
+	}
+	block := make([]byte, totalLength-8)
+	read := 0
+	if blockType == pcapngBlockSectionHeader {
+		if len(block) < 4 {
+			return 0, nil, fmt.Errorf("pcapng section header too short: %d bytes", totalLength)
+		}
+		copy(block, header[8:12])
+		read = 4
+	}
+	if _, err := io.ReadFull(r.r, block[read:]); err != nil {
+		return 0, nil, fmt.Errorf("truncated pcapng block of type 0x%08x: %w", blockType, err)
+	}
+	body := block[:len(block)-4]
+	if trailer := order.Uint32(block[len(block)-4:]); trailer != totalLength {
+		return 0, nil, fmt.Errorf("pcapng block length mismatch: %d != %d", trailer, totalLength)
+	}
+	return blockType, body, nil
+}
+
+// parseOptions calls fn for every option up to opt_endofopt or the end of data.
+func (r *PcapngReader) parseOptions(data []byte, fn func(code uint16, value []byte)) error {
+	for len(data) >= 4 {
+		code := r.order.Uint16(data[0:2])
+		length := int(r.order.Uint16(data[2:4]))
+		if code == pcapngOptEndOfOpt {
+			return nil
+		}
+		padded := (length + 3) &^ 3
+		if 4+padded > len(data) {
+			return fmt.Errorf("pcapng option %d overruns its block", code)
+		}
+		fn(code, data[4:4+length])
+		data = data[4+padded:]
+	}
+	return nil
+}
+
+func (r *PcapngReader) parseSectionHeader(body []byte) error {
+	if len(body) < 16 {
+		return fmt.Errorf("pcapng section header too short: %d bytes", len(body))
+	}
+	if major := r.order.Uint16(body[4:6]); major != 1 {
+		return fmt.Errorf("unsupported pcapng version %d.%d", major, r.order.Uint16(body[6:8]))
+	}
+	// A new section starts over with its own interfaces
+	r.section = PcapngSection{}
+	r.interfaces = nil
+	return r.parseOptions(body[16:], func(code uint16, value []byte) {
+		switch code {
+		case pcapngOptComment:
+			r.section.Comment = string(value)
+		case pcapngOptSHBHardware:
+			r.section.Hardware = string(value)
+		case pcapngOptSHBOS:
+			r.section.OS = string(value)
+		case pcapngOptSHBUserAppl:
+			r.section.Application = string(value)
+		}
+	})
+}
+
+func (r *PcapngReader) parseInterfaceDescription(body []byte) error {
+	if len(body) < 8 {
+		return fmt.Errorf("pcapng interface description too short: %d bytes", len(body))
+	}
+	intf := PcapngInterface{
+		LinkType:         layers.LinkType(r.order.Uint16(body[0:2])),
+		SnapLen:          r.order.Uint32(body[4:8]),
+		FCSLen:           pcapngUnknownFCSBytes,
+		tsUnitsPerSecond: tsResolutionUnits(pcapngDefaultTSResol),
+	}
+	err := r.parseOptions(body[8:], func(code uint16, value []byte) {
+		switch code {
+		case pcapngOptComment:
+			intf.Comment = string(value)
+		case pcapngOptIfName:
+			intf.Name = string(value)
+		case pcapngOptIfDesc:
+			intf.Description = string(value)
+		case pcapngOptIfTSResol:
+			if len(value) >= 1 {
+				intf.tsUnitsPerSecond = tsResolutionUnits(value[0])
+			}
+		case pcapngOptIfFCSLen:
+			if len(value) >= 1 {
+				intf.FCSLen = int(value[0])
+			}
+		case pcapngOptIfTSOffset:
+			if len(value) >= 8 {
+				intf.tsOffset = int64(r.order.Uint64(value))
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	r.interfaces = append(r.interfaces, intf)
+	return nil
+}
+
+// parseInterfaceStatistics records the drop counters of an Interface Statistics Block.
+// Statistics of an interface that was never described are ignored.
+func (r *PcapngReader) parseInterfaceStatistics(body []byte) error {
+	if len(body) < 12 {
+		return fmt.Errorf("pcapng interface statistics block too short: %d bytes", len(body))
+	}
+	id := int(r.order.Uint32(body[0:4]))
+	if id >= len(r.interfaces) {
+		return nil
+	}
+	var ifDrop, osDrop uint64
+	seen := false
+	err := r.parseOptions(body[12:], func(code uint16, value []byte) {
+		if len(value) < 8 {
+			return
+		}
+		switch code {
+		case pcapngOptISBIfDrop:
+			ifDrop, seen = r.order.Uint64(value), true
+		case pcapngOptISBOSDrop:
+			osDrop, seen = r.order.Uint64(value), true
+		}
+	})
+	if err != nil {
+		return err
+	}
+	// Statistics are cumulative; count the growth since the interface's previous block.
+	if dropped := ifDrop + osDrop; seen && dropped > r.interfaces[id].Dropped {
+		r.dropped += dropped - r.interfaces[id].Dropped
+		r.interfaces[id].Dropped = dropped
+	}
+	return nil
+}
+
+// tsResolutionUnits converts an if_tsresol value to timestamp units per second.
+func tsResolutionUnits(resol uint8) uint64 {
+	if resol&0x80 != 0 {
+		exp := resol & 0x7f
+		if exp > 63 {
+			exp = 63
+		}
+		return 1 << exp
+	}
+	if resol > 19 {
+		resol = 19
+	}
+	units := uint64(1)
+	for i := uint8(0); i < resol; i++ {
+		units *= 10
+	}
+	return units
+}
+
+// timestamp converts a raw pcapng timestamp of intf to a time.Time.
+func (intf PcapngInterface) timestamp(raw uint64) time.Time {
+	units := intf.tsUnitsPerSecond
+	if units == 0 {
+		units = tsResolutionUnits(pcapngDefaultTSResol)
+	}
+	secs := raw / units
+	hi, lo := bits.Mul64(raw%units, uint64(time.Second))
+	nanos, _ := bits.Div64(hi, lo, units)
+	return time.Unix(int64(secs)+intf.tsOffset, int64(nanos)).UTC()
+}
+
+func (r *PcapngReader) packetInterface(id int) (PcapngInterface, error) {
+	intf, ok := r.Interface(id)
+	if !ok {
+		return PcapngInterface{}, fmt.Errorf("pcapng packet references unknown interface %d", id)
+	}
+	return intf, nil
+}
+
+func (r *PcapngReader) parseEnhancedPacket(body []byte) (*PcapngPacket, error) {
+	if len(body) < 20 {
+		return nil, fmt.Errorf("pcapng enhanced packet block too short: %d bytes", len(body))
+	}
+	id := int(r.order.Uint32(body[0:4]))
+	intf, err := r.packetInterface(id)
+	if err != nil {
+		return nil, err
+	}
+	raw := uint64(r.order.Uint32(body[4:8]))<<32 | uint64(r.order.Uint32(body[8:12]))
+	capLen := int(r.order.Uint32(body[12:16]))
+	origLen := int(r.order.Uint32(body[16:20]))
+	padded := (capLen + 3) &^ 3
+	if capLen < 0 || 20+padded > len(body) {
+		return nil, fmt.Errorf("pcapng enhanced packet data (%d bytes) overruns its block", capLen)
+	}
+	pkt := &PcapngPacket{
+		InterfaceID: id,
+		Data:        body[20 : 20+capLen],
+		CaptureInfo: gopacket.CaptureInfo{
+			Timestamp:      intf.timestamp(raw),
+			CaptureLength:  capLen,
+			Length:         origLen,
+			InterfaceIndex: id,
+		},
+	}
+	err = r.parseOptions(body[20+padded:], func(code uint16, value []byte) {
+		switch code {
+		case pcapngOptComment:
+			pkt.Comments = append(pkt.Comments, string(value))
+		case pcapngOptEPBFlags:
+			if len(value) >= 4 {
+				pkt.Flags = r.order.Uint32(value)
+			}
+		}
+	})
+	return pkt, err
+}
+
+// parseSimplePacket decodes a Simple Packet Block: interface 0, no timestamp.
+func (r *PcapngReader) parseSimplePacket(body []byte) (*PcapngPacket, error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("pcapng simple packet block too short: %d bytes", len(body))
+	}
+	intf, err := r.packetInterface(0)
+	if err != nil {
+		return nil, err
+	}
+	origLen := int(r.order.Uint32(body[0:4]))
+	capLen := origLen
+	if intf.SnapLen > 0 && capLen > int(intf.SnapLen) {
+		capLen = int(intf.SnapLen)
+	}
+	if capLen > len(body)-4 {
+		capLen = len(body) - 4
+	}
+	return &PcapngPacket{
+		Data:        body[4 : 4+capLen],
+		CaptureInfo: gopacket.CaptureInfo{CaptureLength: capLen, Length: origLen},
+	}, nil
+}
+
+// parseObsoletePacket decodes the obsolete Packet Block still written by some old tools.
+func (r *PcapngReader) parseObsoletePacket(body []byte) (*PcapngPacket, error) {
+	if len(body) < 20 {
+		return nil, fmt.Errorf("pcapng packet block too short: %d bytes", len(body))
+	}
+	id := int(r.order.Uint16(body[0:2]))
+	intf, err := r.packetInterface(id)
+	if err != nil {
+		return nil, err
+	}
+	raw := uint64(r.order.Uint32(body[4:8]))<<32 | uint64(r.order.Uint32(body[8:12]))
+	capLen := int(r.order.Uint32(body[12:16]))
+	origLen := int(r.order.Uint32(body[16:20]))
+	padded := (capLen + 3) &^ 3
+	if 20+padded > len(body) {
+		return nil, fmt.Errorf("pcapng packet data (%d bytes) overruns its block", capLen)
+	}
+	pkt := &PcapngPacket{
+		InterfaceID: id,
+		Data:        body[20 : 20+capLen],
+		CaptureInfo: gopacket.CaptureInfo{Timestamp: intf.timestamp(raw), CaptureLength: capLen, Length: origLen, InterfaceIndex: id},
+	}
+	err = r.parseOptions(body[20+padded:], func(code uint16, value []byte) {
+		if code == pcapngOptComment {
+			pkt.Comments = append(pkt.Comments, string(value))
+		}
+	})
+	return pkt, err
+}
+
+// PcapngWriter writes a little-endian pcapng stream with nanosecond timestamps.
+type PcapngWriter struct {
+	w          *bufio.Writer
+	interfaces int
+}
+
+// NewPcapngWriter writes the Section Header Block for section.
+func NewPcapngWriter(w io.Writer, section PcapngSection) (*PcapngWriter, error) {
+	writer := &PcapngWriter{w: bufio.NewWriter(w)}
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint32(body[0:4], pcapngByteOrderMagic)
+	binary.LittleEndian.PutUint16(body[4:6], 1) // Major version
+	binary.LittleEndian.PutUint16(body[6:8], 0) // Minor version
+	binary.LittleEndian.PutUint64(body[8:16], ^uint64(0))
+	body = appendPcapngStringOption(body, pcapngOptSHBHardware, section.Hardware)
+	body = appendPcapngStringOption(body, pcapngOptSHBOS, section.OS)
+	body = appendPcapngStringOption(body, pcapngOptSHBUserAppl, section.Application)
+	body = appendPcapngStringOption(body, pcapngOptComment, section.Comment)
+	body = appendPcapngEndOfOptions(body, 16)
+	if err := writer.writeBlock(pcapngBlockSectionHeader, body); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// AddInterface writes an Interface Description Block and returns its interface ID.
+func (w *PcapngWriter) AddInterface(intf PcapngInterface) (int, error) {
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[0:2], uint16(intf.LinkType))
+	binary.LittleEndian.PutUint32(body[4:8], intf.SnapLen)
+	body = appendPcapngStringOption(body, pcapngOptIfName, intf.Name)
+	body = appendPcapngStringOption(body, pcapngOptIfDesc, intf.Description)
+	body = appendPcapngOption(body, pcapngOptIfTSResol, []byte{pcapngWriterTSResol})
+	if intf.FCSLen >= 0 {
+		body = appendPcapngOption(body, pcapngOptIfFCSLen, []byte{uint8(intf.FCSLen)})
+	}
+	body = appendPcapngStringOption(body, pcapngOptComment, intf.Comment)
+	body = appendPcapngEndOfOptions(body, 8)
+	if err := w.writeBlock(pcapngBlockInterfaceDescription, body); err != nil {
+		return 0, err
+	}
+	w.interfaces++
+	return w.interfaces - 1, nil
+}
+
+// WritePacket writes an Enhanced Packet Block with one opt_comment per comment.
+func (w *PcapngWriter) WritePacket(interfaceID int, ci gopacket.CaptureInfo, data []byte, comments ...string) error {
+	if interfaceID < 0 || interfaceID >= w.interfaces {
+		return fmt.Errorf("pcapng writer: unknown interface %d", interfaceID)
+	}
+	if ci.CaptureLength != len(data) {
+		ci.CaptureLength = len(data)
+	}
+	if ci.Length < ci.CaptureLength {
+		ci.Length = ci.CaptureLength
+	}
+	// Timestamps are unsigned nanoseconds since 1970, and UnixNano is undefined past 2262.
+	if ci.Timestamp.Before(time.Unix(0, 0)) || ci.Timestamp.After(time.Unix(0, math.MaxInt64)) {
+		return fmt.Errorf("pcapng writer: timestamp %v out of range", ci.Timestamp)
+	}
+	ts := uint64(ci.Timestamp.UnixNano())
+	body := make([]byte, 20, 20+len(data)+3)
+	binary.LittleEndian.PutUint32(body[0:4], uint32(interfaceID))
+	binary.LittleEndian.PutUint32(body[4:8], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(ts))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(ci.CaptureLength))
+	binary.LittleEndian.PutUint32(body[16:20], uint32(ci.Length))
+	body = append(body, data...)
+	body = padPcapng(body)
+	optionsStart := len(body)
+	for _, comment := range comments {
+		body = appendPcapngStringOption(body, pcapngOptComment, comment)
+	}
+	body = appendPcapngEndOfOptions(body, optionsStart)
+	return w.writeBlock(pcapngBlockEnhancedPacket, body)
+}
+
+// WriteCustomBlock writes a Custom Block.
+func (w *PcapngWriter) WriteCustomBlock(block PcapngCustomBlock) error {
+	blockType := pcapngBlockCustomNoCopy
+	if block.Copyable {
+		blockType = pcapngBlockCustomCopyable
+	}
+	body := make([]byte, 4, 4+len(block.Data)+3)
+	binary.LittleEndian.PutUint32(body, block.PEN)
+	body = append(body, block.Data...)
+	return w.writeBlock(blockType, padPcapng(body))
+}
+
+// Flush writes any buffered blocks to the underlying writer.
+func (w *PcapngWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func (w *PcapngWriter) writeBlock(blockType uint32, body []byte) error {
+	totalLength := uint32(12 + len(body))
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[0:4], blockType)
+	binary.LittleEndian.PutUint32(header[4:8], totalLength)
+	if _, err := w.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(body); err != nil {
+		return err
+	}
+	_, err := w.w.Write(header[4:8])
+	return err
+}
+
+func padPcapng(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func appendPcapngOption(b []byte, code uint16, value []byte) []byte {
+	var header [4]byte
+	binary.LittleEndian.PutUint16(header[0:2], code)
+	binary.LittleEndian.PutUint16(header[2:4], uint16(len(value)))
+	b = append(b, header[:]...)
+	b = append(b, value...)
+	return padPcapng(b)
+}
+
+func appendPcapngStringOption(b []byte, code uint16, value string) []byte {
+	if value == "" {
+		return b
+	}
+	if len(value) > 0xffff-3 {
+		value = value[:0xffff-3]
+	}
+	return appendPcapngOption(b, code, []byte(value))
+}
+
+// appendPcapngEndOfOptions terminates the options written after optionsStart, if any.
+func appendPcapngEndOfOptions(b []byte, optionsStart int) []byte {
+	if len(b) == optionsStart {
+		return b
+	}
+	return append(b, 0, 0, 0, 0)
+}
@@ -0,0 +1,217 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/utils"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// CaptureAnnotations describes where an exported capture came from.
+type CaptureAnnotations struct {
+	Agent     string // Capture agent address, e.g. "192.168.6.250:50051"
+	Interface string // Capture interface on the agent, e.g. "ath1"
+	Channel   int    // Channel requested when the capture started
+	Bandwidth string // Bandwidth requested when the capture started, e.g. "HT20"
+}
+
+// pcapngExportKey identifies one exported interface: frames from different source
+// interfaces or with different link types get their own Interface Description Block.
+type pcapngExportKey struct {
+	sourceID int
+	name     string
+	linkType layers.LinkType
+}
+
+// PcapngExporter writes parsed frames to a pcapng stream. The section and interfaces carry
+// the capture annotations, and every packet gets its original comments plus the comments
+// from FrameAnnotations.
+type PcapngExporter struct {
+	mu          sync.Mutex
+	writer      *PcapngWriter
+	annotations CaptureAnnotations
+	interfaces  map[pcapngExportKey]int
+	lastChannel map[int]int // Last channel seen per exported interface
+	frames      int
+}
+
+// NewPcapngExporter writes the section header of an annotated pcapng export to w.
+func NewPcapngExporter(w io.Writer, annotations CaptureAnnotations) (*PcapngExporter, error) {
+	section := PcapngSection{
+		OS:          runtime.GOOS,
+		Application: "WifiPcapAnalyzer",
+	}
+	if annotations.Agent != "" {
+		section.Hardware = "Capture agent " + annotations.Agent
+		section.Comment = fmt.Sprintf("Captured via agent %s", annotations.Agent)
+	}
+	writer, err := NewPcapngWriter(w, section)
+	if err != nil {
+		return nil, fmt.Errorf("writing pcapng section header: %w", err)
+	}
+	return &PcapngExporter{
+		writer:      writer,
+		annotations: annotations,
+		interfaces:  make(map[pcapngExportKey]int),
+		lastChannel: make(map[int]int),
+	}, nil
+}
+
+// Export writes one parsed frame. Frames without RawData (not produced by the frame
+// processor) are skipped.
+func (e *PcapngExporter) Export(info *ParsedFrameInfo) error {
+	if info == nil || len(info.RawData) == 0 {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	linkType := info.LinkType
+	if linkType == layers.LinkTypeNull {
+		linkType = layers.LinkTypeIEEE80211Radio
+	}
+	key := pcapngExportKey{sourceID: info.InterfaceID, name: info.InterfaceName, linkType: linkType}
+	id, ok := e.interfaces[key]
+	if !ok {
+		var err error
+		id, err = e.writer.AddInterface(e.interfaceFor(key))
+		if err != nil {
+			return fmt.Errorf("writing pcapng interface: %w", err)
+		}
+		e.interfaces[key] = id
+		if e.annotations.Channel > 0 {
+			e.lastChannel[id] = e.annotations.Channel
+		}
+	}
+
+	comments := append([]string(nil), info.PacketComments...)
+	if info.Channel > 0 && e.lastChannel[id] != info.Channel {
+		if _, seen := e.lastChannel[id]; seen {
+			comments = append(comments, "Channel changed to "+describeChannel(info.Channel, info.Frequency, ""))
+		}
+		e.lastChannel[id] = info.Channel
+	}
+	comments = append(comments, FrameAnnotations(info)...)
+
+	ci := gopacket.CaptureInfo{
+		Timestamp:     info.Timestamp,
+		CaptureLength: len(info.RawData),
+		Length:        info.FrameLength,
+	}
+	if err := e.writer.WritePacket(id, ci, info.RawData, comments...); err != nil {
+		return fmt.Errorf("writing pcapng packet: %w", err)
+	}
+	e.frames++
+	return nil
+}
+
+// Frames returns the number of frames exported so far.
+func (e *PcapngExporter) Frames() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.frames
+}
+
+// Flush writes buffered blocks to the underlying writer.
+func (e *PcapngExporter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.writer.Flush()
+}
+
+func (e *PcapngExporter) interfaceFor(key pcapngExportKey) PcapngInterface {
+	intf := PcapngInterface{LinkType: key.linkType, FCSLen: pcapngUnknownFCSBytes, Name: key.name}
+	if intf.Name == "" {
+		intf.Name = e.annotations.Interface
+	}
+	if e.annotations.Agent != "" {
+		intf.Description = "Agent " + e.annotations.Agent
+	}
+	if e.annotations.Channel > 0 {
+		freq := 0
+		for _, band := range []utils.Band{utils.Band2_4GHz, utils.Band5GHz} {
+			if utils.IsValidPrimaryChannel(band, e.annotations.Channel) {
+				freq, _ = utils.ChannelToFrequency(band, e.annotations.Channel)
+				break
+			}
+		}
+		intf.Comment = "Capture started on " + describeChannel(e.annotations.Channel, freq, e.annotations.Bandwidth)
+	}
+	return intf
+}
+
+// describeChannel formats a channel as e.g. "channel 36 (5GHz, 5180 MHz) HT20".
+func describeChannel(channel, freqMHz int, bandwidth string) string {
+	desc := fmt.Sprintf("channel %d", channel)
+	if ch, ok := utils.ChannelFromFrequency(freqMHz); ok {
+		desc += fmt.Sprintf(" (%s, %d MHz)", ch.Band, freqMHz)
+	}
+	if bandwidth != "" {
+		desc += " " + bandwidth
+	}
+	return desc
+}
+
+// FrameAnnotations returns analyzer comments for frames worth pointing out in an export:
+// bad-FCS frames, disconnects, failed authentication/association, handshakes, channel
+// switches, BSS transition exchanges, malformed action frames and decrypted frames.
+func FrameAnnotations(info *ParsedFrameInfo) []string {
+	var comments []string
+	if info.BadFCS {
+		return []string{"Bad FCS"}
+	}
+	switch info.FrameType {
+	case "MgmtDeauthentication", "MgmtDisassociation":
+		kind := strings.TrimPrefix(info.FrameType, "Mgmt")
+		comments = append(comments, fmt.Sprintf("%s: reason %d (%s)", kind, info.ReasonCode, info.Reason))
+	}
+	if auth := info.Auth; auth != nil {
+		if auth.StatusCode != 0 {
+			comments = append(comments, fmt.Sprintf("Authentication failed: status %d (%s)", auth.StatusCode, auth.Status))
+		} else if auth.SAEMessage != "" {
+			comments = append(comments, "SAE "+auth.SAEMessage)
+		}
+	}
+	if resp := info.AssocResponse; resp != nil && resp.StatusCode != 0 {
+		comments = append(comments, fmt.Sprintf("Association rejected: status %d (%s)", resp.StatusCode, resp.Status))
+	}
+	if key := info.EAPOLKey; key != nil && key.Message > 0 {
+		if key.GroupHandshake {
+			comments = append(comments, fmt.Sprintf("Group key handshake message %d/2", key.Message))
+		} else {
+			comments = append(comments, fmt.Sprintf("4-way handshake message %d/4", key.Message))
+		}
+	}
+	if eap := info.EAP; eap != nil && (eap.Code == "Success" || eap.Code == "Failure") {
+		comments = append(comments, "EAP "+eap.Code)
+	}
+	if action := info.Action; action != nil {
+		if action.Error != "" {
+			comments = append(comments, fmt.Sprintf("Malformed %s action frame: %s", action.CategoryName, action.Error))
+		}
+		if cs := action.ChannelSwitch; cs != nil {
+			comments = append(comments, fmt.Sprintf("Channel switch to %d in %d TBTTs", cs.NewChannel, cs.Count))
+		}
+	}
+	if ra := info.RoamingAction; ra != nil {
+		switch ra.Kind {
+		case RoamingActionBTMRequest:
+			comment := fmt.Sprintf("BTM Request with %d candidates", len(ra.Candidates))
+			if ra.DisassociationImminent {
+				comment += ", disassociation imminent"
+			}
+			comments = append(comments, comment)
+		case RoamingActionBTMResponse:
+			comments = append(comments, "BTM Response: "+ra.Status)
+		}
+	}
+	if info.Decrypted {
+		comments = append(comments, "Decrypted with a configured key")
+	}
+	return comments
+}
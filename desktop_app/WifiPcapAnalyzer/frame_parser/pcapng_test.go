@@ -0,0 +1,349 @@
+package frame_parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Deauthentication from AP 02:00:00:00:00:aa to STA 02:00:00:00:00:01, reason 15 (FCS appended)
+const pcapngTestDeauth = "c000 0000 020000000001 0200000000aa 0200000000aa 1000 0f00 00000000"
+
+// The same frame behind a radiotap header with a Channel field (2437 MHz, channel 6)
+const pcapngTestRadiotapDeauth = "0000 0c00 08000000 8509 a000 " + pcapngTestDeauth
+
+func TestPcapngWriterReaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewPcapngWriter(&buf, PcapngSection{Hardware: "hw", OS: "linux", Application: "test", Comment: "section"})
+	require.NoError(t, err)
+	radio, err := w.AddInterface(PcapngInterface{LinkType: layers.LinkTypeIEEE80211Radio, Name: "mon0", Description: "radiotap", Comment: "first", FCSLen: 4})
+	require.NoError(t, err)
+	plain, err := w.AddInterface(PcapngInterface{LinkType: layers.LinkTypeIEEE802_11, Name: "wlan1", FCSLen: pcapngUnknownFCSBytes})
+	require.NoError(t, err)
+
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	require.NoError(t, w.WritePacket(radio, gopacket.CaptureInfo{Timestamp: ts, Length: 100}, []byte{1, 2, 3}, "one", "two"))
+	require.NoError(t, w.WriteCustomBlock(PcapngCustomBlock{PEN: 32473, Data: []byte("customer"), Copyable: true}))
+	require.NoError(t, w.WritePacket(plain, gopacket.CaptureInfo{Timestamp: ts.Add(time.Second)}, []byte{4, 5, 6, 7, 8}))
+	assert.Error(t, w.WritePacket(2, gopacket.CaptureInfo{}, []byte{1}))
+	require.NoError(t, w.Flush())
+	assert.Zero(t, buf.Len()%4)
+
+	r, err := NewPcapngReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, PcapngSection{Hardware: "hw", OS: "linux", Application: "test", Comment: "section"}, r.Section())
+
+	pkt, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 0, pkt.InterfaceID)
+	assert.Equal(t, []byte{1, 2, 3}, pkt.Data)
+	assert.Equal(t, []string{"one", "two"}, pkt.Comments)
+	assert.True(t, ts.Equal(pkt.CaptureInfo.Timestamp), "%v", pkt.CaptureInfo.Timestamp)
+	assert.Equal(t, 3, pkt.CaptureInfo.CaptureLength)
+	assert.Equal(t, 100, pkt.CaptureInfo.Length)
+
+	pkt, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 1, pkt.InterfaceID)
+	assert.Equal(t, []byte{4, 5, 6, 7, 8}, pkt.Data)
+	assert.Empty(t, pkt.Comments)
+	assert.Equal(t, 5, pkt.CaptureInfo.Length)
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+
+	require.Len(t, r.Interfaces(), 2)
+	intf, ok := r.Interface(0)
+	require.True(t, ok)
+	assert.Equal(t, layers.LinkTypeIEEE80211Radio, intf.LinkType)
+	assert.Equal(t, "mon0", intf.Name)
+	assert.Equal(t, "radiotap", intf.Description)
+	assert.Equal(t, "first", intf.Comment)
+	assert.Equal(t, 4, intf.FCSLen)
+	intf, _ = r.Interface(1)
+	assert.Equal(t, layers.LinkTypeIEEE802_11, intf.LinkType)
+	assert.Equal(t, pcapngUnknownFCSBytes, intf.FCSLen)
+	assert.Equal(t, []PcapngCustomBlock{{PEN: 32473, Data: []byte("customer"), Copyable: true}}, r.CustomBlocks())
+}
+
+// pcapngBlock builds a raw block in the given byte order, for reader tests.
+func pcapngBlock(order binary.AppendByteOrder, blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	b := order.AppendUint32(nil, blockType)
+	b = order.AppendUint32(b, uint32(12+len(body)))
+	b = append(b, body...)
+	return order.AppendUint32(b, uint32(12+len(body)))
+}
+
+func TestPcapngReader_BigEndianAndResolutions(t *testing.T) {
+	be := binary.BigEndian
+	shb := be.AppendUint32(nil, pcapngByteOrderMagic)
+	shb = be.AppendUint16(shb, 1)
+	shb = be.AppendUint16(shb, 0)
+	shb = be.AppendUint64(shb, ^uint64(0))
+
+	// Interface 0: default microsecond resolution. Interface 1: 2^-10 s with a 100 s offset.
+	idb0 := []byte{0, 105, 0, 0, 0, 0, 0xff, 0xff}
+	idb1 := []byte{0, 127, 0, 0, 0, 0, 0xff, 0xff, 0, 9, 0, 1, 0x8a, 0, 0, 0, 0, 14, 0, 8}
+	idb1 = be.AppendUint64(idb1, 100)
+	idb1 = append(idb1, 0, 0, 0, 0)
+
+	epb := func(id uint32, ts uint64, data []byte, comment string) []byte {
+		b := be.AppendUint32(nil, id)
+		b = be.AppendUint32(b, uint32(ts>>32))
+		b = be.AppendUint32(b, uint32(ts))
+		b = be.AppendUint32(b, uint32(len(data)))
+		b = be.AppendUint32(b, uint32(len(data)))
+		b = append(b, data...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		if comment != "" {
+			b = be.AppendUint16(b, pcapngOptComment)
+			b = be.AppendUint16(b, uint16(len(comment)))
+			b = append(b, comment...)
+			for len(b)%4 != 0 {
+				b = append(b, 0)
+			}
+			b = append(b, 0, 0, 0, 0)
+		}
+		return b
+	}
+
+	var file []byte
+	file = append(file, pcapngBlock(be, pcapngBlockSectionHeader, shb)...)
+	file = append(file, pcapngBlock(be, pcapngBlockInterfaceDescription, idb0)...)
+	file = append(file, pcapngBlock(be, pcapngBlockInterfaceDescription, idb1)...)
+	file = append(file, pcapngBlock(be, 0x00000005, make([]byte, 12))...) // Interface Statistics, skipped
+	file = append(file, pcapngBlock(be, pcapngBlockEnhancedPacket, epb(0, 1_500_000, []byte{0xaa}, "note"))...)
+	file = append(file, pcapngBlock(be, pcapngBlockEnhancedPacket, epb(1, 1024*3+512, []byte{0xbb, 0xcc}, ""))...)
+	file = append(file, pcapngBlock(be, pcapngBlockSimplePacket, append(be.AppendUint32(nil, 1), 0xdd))...)
+
+	r, err := NewPcapngReader(bytes.NewReader(file))
+	require.NoError(t, err)
+
+	pkt, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1, 500_000_000).UTC(), pkt.CaptureInfo.Timestamp)
+	assert.Equal(t, []string{"note"}, pkt.Comments)
+
+	pkt, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 1, pkt.InterfaceID)
+	assert.Equal(t, time.Unix(103, 500_000_000).UTC(), pkt.CaptureInfo.Timestamp)
+	assert.Equal(t, []byte{0xbb, 0xcc}, pkt.Data)
+
+	pkt, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 0, pkt.InterfaceID)
+	assert.Equal(t, []byte{0xdd}, pkt.Data)
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestPcapngReader_Errors(t *testing.T) {
+	_, err := NewPcapngReader(bytes.NewReader(mustHex(t, "d4c3b2a1 0200 0400")))
+	assert.Error(t, err, "classic pcap magic")
+
+	var buf bytes.Buffer
+	w, err := NewPcapngWriter(&buf, PcapngSection{})
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	le := binary.LittleEndian
+	badEPB := pcapngBlock(le, pcapngBlockEnhancedPacket, make([]byte, 20)) // Interface 0 was never described
+	r, err := NewPcapngReader(bytes.NewReader(append(buf.Bytes(), badEPB...)))
+	require.NoError(t, err)
+	_, err = r.Next()
+	assert.ErrorContains(t, err, "unknown interface 0")
+
+	truncated := append(buf.Bytes(), badEPB[:len(badEPB)-6]...)
+	r, err = NewPcapngReader(bytes.NewReader(truncated))
+	require.NoError(t, err)
+	_, err = r.Next()
+	assert.ErrorContains(t, err, "truncated")
+}
+
+func TestPcapngWriter_RejectsUnrepresentableTimestamps(t *testing.T) {
+	w, err := NewPcapngWriter(io.Discard, PcapngSection{})
+	require.NoError(t, err)
+	id, err := w.AddInterface(PcapngInterface{LinkType: layers.LinkTypeIEEE802_11})
+	require.NoError(t, err)
+	for _, ts := range []time.Time{{}, time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)} {
+		assert.ErrorContains(t, w.WritePacket(id, gopacket.CaptureInfo{Timestamp: ts}, []byte{0}), "out of range", "%v", ts)
+	}
+	assert.NoError(t, w.WritePacket(id, gopacket.CaptureInfo{Timestamp: time.Unix(0, 0)}, []byte{0}))
+}
+
+func TestPcapngReader_CapsCustomBlocks(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewPcapngWriter(&buf, PcapngSection{})
+	require.NoError(t, err)
+	for i := 0; i < maxPcapngCustomBlocks+5; i++ {
+		require.NoError(t, w.WriteCustomBlock(PcapngCustomBlock{PEN: uint32(i), Data: []byte{1, 2, 3, 4}}))
+	}
+	require.NoError(t, w.Flush())
+
+	r, err := NewPcapngReader(&buf)
+	require.NoError(t, err)
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+	require.Len(t, r.CustomBlocks(), maxPcapngCustomBlocks)
+	assert.Equal(t, uint32(maxPcapngCustomBlocks-1), r.CustomBlocks()[maxPcapngCustomBlocks-1].PEN)
+	assert.Equal(t, 5, r.SkippedCustomBlocks())
+}
+
+// writeMixedLinkTypeCapture writes a pcapng file with the deauth frame on a radiotap
+// interface and on a plain 802.11 interface.
+func writeMixedLinkTypeCapture(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w, err := NewPcapngWriter(&buf, PcapngSection{Application: "test"})
+	require.NoError(t, err)
+	radio, err := w.AddInterface(PcapngInterface{LinkType: layers.LinkTypeIEEE80211Radio, Name: "mon0"})
+	require.NoError(t, err)
+	plain, err := w.AddInterface(PcapngInterface{LinkType: layers.LinkTypeIEEE802_11, Name: "wlan1"})
+	require.NoError(t, err)
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, w.WritePacket(radio, gopacket.CaptureInfo{Timestamp: ts}, mustHex(t, pcapngTestRadiotapDeauth), "from the field"))
+	require.NoError(t, w.WriteCustomBlock(PcapngCustomBlock{PEN: 1, Data: []byte{1, 2, 3, 4}}))
+	require.NoError(t, w.WritePacket(plain, gopacket.CaptureInfo{Timestamp: ts.Add(time.Millisecond)}, mustHex(t, pcapngTestDeauth)))
+	require.NoError(t, w.Flush())
+	return buf.Bytes()
+}
+
+func TestProcessCaptureStream_PcapngMixedLinkTypes(t *testing.T) {
+	var frames []*ParsedFrameInfo
+	err := ProcessCaptureStream(bytes.NewReader(writeMixedLinkTypeCapture(t)), func(info *ParsedFrameInfo) {
+		frames = append(frames, info)
+	})
+	require.NoError(t, err)
+	require.Len(t, frames, 2)
+
+	assert.Equal(t, "MgmtDeauthentication", frames[0].FrameType)
+	assert.Equal(t, layers.LinkTypeIEEE80211Radio, frames[0].LinkType)
+	assert.Equal(t, 6, frames[0].Channel)
+	assert.Equal(t, "mon0", frames[0].InterfaceName)
+	assert.Equal(t, []string{"from the field"}, frames[0].PacketComments)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), frames[0].Timestamp)
+
+	assert.Equal(t, "MgmtDeauthentication", frames[1].FrameType)
+	assert.Equal(t, layers.LinkTypeIEEE802_11, frames[1].LinkType)
+	assert.Equal(t, 1, frames[1].InterfaceID)
+	assert.Equal(t, "wlan1", frames[1].InterfaceName)
+	assert.Equal(t, uint16(15), frames[1].ReasonCode)
+	assert.Equal(t, mustHex(t, pcapngTestDeauth), frames[1].RawData)
+}
+
+func TestPcapngExporter(t *testing.T) {
+	var out bytes.Buffer
+	exporter, err := NewPcapngExporter(&out, CaptureAnnotations{Agent: "192.168.6.250:50051", Interface: "ath1", Channel: 1, Bandwidth: "HT20"})
+	require.NoError(t, err)
+	err = ProcessCaptureStream(bytes.NewReader(writeMixedLinkTypeCapture(t)), func(info *ParsedFrameInfo) {
+		require.NoError(t, exporter.Export(info))
+	})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Flush())
+	assert.Equal(t, 2, exporter.Frames())
+
+	r, err := NewPcapngReader(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "WifiPcapAnalyzer", r.Section().Application)
+	assert.Equal(t, "Captured via agent 192.168.6.250:50051", r.Section().Comment)
+
+	pkt, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, mustHex(t, pcapngTestRadiotapDeauth), pkt.Data)
+	assert.Equal(t, []string{
+		"from the field",
+		"Channel changed to channel 6 (2.4GHz, 2437 MHz)",
+		"Deauthentication: reason 15 (4-way handshake timeout)",
+	}, pkt.Comments)
+
+	pkt, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, 1, pkt.InterfaceID)
+	assert.Equal(t, []string{"Deauthentication: reason 15 (4-way handshake timeout)"}, pkt.Comments)
+
+	require.Len(t, r.Interfaces(), 2)
+	intf, _ := r.Interface(0)
+	assert.Equal(t, "mon0", intf.Name)
+	assert.Equal(t, "Agent 192.168.6.250:50051", intf.Description)
+	assert.Equal(t, "Capture started on channel 1 (2.4GHz, 2412 MHz) HT20", intf.Comment)
+	intf, _ = r.Interface(1)
+	assert.Equal(t, layers.LinkTypeIEEE802_11, intf.LinkType)
+}
+
+func TestFrameAnnotations(t *testing.T) {
+	assert.Empty(t, FrameAnnotations(&ParsedFrameInfo{FrameType: "MgmtBeacon"}))
+	assert.Equal(t, []string{"4-way handshake message 3/4", "Decrypted with a configured key"},
+		FrameAnnotations(&ParsedFrameInfo{FrameType: "Data", EAPOLKey: &EAPOLKeyInfo{Message: 3}, Decrypted: true}))
+	assert.Equal(t, []string{"Association rejected: status 17 (AP is unable to handle additional associated STAs)"},
+		FrameAnnotations(&ParsedFrameInfo{AssocResponse: &AssocResponseInfo{StatusCode: 17, Status: StatusCodeString(17)}}))
+	assert.Equal(t, []string{"Channel switch to 36 in 5 TBTTs", "BTM Request with 0 candidates, disassociation imminent"},
+		FrameAnnotations(&ParsedFrameInfo{
+			Action:        &ActionFrameInfo{ChannelSwitch: &ChannelSwitchInfo{NewChannel: 36, Count: 5}},
+			RoamingAction: &RoamingActionInfo{Kind: RoamingActionBTMRequest, DisassociationImminent: true},
+		}))
+}
+
+func TestProcessCaptureStream_PipelineStats(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewPcapngWriter(&buf, PcapngSection{})
+	require.NoError(t, err)
+	plain, err := w.AddInterface(PcapngInterface{LinkType: layers.LinkTypeIEEE802_11, Name: "wlan1"})
+	require.NoError(t, err)
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, w.WritePacket(plain, gopacket.CaptureInfo{Timestamp: ts}, mustHex(t, pcapngTestDeauth)))
+	require.NoError(t, w.WritePacket(plain, gopacket.CaptureInfo{Timestamp: ts}, []byte{0xc0}))
+	require.NoError(t, w.Flush())
+
+	le := binary.LittleEndian
+	isb := func(ifDrop, osDrop uint64) []byte {
+		b := make([]byte, 12) // Interface 0, no timestamp
+		b = le.AppendUint16(b, pcapngOptISBIfDrop)
+		b = le.AppendUint16(b, 8)
+		b = le.AppendUint64(b, ifDrop)
+		b = le.AppendUint16(b, pcapngOptISBOSDrop)
+		b = le.AppendUint16(b, 8)
+		b = le.AppendUint64(b, osDrop)
+		return pcapngBlock(le, pcapngBlockInterfaceStatistics, append(b, 0, 0, 0, 0))
+	}
+	// Statistics are cumulative: the second block adds 4 drops to the first 4
+	file := append(buf.Bytes(), isb(3, 1)...)
+	file = append(file, isb(6, 2)...)
+	// A second section renumbers its interfaces: its interface 0 adds 2 drops of its own.
+	var section bytes.Buffer
+	w, err = NewPcapngWriter(&section, PcapngSection{})
+	require.NoError(t, err)
+	_, err = w.AddInterface(PcapngInterface{LinkType: layers.LinkTypeIEEE802_11, Name: "wlan2"})
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	file = append(file, section.Bytes()...)
+	file = append(file, isb(2, 0)...)
+
+	r, err := NewPcapngReader(bytes.NewReader(file))
+	require.NoError(t, err)
+	for err == nil {
+		_, err = r.Next()
+	}
+	intf, _ := r.Interface(0)
+	assert.Equal(t, uint64(2), intf.Dropped)
+	assert.Equal(t, uint64(10), r.Dropped())
+
+	before := GetPipelineStats()
+	err = ProcessCaptureStream(bytes.NewReader(file), func(info *ParsedFrameInfo) {})
+	assert.ErrorContains(t, err, "1 errors")
+	after := GetPipelineStats()
+	assert.Equal(t, uint64(1), after.FramesParsed-before.FramesParsed)
+	assert.Equal(t, uint64(1), after.ParseErrors-before.ParseErrors)
+	assert.Equal(t, uint64(10), after.FramesDropped-before.FramesDropped)
+}
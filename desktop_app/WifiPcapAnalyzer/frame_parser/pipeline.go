@@ -0,0 +1,171 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/config"
+	"encoding/binary"
+	"runtime"
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// The parse pipeline has three stages:
+//
+//  1. The reader (the caller of process/processData) wraps each packet in a frameJob, queues it
+//     for one worker and appends it to the pending queue, which keeps capture order.
+//  2. Workers decode and parse their jobs. A link's frames (both directions between a
+//     transmitter and a receiver) always go to the same worker.
+//  3. A single writer takes jobs off the pending queue, waits for each to be parsed and calls
+//     the handler. The handler therefore sees every frame in capture order, from one goroutine,
+//     which A-MPDU grouping and the join timelines in the state manager depend on.
+//
+// Decryption is not done on workers: the decryptor learns from Beacons, 4-way handshakes and
+// group key handshakes that a BSS's frames spread over several workers, and must see them in
+// capture order. With decryption configured, frames are parsed inline.
+
+// jobsPerWorker bounds how far the reader can run ahead of the writer.
+const jobsPerWorker = 256
+
+// frameJob is one packet travelling through the parse pipeline.
+type frameJob struct {
+	data     []byte
+	ci       gopacket.CaptureInfo
+	packet   gopacket.Packet // Already decoded, or nil to decode data with linkType
+	linkType layers.LinkType
+	annotate func(info *ParsedFrameInfo)
+
+	info *ParsedFrameInfo
+	err  error
+	done chan struct{} // Signalled once the job is parsed
+}
+
+var frameJobPool = sync.Pool{
+	New: func() interface{} { return &frameJob{done: make(chan struct{}, 1)} },
+}
+
+func newFrameJob() *frameJob {
+	return frameJobPool.Get().(*frameJob)
+}
+
+func releaseFrameJob(job *frameJob) {
+	done := job.done
+	*job = frameJob{done: done}
+	frameJobPool.Put(job)
+}
+
+// parseWorkers returns the configured number of parse workers. Zero and one parse inline,
+// on the reader's goroutine; a negative number means one worker per CPU.
+func parseWorkers() int {
+	n := config.GlobalConfig.ParseWorkers
+	switch {
+	case n < 0:
+		return runtime.GOMAXPROCS(0)
+	case n == 0:
+		return 1
+	}
+	return n
+}
+
+func (fp *frameProcessor) startPipeline(workers int) {
+	fp.workers = make([]chan *frameJob, workers)
+	fp.pending = make(chan *frameJob, workers*jobsPerWorker)
+	fp.writerDone = make(chan struct{})
+
+	for i := range fp.workers {
+		queue := make(chan *frameJob, jobsPerWorker)
+		fp.workers[i] = queue
+		go func() {
+			parser := fp.newParser()
+			for job := range queue {
+				fp.parse(job, parser)
+				job.done <- struct{}{}
+			}
+		}()
+	}
+	go func() {
+		defer close(fp.writerDone)
+		for job := range fp.pending {
+			<-job.done
+			fp.deliver(job)
+			releaseFrameJob(job)
+		}
+	}()
+}
+
+// submit runs a job inline, or hands it to the worker owning its link.
+func (fp *frameProcessor) submit(job *frameJob) {
+	fp.frameCount++
+	if fp.workers == nil {
+		fp.parse(job, fp.inlineParser)
+		fp.deliver(job)
+		releaseFrameJob(job)
+		return
+	}
+	fp.pending <- job
+	fp.workers[linkShard(job.data, job.linkType, len(fp.workers))] <- job
+}
+
+// stopPipeline waits until every submitted job has been delivered.
+func (fp *frameProcessor) stopPipeline() {
+	if fp.workers == nil {
+		return
+	}
+	for _, queue := range fp.workers {
+		close(queue)
+	}
+	close(fp.pending)
+	<-fp.writerDone
+	fp.workers = nil
+}
+
+// linkShard picks the worker for a packet from the 802.11 addresses in its raw data. Unicast
+// frames are keyed by their transmitter and receiver, in either direction; group-addressed
+// frames and frames without a transmitter address (ACK, CTS) by the single address.
+func linkShard(data []byte, linkType layers.LinkType, workers int) int {
+	offset := dot11HeaderOffset(data, linkType)
+	if offset < 0 || len(data) < offset+10 {
+		return 0
+	}
+	header := data[offset:]
+	key := macKey(header[4:10]) // Address 1 (receiver)
+	frameType := layers.Dot11Type(header[0] >> 2)
+	if len(header) >= 16 && frameType != layers.Dot11TypeCtrlCTS && frameType != layers.Dot11TypeCtrlAck {
+		ta := macKey(header[10:16])
+		if header[4]&0x01 != 0 {
+			key = ta
+		} else {
+			key ^= ta
+		}
+	}
+	key *= 0x9e3779b97f4a7c15 // Spread the address bits over the high bits
+	return int((key >> 32) % uint64(workers))
+}
+
+func macKey(mac []byte) uint64 {
+	return uint64(mac[0])<<40 | uint64(mac[1])<<32 | uint64(mac[2])<<24 |
+		uint64(mac[3])<<16 | uint64(mac[4])<<8 | uint64(mac[5])
+}
+
+// dot11HeaderOffset returns the length of the capture header in front of the 802.11 frame,
+// or -1 if it can't be determined from the data.
+func dot11HeaderOffset(data []byte, linkType layers.LinkType) int {
+	switch linkType {
+	case layers.LinkTypeIEEE802_11:
+		return 0
+	case layers.LinkTypeIEEE80211Radio, LinkTypePPI:
+		if len(data) < 4 {
+			return -1
+		}
+		return int(binary.LittleEndian.Uint16(data[2:4]))
+	case layers.LinkTypePrismHeader, LinkTypeAVS:
+		if len(data) < 8 {
+			return -1
+		}
+		if binary.BigEndian.Uint32(data[0:4])>>4 == avsMagic {
+			return int(binary.BigEndian.Uint32(data[4:8]))
+		}
+		return int(binary.LittleEndian.Uint32(data[4:8]))
+	}
+	return -1
+}
@@ -0,0 +1,178 @@
+package frame_parser
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"WifiPcapAnalyzer/config"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Radiotap header with the channel field (2437 MHz) in front of every synthetic frame.
+const syntheticRadiotap = "0000 0c00 08000000 8509 a000"
+
+// writeSyntheticCapture writes a radiotap pcap of beacons from 8 APs, and QoS data frames and
+// their ACKs between those APs and 64 STAs, in a fixed rotation.
+func writeSyntheticCapture(tb testing.TB, frames int) []byte {
+	tb.Helper()
+	beacon := mustHex(tb, syntheticRadiotap+"8000 0000 ffffffffffff 0200000000a0 0200000000a0 0000"+
+		"0000000000000000 6400 0104 00 04 74657374 03 01 06 01 04 82848b96 00000000")
+	ip, udp := udpLayers("192.168.1.10", "192.168.1.1", 40000, 9)
+	payload := gopacket.NewSerializeBuffer()
+	require.NoError(tb, gopacket.SerializeLayers(payload, gopacket.SerializeOptions{FixLengths: true},
+		ip, udp, gopacket.Payload(make([]byte, 64))))
+	data := mustHex(tb, syntheticRadiotap+"8801 3000 0200000000a0 020000000100 0200000000a0 0000 0000 aaaa03000000 0800")
+	data = append(append(data, payload.Bytes()...), 0, 0, 0, 0)
+	ack := mustHex(tb, syntheticRadiotap+"d400 0000 020000000100 00000000")
+
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	require.NoError(tb, w.WriteFileHeader(65535, layers.LinkTypeIEEE80211Radio))
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	apOffset := len(mustHex(tb, syntheticRadiotap)) + 15 // Last octet of the AP's TA/BSSID
+	for i := 0; i < frames; i++ {
+		ap, sta := byte(0xa0+i%8), byte(i%64)
+		var frame []byte
+		switch i % 4 {
+		case 0:
+			frame = beacon
+			frame[apOffset], frame[apOffset+6] = ap, ap
+		case 1, 2:
+			frame = data
+			frame[apOffset-6], frame[apOffset], frame[apOffset+6] = ap, sta, ap // BSSID, SA, DA
+		case 3:
+			frame = ack
+			frame[apOffset-6] = sta
+		}
+		ci := gopacket.CaptureInfo{Timestamp: ts.Add(time.Duration(i) * 100 * time.Microsecond), CaptureLength: len(frame), Length: len(frame)}
+		require.NoError(tb, w.WritePacket(ci, frame))
+	}
+	return buf.Bytes()
+}
+
+func withParseWorkers(tb testing.TB, workers int) {
+	saved := config.GlobalConfig.ParseWorkers
+	config.GlobalConfig.ParseWorkers = workers
+	tb.Cleanup(func() { config.GlobalConfig.ParseWorkers = saved })
+}
+
+func TestParseWorkers(t *testing.T) {
+	cases := []struct{ configured, want int }{
+		{0, 1},
+		{1, 1},
+		{4, 4},
+		{-1, runtime.GOMAXPROCS(0)},
+	}
+	for _, tc := range cases {
+		withParseWorkers(t, tc.configured)
+		assert.Equal(t, tc.want, parseWorkers(), "parse_workers=%d", tc.configured)
+	}
+}
+
+func TestNewFrameProcessor_DecryptionParsesInline(t *testing.T) {
+	withDecryptionKeys(t, config.DecryptionKeyConfig{Passphrase: "correct horse battery"})
+	fp := newFrameProcessorWithWorkers(func(*ParsedFrameInfo) {}, 4)
+	assert.Nil(t, fp.workers)
+	assert.NotNil(t, fp.inlineParser)
+	assert.NoError(t, fp.finish("test"))
+}
+
+func TestProcessCaptureStream_ParallelKeepsCaptureOrder(t *testing.T) {
+	capture := writeSyntheticCapture(t, 2000)
+	for _, workers := range []int{1, 4} {
+		withParseWorkers(t, workers)
+		var frames []*ParsedFrameInfo
+		require.NoError(t, ProcessCaptureStream(bytes.NewReader(capture), func(info *ParsedFrameInfo) {
+			frames = append(frames, info)
+		}))
+		require.Len(t, frames, 2000, "workers=%d", workers)
+		for i := 1; i < len(frames); i++ {
+			require.True(t, frames[i].Timestamp.After(frames[i-1].Timestamp), "workers=%d: frame %d out of order", workers, i)
+		}
+		assert.Equal(t, "MgmtBeacon", frames[0].FrameType)
+		assert.Equal(t, "DataQOSData", frames[1].FrameType)
+		assert.Equal(t, "CtrlAck", frames[3].FrameType)
+		assert.Equal(t, layers.LinkTypeIEEE80211Radio, frames[1].LinkType)
+		assert.Equal(t, 2437, frames[1].Frequency)
+	}
+}
+
+func TestLinkShard(t *testing.T) {
+	frame := func(fc, a1, a2 string) []byte {
+		return mustHex(t, syntheticRadiotap+fc+"0000"+a1+a2+"0200000000aa 0000")
+	}
+	const ap, sta = "0200000000aa", "020000000001"
+	const workers = 7
+
+	uplink := linkShard(frame("8801", ap, sta), layers.LinkTypeIEEE80211Radio, workers)
+	downlink := linkShard(frame("8802", sta, ap), layers.LinkTypeIEEE80211Radio, workers)
+	assert.Equal(t, uplink, downlink, "both directions of a link share a worker")
+
+	// Group-addressed frames are keyed by the transmitter alone, ACKs by the receiver alone.
+	beacon := linkShard(frame("8000", "ffffffffffff", ap), layers.LinkTypeIEEE80211Radio, workers)
+	assert.Equal(t, linkShard(frame("0800", "01005e000001", ap), layers.LinkTypeIEEE80211Radio, workers), beacon)
+	ack := mustHex(t, "d400 0000"+sta+"00000000")
+	assert.Equal(t, linkShard(frame("8802", "ffffffffffff", sta), layers.LinkTypeIEEE80211Radio, workers),
+		linkShard(ack, layers.LinkTypeIEEE802_11, workers))
+
+	// Headers that can't be located fall back to the first worker.
+	assert.Equal(t, 0, linkShard([]byte{0, 0}, layers.LinkTypeIEEE80211Radio, workers))
+	assert.Equal(t, 0, linkShard(ack, layers.LinkTypeEthernet, workers))
+}
+
+// benchmarkFrames is the size of the synthetic capture used by BenchmarkProcessCapture.
+const benchmarkFrames = 1000000
+
+var (
+	benchmarkCaptureOnce sync.Once
+	benchmarkCapture     []byte
+)
+
+// BenchmarkProcessCapture parses a synthetic 1M-frame pcap through the gopacket.PacketSource
+// path (decode while reading, copying each packet) and through the parse pipeline, with each
+// parser, with a single inline worker and with one worker per CPU.
+func BenchmarkProcessCapture(b *testing.B) {
+	benchmarkCaptureOnce.Do(func() { benchmarkCapture = writeSyntheticCapture(b, benchmarkFrames) })
+	handler := func(info *ParsedFrameInfo) {}
+
+	run := func(b *testing.B, workers int, process func() error) {
+		withParseWorkers(b, workers)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, process())
+		}
+		b.ReportMetric(float64(benchmarkFrames)*float64(b.N)/b.Elapsed().Seconds(), "frames/s")
+	}
+
+	b.Run("PacketSource", func(b *testing.B) {
+		run(b, 1, func() error {
+			r, err := pcapgo.NewReader(bytes.NewReader(benchmarkCapture))
+			if err != nil {
+				return err
+			}
+			return ProcessPacketSource(gopacket.NewPacketSource(r, r.LinkType()), handler)
+		})
+	})
+	workerCounts := []int{1}
+	if n := runtime.GOMAXPROCS(0); n > 1 {
+		workerCounts = append(workerCounts, n)
+	}
+	for _, parser := range []string{ParserGoPacket, ParserFast} {
+		for _, workers := range workerCounts {
+			b.Run(fmt.Sprintf("%s/Workers=%d", parser, workers), func(b *testing.B) {
+				withParser(b, parser)
+				run(b, workers, func() error { return ProcessCaptureStream(bytes.NewReader(benchmarkCapture), handler) })
+			})
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package frame_parser
+
+import "fmt"
+
+// reasonCodeNames maps Deauthentication/Disassociation reason codes to their meaning.
+// Reference: IEEE 802.11-2020, Table 9-49 (Reason codes)
+var reasonCodeNames = map[uint16]string{
+	1:  "Unspecified reason",
+	2:  "Previous authentication no longer valid",
+	3:  "Deauthenticated because sending STA is leaving (or has left) IBSS or ESS",
+	4:  "Disassociated due to inactivity",
+	5:  "Disassociated because AP is unable to handle all currently associated STAs",
+	6:  "Class 2 frame received from nonauthenticated STA",
+	7:  "Class 3 frame received from nonassociated STA",
+	8:  "Disassociated because sending STA is leaving (or has left) BSS",
+	9:  "STA requesting (re)association is not authenticated with responding STA",
+	10: "Disassociated because the information in the Power Capability element is unacceptable",
+	11: "Disassociated because the information in the Supported Channels element is unacceptable",
+	12: "Disassociated due to BSS transition management",
+	13: "Invalid element",
+	14: "Message integrity code (MIC) failure",
+	15: "4-way handshake timeout",
+	16: "Group key handshake timeout",
+	17: "Element in 4-way handshake different from (Re)Association Request/Probe Response/Beacon frame",
+	18: "Invalid group cipher",
+	19: "Invalid pairwise cipher",
+	20: "Invalid AKMP",
+	21: "Unsupported RSNE version",
+	22: "Invalid RSNE capabilities",
+	23: "IEEE 802.1X authentication failed",
+	24: "Cipher suite rejected because of the security policy",
+	25: "TDLS direct-link teardown due to TDLS peer STA unreachable via the TDLS direct link",
+	26: "TDLS direct-link teardown for unspecified reason",
+	27: "Disassociated because session terminated by SSP request",
+	28: "Disassociated because of lack of SSP roaming agreement",
+	29: "Requested service rejected because of SSP cipher suite or AKM requirement",
+	30: "Requested service not authorized in this location",
+	31: "TS deleted because QoS AP lacks sufficient bandwidth due to a change in BSS service characteristics or operational mode",
+	32: "Disassociated for unspecified, QoS-related reason",
+	33: "Disassociated because QoS AP lacks sufficient bandwidth for this QoS STA",
+	34: "Disassociated because of excessive unacknowledged frames (poor channel conditions)",
+	35: "Disassociated because STA is transmitting outside the limits of its TXOPs",
+	36: "Requesting STA is leaving the BSS (or resetting)",
+	37: "Requesting STA is no longer using the stream or session",
+	38: "Requesting STA received frames using a mechanism for which a setup has not been completed",
+	39: "Requested from peer STA due to timeout",
+	45: "Peer STA does not support the requested cipher suite",
+	46: "Disassociated because authorized access limit reached",
+	47: "Disassociated due to external service requirements",
+	48: "Invalid FT Action frame count",
+	49: "Invalid pairwise master key identifier (PMKID)",
+	50: "Invalid MDE",
+	51: "Invalid FTE",
+	52: "Mesh peering canceled",
+	53: "Mesh maximum number of peers reached",
+	54: "Mesh configuration policy violation",
+	55: "Mesh peering close received",
+	56: "Mesh maximum retries reached",
+	57: "Mesh confirm timeout",
+	58: "Mesh invalid GTK",
+	59: "Mesh inconsistent parameters",
+	60: "Mesh invalid security capability",
+	61: "Mesh path error: no proxy information",
+	62: "Mesh path error: no forwarding information",
+	63: "Mesh path error: destination unreachable",
+	64: "MAC address already exists in MBSS",
+	65: "Mesh channel switch due to regulatory requirements",
+	66: "Mesh channel switch for unspecified reason",
+	67: "Transmission link establishment in alternative band failed",
+	68: "Alternative channel occupied",
+	71: "Poor RSSI conditions",
+}
+
+// statusCodeNames maps status codes of Authentication and (Re)Association Response frames
+// (and other management frames) to their meaning.
+// Reference: IEEE 802.11-2020, Table 9-50 (Status codes)
+var statusCodeNames = map[uint16]string{
+	0:   "Success",
+	1:   "Unspecified failure",
+	2:   "TDLS wakeup schedule rejected but alternative schedule provided",
+	3:   "TDLS wakeup schedule rejected",
+	5:   "Security disabled",
+	6:   "Unacceptable lifetime",
+	7:   "Not in same BSS",
+	10:  "Cannot support all requested capabilities in the Capability Information field",
+	11:  "Reassociation denied due to inability to confirm that association exists",
+	12:  "Association denied due to reason outside the scope of this standard",
+	13:  "Responding STA does not support the specified authentication algorithm",
+	14:  "Authentication transaction sequence number out of expected sequence",
+	15:  "Authentication rejected because of challenge failure",
+	16:  "Authentication rejected due to timeout waiting for next frame in sequence",
+	17:  "AP is unable to handle additional associated STAs",
+	18:  "Association denied because STA does not support all rates in the BSSBasicRateSet",
+	19:  "Association denied because STA does not support short preamble",
+	22:  "Association request rejected because Spectrum Management capability is required",
+	23:  "Association request rejected because the Power Capability element is unacceptable",
+	24:  "Association request rejected because the Supported Channels element is unacceptable",
+	25:  "Association denied because STA does not support short slot time",
+	27:  "Association denied because STA does not support HT features",
+	28:  "R0KH unreachable",
+	29:  "Association denied because STA does not support the PCO transition time",
+	30:  "Association request rejected temporarily; try again later",
+	31:  "Robust management frame policy violation",
+	32:  "Unspecified, QoS-related failure",
+	33:  "Association denied because QoS AP has insufficient bandwidth",
+	34:  "Association denied due to excessive frame loss rates and/or poor channel conditions",
+	35:  "Association denied because STA does not support QoS",
+	37:  "The request has been declined",
+	38:  "The request has not been successful as one or more parameters have invalid values",
+	39:  "The allocation or TS has not been created; a suggested change is provided",
+	40:  "Invalid element",
+	41:  "Invalid group cipher",
+	42:  "Invalid pairwise cipher",
+	43:  "Invalid AKMP",
+	44:  "Unsupported RSNE version",
+	45:  "Invalid RSNE capabilities",
+	46:  "Cipher suite rejected because of security policy",
+	47:  "The TS or allocation has not been created; retry after the TS delay",
+	48:  "Direct link is not allowed in the BSS by policy",
+	49:  "The Destination STA is not present within this BSS",
+	50:  "The Destination STA is not a QoS STA",
+	51:  "Association denied because the listen interval is too large",
+	52:  "Invalid FT Action frame count",
+	53:  "Invalid pairwise master key identifier (PMKID)",
+	54:  "Invalid MDE",
+	55:  "Invalid FTE",
+	56:  "Requested TCLAS processing is not supported by the AP or PCP",
+	57:  "The AP or PCP has insufficient TCLAS processing resources",
+	58:  "The TS has not been created because the request cannot be honored; try another BSS",
+	59:  "GAS Advertisement Protocol not supported",
+	60:  "No outstanding GAS request",
+	61:  "GAS Response not received from the Advertisement Server",
+	62:  "STA timed out waiting for GAS Query Response",
+	63:  "GAS Response is larger than query response length limit",
+	64:  "Request refused because home network does not support request",
+	65:  "Advertisement Server in the network is not currently reachable",
+	67:  "Request refused due to permissions received via SSPN interface",
+	68:  "Request refused because the AP or PCP does not support unauthenticated access",
+	72:  "Invalid contents of RSNE",
+	73:  "U-APSD coexistence is not supported",
+	74:  "Requested U-APSD coexistence mode is not supported",
+	75:  "Requested interval/duration value cannot be supported with U-APSD coexistence",
+	76:  "Anti-clogging token required",
+	77:  "Finite cyclic group not supported",
+	78:  "Cannot find an alternative TBTT",
+	79:  "Transmission failure",
+	80:  "Requested TCLAS not supported",
+	81:  "TCLAS resources exhausted",
+	82:  "Rejected with suggested BSS transition",
+	83:  "Reject with recommended schedule",
+	84:  "Reject because no wakeup schedule specified",
+	85:  "Success, the destination STA is in power save mode",
+	86:  "FST pending, in process of admitting FST session",
+	87:  "Performing FST now",
+	88:  "FST pending, gap(s) in block ack window",
+	89:  "Reject because of U-PID setting",
+	92:  "Refused because of external reason",
+	93:  "Refused because AP or PCP is out of memory",
+	94:  "Refused because emergency services are not supported at the AP",
+	95:  "GAS query response not yet received",
+	96:  "Reject since the request is for transition to a frequency band subject to DSE procedures",
+	97:  "Requested TCLAS processing has been terminated by the AP",
+	98:  "The TS schedule conflicts with an existing schedule",
+	99:  "Association denied; suggested band and channel provided",
+	100: "MCCAOP reservation conflict",
+	101: "MAF limit exceeded",
+	102: "MCCA track limit exceeded",
+	103: "Denied due to spectrum management",
+	104: "Association denied because STA does not support VHT features",
+	105: "Enablement denied",
+	106: "Restriction from an authorized GDB",
+	107: "Authorization deenabled",
+	112: "FILS authentication failure",
+	113: "Unknown authentication server",
+	126: "SAE hash-to-element",
+	127: "SAE-PK",
+}
+
+// ReasonCodeString returns the meaning of a reason code, e.g. "4-way handshake timeout".
+func ReasonCodeString(code uint16) string {
+	if name, ok := reasonCodeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("Reserved/unknown reason (%d)", code)
+}
+
+// StatusCodeString returns the meaning of a status code, e.g. "AP is unable to handle additional associated STAs".
+func StatusCodeString(code uint16) string {
+	if name, ok := statusCodeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("Reserved/unknown status (%d)", code)
+}
@@ -0,0 +1,177 @@
+package frame_parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Element IDs of the load and regulatory elements (IEEE 802.11-2020, Table 9-92).
+const (
+	ieIDCountry                uint8 = 7
+	ieIDBSSLoad                uint8 = 11
+	ieIDPowerConstraint        uint8 = 32
+	ieIDTransmitPowerEnvelope  uint8 = 195
+	countryOperatingTripletMin uint8 = 201 // First octet >= 201 marks an Operating triplet
+)
+
+// BSSLoadInfo is the decoded BSS Load element.
+type BSSLoadInfo struct {
+	StationCount               uint16  `json:"station_count"`
+	ChannelUtilization         uint8   `json:"channel_utilization"`          // Raw value, 255 = 100% busy
+	ChannelUtilizationPercent  float64 `json:"channel_utilization_percent"`  // ChannelUtilization scaled to 0.0 - 100.0
+	AvailableAdmissionCapacity uint16  `json:"available_admission_capacity"` // In units of 32 us/s
+}
+
+// CountryInfo is the decoded Country element.
+type CountryInfo struct {
+	Code        string           `json:"code"`        // ISO 3166-1 alpha-2, e.g. "US"
+	Environment string           `json:"environment"` // "Any", "Indoor", "Outdoor", "Non-country entity" or "Global operating classes"
+	Subbands    []CountrySubband `json:"subbands,omitempty"`
+}
+
+// CountrySubband is one Subband triplet of the Country element. OperatingClass is set when
+// the triplet follows an Operating triplet.
+type CountrySubband struct {
+	OperatingClass uint8 `json:"operating_class,omitempty"`
+	FirstChannel   uint8 `json:"first_channel"`
+	NumChannels    uint8 `json:"num_channels"`
+	MaxTxPowerDBm  int8  `json:"max_tx_power_dbm"`
+}
+
+// TransmitPowerEnvelopeInfo is one decoded Transmit Power Envelope element.
+type TransmitPowerEnvelopeInfo struct {
+	Interpretation string    `json:"interpretation"` // "Local EIRP", "Local EIRP PSD", "Regulatory client EIRP", "Regulatory client EIRP PSD"
+	Category       string    `json:"category"`       // "Default" or "Subordinate Device"
+	Values         []float64 `json:"values"`         // EIRP: dBm for 20/40/80/160 MHz; PSD: dBm/MHz per 20 MHz subchannel (or one value for all)
+}
+
+var tpeInterpretationNames = map[uint8]string{
+	0: "Local EIRP",
+	1: "Local EIRP PSD",
+	2: "Regulatory client EIRP",
+	3: "Regulatory client EIRP PSD",
+}
+
+func init() {
+	RegisterIEDecoder(ieIDCountry, "Country", decodeCountryIE)
+	RegisterIEDecoder(ieIDBSSLoad, "BSS Load", decodeBSSLoadIE)
+	RegisterIEDecoder(ieIDPowerConstraint, "Power Constraint", decodePowerConstraintIE)
+	RegisterIEDecoder(ieIDTransmitPowerEnvelope, "Transmit Power Envelope", decodeTPEIE)
+}
+
+func decodeBSSLoadIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 5 {
+		return nil, fmt.Errorf("BSS Load element too short: %d bytes", len(ieData))
+	}
+	info.BSSLoad = &BSSLoadInfo{
+		StationCount:               binary.LittleEndian.Uint16(ieData[0:2]),
+		ChannelUtilization:         ieData[2],
+		ChannelUtilizationPercent:  math.Round(float64(ieData[2])*10000/255) / 100,
+		AvailableAdmissionCapacity: binary.LittleEndian.Uint16(ieData[3:5]),
+	}
+	return info.BSSLoad, nil
+}
+
+func decodeCountryIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 3 {
+		return nil, fmt.Errorf("Country element too short: %d bytes", len(ieData))
+	}
+	country := &CountryInfo{Code: string(ieData[0:2])}
+	switch ieData[2] {
+	case ' ':
+		country.Environment = "Any"
+	case 'I':
+		country.Environment = "Indoor"
+	case 'O':
+		country.Environment = "Outdoor"
+	case 'X':
+		country.Environment = "Non-country entity"
+	case 0x04:
+		country.Environment = "Global operating classes"
+	default:
+		country.Environment = fmt.Sprintf("Unknown(0x%02x)", ieData[2])
+	}
+
+	var operatingClass uint8
+	// Triplets follow; a trailing pad octet keeps the element length even
+	for triplets := ieData[3:]; len(triplets) >= 3; triplets = triplets[3:] {
+		if triplets[0] >= countryOperatingTripletMin {
+			operatingClass = triplets[1] // Operating Extension Identifier, Operating Class, Coverage Class
+			continue
+		}
+		country.Subbands = append(country.Subbands, CountrySubband{
+			OperatingClass: operatingClass,
+			FirstChannel:   triplets[0],
+			NumChannels:    triplets[1],
+			MaxTxPowerDBm:  int8(triplets[2]),
+		})
+	}
+	info.Country = country
+	return country, nil
+}
+
+func decodePowerConstraintIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 1 {
+		return nil, fmt.Errorf("Power Constraint element is empty")
+	}
+	info.PowerConstraintDB = ieData[0]
+	return info.PowerConstraintDB, nil
+}
+
+// decodeTPEIE decodes a Transmit Power Envelope element. Values are signed and in 0.5 dB steps.
+func decodeTPEIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 2 {
+		return nil, fmt.Errorf("Transmit Power Envelope element too short: %d bytes", len(ieData))
+	}
+	count := int(ieData[0] & 0x07)
+	interpretation := (ieData[0] >> 3) & 0x07
+	tpe := TransmitPowerEnvelopeInfo{Category: "Default"}
+	if ieData[0]>>6 == 1 {
+		tpe.Category = "Subordinate Device"
+	}
+	if name, ok := tpeInterpretationNames[interpretation]; ok {
+		tpe.Interpretation = name
+	} else {
+		tpe.Interpretation = fmt.Sprintf("Unknown(%d)", interpretation)
+	}
+
+	// EIRP: one value per bandwidth up to 20 << count MHz. PSD: count 0 means a single
+	// value for every 20 MHz subchannel, otherwise 2^(count-1) values.
+	numValues := count + 1
+	if interpretation == 1 || interpretation == 3 {
+		numValues = 1
+		if count > 0 {
+			numValues = 1 << (count - 1)
+		}
+	}
+	if len(ieData) < 1+numValues {
+		return nil, fmt.Errorf("Transmit Power Envelope element truncated: %d of %d values", len(ieData)-1, numValues)
+	}
+	for _, v := range ieData[1 : 1+numValues] {
+		tpe.Values = append(tpe.Values, float64(int8(v))/2)
+	}
+	info.TransmitPowerEnvelopes = append(info.TransmitPowerEnvelopes, tpe)
+	return tpe, nil
+}
+
+// CountryAllowsChannel reports whether channel lies in one of the Subband triplets of country.
+// ok is false when the element has no Subband triplets to check against. Channels step by 1
+// in the 2.4 GHz band and by 4 in the 5 and 6 GHz bands (and in 6 GHz operating classes).
+func CountryAllowsChannel(country *CountryInfo, channel int) (allowed bool, maxTxPowerDBm int8, ok bool) {
+	if country == nil || len(country.Subbands) == 0 || channel <= 0 {
+		return false, 0, false
+	}
+	for _, sb := range country.Subbands {
+		step := 1
+		if sb.FirstChannel > 14 || (sb.OperatingClass >= 131 && sb.OperatingClass <= 137) {
+			step = 4
+		}
+		first := int(sb.FirstChannel)
+		last := first + (int(sb.NumChannels)-1)*step
+		if channel >= first && channel <= last && (channel-first)%step == 0 {
+			return true, sb.MaxTxPowerDBm, true
+		}
+	}
+	return false, 0, true
+}
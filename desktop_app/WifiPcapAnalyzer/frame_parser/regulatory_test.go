@@ -0,0 +1,76 @@
+package frame_parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInformationElements_LoadAndRegulatory(t *testing.T) {
+	var payload []byte
+	payload = append(payload, mustHex(t, "0b 05 0c00 80 1027")...)              // BSS Load: 12 STAs, 128/255 busy
+	payload = append(payload, mustHex(t, "07 0a 555320 010b1e 240414 00")...)   // Country US, 1-11 @ 30 dBm, 36-48 @ 20 dBm, pad
+	payload = append(payload, mustHex(t, "20 01 03")...)                        // Power Constraint 3 dB
+	payload = append(payload, mustHex(t, "c3 04 02 28 26 24")...)               // TPE Local EIRP 20/40/80 MHz
+	payload = append(payload, mustHex(t, "c3 03 5a 0a f6")...)                  // TPE Regulatory client EIRP PSD, subordinate, 2 values
+	payload = append(payload, mustHex(t, "07 09 4445 04 c9 83 00 01 3d 17")...) // Country DE, global classes, class 131: 1-233
+	info := &ParsedFrameInfo{}
+	parseInformationElements(info, payload[:len(payload)-11])
+
+	require.NotNil(t, info.BSSLoad)
+	assert.Equal(t, uint16(12), info.BSSLoad.StationCount)
+	assert.Equal(t, 50.2, info.BSSLoad.ChannelUtilizationPercent)
+	assert.Equal(t, uint16(10000), info.BSSLoad.AvailableAdmissionCapacity)
+
+	require.NotNil(t, info.Country)
+	assert.Equal(t, &CountryInfo{Code: "US", Environment: "Any", Subbands: []CountrySubband{
+		{FirstChannel: 1, NumChannels: 11, MaxTxPowerDBm: 30},
+		{FirstChannel: 36, NumChannels: 4, MaxTxPowerDBm: 20},
+	}}, info.Country)
+	assert.Equal(t, uint8(3), info.PowerConstraintDB)
+
+	require.Len(t, info.TransmitPowerEnvelopes, 2)
+	assert.Equal(t, TransmitPowerEnvelopeInfo{Interpretation: "Local EIRP", Category: "Default", Values: []float64{20, 19, 18}}, info.TransmitPowerEnvelopes[0])
+	assert.Equal(t, TransmitPowerEnvelopeInfo{Interpretation: "Regulatory client EIRP PSD", Category: "Subordinate Device", Values: []float64{5, -5}}, info.TransmitPowerEnvelopes[1])
+
+	// Operating triplet followed by a 6 GHz subband
+	info = &ParsedFrameInfo{}
+	parseInformationElements(info, payload[len(payload)-11:])
+	require.NotNil(t, info.Country)
+	assert.Equal(t, "Global operating classes", info.Country.Environment)
+	assert.Equal(t, []CountrySubband{{OperatingClass: 131, FirstChannel: 1, NumChannels: 61, MaxTxPowerDBm: 23}}, info.Country.Subbands)
+}
+
+func TestCountryAllowsChannel(t *testing.T) {
+	us := &CountryInfo{Code: "US", Subbands: []CountrySubband{
+		{FirstChannel: 1, NumChannels: 11, MaxTxPowerDBm: 30},
+		{FirstChannel: 36, NumChannels: 4, MaxTxPowerDBm: 17},
+		{FirstChannel: 149, NumChannels: 5, MaxTxPowerDBm: 30},
+	}}
+	cases := []struct {
+		channel  int
+		allowed  bool
+		maxPower int8
+	}{
+		{1, true, 30},
+		{11, true, 30},
+		{13, false, 0},
+		{36, true, 17},
+		{48, true, 17},
+		{52, false, 0},
+		{165, true, 30},
+		{38, false, 0}, // Not on the 4-channel raster
+	}
+	for _, tc := range cases {
+		allowed, maxPower, ok := CountryAllowsChannel(us, tc.channel)
+		assert.True(t, ok)
+		assert.Equal(t, tc.allowed, allowed, "channel %d", tc.channel)
+		assert.Equal(t, tc.maxPower, maxPower, "channel %d", tc.channel)
+	}
+
+	_, _, ok := CountryAllowsChannel(&CountryInfo{Code: "DE"}, 36)
+	assert.False(t, ok, "No subband triplets to check against")
+	_, _, ok = CountryAllowsChannel(nil, 36)
+	assert.False(t, ok)
+}
@@ -0,0 +1,367 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/logger"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// Replay speed limits, as multiples of capture time.
+const (
+	MinReplaySpeed = 0.5
+	MaxReplaySpeed = 100.0
+)
+
+// Replay states reported in ReplayStatus.
+const (
+	ReplayPlaying  = "playing"
+	ReplayPaused   = "paused"
+	ReplayFinished = "finished"
+)
+
+// replayProgressInterval is the shortest wall time between progress reports while playing.
+const replayProgressInterval = 100 * time.Millisecond
+
+// ReplayStatus is the position and state of a Replayer. Times are Unix milliseconds of
+// capture time.
+type ReplayStatus struct {
+	State       string  `json:"state"`
+	Speed       float64 `json:"speed"`
+	Frame       int     `json:"frame"` // Frames delivered so far
+	TotalFrames int     `json:"total_frames"`
+	Position    int64   `json:"position_ms"` // Capture time the replay has reached
+	Start       int64   `json:"start_ms"`
+	End         int64   `json:"end_ms"`
+}
+
+// replayFrame is one packet of a capture file held for replay.
+type replayFrame struct {
+	data     []byte
+	ci       gopacket.CaptureInfo
+	linkType layers.LinkType
+	annotate func(info *ParsedFrameInfo)
+}
+
+// Replayer plays a capture file back in capture time, at a chosen speed, instead of reading
+// it as fast as possible. The whole file is read into memory up front, so it can be paused,
+// stepped one frame at a time and seeked in both directions.
+//
+// Frames are parsed and handed to the handler one at a time, by the playback goroutine or by
+// the goroutine calling Seek or Step, never by two at once. Seeking backwards calls reset, which
+// must clear whatever state the handler built, and then delivers the frames from the start of
+// the file again, as fast as possible, up to the seek position.
+type Replayer struct {
+	frames     []replayFrame
+	handler    PacketInfoHandler
+	reset      func()
+	onProgress func(ReplayStatus)
+
+	mutex        sync.Mutex
+	fp           *frameProcessor
+	next         int       // Index of the next frame to deliver
+	position     time.Time // Capture time reached
+	playing      bool
+	speed        float64
+	anchorWall   time.Time // Wall time at which the replay was at anchorTs
+	anchorTs     time.Time
+	generation   int // Changed by every control call, to interrupt the wait for the next frame
+	lastProgress time.Time
+	closed       bool
+
+	wake chan struct{}
+	done chan struct{}
+}
+
+// NewReplayer reads a pcap or pcapng file and returns a Replayer, paused at its first frame.
+// onProgress, if not nil, is called with the status after every control call and, while
+// playing, every replayProgressInterval.
+func NewReplayer(path string, pktHandler PacketInfoHandler, reset func(), onProgress func(ReplayStatus)) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening capture file: %w", err)
+	}
+	defer f.Close()
+	return NewReplayerFromReader(f, pktHandler, reset, onProgress)
+}
+
+// NewReplayerFromReader is NewReplayer for a pcap or pcapng stream.
+func NewReplayerFromReader(stream io.Reader, pktHandler PacketInfoHandler, reset func(), onProgress func(ReplayStatus)) (*Replayer, error) {
+	frames, err := readReplayFrames(stream)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("capture has no packets")
+	}
+	if reset == nil {
+		reset = func() {}
+	}
+	if onProgress == nil {
+		onProgress = func(ReplayStatus) {}
+	}
+	r := &Replayer{
+		frames:     frames,
+		handler:    pktHandler,
+		reset:      reset,
+		onProgress: onProgress,
+		fp:         newFrameProcessorWithWorkers(pktHandler, 1),
+		position:   frames[0].ci.Timestamp,
+		speed:      1,
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	logger.Log.Info().
+		Int("frames", len(frames)).
+		Time("start", frames[0].ci.Timestamp).
+		Time("end", frames[len(frames)-1].ci.Timestamp).
+		Msg("INFO_REPLAY: Capture loaded for replay")
+	go r.run()
+	return r, nil
+}
+
+// readReplayFrames reads every packet of a pcap or pcapng stream.
+func readReplayFrames(stream io.Reader) ([]replayFrame, error) {
+	br := bufio.NewReader(stream)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("reading capture header: %w", err)
+	}
+
+	var frames []replayFrame
+	if IsPcapngMagic(magic) {
+		reader, err := NewPcapngReader(br)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			pkt, err := reader.Next()
+			if err == io.EOF {
+				return frames, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading pcapng packet %d: %w", len(frames)+1, err)
+			}
+			intf, _ := reader.Interface(pkt.InterfaceID)
+			frames = append(frames, replayFrame{data: pkt.Data, ci: pkt.CaptureInfo, linkType: intf.LinkType, annotate: pcapngAnnotation(pkt, intf)})
+		}
+	}
+
+	r, err := pcapgo.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("creating pcapgo.Reader: %w", err)
+	}
+	for {
+		data, ci, err := r.ReadPacketData()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading pcap packet %d: %w", len(frames)+1, err)
+		}
+		frames = append(frames, replayFrame{data: data, ci: ci, linkType: r.LinkType()})
+	}
+}
+
+// Play starts or resumes playback. At the end of the capture, it plays again from the start.
+func (r *Replayer) Play() {
+	r.control(func() {
+		if r.next >= len(r.frames) {
+			r.rewindLocked()
+		}
+		r.playing = r.next < len(r.frames)
+	})
+}
+
+// Pause stops playback at the current position.
+func (r *Replayer) Pause() {
+	r.control(func() { r.playing = false })
+}
+
+// SetSpeed sets the playback speed as a multiple of capture time.
+func (r *Replayer) SetSpeed(speed float64) error {
+	if speed < MinReplaySpeed || speed > MaxReplaySpeed {
+		return fmt.Errorf("replay speed %.2fx out of range %.1fx to %.0fx", speed, MinReplaySpeed, MaxReplaySpeed)
+	}
+	r.control(func() { r.speed = speed })
+	return nil
+}
+
+// Seek moves the replay to a capture time: every frame captured up to then has been delivered,
+// and none after it. Playback continues from there if it was playing.
+func (r *Replayer) Seek(t time.Time) {
+	r.control(func() { r.seekLocked(t) })
+}
+
+// Step pauses playback and delivers the next frame. It returns false at the end of the capture.
+func (r *Replayer) Step() bool {
+	stepped := false
+	r.control(func() {
+		r.playing = false
+		if r.next < len(r.frames) {
+			r.deliverLocked()
+			stepped = true
+		}
+	})
+	return stepped
+}
+
+// Status returns the current state and position of the replay.
+func (r *Replayer) Status() ReplayStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.statusLocked()
+}
+
+// Close stops the replay. No frames are delivered once it returns.
+func (r *Replayer) Close() {
+	r.mutex.Lock()
+	r.closed = true
+	r.mutex.Unlock()
+	r.signal()
+	<-r.done
+}
+
+// control runs a control call, restarts the playback timing from the current position and
+// reports the new status.
+func (r *Replayer) control(fn func()) {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return
+	}
+	fn()
+	r.generation++
+	r.anchorWall, r.anchorTs = time.Now(), r.position
+	status := r.reportLocked()
+	r.mutex.Unlock()
+
+	r.signal()
+	r.onProgress(status)
+}
+
+// signal wakes the playback goroutine.
+func (r *Replayer) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the playback goroutine: it waits until the next frame is due in replay time and
+// delivers it.
+func (r *Replayer) run() {
+	defer close(r.done)
+	for {
+		r.mutex.Lock()
+		if r.closed {
+			r.mutex.Unlock()
+			return
+		}
+		if !r.playing || r.next >= len(r.frames) {
+			r.playing = false
+			r.mutex.Unlock()
+			<-r.wake
+			continue
+		}
+		generation := r.generation
+		wait := time.Until(r.anchorWall.Add(time.Duration(float64(r.frames[r.next].ci.Timestamp.Sub(r.anchorTs)) / r.speed)))
+		r.mutex.Unlock()
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-r.wake:
+				timer.Stop()
+				continue
+			}
+		}
+
+		r.mutex.Lock()
+		if r.closed || r.generation != generation {
+			r.mutex.Unlock()
+			continue
+		}
+		r.deliverLocked()
+		report := r.next >= len(r.frames) || time.Since(r.lastProgress) >= replayProgressInterval
+		var status ReplayStatus
+		if report {
+			status = r.reportLocked()
+		}
+		r.mutex.Unlock()
+		if report {
+			r.onProgress(status)
+		}
+	}
+}
+
+// seekLocked delivers the frames up to t, starting over from the first frame when t is
+// before the current position. Caller must hold r.mutex.
+func (r *Replayer) seekLocked(t time.Time) {
+	if t.Before(r.position) {
+		r.rewindLocked()
+	}
+	for r.next < len(r.frames) && !r.frames[r.next].ci.Timestamp.After(t) {
+		r.deliverLocked()
+	}
+	if t.After(r.position) && r.next < len(r.frames) {
+		r.position = t
+	}
+	if r.next >= len(r.frames) {
+		r.playing = false
+	}
+}
+
+// rewindLocked starts over from the first frame, with nothing delivered. Caller must hold r.mutex.
+func (r *Replayer) rewindLocked() {
+	r.reset()
+	r.fp = newFrameProcessorWithWorkers(r.handler, 1) // Forget decryption keys derived so far
+	r.next = 0
+	r.position = r.frames[0].ci.Timestamp
+}
+
+// deliverLocked parses the next frame and hands it to the handler. Caller must hold r.mutex.
+func (r *Replayer) deliverLocked() {
+	f := r.frames[r.next]
+	r.fp.processData(f.data, f.ci, f.linkType, f.annotate)
+	r.next++
+	if f.ci.Timestamp.After(r.position) {
+		r.position = f.ci.Timestamp
+	}
+	if r.next >= len(r.frames) {
+		r.playing = false
+	}
+}
+
+// reportLocked returns the status for a progress report. Caller must hold r.mutex.
+func (r *Replayer) reportLocked() ReplayStatus {
+	r.lastProgress = time.Now()
+	return r.statusLocked()
+}
+
+func (r *Replayer) statusLocked() ReplayStatus {
+	state := ReplayPaused
+	switch {
+	case r.playing:
+		state = ReplayPlaying
+	case r.next >= len(r.frames):
+		state = ReplayFinished
+	}
+	return ReplayStatus{
+		State:       state,
+		Speed:       r.speed,
+		Frame:       r.next,
+		TotalFrames: len(r.frames),
+		Position:    r.position.UnixMilli(),
+		Start:       r.frames[0].ci.Timestamp.UnixMilli(),
+		End:         r.frames[len(r.frames)-1].ci.Timestamp.UnixMilli(),
+	}
+}
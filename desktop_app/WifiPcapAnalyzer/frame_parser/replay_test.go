@@ -0,0 +1,139 @@
+package frame_parser
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replayRecorder collects what a Replayer delivers and reports.
+type replayRecorder struct {
+	mutex    sync.Mutex
+	frames   []time.Time
+	resets   int
+	finished chan ReplayStatus
+}
+
+func newReplay(t *testing.T, frames int) (*Replayer, *replayRecorder) {
+	rec := &replayRecorder{finished: make(chan ReplayStatus, 1)}
+	r, err := NewReplayerFromReader(bytes.NewReader(writeSyntheticCapture(t, frames)),
+		func(info *ParsedFrameInfo) {
+			rec.mutex.Lock()
+			defer rec.mutex.Unlock()
+			rec.frames = append(rec.frames, info.Timestamp)
+		},
+		func() {
+			rec.mutex.Lock()
+			defer rec.mutex.Unlock()
+			rec.frames = nil
+			rec.resets++
+		},
+		func(status ReplayStatus) {
+			if status.State == ReplayFinished {
+				select {
+				case rec.finished <- status:
+				default:
+				}
+			}
+		})
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+	return r, rec
+}
+
+func (rec *replayRecorder) delivered() []time.Time {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return append([]time.Time(nil), rec.frames...)
+}
+
+func TestReplayer_StepAndSeek(t *testing.T) {
+	r, rec := newReplay(t, 200) // Frames 100µs apart
+	start := time.UnixMilli(r.Status().Start).UTC()
+
+	status := r.Status()
+	assert.Equal(t, ReplayPaused, status.State)
+	assert.Equal(t, 0, status.Frame)
+	assert.Equal(t, 200, status.TotalFrames)
+
+	require.True(t, r.Step())
+	assert.Equal(t, []time.Time{start}, rec.delivered())
+	assert.Equal(t, 1, r.Status().Frame)
+
+	// Forward: frames up to and including the seek time are delivered, without a reset
+	r.Seek(start.Add(5 * time.Millisecond))
+	assert.Len(t, rec.delivered(), 51)
+	assert.Equal(t, 0, rec.resets)
+	assert.Equal(t, start.Add(5*time.Millisecond).UnixMilli(), r.Status().Position)
+
+	// Backward: the state is reset and the frames up to the seek time delivered again
+	r.Seek(start.Add(1 * time.Millisecond))
+	assert.Equal(t, 1, rec.resets)
+	frames := rec.delivered()
+	require.Len(t, frames, 11)
+	assert.Equal(t, start, frames[0])
+
+	require.True(t, r.Step())
+	assert.Equal(t, start.Add(1100*time.Microsecond), rec.delivered()[11])
+
+	r.Seek(start.Add(time.Hour))
+	assert.Equal(t, ReplayFinished, r.Status().State)
+	assert.False(t, r.Step())
+	assert.Len(t, rec.delivered(), 200)
+}
+
+func TestReplayer_PlaysInCaptureTime(t *testing.T) {
+	r, rec := newReplay(t, 200) // 19.9ms of capture
+
+	assert.Error(t, r.SetSpeed(0.25))
+	assert.Error(t, r.SetSpeed(200))
+	require.NoError(t, r.SetSpeed(0.5))
+
+	begin := time.Now()
+	r.Play()
+	assert.Equal(t, ReplayPlaying, r.Status().State)
+	select {
+	case status := <-rec.finished:
+		assert.Equal(t, 200, status.Frame)
+		assert.Equal(t, status.End, status.Position)
+	case <-time.After(5 * time.Second):
+		t.Fatal("replay did not finish")
+	}
+	assert.GreaterOrEqual(t, time.Since(begin), 39*time.Millisecond, "19.9ms of capture at 0.5x")
+
+	frames := rec.delivered()
+	require.Len(t, frames, 200)
+	for i := 1; i < len(frames); i++ {
+		require.True(t, frames[i].After(frames[i-1]), "frame %d out of order", i)
+	}
+
+	// Pausing holds the position; playing at the end starts over
+	r.Play()
+	r.Pause()
+	paused := r.Status()
+	assert.Equal(t, ReplayPaused, paused.State)
+	assert.Equal(t, 1, rec.resets)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, paused.Frame, r.Status().Frame)
+}
+
+func TestReplayer_ReplaysOneFrameCapture(t *testing.T) {
+	r, rec := newReplay(t, 1) // Every frame shares one timestamp
+
+	for i := 1; i <= 3; i++ {
+		r.Play() // Starts over once finished
+		select {
+		case status := <-rec.finished:
+			assert.Equal(t, 1, status.Frame)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("replay %d did not finish", i)
+		}
+		assert.Len(t, rec.delivered(), 1)
+		assert.Equal(t, i-1, rec.resets)
+	}
+	assert.Equal(t, ReplayFinished, r.Status().State)
+}
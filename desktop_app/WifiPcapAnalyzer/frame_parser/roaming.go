@@ -0,0 +1,473 @@
+package frame_parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Element IDs of the 802.11k/v/r elements (IEEE 802.11-2020, Table 9-92).
+const (
+	ieIDNeighborReport        uint8 = 52
+	ieIDMobilityDomain        uint8 = 54
+	ieIDFastBSSTransition     uint8 = 55
+	ieIDRMEnabledCapabilities uint8 = 70
+	ieIDExtendedCapabilities  uint8 = 127
+)
+
+// Action codes used for roaming (IEEE 802.11-2020, 9.6.7 and 9.6.13).
+const (
+	rmActionNeighborReportRequest  uint8 = 4
+	rmActionNeighborReportResponse uint8 = 5
+
+	wnmActionBTMQuery    uint8 = 6
+	wnmActionBTMRequest  uint8 = 7
+	wnmActionBTMResponse uint8 = 8
+)
+
+// Roaming action frame kinds, stored in RoamingActionInfo.Kind.
+const (
+	RoamingActionNeighborReportRequest  = "NeighborReportRequest"
+	RoamingActionNeighborReportResponse = "NeighborReportResponse"
+	RoamingActionBTMQuery               = "BTMQuery"
+	RoamingActionBTMRequest             = "BTMRequest"
+	RoamingActionBTMResponse            = "BTMResponse"
+)
+
+// Neighbor Report optional subelement carrying the BSS Transition Candidate Preference.
+const neighborSubelemCandidatePreference uint8 = 3
+
+// FTE optional subelement IDs.
+const (
+	ftSubelemR1KHID uint8 = 1
+	ftSubelemGTK    uint8 = 2
+	ftSubelemR0KHID uint8 = 3
+	ftSubelemIGTK   uint8 = 4
+)
+
+// RMCapabilitiesInfo is the decoded RM Enabled Capabilities element (802.11k).
+type RMCapabilitiesInfo struct {
+	LinkMeasurement bool `json:"link_measurement"`
+	NeighborReport  bool `json:"neighbor_report"`
+	BeaconPassive   bool `json:"beacon_passive"`
+	BeaconActive    bool `json:"beacon_active"`
+	BeaconTable     bool `json:"beacon_table"`
+	ChannelLoad     bool `json:"channel_load"`
+	NoiseHistogram  bool `json:"noise_histogram"`
+	LCIMeasurement  bool `json:"lci_measurement"`
+	APChannelReport bool `json:"ap_channel_report"`
+}
+
+// ExtendedCapabilitiesInfo holds the Extended Capabilities bits relevant to roaming and WNM.
+type ExtendedCapabilitiesInfo struct {
+	ProxyARP                  bool `json:"proxy_arp"`
+	WNMSleepMode              bool `json:"wnm_sleep_mode"`
+	BSSTransition             bool `json:"bss_transition"` // 802.11v BSS Transition Management
+	Interworking              bool `json:"interworking"`
+	QoSMap                    bool `json:"qos_map"`
+	OperatingModeNotification bool `json:"operating_mode_notification"`
+	FTMResponder              bool `json:"ftm_responder"`
+	FTMInitiator              bool `json:"ftm_initiator"`
+}
+
+// MobilityDomainInfo is the decoded Mobility Domain element (802.11r).
+type MobilityDomainInfo struct {
+	MDID                    uint16 `json:"mdid"`
+	FTOverDS                bool   `json:"ft_over_ds"`
+	ResourceRequestProtocol bool   `json:"resource_request_protocol"`
+}
+
+// FTInfo is the decoded Fast BSS Transition element (802.11r).
+type FTInfo struct {
+	ElementCount uint8  `json:"element_count"` // Number of elements protected by the MIC
+	MICLength    int    `json:"mic_length"`
+	MICPresent   bool   `json:"mic_present"` // MIC is non-zero
+	ANonce       bool   `json:"anonce"`      // ANonce is non-zero
+	SNonce       bool   `json:"snonce"`      // SNonce is non-zero
+	R0KHID       string `json:"r0kh_id,omitempty"`
+	R1KHID       string `json:"r1kh_id,omitempty"`
+	GTKIncluded  bool   `json:"gtk_included,omitempty"`
+	IGTKIncluded bool   `json:"igtk_included,omitempty"`
+}
+
+// NeighborReport is a Neighbor Report element, used both for 802.11k neighbor lists
+// and for 802.11v BSS transition candidate lists.
+type NeighborReport struct {
+	BSSID          string `json:"bssid"`
+	BSSIDInfo      uint32 `json:"bssid_info"`
+	Reachability   string `json:"reachability"` // "Reachable", "Not reachable", "Unknown"
+	Security       bool   `json:"security"`     // Same security as the reporting AP
+	MobilityDomain bool   `json:"mobility_domain"`
+	OperatingClass uint8  `json:"operating_class"`
+	Channel        uint8  `json:"channel"`
+	PHYType        uint8  `json:"phy_type"`
+	// Preference is the BSS Transition Candidate Preference (0 = excluded, 255 = most preferred).
+	// HasPreference is false when the subelement is absent.
+	Preference    uint8 `json:"preference"`
+	HasPreference bool  `json:"has_preference"`
+}
+
+// RoamingActionInfo describes an 802.11k Neighbor Report or 802.11v BSS Transition Management
+// action frame. Only the fields relevant to Kind are set.
+type RoamingActionInfo struct {
+	Kind        string `json:"kind"` // One of the RoamingAction* constants
+	DialogToken uint8  `json:"dialog_token"`
+	// BTM Query
+	QueryReasonCode uint8  `json:"query_reason_code,omitempty"`
+	QueryReason     string `json:"query_reason,omitempty"`
+	// BTM Request
+	RequestMode                    uint8  `json:"request_mode,omitempty"`
+	PreferredCandidateListIncluded bool   `json:"preferred_candidate_list_included,omitempty"`
+	Abridged                       bool   `json:"abridged,omitempty"`
+	DisassociationImminent         bool   `json:"disassociation_imminent,omitempty"`
+	BSSTerminationIncluded         bool   `json:"bss_termination_included,omitempty"`
+	ESSDisassociationImminent      bool   `json:"ess_disassociation_imminent,omitempty"`
+	DisassociationTimer            uint16 `json:"disassociation_timer,omitempty"` // In TBTTs
+	ValidityInterval               uint8  `json:"validity_interval,omitempty"`    // In TBTTs
+	SessionInfoURL                 string `json:"session_info_url,omitempty"`
+	// BTM Response
+	StatusCode       uint8  `json:"status_code,omitempty"`
+	Status           string `json:"status,omitempty"`
+	TerminationDelay uint8  `json:"termination_delay,omitempty"` // In minutes
+	TargetBSSID      string `json:"target_bssid,omitempty"`
+	// Neighbor Report Response neighbors, or BTM candidate list entries
+	Candidates []NeighborReport `json:"candidates,omitempty"`
+}
+
+// btmStatusNames maps BTM Status codes (IEEE 802.11-2020, Table 9-428).
+var btmStatusNames = map[uint8]string{
+	0: "Accept",
+	1: "Reject - Unspecified reject reason",
+	2: "Reject - Insufficient Beacon or Probe Response frames received from all candidates",
+	3: "Reject - Insufficient available capacity from all candidates",
+	4: "Reject - BSS termination undesired",
+	5: "Reject - BSS termination delay requested",
+	6: "Reject - STA BSS Transition Candidate List provided",
+	7: "Reject - No suitable BSS transition candidates",
+	8: "Reject - Leaving ESS",
+}
+
+// btmQueryReasonNames maps Transition and Transition Query reasons (IEEE 802.11-2020, Table 9-427).
+var btmQueryReasonNames = map[uint8]string{
+	0:  "Unspecified",
+	1:  "Excessive frame loss rates and/or poor conditions",
+	2:  "Excessive delay for current traffic stream",
+	3:  "Insufficient QoS capacity for current traffic stream",
+	4:  "First association to ESS",
+	5:  "Load balancing",
+	6:  "Better AP found",
+	7:  "Deauthenticated or Disassociated from the previous AP",
+	8:  "AP failed IEEE 802.1X EAP Authentication",
+	9:  "AP failed 4-way handshake",
+	10: "Received too many replay counter failures",
+	11: "Received too many data MIC failures",
+	12: "Exceeded maximum number of retransmissions",
+	13: "Received too many broadcast disassociations",
+	14: "Received too many broadcast deauthentications",
+	15: "Previous transition failed",
+	16: "Low RSSI",
+	17: "Roam from a non-IEEE-802.11 system",
+	18: "Transition due to received BSS Transition Request frame",
+	19: "Preferred BSS transition candidate list included",
+	20: "Leaving ESS",
+}
+
+// BTMStatusString returns the meaning of a BTM Response status code.
+func BTMStatusString(code uint8) string {
+	if name, ok := btmStatusNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("Reserved/unknown status (%d)", code)
+}
+
+// BTMQueryReasonString returns the meaning of a BTM Query reason.
+func BTMQueryReasonString(code uint8) string {
+	if name, ok := btmQueryReasonNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("Reserved/unknown reason (%d)", code)
+}
+
+func init() {
+	RegisterIEDecoder(ieIDRMEnabledCapabilities, "RM Enabled Capabilities", decodeRMCapabilitiesIE)
+	RegisterIEDecoder(ieIDExtendedCapabilities, "Extended Capabilities", decodeExtendedCapabilitiesIE)
+	RegisterIEDecoder(ieIDMobilityDomain, "Mobility Domain", decodeMobilityDomainIE)
+	RegisterIEDecoder(ieIDFastBSSTransition, "Fast BSS Transition", decodeFTIE)
+	RegisterIEDecoder(ieIDNeighborReport, "Neighbor Report", decodeNeighborReportIE)
+}
+
+// hasBit reports whether bit n (little endian bit numbering) is set in data.
+func hasBit(data []byte, n int) bool {
+	if n/8 >= len(data) {
+		return false
+	}
+	return data[n/8]&(1<<(n%8)) != 0
+}
+
+func decodeRMCapabilitiesIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 5 {
+		return nil, fmt.Errorf("RM Enabled Capabilities element too short: %d bytes", len(ieData))
+	}
+	info.RMCapabilities = &RMCapabilitiesInfo{
+		LinkMeasurement: hasBit(ieData, 0),
+		NeighborReport:  hasBit(ieData, 1),
+		BeaconPassive:   hasBit(ieData, 4),
+		BeaconActive:    hasBit(ieData, 5),
+		BeaconTable:     hasBit(ieData, 6),
+		ChannelLoad:     hasBit(ieData, 9),
+		NoiseHistogram:  hasBit(ieData, 10),
+		LCIMeasurement:  hasBit(ieData, 12),
+		APChannelReport: hasBit(ieData, 16),
+	}
+	return info.RMCapabilities, nil
+}
+
+// decodeExtendedCapabilitiesIE decodes the variable length Extended Capabilities bitmap.
+// Bits beyond the advertised length are treated as zero.
+func decodeExtendedCapabilitiesIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	info.ExtendedCapabilities = &ExtendedCapabilitiesInfo{
+		ProxyARP:                  hasBit(ieData, 12),
+		WNMSleepMode:              hasBit(ieData, 17),
+		BSSTransition:             hasBit(ieData, 19),
+		Interworking:              hasBit(ieData, 31),
+		QoSMap:                    hasBit(ieData, 32),
+		OperatingModeNotification: hasBit(ieData, 62),
+		FTMResponder:              hasBit(ieData, 70),
+		FTMInitiator:              hasBit(ieData, 71),
+	}
+	return info.ExtendedCapabilities, nil
+}
+
+func decodeMobilityDomainIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 3 {
+		return nil, fmt.Errorf("Mobility Domain element too short: %d bytes", len(ieData))
+	}
+	info.MobilityDomain = &MobilityDomainInfo{
+		MDID:                    binary.LittleEndian.Uint16(ieData[0:2]),
+		FTOverDS:                ieData[2]&0x01 != 0,
+		ResourceRequestProtocol: ieData[2]&0x02 != 0,
+	}
+	return info.MobilityDomain, nil
+}
+
+// decodeFTIE decodes the Fast BSS Transition element. The MIC is 16 octets for most AKMs and
+// 24 or 32 octets for the SHA-384 based ones; since the AKM is not known here, the shortest
+// MIC length whose trailing subelements parse cleanly is used.
+func decodeFTIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	for _, micLen := range []int{16, 24, 32} {
+		fixedLen := 2 + micLen + 32 + 32 // MIC Control, MIC, ANonce, SNonce
+		if len(ieData) < fixedLen {
+			break
+		}
+		ft := &FTInfo{
+			ElementCount: ieData[1],
+			MICLength:    micLen,
+			MICPresent:   !isAllZero(ieData[2 : 2+micLen]),
+			ANonce:       !isAllZero(ieData[2+micLen : 2+micLen+32]),
+			SNonce:       !isAllZero(ieData[2+micLen+32 : fixedLen]),
+		}
+		if parseFTSubelements(ft, ieData[fixedLen:]) {
+			info.FastBSSTransition = ft
+			return ft, nil
+		}
+	}
+	return nil, fmt.Errorf("malformed Fast BSS Transition element (%d bytes)", len(ieData))
+}
+
+// parseFTSubelements fills the FTE subelement fields. It returns false if the subelement
+// list does not parse, which indicates a wrong MIC length assumption.
+func parseFTSubelements(ft *FTInfo, data []byte) bool {
+	for len(data) > 0 {
+		if len(data) < 2 || len(data) < 2+int(data[1]) {
+			return false
+		}
+		id, body := data[0], data[2:2+int(data[1])]
+		data = data[2+len(body):]
+		switch id {
+		case ftSubelemR1KHID:
+			if len(body) != 6 {
+				return false
+			}
+			ft.R1KHID = net.HardwareAddr(body).String()
+		case ftSubelemR0KHID:
+			if len(body) < 1 || len(body) > 48 {
+				return false
+			}
+			ft.R0KHID = printableOrHex(body)
+		case ftSubelemGTK:
+			ft.GTKIncluded = true
+		case ftSubelemIGTK:
+			ft.IGTKIncluded = true
+		case 0:
+			return false // Reserved
+		}
+	}
+	return true
+}
+
+func decodeNeighborReportIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	nr, err := parseNeighborReport(ieData)
+	if err != nil {
+		return nil, err
+	}
+	return nr, nil
+}
+
+// parseNeighborReport decodes the body of a Neighbor Report element.
+func parseNeighborReport(data []byte) (NeighborReport, error) {
+	if len(data) < 13 {
+		return NeighborReport{}, fmt.Errorf("Neighbor Report element too short: %d bytes", len(data))
+	}
+	nr := NeighborReport{
+		BSSID:          net.HardwareAddr(data[0:6]).String(),
+		BSSIDInfo:      binary.LittleEndian.Uint32(data[6:10]),
+		OperatingClass: data[10],
+		Channel:        data[11],
+		PHYType:        data[12],
+	}
+	switch nr.BSSIDInfo & 0x03 {
+	case 1:
+		nr.Reachability = "Not reachable"
+	case 3:
+		nr.Reachability = "Reachable"
+	default:
+		nr.Reachability = "Unknown"
+	}
+	nr.Security = nr.BSSIDInfo&(1<<2) != 0
+	nr.MobilityDomain = nr.BSSIDInfo&(1<<10) != 0
+
+	sub := data[13:]
+	for len(sub) >= 2 && len(sub) >= 2+int(sub[1]) {
+		id, body := sub[0], sub[2:2+int(sub[1])]
+		sub = sub[2+len(body):]
+		if id == neighborSubelemCandidatePreference && len(body) >= 1 {
+			nr.Preference = body[0]
+			nr.HasPreference = true
+		}
+	}
+	return nr, nil
+}
+
+// parseNeighborReportList decodes the Neighbor Report elements in a candidate/neighbor list,
+// skipping any other elements.
+func parseNeighborReportList(data []byte) []NeighborReport {
+	var reports []NeighborReport
+	for len(data) >= 2 && len(data) >= 2+int(data[1]) {
+		id, body := data[0], data[2:2+int(data[1])]
+		data = data[2+len(body):]
+		if id != ieIDNeighborReport {
+			continue
+		}
+		if nr, err := parseNeighborReport(body); err == nil {
+			reports = append(reports, nr)
+		}
+	}
+	return reports
+}
+
+// decodeRoamingAction decodes Neighbor Report (Radio Measurement) and BSS Transition Management
+// (WNM) action frames into info.RoamingAction. body starts at the Dialog Token field.
+// Other actions of these categories are only named by the dispatcher.
+func decodeRoamingAction(info *ParsedFrameInfo, action *ActionFrameInfo, body []byte) error {
+	if len(body) < 1 { // Dialog Token
+		return nil
+	}
+	category, code := action.Category, action.Action
+	ra := &RoamingActionInfo{DialogToken: body[0]}
+	rest := body[1:]
+
+	switch {
+	case category == ActionCategoryRadioMeasurement && code == rmActionNeighborReportRequest:
+		ra.Kind = RoamingActionNeighborReportRequest
+	case category == ActionCategoryRadioMeasurement && code == rmActionNeighborReportResponse:
+		ra.Kind = RoamingActionNeighborReportResponse
+		ra.Candidates = parseNeighborReportList(rest)
+	case category == ActionCategoryWNM && code == wnmActionBTMQuery:
+		if len(rest) < 1 {
+			return errors.New("BTM Query too short")
+		}
+		ra.Kind = RoamingActionBTMQuery
+		ra.QueryReasonCode = rest[0]
+		ra.QueryReason = BTMQueryReasonString(rest[0])
+		ra.Candidates = parseNeighborReportList(rest[1:])
+	case category == ActionCategoryWNM && code == wnmActionBTMRequest:
+		if err := parseBTMRequest(ra, rest); err != nil {
+			return err
+		}
+	case category == ActionCategoryWNM && code == wnmActionBTMResponse:
+		if len(rest) < 2 {
+			return errors.New("BTM Response too short")
+		}
+		ra.Kind = RoamingActionBTMResponse
+		ra.StatusCode = rest[0]
+		ra.Status = BTMStatusString(rest[0])
+		ra.TerminationDelay = rest[1]
+		rest = rest[2:]
+		// The Target BSSID is present only when the STA accepted the request
+		if ra.StatusCode == 0 && len(rest) >= 6 {
+			ra.TargetBSSID = net.HardwareAddr(rest[0:6]).String()
+			rest = rest[6:]
+		}
+		ra.Candidates = parseNeighborReportList(rest)
+	default:
+		return nil
+	}
+	info.RoamingAction = ra
+	return nil
+}
+
+// parseBTMRequest decodes the body of a BTM Request after the Dialog Token.
+func parseBTMRequest(ra *RoamingActionInfo, rest []byte) error {
+	if len(rest) < 4 { // Request Mode, Disassociation Timer, Validity Interval
+		return errors.New("BTM Request too short")
+	}
+	ra.Kind = RoamingActionBTMRequest
+	ra.RequestMode = rest[0]
+	ra.PreferredCandidateListIncluded = rest[0]&0x01 != 0
+	ra.Abridged = rest[0]&0x02 != 0
+	ra.DisassociationImminent = rest[0]&0x04 != 0
+	ra.BSSTerminationIncluded = rest[0]&0x08 != 0
+	ra.ESSDisassociationImminent = rest[0]&0x10 != 0
+	ra.DisassociationTimer = binary.LittleEndian.Uint16(rest[1:3])
+	ra.ValidityInterval = rest[3]
+	rest = rest[4:]
+
+	if ra.BSSTerminationIncluded {
+		if len(rest) < 12 { // BSS Termination Duration subelement
+			return errors.New("BTM Request truncated in BSS Termination Duration")
+		}
+		rest = rest[12:]
+	}
+	if ra.ESSDisassociationImminent {
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return errors.New("BTM Request truncated in Session Information URL")
+		}
+		ra.SessionInfoURL = string(rest[1 : 1+int(rest[0])])
+		rest = rest[1+int(rest[0]):]
+	}
+	if ra.PreferredCandidateListIncluded {
+		ra.Candidates = parseNeighborReportList(rest)
+	}
+	return nil
+}
+
+func isAllZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// printableOrHex returns data as a string if it is printable ASCII, otherwise as hex.
+func printableOrHex(data []byte) string {
+	for _, b := range data {
+		if b < 0x20 || b > 0x7E {
+			return fmt.Sprintf("%x", data)
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,157 @@
+package frame_parser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInformationElements_RoamingElements(t *testing.T) {
+	var payload []byte
+	payload = append(payload, mustHex(t, "46 05 73 00 01 00 00")...) // RM Enabled Capabilities
+	payload = append(payload, mustHex(t, "7f 03 00 00 08")...)       // Extended Capabilities, BSS Transition (bit 19)
+	payload = append(payload, mustHex(t, "36 03 3412 01")...)        // Mobility Domain 0x1234, FT over DS
+
+	// FTE with a 16 octet MIC, ANonce/SNonce, R1KH-ID and R0KH-ID subelements
+	fte := []byte{0x00, 0x03}
+	fte = append(fte, bytes.Repeat([]byte{0x11}, 16)...)
+	fte = append(fte, bytes.Repeat([]byte{0x22}, 32)...)
+	fte = append(fte, make([]byte, 32)...)
+	fte = append(fte, mustHex(t, "01 06 0200000000aa")...)
+	fte = append(fte, 0x03, 0x08)
+	fte = append(fte, "nas1.lan"...)
+	payload = append(payload, ieIDFastBSSTransition, byte(len(fte)))
+	payload = append(payload, fte...)
+
+	info := &ParsedFrameInfo{}
+	parseInformationElements(info, payload)
+	require.Len(t, info.Elements, 4)
+	assert.Equal(t, "RM Enabled Capabilities", info.Elements[0].Name)
+	assert.Equal(t, "Fast BSS Transition", info.Elements[3].Name)
+
+	require.NotNil(t, info.RMCapabilities)
+	assert.True(t, info.RMCapabilities.LinkMeasurement)
+	assert.True(t, info.RMCapabilities.NeighborReport)
+	assert.True(t, info.RMCapabilities.BeaconActive)
+	assert.False(t, info.RMCapabilities.ChannelLoad)
+	assert.True(t, info.RMCapabilities.APChannelReport)
+
+	require.NotNil(t, info.ExtendedCapabilities)
+	assert.True(t, info.ExtendedCapabilities.BSSTransition)
+	assert.False(t, info.ExtendedCapabilities.Interworking) // Beyond the advertised length
+
+	require.NotNil(t, info.MobilityDomain)
+	assert.Equal(t, uint16(0x1234), info.MobilityDomain.MDID)
+	assert.True(t, info.MobilityDomain.FTOverDS)
+
+	require.NotNil(t, info.FastBSSTransition)
+	assert.Equal(t, 16, info.FastBSSTransition.MICLength)
+	assert.Equal(t, uint8(3), info.FastBSSTransition.ElementCount)
+	assert.True(t, info.FastBSSTransition.MICPresent)
+	assert.True(t, info.FastBSSTransition.ANonce)
+	assert.False(t, info.FastBSSTransition.SNonce)
+	assert.Equal(t, "02:00:00:00:00:aa", info.FastBSSTransition.R1KHID)
+	assert.Equal(t, "nas1.lan", info.FastBSSTransition.R0KHID)
+}
+
+func TestParsePacket_BTMRequest(t *testing.T) {
+	// BTM Request from AP 02:00:00:00:00:aa to STA 02:00:00:00:00:01: candidate list included,
+	// disassociation imminent, timer 10 TBTTs, one candidate (ch 36, preference 255) (FCS appended)
+	data := append(mustHex(t, "d000 0000 020000000001 0200000000aa 0200000000aa 1000"+
+		" 0a 07 05 05 0a00 ff"+
+		" 34 10 0200000000bb 07040000 73 24 09 03 01 ff"), 0, 0, 0, 0)
+	packet := gopacket.NewPacket(data, layers.LayerTypeDot11, gopacket.Default)
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+
+	assert.Equal(t, "MgmtAction", info.FrameType)
+	ra := info.RoamingAction
+	require.NotNil(t, ra)
+	assert.Equal(t, RoamingActionBTMRequest, ra.Kind)
+	assert.Equal(t, uint8(5), ra.DialogToken)
+	assert.True(t, ra.PreferredCandidateListIncluded)
+	assert.True(t, ra.DisassociationImminent)
+	assert.False(t, ra.Abridged)
+	assert.Equal(t, uint16(10), ra.DisassociationTimer)
+	assert.Equal(t, uint8(255), ra.ValidityInterval)
+	require.Len(t, ra.Candidates, 1)
+	assert.Equal(t, NeighborReport{
+		BSSID:          "02:00:00:00:00:bb",
+		BSSIDInfo:      0x0407,
+		Reachability:   "Reachable",
+		Security:       true,
+		MobilityDomain: true,
+		OperatingClass: 115,
+		Channel:        36,
+		PHYType:        9,
+		Preference:     255,
+		HasPreference:  true,
+	}, ra.Candidates[0])
+}
+
+func TestDecodeRoamingAction(t *testing.T) {
+	cases := []struct {
+		name   string
+		body   string
+		expect *RoamingActionInfo
+	}{
+		{
+			name:   "BTM Response accept",
+			body:   "0a 08 05 00 00 0200000000bb",
+			expect: &RoamingActionInfo{Kind: RoamingActionBTMResponse, DialogToken: 5, Status: "Accept", TargetBSSID: "02:00:00:00:00:bb"},
+		},
+		{
+			name:   "BTM Response reject without target",
+			body:   "0a 08 05 07 00",
+			expect: &RoamingActionInfo{Kind: RoamingActionBTMResponse, DialogToken: 5, StatusCode: 7, Status: "Reject - No suitable BSS transition candidates"},
+		},
+		{
+			name:   "BTM Query low RSSI",
+			body:   "0a 06 02 10",
+			expect: &RoamingActionInfo{Kind: RoamingActionBTMQuery, DialogToken: 2, QueryReasonCode: 16, QueryReason: "Low RSSI"},
+		},
+		{
+			name:   "BTM Request with ESS disassociation URL",
+			body:   "0a 07 01 14 0000 00 04 61 2e 62 63",
+			expect: &RoamingActionInfo{Kind: RoamingActionBTMRequest, DialogToken: 1, RequestMode: 0x14, DisassociationImminent: true, ESSDisassociationImminent: true, SessionInfoURL: "a.bc"},
+		},
+		{
+			name:   "Neighbor Report Request",
+			body:   "05 04 09",
+			expect: &RoamingActionInfo{Kind: RoamingActionNeighborReportRequest, DialogToken: 9},
+		},
+		{
+			name: "Neighbor Report Response",
+			body: "05 05 09 34 0d 0200000000cc 03000000 51 06 07",
+			expect: &RoamingActionInfo{Kind: RoamingActionNeighborReportResponse, DialogToken: 9, Candidates: []NeighborReport{
+				{BSSID: "02:00:00:00:00:cc", BSSIDInfo: 3, Reachability: "Reachable", OperatingClass: 81, Channel: 6, PHYType: 7},
+			}},
+		},
+		{
+			name:   "Unrelated action frame",
+			body:   "03 00 01 0210 0000 0000",
+			expect: nil,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &ParsedFrameInfo{}
+			parseActionFrame(info, mustHex(t, tc.body), false)
+			require.NotNil(t, info.Action)
+			assert.Empty(t, info.Action.Error)
+			assert.Equal(t, tc.expect, info.RoamingAction)
+		})
+	}
+
+	t.Run("Truncated BTM Request", func(t *testing.T) {
+		info := &ParsedFrameInfo{}
+		parseActionFrame(info, mustHex(t, "0a 07 01 08 0000 00"), false)
+		require.NotNil(t, info.Action)
+		assert.Equal(t, "BTM Request truncated in BSS Termination Duration", info.Action.Error)
+		assert.Nil(t, info.RoamingAction)
+	})
+}
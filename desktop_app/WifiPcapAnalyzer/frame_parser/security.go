@@ -0,0 +1,272 @@
+package frame_parser
+
+import (
+	"WifiPcapAnalyzer/logger"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// RSN Capabilities field bits (IEEE 802.11-2020, 9.4.2.24.4).
+const (
+	rsnCapPreAuth     uint16 = 1 << 0
+	rsnCapMFPRequired uint16 = 1 << 6
+	rsnCapMFPCapable  uint16 = 1 << 7
+)
+
+// ouiWFA is the Wi-Fi Alliance OUI; vendor type 0x1C is the OWE Transition Mode element.
+var ouiWFA = [3]byte{0x50, 0x6F, 0x9A}
+
+const wfaVendorTypeOWETransition uint8 = 0x1C
+
+// SecurityProfile is the structured view of a BSS's RSN (or legacy WPA) configuration.
+type SecurityProfile struct {
+	Protocol        string   `json:"protocol"` // "RSN", "WPA" or "Open" (OWE transition only)
+	Version         uint16   `json:"version,omitempty"`
+	GroupCipher     string   `json:"group_cipher,omitempty"`
+	PairwiseCiphers []string `json:"pairwise_ciphers,omitempty"`
+	AKMs            []string `json:"akms,omitempty"`
+	GroupMgmtCipher string   `json:"group_mgmt_cipher,omitempty"` // BIP cipher, only present with MFP
+	RSNCapabilities uint16   `json:"rsn_capabilities"`
+	PreAuth         bool     `json:"pre_auth"`
+	MFPCapable      bool     `json:"mfp_capable"`
+	MFPRequired     bool     `json:"mfp_required"`
+	PMKIDCount      int      `json:"pmkid_count"`
+	// Derived flags
+	FastTransition       bool   `json:"fast_transition"`       // At least one FT AKM is offered
+	SuiteB               bool   `json:"suite_b"`               // Suite-B (CNSA) AKM is offered
+	WPA3Transition       bool   `json:"wpa3_transition"`       // WPA2-Personal and WPA3-Personal (PSK + SAE) together
+	EnterpriseTransition bool   `json:"enterprise_transition"` // WPA2-Enterprise and WPA3-Enterprise together
+	WPAMixed             bool   `json:"wpa_mixed"`             // Legacy WPA1 element advertised alongside RSN
+	OWETransition        bool   `json:"owe_transition"`        // OWE Transition Mode element present
+	Label                string `json:"label"`                 // e.g. "WPA3-Personal transition"
+}
+
+func init() {
+	RegisterVendorIEDecoder(ouiWFA, wfaVendorTypeOWETransition, "Vendor Specific: Wi-Fi Alliance OWE Transition Mode", decodeOWETransitionIE)
+}
+
+// parseRSNElement parses the body of an RSN element.
+// Every field after Version is optional; missing suites take the defaults from the standard
+// (CCMP-128 group and pairwise cipher, 802.1X AKM).
+// Reference: IEEE 802.11-2020, Section 9.4.2.24 (RSNE)
+func parseRSNElement(ieData []byte) (*SecurityProfile, error) {
+	if len(ieData) < 2 {
+		return nil, fmt.Errorf("RSN element too short for Version (%d bytes)", len(ieData))
+	}
+	p := &SecurityProfile{Protocol: "RSN", Version: binary.LittleEndian.Uint16(ieData[0:2])}
+	if p.Version != 1 {
+		return nil, fmt.Errorf("unsupported RSN version %d", p.Version)
+	}
+	currentIndex := 2
+
+	// Group Data Cipher Suite (4 bytes: OUI[3] + Suite Type[1])
+	if currentIndex+4 > len(ieData) {
+		p.GroupCipher = "CCMP-128"
+		p.PairwiseCiphers = []string{"CCMP-128"}
+		p.AKMs = []string{"802.1X"}
+		return p, nil
+	}
+	p.GroupCipher = ouiAndCipherSuiteToString(ieData[currentIndex:currentIndex+3], ieData[currentIndex+3])
+	currentIndex += 4
+
+	// Pairwise Cipher Suite Count + List
+	suites, next, err := parseRSNSuiteList(ieData, currentIndex, ouiAndCipherSuiteToString)
+	if err != nil {
+		return p, fmt.Errorf("pairwise cipher suites: %w", err)
+	}
+	if suites == nil {
+		suites = []string{"CCMP-128"}
+	}
+	p.PairwiseCiphers = suites
+	currentIndex = next
+
+	// AKM Suite Count + List
+	suites, next, err = parseRSNSuiteList(ieData, currentIndex, ouiAndAKMSuiteToString)
+	if err != nil {
+		return p, fmt.Errorf("AKM suites: %w", err)
+	}
+	if suites == nil {
+		suites = []string{"802.1X"}
+	}
+	p.AKMs = suites
+	currentIndex = next
+
+	// RSN Capabilities (2 bytes)
+	if currentIndex+2 > len(ieData) {
+		return p, nil
+	}
+	p.RSNCapabilities = binary.LittleEndian.Uint16(ieData[currentIndex : currentIndex+2])
+	p.PreAuth = p.RSNCapabilities&rsnCapPreAuth != 0
+	p.MFPRequired = p.RSNCapabilities&rsnCapMFPRequired != 0
+	p.MFPCapable = p.RSNCapabilities&rsnCapMFPCapable != 0
+	currentIndex += 2
+
+	// PMKID Count + List (16 bytes each)
+	if currentIndex+2 > len(ieData) {
+		return p, nil
+	}
+	p.PMKIDCount = int(binary.LittleEndian.Uint16(ieData[currentIndex : currentIndex+2]))
+	currentIndex += 2
+	if currentIndex+16*p.PMKIDCount > len(ieData) {
+		return p, fmt.Errorf("PMKID list truncated: %d PMKIDs declared, %d bytes left", p.PMKIDCount, len(ieData)-currentIndex)
+	}
+	currentIndex += 16 * p.PMKIDCount
+
+	// Group Management Cipher Suite (4 bytes)
+	if currentIndex+4 <= len(ieData) {
+		p.GroupMgmtCipher = ouiAndCipherSuiteToString(ieData[currentIndex:currentIndex+3], ieData[currentIndex+3])
+	}
+	return p, nil
+}
+
+// parseRSNSuiteList reads a 2-byte suite count followed by that many 4-byte suite selectors.
+// It returns nil suites (and no error) when the count field itself is absent.
+func parseRSNSuiteList(ieData []byte, offset int, toString func([]byte, byte) string) ([]string, int, error) {
+	if offset+2 > len(ieData) {
+		return nil, offset, nil
+	}
+	count := int(binary.LittleEndian.Uint16(ieData[offset : offset+2]))
+	offset += 2
+	suites := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if offset+4 > len(ieData) {
+			return suites, offset, fmt.Errorf("list ended after %d of %d suites", i, count)
+		}
+		suites = append(suites, toString(ieData[offset:offset+3], ieData[offset+3]))
+		offset += 4
+	}
+	return suites, offset, nil
+}
+
+// parseRSNIE parses the RSN element into info.SecurityProfile.
+// The final label is derived in finalizeSecurityProfile once all elements have been seen.
+func parseRSNIE(info *ParsedFrameInfo, ieData []byte) error {
+	profile, err := parseRSNElement(ieData)
+	if profile != nil {
+		info.SecurityProfile = profile
+	}
+	if err != nil {
+		logger.Log.Warn().Err(err).Int("ie_len", len(ieData)).Msg("RSN IE parsing incomplete.")
+		return err
+	}
+	logger.Log.Debug().Strs("akms", profile.AKMs).Strs("pairwise", profile.PairwiseCiphers).Msg("RSN IE Parsed")
+	return nil
+}
+
+// decodeOWETransitionIE records that the BSS takes part in an OWE transition mode pair.
+// The body holds the BSSID and SSID of the companion (open or OWE) BSS.
+func decodeOWETransitionIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 7 {
+		return nil, fmt.Errorf("OWE Transition Mode element too short (%d bytes)", len(ieData))
+	}
+	ssidLen := int(ieData[6])
+	if 7+ssidLen > len(ieData) {
+		return nil, fmt.Errorf("OWE Transition Mode SSID length %d exceeds element", ssidLen)
+	}
+	info.OWETransition = true
+	return map[string]string{
+		"bssid": fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", ieData[0], ieData[1], ieData[2], ieData[3], ieData[4], ieData[5]),
+		"ssid":  string(ieData[7 : 7+ssidLen]),
+	}, nil
+}
+
+// finalizeSecurityProfile merges the RSN element, the legacy WPA1 element and the
+// OWE transition element into info.SecurityProfile and sets info.Security to its label.
+func finalizeSecurityProfile(info *ParsedFrameInfo) {
+	p := info.SecurityProfile
+	if p == nil && info.WPA != nil {
+		p = &SecurityProfile{
+			Protocol:        "WPA",
+			Version:         info.WPA.Version,
+			GroupCipher:     info.WPA.GroupCipher,
+			PairwiseCiphers: info.WPA.PairwiseCiphers,
+			AKMs:            info.WPA.AKMs,
+		}
+	} else if p != nil && info.WPA != nil {
+		p.WPAMixed = true
+	}
+	if p == nil && info.OWETransition {
+		p = &SecurityProfile{Protocol: "Open"}
+	}
+	if p == nil {
+		return
+	}
+	p.OWETransition = info.OWETransition
+	p.Label = deriveSecurityLabel(p)
+	info.SecurityProfile = p
+	info.Security = p.Label
+}
+
+// deriveSecurityLabel sets the derived flags of p and returns a Wi-Fi Alliance style label,
+// e.g. "WPA2-Personal", "WPA3-Personal transition", "WPA3-Enterprise 192-bit" or "OWE".
+func deriveSecurityLabel(p *SecurityProfile) string {
+	var psk, sae, eap, eapSHA256, suiteB192, owe bool
+	for _, akm := range p.AKMs {
+		if strings.HasPrefix(akm, "FT-") {
+			p.FastTransition = true
+		}
+		switch akm {
+		case "PSK", "FT-PSK", "WPA-SHA256-PSK", "PSK-SHA384", "FT-PSK-SHA384":
+			psk = true
+		case "SAE", "FT-SAE", "SAE-EXT-KEY", "FT-SAE-EXT-KEY":
+			sae = true
+		case "802.1X", "FT-802.1X", "FILS-SHA256", "FILS-SHA384", "FT-FILS-SHA256", "FT-FILS-SHA384", "802.1X-SHA384":
+			eap = true
+		case "WPA-SHA256-802.1X":
+			eapSHA256 = true
+		case "802.1X-SuiteB-SHA256":
+			p.SuiteB = true
+			eapSHA256 = true
+		case "802.1X-SuiteB-SHA384", "FT-802.1X-SHA384":
+			p.SuiteB = true
+			suiteB192 = true
+		case "OWE":
+			owe = true
+		}
+	}
+
+	if p.Protocol == "WPA" {
+		switch {
+		case psk:
+			return "WPA-Personal"
+		case eap:
+			return "WPA-Enterprise"
+		}
+		return "WPA"
+	}
+	if p.Protocol == "Open" {
+		return "Open (OWE transition)"
+	}
+
+	var label string
+	switch {
+	case sae && psk:
+		p.WPA3Transition = true
+		label = "WPA3-Personal transition"
+	case sae:
+		label = "WPA3-Personal"
+	case owe:
+		label = "OWE"
+		if p.OWETransition {
+			label = "OWE transition"
+		}
+	case suiteB192:
+		label = "WPA3-Enterprise 192-bit"
+	case eapSHA256 && eap && p.MFPCapable:
+		p.EnterpriseTransition = true
+		label = "WPA3-Enterprise transition"
+	case eapSHA256 && p.MFPRequired:
+		label = "WPA3-Enterprise"
+	case eap || eapSHA256:
+		label = "WPA2-Enterprise"
+	case psk:
+		label = "WPA2-Personal"
+	default:
+		return fmt.Sprintf("RSN (%s)", strings.Join(p.AKMs, "/"))
+	}
+	if p.WPAMixed && strings.HasPrefix(label, "WPA2-") {
+		label = "WPA/" + label
+	}
+	return label
+}
@@ -0,0 +1,262 @@
+package frame_parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rsnCorpus holds RSN element bodies (without Element ID and Length) and the expected profile.
+var rsnCorpus = []struct {
+	name     string
+	body     string
+	label    string
+	group    string
+	pairwise []string
+	akms     []string
+	check    func(t *testing.T, p *SecurityProfile)
+}{
+	{
+		name:     "WPA2-PSK CCMP",
+		body:     "0100 000fac04 0100 000fac04 0100 000fac02 0c00",
+		label:    "WPA2-Personal",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"PSK"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.Equal(t, uint16(0x000c), p.RSNCapabilities)
+			assert.False(t, p.MFPCapable)
+			assert.False(t, p.MFPRequired)
+		},
+	},
+	{
+		name:     "WPA2-PSK TKIP/CCMP mixed ciphers",
+		body:     "0100 000fac02 0200 000fac02 000fac04 0100 000fac02 0000",
+		label:    "WPA2-Personal",
+		group:    "TKIP",
+		pairwise: []string{"TKIP", "CCMP-128"},
+		akms:     []string{"PSK"},
+	},
+	{
+		name:     "WPA3-SAE only",
+		body:     "0100 000fac04 0100 000fac04 0100 000fac08 c000",
+		label:    "WPA3-Personal",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"SAE"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.True(t, p.MFPCapable)
+			assert.True(t, p.MFPRequired)
+			assert.False(t, p.WPA3Transition)
+		},
+	},
+	{
+		name:     "WPA3-Personal transition",
+		body:     "0100 000fac04 0100 000fac04 0200 000fac02 000fac08 8000",
+		label:    "WPA3-Personal transition",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"PSK", "SAE"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.True(t, p.WPA3Transition)
+			assert.True(t, p.MFPCapable)
+			assert.False(t, p.MFPRequired)
+		},
+	},
+	{
+		name:     "SAE and SAE-EXT-KEY with GCMP-256 and BIP",
+		body:     "0100 000fac09 0200 000fac04 000fac09 0200 000fac08 000fac18 c000 0000 000fac06",
+		label:    "WPA3-Personal",
+		group:    "GCMP-256",
+		pairwise: []string{"CCMP-128", "GCMP-256"},
+		akms:     []string{"SAE", "SAE-EXT-KEY"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.Equal(t, "BIP-CMAC-128", p.GroupMgmtCipher)
+			assert.Equal(t, 0, p.PMKIDCount)
+		},
+	},
+	{
+		name:     "FT-PSK, PSK, FT-SAE and SAE",
+		body:     "0100 000fac04 0100 000fac04 0400 000fac02 000fac04 000fac08 000fac09 8000",
+		label:    "WPA3-Personal transition",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"PSK", "FT-PSK", "SAE", "FT-SAE"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.True(t, p.FastTransition)
+			assert.True(t, p.WPA3Transition)
+		},
+	},
+	{
+		name:     "FT-SAE-EXT-KEY only",
+		body:     "0100 000fac09 0100 000fac09 0100 000fac19 c000",
+		label:    "WPA3-Personal",
+		group:    "GCMP-256",
+		pairwise: []string{"GCMP-256"},
+		akms:     []string{"FT-SAE-EXT-KEY"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.True(t, p.FastTransition)
+		},
+	},
+	{
+		name:     "OWE",
+		body:     "0100 000fac04 0100 000fac04 0100 000fac12 c000",
+		label:    "OWE",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"OWE"},
+	},
+	{
+		name:     "WPA2-Enterprise with FT and pre-authentication",
+		body:     "0100 000fac04 0100 000fac04 0200 000fac01 000fac03 0100",
+		label:    "WPA2-Enterprise",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"802.1X", "FT-802.1X"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.True(t, p.PreAuth)
+			assert.True(t, p.FastTransition)
+		},
+	},
+	{
+		name:     "WPA3-Enterprise transition",
+		body:     "0100 000fac04 0100 000fac04 0200 000fac01 000fac05 8000",
+		label:    "WPA3-Enterprise transition",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"802.1X", "WPA-SHA256-802.1X"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.True(t, p.EnterpriseTransition)
+		},
+	},
+	{
+		name:     "WPA3-Enterprise only",
+		body:     "0100 000fac04 0100 000fac04 0100 000fac05 c000",
+		label:    "WPA3-Enterprise",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"WPA-SHA256-802.1X"},
+	},
+	{
+		name:     "WPA3-Enterprise 192-bit (Suite-B)",
+		body:     "0100 000fac09 0100 000fac09 0100 000fac0c e000 0000 000fac0c",
+		label:    "WPA3-Enterprise 192-bit",
+		group:    "GCMP-256",
+		pairwise: []string{"GCMP-256"},
+		akms:     []string{"802.1X-SuiteB-SHA384"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.True(t, p.SuiteB)
+			assert.Equal(t, "BIP-GMAC-256", p.GroupMgmtCipher)
+		},
+	},
+	{
+		name:     "PMKID list in (re)association request",
+		body:     "0100 000fac04 0100 000fac04 0100 000fac02 0000 0100 00112233445566778899aabbccddeeff",
+		label:    "WPA2-Personal",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"PSK"},
+		check: func(t *testing.T, p *SecurityProfile) {
+			assert.Equal(t, 1, p.PMKIDCount)
+		},
+	},
+	{
+		name:     "Version only uses defaults",
+		body:     "0100",
+		label:    "WPA2-Enterprise",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"802.1X"},
+	},
+	{
+		name:     "Unknown AKM",
+		body:     "0100 000fac04 0100 000fac04 0100 000fac07",
+		label:    "RSN (TDLS)",
+		group:    "CCMP-128",
+		pairwise: []string{"CCMP-128"},
+		akms:     []string{"TDLS"},
+	},
+}
+
+func TestParseRSNElement_Corpus(t *testing.T) {
+	for _, tc := range rsnCorpus {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := parseRSNElement(mustHex(t, tc.body))
+			require.NoError(t, err)
+			require.NotNil(t, p)
+			p.Label = deriveSecurityLabel(p)
+
+			assert.Equal(t, "RSN", p.Protocol)
+			assert.Equal(t, uint16(1), p.Version)
+			assert.Equal(t, tc.label, p.Label)
+			assert.Equal(t, tc.group, p.GroupCipher)
+			assert.Equal(t, tc.pairwise, p.PairwiseCiphers)
+			assert.Equal(t, tc.akms, p.AKMs)
+			if tc.check != nil {
+				tc.check(t, p)
+			}
+		})
+	}
+}
+
+func TestParseRSNElement_Malformed(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+	}{
+		{"empty", ""},
+		{"unsupported version", "0200 000fac04 0100 000fac04 0100 000fac02"},
+		{"truncated pairwise list", "0100 000fac04 0200 000fac04"},
+		{"truncated AKM list", "0100 000fac04 0100 000fac04 0200 000fac02"},
+		{"truncated PMKID list", "0100 000fac04 0100 000fac04 0100 000fac02 0000 0200 00112233445566778899aabbccddeeff"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseRSNElement(mustHex(t, tc.body))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFinalizeSecurityProfile(t *testing.T) {
+	rsnIE := "30 14 0100 000fac04 0100 000fac04 0100 000fac02 0c00"
+	wpaIE := "dd 16 0050f201 0100 0050f202 0100 0050f202 0100 0050f202"
+	oweIE := "dd 0f 506f9a1c 020000000001 04 6f70656e"
+
+	cases := []struct {
+		name     string
+		ies      string
+		protocol string
+		label    string
+		wpaMixed bool
+		owe      bool
+	}{
+		{"WPA1 only", wpaIE, "WPA", "WPA-Personal", false, false},
+		{"WPA/WPA2 mixed", rsnIE + wpaIE, "RSN", "WPA/WPA2-Personal", true, false},
+		{"Open with OWE transition", oweIE, "Open", "Open (OWE transition)", false, true},
+		{"OWE with OWE transition", "30 14 0100 000fac04 0100 000fac04 0100 000fac12 c000" + oweIE, "RSN", "OWE transition", false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &ParsedFrameInfo{}
+			parseInformationElements(info, mustHex(t, tc.ies))
+			finalizeSecurityProfile(info)
+
+			require.NotNil(t, info.SecurityProfile)
+			assert.Equal(t, tc.protocol, info.SecurityProfile.Protocol)
+			assert.Equal(t, tc.label, info.SecurityProfile.Label)
+			assert.Equal(t, tc.label, info.Security)
+			assert.Equal(t, tc.wpaMixed, info.SecurityProfile.WPAMixed)
+			assert.Equal(t, tc.owe, info.SecurityProfile.OWETransition)
+		})
+	}
+
+	t.Run("No security elements", func(t *testing.T) {
+		info := &ParsedFrameInfo{}
+		parseInformationElements(info, mustHex(t, "00 04 74657374"))
+		finalizeSecurityProfile(info)
+		assert.Nil(t, info.SecurityProfile)
+		assert.Equal(t, "", info.Security)
+	})
+}
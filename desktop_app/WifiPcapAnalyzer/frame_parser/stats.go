@@ -0,0 +1,38 @@
+package frame_parser
+
+import "sync/atomic"
+
+// PipelineStats counts frames across every capture processed since the program started,
+// live or from files, for monitoring. The counters only grow.
+type PipelineStats struct {
+	FramesParsed  uint64 // Frames parsed and handed to the handler
+	ParseErrors   uint64 // Frames that could not be parsed
+	FramesDropped uint64 // Frames the capture lost before they reached us, from pcapng interface statistics only
+}
+
+var pipelineCounters struct {
+	framesParsed  atomic.Uint64
+	parseErrors   atomic.Uint64
+	framesDropped atomic.Uint64
+}
+
+// GetPipelineStats returns the current pipeline counters.
+func GetPipelineStats() PipelineStats {
+	return PipelineStats{
+		FramesParsed:  pipelineCounters.framesParsed.Load(),
+		ParseErrors:   pipelineCounters.parseErrors.Load(),
+		FramesDropped: pipelineCounters.framesDropped.Load(),
+	}
+}
+
+// dropCounter adds the growth of a pcapng reader's drop count to the pipeline counters.
+type dropCounter struct {
+	counted uint64
+}
+
+func (d *dropCounter) update(reader *PcapngReader) {
+	if dropped := reader.Dropped(); dropped > d.counted {
+		pipelineCounters.framesDropped.Add(dropped - d.counted)
+		d.counted = dropped
+	}
+}
@@ -0,0 +1,245 @@
+package frame_parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/textproto"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Well-known ports decoded here rather than by gopacket.
+const (
+	mdnsPort = 5353
+	ssdpPort = 1900
+)
+
+// FlowInfo is the IP flow of a data frame. Ports are only set for TCP and UDP.
+type FlowInfo struct {
+	Protocol string // "TCP", "UDP", "ICMPv4", "ICMPv6", ...
+	SrcIP    net.IP
+	DstIP    net.IP
+	SrcPort  uint16
+	DstPort  uint16
+}
+
+// DHCPInfo is a DHCPv4 message.
+type DHCPInfo struct {
+	MessageType  string           // "Discover", "Offer", "Request", "Ack", "Nak", "Release", ...
+	ClientMAC    net.HardwareAddr // chaddr
+	Hostname     string           // Option 12
+	RequestedIP  net.IP           // Option 50
+	YourIP       net.IP           // yiaddr, the address offered or assigned by the server
+	ClientIP     net.IP           // ciaddr, set when the client already has an address (renew, release)
+	LeaseSeconds uint32           // Option 51
+	VendorClass  string           // Option 60
+}
+
+// ARPInfo is an ARP request or reply.
+type ARPInfo struct {
+	Operation string // "Request" or "Reply"
+	SenderMAC net.HardwareAddr
+	SenderIP  net.IP
+	TargetMAC net.HardwareAddr
+	TargetIP  net.IP
+}
+
+// DNSInfo holds the names of a DNS or mDNS message.
+type DNSInfo struct {
+	MDNS      bool
+	Response  bool
+	Queries   []string // Question names
+	Hostnames []string // mDNS: host names of A/AAAA answers, without ".local"
+	Instances []string // mDNS: service instance names from PTR/SRV answers, e.g. "Meeting Room TV"
+}
+
+// SSDPInfo is an SSDP (UPnP discovery) message.
+type SSDPInfo struct {
+	Method   string // "NOTIFY", "M-SEARCH" or "Response"
+	Server   string // SERVER header, e.g. "Linux/4.9 UPnP/1.0 Sony-BRAVIA/1.0"
+	Location string // URL of the device description
+	NT       string // Notification type (NOTIFY) or ST (search target)
+	USN      string
+}
+
+// parseUpperLayers decodes the IP flow and the DHCP, ARP, DNS/mDNS and SSDP payloads of an
+// unencrypted (or decrypted) data frame.
+func parseUpperLayers(info *ParsedFrameInfo, packet layerSource) {
+	if arpLayer := packet.Layer(layers.LayerTypeARP); arpLayer != nil {
+		info.ARP = parseARP(arpLayer.(*layers.ARP))
+		return
+	}
+
+	flow := &FlowInfo{}
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		ipv4 := ipLayer.(*layers.IPv4)
+		flow.SrcIP, flow.DstIP, flow.Protocol = ipv4.SrcIP, ipv4.DstIP, ipv4.Protocol.String()
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		ipv6 := ipLayer.(*layers.IPv6)
+		flow.SrcIP, flow.DstIP, flow.Protocol = ipv6.SrcIP, ipv6.DstIP, ipv6.NextHeader.String()
+	} else {
+		return
+	}
+	info.Flow = flow
+
+	var udpPayload []byte
+	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
+		tcp := tcpLayer.(*layers.TCP)
+		flow.SrcPort, flow.DstPort = uint16(tcp.SrcPort), uint16(tcp.DstPort)
+	} else if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
+		udp := udpLayer.(*layers.UDP)
+		flow.SrcPort, flow.DstPort = uint16(udp.SrcPort), uint16(udp.DstPort)
+		udpPayload = udp.Payload
+	}
+
+	if dhcpLayer := packet.Layer(layers.LayerTypeDHCPv4); dhcpLayer != nil {
+		info.DHCP = parseDHCP(dhcpLayer.(*layers.DHCPv4))
+	} else if dnsLayer := packet.Layer(layers.LayerTypeDNS); dnsLayer != nil {
+		info.DNS = parseDNS(dnsLayer.(*layers.DNS), false)
+	} else if udpPayload != nil && (flow.SrcPort == mdnsPort || flow.DstPort == mdnsPort) {
+		dns := &layers.DNS{}
+		if err := dns.DecodeFromBytes(udpPayload, gopacket.NilDecodeFeedback); err == nil {
+			info.DNS = parseDNS(dns, true)
+		}
+	} else if udpPayload != nil && (flow.SrcPort == ssdpPort || flow.DstPort == ssdpPort) {
+		info.SSDP = parseSSDP(udpPayload)
+	}
+}
+
+func parseARP(arp *layers.ARP) *ARPInfo {
+	if arp.AddrType != layers.LinkTypeEthernet || arp.Protocol != layers.EthernetTypeIPv4 {
+		return nil
+	}
+	info := &ARPInfo{
+		SenderMAC: net.HardwareAddr(arp.SourceHwAddress),
+		SenderIP:  net.IP(arp.SourceProtAddress),
+		TargetMAC: net.HardwareAddr(arp.DstHwAddress),
+		TargetIP:  net.IP(arp.DstProtAddress),
+	}
+	switch arp.Operation {
+	case layers.ARPRequest:
+		info.Operation = "Request"
+	case layers.ARPReply:
+		info.Operation = "Reply"
+	}
+	return info
+}
+
+func parseDHCP(dhcp *layers.DHCPv4) *DHCPInfo {
+	info := &DHCPInfo{ClientMAC: dhcp.ClientHWAddr}
+	if !dhcp.YourClientIP.IsUnspecified() {
+		info.YourIP = dhcp.YourClientIP
+	}
+	if !dhcp.ClientIP.IsUnspecified() {
+		info.ClientIP = dhcp.ClientIP
+	}
+	for _, opt := range dhcp.Options {
+		switch opt.Type {
+		case layers.DHCPOptMessageType:
+			if len(opt.Data) == 1 {
+				info.MessageType = layers.DHCPMsgType(opt.Data[0]).String()
+			}
+		case layers.DHCPOptHostname:
+			info.Hostname = string(opt.Data)
+		case layers.DHCPOptRequestIP:
+			if len(opt.Data) == 4 {
+				info.RequestedIP = net.IP(opt.Data)
+			}
+		case layers.DHCPOptLeaseTime:
+			if len(opt.Data) == 4 {
+				info.LeaseSeconds = binary.BigEndian.Uint32(opt.Data)
+			}
+		case layers.DHCPOptClassID:
+			info.VendorClass = string(opt.Data)
+		}
+	}
+	return info
+}
+
+func parseDNS(dns *layers.DNS, mdns bool) *DNSInfo {
+	info := &DNSInfo{MDNS: mdns, Response: dns.QR}
+	for _, q := range dns.Questions {
+		info.Queries = append(info.Queries, string(q.Name))
+	}
+	if !mdns {
+		return info
+	}
+	records := append(append([]layers.DNSResourceRecord(nil), dns.Answers...), dns.Additionals...)
+	for _, rr := range records {
+		switch rr.Type {
+		case layers.DNSTypeA, layers.DNSTypeAAAA:
+			info.Hostnames = appendUnique(info.Hostnames, strings.TrimSuffix(string(rr.Name), ".local"))
+		case layers.DNSTypePTR:
+			if instance := mdnsInstanceName(string(rr.PTR)); instance != "" {
+				info.Instances = appendUnique(info.Instances, instance)
+			}
+		case layers.DNSTypeSRV:
+			if instance := mdnsInstanceName(string(rr.Name)); instance != "" {
+				info.Instances = appendUnique(info.Instances, instance)
+			}
+		}
+	}
+	return info
+}
+
+// mdnsInstanceName returns the instance part of a DNS-SD service instance name
+// ("Meeting Room TV._airplay._tcp.local" -> "Meeting Room TV"), or "" for other names.
+func mdnsInstanceName(name string) string {
+	for _, proto := range []string{"._tcp.", "._udp."} {
+		end := strings.Index(name, proto)
+		if end < 0 {
+			continue
+		}
+		// The service label ("_airplay") precedes the protocol label.
+		start := strings.LastIndex(name[:end], "._")
+		if start <= 0 {
+			return ""
+		}
+		return name[:start]
+	}
+	return ""
+}
+
+func parseSSDP(payload []byte) *SSDPInfo {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(payload)))
+	line, err := reader.ReadLine()
+	if err != nil {
+		return nil
+	}
+	info := &SSDPInfo{}
+	switch {
+	case strings.HasPrefix(line, "NOTIFY "):
+		info.Method = "NOTIFY"
+	case strings.HasPrefix(line, "M-SEARCH "):
+		info.Method = "M-SEARCH"
+	case strings.HasPrefix(line, "HTTP/"):
+		info.Method = "Response"
+	default:
+		return nil
+	}
+	header, _ := reader.ReadMIMEHeader() // A missing final blank line still yields the headers read
+	info.Server = header.Get("Server")
+	info.Location = header.Get("Location")
+	info.USN = header.Get("Usn")
+	info.NT = header.Get("Nt")
+	if info.NT == "" {
+		info.NT = header.Get("St")
+	}
+	return info
+}
+
+func appendUnique(list []string, s string) []string {
+	if s == "" {
+		return list
+	}
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
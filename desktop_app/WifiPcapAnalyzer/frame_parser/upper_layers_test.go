@@ -0,0 +1,142 @@
+package frame_parser
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Uplink data frame (To DS) from STA 02:00:00:00:00:01 to 02:00:00:00:00:99 via AP 02:00:00:00:00:aa.
+const upperLayersTestHeader = "0801 3000 0200000000aa 020000000001 020000000099 0000 aaaa03000000"
+
+// snapDataFrame serializes the layers behind the LLC/SNAP header of upperLayersTestHeader.
+func snapDataFrame(t testing.TB, etherType layers.EthernetType, ls ...gopacket.SerializableLayer) []byte {
+	t.Helper()
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, ls...))
+	frame := append(mustHex(t, upperLayersTestHeader), byte(etherType>>8), byte(etherType))
+	return append(append(frame, buf.Bytes()...), 0, 0, 0, 0)
+}
+
+// parseDataFrame serializes the layers behind an LLC/SNAP header and parses the frame.
+func parseDataFrame(t *testing.T, etherType layers.EthernetType, ls ...gopacket.SerializableLayer) *ParsedFrameInfo {
+	t.Helper()
+	frame := snapDataFrame(t, etherType, ls...)
+	packet := gopacket.NewPacket(frame, layers.LayerTypeDot11, gopacket.Default)
+	info, err := (&GoPacketParser{}).ParsePacket(packet)
+	require.NoError(t, err)
+	return info
+}
+
+func udpLayers(src, dst string, srcPort, dstPort uint16) (*layers.IPv4, *layers.UDP) {
+	ip := &layers.IPv4{Version: 4, IHL: 5, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.ParseIP(src).To4(), DstIP: net.ParseIP(dst).To4()}
+	udp := &layers.UDP{SrcPort: layers.UDPPort(srcPort), DstPort: layers.UDPPort(dstPort)}
+	udp.SetNetworkLayerForChecksum(ip)
+	return ip, udp
+}
+
+func TestParseUpperLayers_DHCP(t *testing.T) {
+	sta, _ := net.ParseMAC("02:00:00:00:00:01")
+	ip, udp := udpLayers("0.0.0.0", "255.255.255.255", 68, 67)
+	dhcp := &layers.DHCPv4{
+		Operation: layers.DHCPOpRequest, HardwareType: layers.LinkTypeEthernet, ClientHWAddr: sta,
+		Options: layers.DHCPOptions{
+			layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(layers.DHCPMsgTypeRequest)}),
+			layers.NewDHCPOption(layers.DHCPOptHostname, []byte("meeting-room-tv")),
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 1, 50}),
+			layers.NewDHCPOption(layers.DHCPOptClassID, []byte("android-dhcp-13")),
+		},
+	}
+	info := parseDataFrame(t, layers.EthernetTypeIPv4, ip, udp, dhcp)
+
+	require.NotNil(t, info.Flow)
+	assert.Equal(t, FlowInfo{Protocol: "UDP", SrcIP: net.IPv4zero.To4(), DstIP: net.IPv4bcast.To4(), SrcPort: 68, DstPort: 67}, *info.Flow)
+	require.NotNil(t, info.DHCP)
+	assert.Equal(t, "Request", info.DHCP.MessageType)
+	assert.Equal(t, sta, info.DHCP.ClientMAC)
+	assert.Equal(t, "meeting-room-tv", info.DHCP.Hostname)
+	assert.Equal(t, "192.168.1.50", info.DHCP.RequestedIP.String())
+	assert.Equal(t, "android-dhcp-13", info.DHCP.VendorClass)
+	assert.Nil(t, info.DHCP.YourIP)
+}
+
+func TestParseUpperLayers_ARP(t *testing.T) {
+	arp := &layers.ARP{
+		AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4, HwAddressSize: 6, ProtAddressSize: 4,
+		Operation:         layers.ARPReply,
+		SourceHwAddress:   mustHex(t, "020000000001"),
+		SourceProtAddress: []byte{192, 168, 1, 50},
+		DstHwAddress:      mustHex(t, "020000000099"),
+		DstProtAddress:    []byte{192, 168, 1, 1},
+	}
+	info := parseDataFrame(t, layers.EthernetTypeARP, arp)
+	require.NotNil(t, info.ARP)
+	assert.Equal(t, "Reply", info.ARP.Operation)
+	assert.Equal(t, "02:00:00:00:00:01", info.ARP.SenderMAC.String())
+	assert.Equal(t, "192.168.1.50", info.ARP.SenderIP.String())
+	assert.Equal(t, "192.168.1.1", info.ARP.TargetIP.String())
+	assert.Nil(t, info.Flow)
+}
+
+func TestParseUpperLayers_DNSAndMDNS(t *testing.T) {
+	ip, udp := udpLayers("192.168.1.50", "192.168.1.1", 53000, 53)
+	query := &layers.DNS{ID: 1, RD: true, Questions: []layers.DNSQuestion{{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}}}
+	info := parseDataFrame(t, layers.EthernetTypeIPv4, ip, udp, query)
+	require.NotNil(t, info.DNS)
+	assert.Equal(t, DNSInfo{Queries: []string{"example.com"}}, *info.DNS)
+
+	ip, udp = udpLayers("192.168.1.50", "224.0.0.251", 5353, 5353)
+	announce := &layers.DNS{
+		QR: true, AA: true,
+		Answers: []layers.DNSResourceRecord{
+			{Name: []byte("_airplay._tcp.local"), Type: layers.DNSTypePTR, Class: layers.DNSClassIN, TTL: 120, PTR: []byte("Meeting Room TV._airplay._tcp.local")},
+			{Name: []byte("Meeting-Room-TV.local"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 120, IP: net.IP{192, 168, 1, 50}},
+		},
+	}
+	info = parseDataFrame(t, layers.EthernetTypeIPv4, ip, udp, announce)
+	require.NotNil(t, info.DNS)
+	assert.True(t, info.DNS.MDNS)
+	assert.True(t, info.DNS.Response)
+	assert.Equal(t, []string{"Meeting-Room-TV"}, info.DNS.Hostnames)
+	assert.Equal(t, []string{"Meeting Room TV"}, info.DNS.Instances)
+}
+
+func TestParseUpperLayers_SSDPAndTCP(t *testing.T) {
+	ip, udp := udpLayers("192.168.1.50", "239.255.255.250", 1900, 1900)
+	notify := "NOTIFY * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nLOCATION: http://192.168.1.50:8080/dd.xml\r\n" +
+		"NT: urn:schemas-upnp-org:device:MediaRenderer:1\r\nNTS: ssdp:alive\r\nSERVER: Linux/4.9 UPnP/1.0 Sony-BRAVIA/1.0\r\n" +
+		"USN: uuid:1234::urn:schemas-upnp-org:device:MediaRenderer:1\r\n\r\n"
+	info := parseDataFrame(t, layers.EthernetTypeIPv4, ip, udp, gopacket.Payload(notify))
+	require.NotNil(t, info.SSDP)
+	assert.Equal(t, SSDPInfo{
+		Method:   "NOTIFY",
+		Server:   "Linux/4.9 UPnP/1.0 Sony-BRAVIA/1.0",
+		Location: "http://192.168.1.50:8080/dd.xml",
+		NT:       "urn:schemas-upnp-org:device:MediaRenderer:1",
+		USN:      "uuid:1234::urn:schemas-upnp-org:device:MediaRenderer:1",
+	}, *info.SSDP)
+
+	ip6 := &layers.IPv6{Version: 6, HopLimit: 64, NextHeader: layers.IPProtocolTCP, SrcIP: net.ParseIP("fe80::1"), DstIP: net.ParseIP("2001:db8::1")}
+	tcp := &layers.TCP{SrcPort: 50000, DstPort: 443, SYN: true, Window: 1024}
+	require.NoError(t, tcp.SetNetworkLayerForChecksum(ip6))
+	info = parseDataFrame(t, layers.EthernetTypeIPv6, ip6, tcp)
+	require.NotNil(t, info.Flow)
+	assert.Equal(t, "TCP", info.Flow.Protocol)
+	assert.Equal(t, "fe80::1", info.Flow.SrcIP.String())
+	assert.Equal(t, "2001:db8::1", info.Flow.DstIP.String())
+	assert.Equal(t, uint16(50000), info.Flow.SrcPort)
+	assert.Equal(t, uint16(443), info.Flow.DstPort)
+	assert.Nil(t, info.SSDP)
+}
+
+func TestMDNSInstanceName(t *testing.T) {
+	assert.Equal(t, "Meeting Room TV", mdnsInstanceName("Meeting Room TV._airplay._tcp.local"))
+	assert.Equal(t, "Printer", mdnsInstanceName("Printer._ipp._udp.local"))
+	assert.Equal(t, "", mdnsInstanceName("_airplay._tcp.local"))
+	assert.Equal(t, "", mdnsInstanceName("host.local"))
+}
@@ -0,0 +1,339 @@
+package frame_parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Well-known OUIs found in Vendor Specific (221) elements.
+var (
+	ouiMicrosoft = [3]byte{0x00, 0x50, 0xF2} // WPA1, WMM/WME, WPS
+	ouiApple     = [3]byte{0x00, 0x17, 0xF2}
+	ouiCisco     = [3]byte{0x00, 0x40, 0x96}
+)
+
+// Microsoft vendor types.
+const (
+	msVendorTypeWPA uint8 = 1
+	msVendorTypeWMM uint8 = 2
+	msVendorTypeWPS uint8 = 4
+)
+
+// Cisco vendor types.
+const (
+	ciscoVendorTypeCCXVersion uint8 = 3
+)
+
+// ieIDCiscoCCX1 is the Cisco proprietary element carrying the AP name (Wireshark: "Cisco CCX1 CKIP + Device Name").
+const ieIDCiscoCCX1 uint8 = 133
+
+// ouiVendorNames maps OUIs (as formatted in InformationElement.OUI) to vendor names for display.
+var ouiVendorNames = map[string]string{
+	"00:50:F2": "Microsoft",
+	"00:17:F2": "Apple",
+	"00:40:96": "Cisco",
+	"00:0B:86": "Aruba",
+	"50:6F:9A": "Wi-Fi Alliance",
+	"00:10:18": "Broadcom",
+	"00:03:7F": "Atheros",
+	"8C:FD:F0": "Qualcomm",
+	"00:0C:E7": "MediaTek",
+	"00:0C:43": "Ralink",
+	"00:90:4C": "Epigram",
+	"00:13:92": "Ruckus",
+	"00:1A:11": "Google",
+}
+
+// VendorNameForOUI returns a display name for an OUI, or "" if unknown.
+func VendorNameForOUI(oui string) string {
+	return ouiVendorNames[oui]
+}
+
+// WPAInfo is the decoded legacy WPA1 (Microsoft OUI type 1) element.
+type WPAInfo struct {
+	Version         uint16   `json:"version"`
+	GroupCipher     string   `json:"group_cipher"`
+	PairwiseCiphers []string `json:"pairwise_ciphers"`
+	AKMs            []string `json:"akms"`
+}
+
+// WMMACParameters holds the EDCA parameters of one access category.
+type WMMACParameters struct {
+	AC              string `json:"ac"` // "AC_BE", "AC_BK", "AC_VI", "AC_VO"
+	ACM             bool   `json:"acm"`
+	AIFSN           uint8  `json:"aifsn"`
+	CWMin           uint16 `json:"cw_min"`
+	CWMax           uint16 `json:"cw_max"`
+	TXOPLimitMicros uint32 `json:"txop_limit_us"`
+}
+
+// WMMInfo is the decoded WMM Information / Parameter element (Microsoft OUI type 2).
+type WMMInfo struct {
+	Subtype       uint8             `json:"subtype"` // 0: Information element, 1: Parameter element
+	Version       uint8             `json:"version"`
+	QoSInfo       uint8             `json:"qos_info"`
+	UAPSD         bool              `json:"uapsd"`                   // AP: U-APSD supported (QoS Info bit 7)
+	ParameterSets uint8             `json:"parameter_set_count"`     // AP: EDCA parameter set update count
+	ACParameters  []WMMACParameters `json:"ac_parameters,omitempty"` // Only present in the Parameter element
+}
+
+// WPSInfo is the decoded Wi-Fi Protected Setup element (Microsoft OUI type 4).
+type WPSInfo struct {
+	Version           uint8  `json:"version,omitempty"`
+	State             string `json:"state,omitempty"` // "Not configured" / "Configured"
+	APSetupLocked     bool   `json:"ap_setup_locked"`
+	SelectedRegistrar bool   `json:"selected_registrar"`
+	DeviceName        string `json:"device_name,omitempty"`
+	Manufacturer      string `json:"manufacturer,omitempty"`
+	ModelName         string `json:"model_name,omitempty"`
+	ModelNumber       string `json:"model_number,omitempty"`
+	SerialNumber      string `json:"serial_number,omitempty"`
+	UUID              string `json:"uuid,omitempty"`
+	ConfigMethods     uint16 `json:"config_methods,omitempty"`
+}
+
+// VendorIEInfo is the generic result for vendor elements whose payload format is not public.
+type VendorIEInfo struct {
+	Vendor     string `json:"vendor"`
+	VendorType uint8  `json:"vendor_type"`
+	Data       []byte `json:"data,omitempty"`
+}
+
+// CiscoInfo collects Cisco proprietary information.
+type CiscoInfo struct {
+	APName      string `json:"ap_name,omitempty"`
+	ClientCount uint8  `json:"client_count,omitempty"`
+	CCXVersion  uint8  `json:"ccx_version,omitempty"`
+}
+
+func init() {
+	RegisterVendorIEDecoder(ouiMicrosoft, msVendorTypeWPA, "Vendor Specific: Microsoft WPA", decodeWPAIE)
+	RegisterVendorIEDecoder(ouiMicrosoft, msVendorTypeWMM, "Vendor Specific: Microsoft WMM/WME", decodeWMMIE)
+	RegisterVendorIEDecoder(ouiMicrosoft, msVendorTypeWPS, "Vendor Specific: Microsoft WPS", decodeWPSIE)
+	RegisterVendorIEDecoder(ouiCisco, ciscoVendorTypeCCXVersion, "Vendor Specific: Cisco CCX Version", decodeCiscoCCXVersionIE)
+	RegisterVendorOUIDecoder(ouiApple, "Vendor Specific: Apple", decodeOpaqueVendorIE("Apple"))
+	RegisterVendorOUIDecoder(ouiCisco, "Vendor Specific: Cisco", decodeOpaqueVendorIE("Cisco"))
+	RegisterIEDecoder(ieIDCiscoCCX1, "Cisco CCX1 CKIP + Device Name", decodeCiscoCCX1IE)
+}
+
+// decodeWPAIE decodes the legacy WPA1 element. The layout mirrors the RSN element
+// without the RSN capabilities field, with suites under the Microsoft OUI.
+func decodeWPAIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 6 {
+		return nil, fmt.Errorf("WPA element too short (%d bytes)", len(ieData))
+	}
+	wpa := &WPAInfo{Version: binary.LittleEndian.Uint16(ieData[0:2])}
+	wpa.GroupCipher = wpaSuiteToCipher(ieData[2:5], ieData[5])
+	currentIndex := 6
+
+	if currentIndex+2 <= len(ieData) {
+		count := int(binary.LittleEndian.Uint16(ieData[currentIndex : currentIndex+2]))
+		currentIndex += 2
+		for i := 0; i < count && currentIndex+4 <= len(ieData); i++ {
+			wpa.PairwiseCiphers = append(wpa.PairwiseCiphers, wpaSuiteToCipher(ieData[currentIndex:currentIndex+3], ieData[currentIndex+3]))
+			currentIndex += 4
+		}
+	}
+	if currentIndex+2 <= len(ieData) {
+		count := int(binary.LittleEndian.Uint16(ieData[currentIndex : currentIndex+2]))
+		currentIndex += 2
+		for i := 0; i < count && currentIndex+4 <= len(ieData); i++ {
+			wpa.AKMs = append(wpa.AKMs, wpaSuiteToAKM(ieData[currentIndex:currentIndex+3], ieData[currentIndex+3]))
+			currentIndex += 4
+		}
+	}
+	info.WPA = wpa
+	return wpa, nil
+}
+
+func wpaSuiteToCipher(oui []byte, suiteType byte) string {
+	if oui[0] == ouiMicrosoft[0] && oui[1] == ouiMicrosoft[1] && oui[2] == ouiMicrosoft[2] {
+		switch suiteType {
+		case 0:
+			return "Use Group Cipher"
+		case 1:
+			return "WEP-40"
+		case 2:
+			return "TKIP"
+		case 4:
+			return "CCMP-128"
+		case 5:
+			return "WEP-104"
+		}
+	}
+	return ouiAndCipherSuiteToString(oui, suiteType)
+}
+
+func wpaSuiteToAKM(oui []byte, suiteType byte) string {
+	if oui[0] == ouiMicrosoft[0] && oui[1] == ouiMicrosoft[1] && oui[2] == ouiMicrosoft[2] {
+		switch suiteType {
+		case 1:
+			return "802.1X"
+		case 2:
+			return "PSK"
+		}
+	}
+	return ouiAndAKMSuiteToString(oui, suiteType)
+}
+
+var wmmACNames = [4]string{"AC_BE", "AC_BK", "AC_VI", "AC_VO"}
+
+// decodeWMMIE decodes the WMM Information (subtype 0) and Parameter (subtype 1) elements.
+// Reference: Wi-Fi Alliance WMM Specification v1.2, section 2.2.
+func decodeWMMIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 3 {
+		return nil, fmt.Errorf("WMM element too short (%d bytes)", len(ieData))
+	}
+	wmm := &WMMInfo{
+		Subtype:       ieData[0],
+		Version:       ieData[1],
+		QoSInfo:       ieData[2],
+		UAPSD:         ieData[2]&0x80 != 0,
+		ParameterSets: ieData[2] & 0x0F,
+	}
+	if wmm.Subtype == 1 {
+		// QoS Info (1) + Reserved (1) + 4 AC Parameter Records (4 bytes each)
+		currentIndex := 4
+		for i := 0; i < 4 && currentIndex+4 <= len(ieData); i++ {
+			aciAifsn := ieData[currentIndex]
+			ecw := ieData[currentIndex+1]
+			aci := (aciAifsn >> 5) & 0x03
+			wmm.ACParameters = append(wmm.ACParameters, WMMACParameters{
+				AC:              wmmACNames[aci],
+				ACM:             aciAifsn&0x10 != 0,
+				AIFSN:           aciAifsn & 0x0F,
+				CWMin:           uint16(1)<<(ecw&0x0F) - 1,
+				CWMax:           uint16(1)<<(ecw>>4) - 1,
+				TXOPLimitMicros: uint32(binary.LittleEndian.Uint16(ieData[currentIndex+2:currentIndex+4])) * 32,
+			})
+			currentIndex += 4
+		}
+	}
+	info.WMM = wmm
+	return wmm, nil
+}
+
+// WPS attribute types (Wi-Fi Simple Configuration Technical Specification, Table 28).
+const (
+	wpsAttrConfigMethods     uint16 = 0x1008
+	wpsAttrDeviceName        uint16 = 0x1011
+	wpsAttrManufacturer      uint16 = 0x1021
+	wpsAttrModelName         uint16 = 0x1023
+	wpsAttrModelNumber       uint16 = 0x1024
+	wpsAttrSelectedRegistrar uint16 = 0x1041
+	wpsAttrSerialNumber      uint16 = 0x1042
+	wpsAttrState             uint16 = 0x1044
+	wpsAttrUUIDE             uint16 = 0x1047
+	wpsAttrVersion           uint16 = 0x104A
+	wpsAttrAPSetupLocked     uint16 = 0x1057
+)
+
+// decodeWPSIE decodes the WPS element. Attributes are big-endian Type(2)/Length(2)/Value TLVs.
+func decodeWPSIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	wps := &WPSInfo{}
+	currentIndex := 0
+	for currentIndex+4 <= len(ieData) {
+		attrType := binary.BigEndian.Uint16(ieData[currentIndex : currentIndex+2])
+		attrLen := int(binary.BigEndian.Uint16(ieData[currentIndex+2 : currentIndex+4]))
+		currentIndex += 4
+		if currentIndex+attrLen > len(ieData) {
+			return nil, fmt.Errorf("WPS attribute 0x%04x length %d exceeds element", attrType, attrLen)
+		}
+		value := ieData[currentIndex : currentIndex+attrLen]
+		currentIndex += attrLen
+
+		switch attrType {
+		case wpsAttrVersion:
+			if attrLen >= 1 {
+				wps.Version = value[0]
+			}
+		case wpsAttrState:
+			if attrLen >= 1 {
+				switch value[0] {
+				case 1:
+					wps.State = "Not configured"
+				case 2:
+					wps.State = "Configured"
+				default:
+					wps.State = fmt.Sprintf("Unknown(%d)", value[0])
+				}
+			}
+		case wpsAttrAPSetupLocked:
+			wps.APSetupLocked = attrLen >= 1 && value[0] != 0
+		case wpsAttrSelectedRegistrar:
+			wps.SelectedRegistrar = attrLen >= 1 && value[0] != 0
+		case wpsAttrDeviceName:
+			wps.DeviceName = printableString(value)
+		case wpsAttrManufacturer:
+			wps.Manufacturer = printableString(value)
+		case wpsAttrModelName:
+			wps.ModelName = printableString(value)
+		case wpsAttrModelNumber:
+			wps.ModelNumber = printableString(value)
+		case wpsAttrSerialNumber:
+			wps.SerialNumber = printableString(value)
+		case wpsAttrUUIDE:
+			if attrLen == 16 {
+				wps.UUID = fmt.Sprintf("%x-%x-%x-%x-%x", value[0:4], value[4:6], value[6:8], value[8:10], value[10:16])
+			}
+		case wpsAttrConfigMethods:
+			if attrLen >= 2 {
+				wps.ConfigMethods = binary.BigEndian.Uint16(value)
+			}
+		}
+	}
+	info.WPS = wps
+	return wps, nil
+}
+
+// decodeCiscoCCX1IE decodes the Cisco Aironet element (ID 133), which carries the AP name.
+// Layout (from Wireshark): 10 unknown bytes, 16-byte NUL-padded device name, client count.
+func decodeCiscoCCX1IE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 26 {
+		return nil, fmt.Errorf("Cisco CCX1 element too short (%d bytes)", len(ieData))
+	}
+	if info.Cisco == nil {
+		info.Cisco = &CiscoInfo{}
+	}
+	info.Cisco.APName = printableString(ieData[10:26])
+	if len(ieData) >= 27 {
+		info.Cisco.ClientCount = ieData[26]
+	}
+	return info.Cisco, nil
+}
+
+func decodeCiscoCCXVersionIE(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+	if len(ieData) < 1 {
+		return nil, fmt.Errorf("Cisco CCX version element is empty")
+	}
+	if info.Cisco == nil {
+		info.Cisco = &CiscoInfo{}
+	}
+	info.Cisco.CCXVersion = ieData[0]
+	return info.Cisco, nil
+}
+
+// decodeOpaqueVendorIE returns a decoder for vendors whose element payloads are not publicly
+// documented. It only labels the element; the payload bytes are carried along as-is.
+func decodeOpaqueVendorIE(vendor string) IEDecoder {
+	return func(info *ParsedFrameInfo, ieData []byte) (interface{}, error) {
+		if len(ieData) < 1 {
+			return nil, fmt.Errorf("%s vendor element is empty", vendor)
+		}
+		data := make([]byte, len(ieData)-1)
+		copy(data, ieData[1:])
+		return &VendorIEInfo{Vendor: vendor, VendorType: ieData[0], Data: data}, nil
+	}
+}
+
+// printableString trims NUL padding and replaces non-printable bytes.
+func printableString(b []byte) string {
+	s := strings.TrimRight(string(b), "\x00 ")
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7F {
+			return '.'
+		}
+		return r
+	}, s)
+}
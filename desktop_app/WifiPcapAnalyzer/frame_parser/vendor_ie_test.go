@@ -0,0 +1,207 @@
+package frame_parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// element prefixes an element body (hex, spaces ignored) with its Element ID and Length.
+func element(t *testing.T, id uint8, body string) []byte {
+	t.Helper()
+	b := mustHex(t, body)
+	require.Less(t, len(b), 256)
+	return append([]byte{id, byte(len(b))}, b...)
+}
+
+const (
+	// WPS element body (after OUI and type) of a configured AP, as seen in Beacons
+	wpsBody = "104a 0001 10" + // Version 1.0
+		" 1044 0001 02" + // Configured
+		" 1057 0001 01" + // AP setup locked
+		" 1041 0001 00" + // No selected registrar
+		" 1047 0010 c63b2a40 8f1d 4a67 b1f8 0a1b2c3d4e5f" + // UUID-E
+		" 1021 0007 4e455447454152" + // Manufacturer "NETGEAR"
+		" 1023 0007 5237303030 0000" + // Model name "R7000", NUL padded
+		" 1011 0003 52 0a 37" + // Device name with a control character
+		" 1008 0002 2008" // Config methods
+	// WMM Parameter element body (after OUI and type) with the usual EDCA defaults
+	wmmParameterBody = "01 01 80 00 03a40000 27a40000 42435e00 62322f00"
+)
+
+func TestDecodeWPAIE(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		group    string
+		pairwise []string
+		akms     []string
+		err      bool
+	}{
+		{name: "WPA-PSK TKIP", body: "0100 0050f202 0100 0050f202 0100 0050f202",
+			group: "TKIP", pairwise: []string{"TKIP"}, akms: []string{"PSK"}},
+		{name: "WPA-Enterprise CCMP/TKIP", body: "0100 0050f202 0200 0050f204 0050f202 0100 0050f201",
+			group: "TKIP", pairwise: []string{"CCMP-128", "TKIP"}, akms: []string{"802.1X"}},
+		{name: "group cipher only", body: "0100 0050f204", group: "CCMP-128"},
+		{name: "pairwise list cut short", body: "0100 0050f202 0200 0050f204 0050",
+			group: "TKIP", pairwise: []string{"CCMP-128"}},
+		{name: "truncated group cipher", body: "0100 0050f2", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &ParsedFrameInfo{}
+			decoded, err := decodeWPAIE(info, mustHex(t, tc.body))
+			if tc.err {
+				assert.Error(t, err)
+				assert.Nil(t, info.WPA)
+				return
+			}
+			require.NoError(t, err)
+			wpa := decoded.(*WPAInfo)
+			assert.Same(t, info.WPA, wpa)
+			assert.Equal(t, uint16(1), wpa.Version)
+			assert.Equal(t, tc.group, wpa.GroupCipher)
+			assert.Equal(t, tc.pairwise, wpa.PairwiseCiphers)
+			assert.Equal(t, tc.akms, wpa.AKMs)
+		})
+	}
+}
+
+func TestDecodeWMMIE(t *testing.T) {
+	info := &ParsedFrameInfo{}
+	decoded, err := decodeWMMIE(info, mustHex(t, wmmParameterBody))
+	require.NoError(t, err)
+	wmm := decoded.(*WMMInfo)
+	assert.Same(t, info.WMM, wmm)
+	assert.Equal(t, uint8(1), wmm.Subtype)
+	assert.Equal(t, uint8(1), wmm.Version)
+	assert.True(t, wmm.UAPSD)
+	assert.Equal(t, uint8(0), wmm.ParameterSets)
+	assert.Equal(t, []WMMACParameters{
+		{AC: "AC_BE", AIFSN: 3, CWMin: 15, CWMax: 1023},
+		{AC: "AC_BK", AIFSN: 7, CWMin: 15, CWMax: 1023},
+		{AC: "AC_VI", AIFSN: 2, CWMin: 7, CWMax: 15, TXOPLimitMicros: 3008},
+		{AC: "AC_VO", AIFSN: 2, CWMin: 3, CWMax: 7, TXOPLimitMicros: 1504},
+	}, wmm.ACParameters)
+
+	// Information element sent by STAs: QoS Info only, U-APSD flags for every AC
+	decoded, err = decodeWMMIE(&ParsedFrameInfo{}, mustHex(t, "00 01 0f"))
+	require.NoError(t, err)
+	wmm = decoded.(*WMMInfo)
+	assert.Equal(t, uint8(0), wmm.Subtype)
+	assert.False(t, wmm.UAPSD)
+	assert.Equal(t, uint8(0x0f), wmm.QoSInfo)
+	assert.Empty(t, wmm.ACParameters)
+
+	// Admission control on AC_VO; records cut short are left out
+	decoded, err = decodeWMMIE(&ParsedFrameInfo{}, mustHex(t, "01 01 00 00 03a40000 72322f00 4243"))
+	require.NoError(t, err)
+	wmm = decoded.(*WMMInfo)
+	require.Len(t, wmm.ACParameters, 2)
+	assert.Equal(t, "AC_VO", wmm.ACParameters[1].AC)
+	assert.True(t, wmm.ACParameters[1].ACM)
+
+	_, err = decodeWMMIE(&ParsedFrameInfo{}, mustHex(t, "01 01"))
+	assert.Error(t, err)
+}
+
+func TestDecodeWPSIE(t *testing.T) {
+	info := &ParsedFrameInfo{}
+	decoded, err := decodeWPSIE(info, mustHex(t, wpsBody))
+	require.NoError(t, err)
+	assert.Same(t, info.WPS, decoded)
+	assert.Equal(t, &WPSInfo{
+		Version:           0x10,
+		State:             "Configured",
+		APSetupLocked:     true,
+		SelectedRegistrar: false,
+		DeviceName:        "R.7",
+		Manufacturer:      "NETGEAR",
+		ModelName:         "R7000",
+		UUID:              "c63b2a40-8f1d-4a67-b1f8-0a1b2c3d4e5f",
+		ConfigMethods:     0x2008,
+	}, info.WPS)
+
+	decoded, err = decodeWPSIE(&ParsedFrameInfo{}, mustHex(t, "1044 0001 01  1047 0004 00010203  1044"))
+	require.NoError(t, err, "a trailing partial attribute header is ignored")
+	assert.Equal(t, &WPSInfo{State: "Not configured"}, decoded, "UUID-E must be 16 bytes")
+
+	info = &ParsedFrameInfo{}
+	_, err = decodeWPSIE(info, mustHex(t, "104a 0001 10  1021 0010 4e4554"))
+	assert.Error(t, err, "attribute longer than the element")
+	assert.Nil(t, info.WPS)
+}
+
+func TestDecodeCiscoIEs(t *testing.T) {
+	ccx1 := "00008f000f00ff035900" + // Unknown
+		"41502d4c4f4242592d3031 0000000000" + // "AP-LOBBY-01", NUL padded to 16 bytes
+		"07 000026" // Clients, unknown
+
+	info := &ParsedFrameInfo{}
+	_, err := decodeCiscoCCX1IE(info, mustHex(t, ccx1))
+	require.NoError(t, err)
+	_, err = decodeCiscoCCXVersionIE(info, mustHex(t, "05"))
+	require.NoError(t, err)
+	assert.Equal(t, &CiscoInfo{APName: "AP-LOBBY-01", ClientCount: 7, CCXVersion: 5}, info.Cisco)
+
+	info = &ParsedFrameInfo{}
+	_, err = decodeCiscoCCX1IE(info, mustHex(t, "00008f000f00ff035900 41502d4c4f4242592d3031 0000000000"))
+	require.NoError(t, err, "the client count is optional")
+	assert.Equal(t, "AP-LOBBY-01", info.Cisco.APName)
+	assert.Zero(t, info.Cisco.ClientCount)
+
+	_, err = decodeCiscoCCX1IE(&ParsedFrameInfo{}, mustHex(t, "00008f000f00ff035900 41502d4c"))
+	assert.Error(t, err)
+	_, err = decodeCiscoCCXVersionIE(&ParsedFrameInfo{}, nil)
+	assert.Error(t, err)
+}
+
+func TestDecodeOpaqueVendorIE(t *testing.T) {
+	decoded, err := decodeOpaqueVendorIE("Apple")(&ParsedFrameInfo{}, mustHex(t, "0a 0001 0301 0000"))
+	require.NoError(t, err)
+	assert.Equal(t, &VendorIEInfo{Vendor: "Apple", VendorType: 0x0a, Data: mustHex(t, "0001 0301 0000")}, decoded)
+
+	_, err = decodeOpaqueVendorIE("Apple")(&ParsedFrameInfo{}, nil)
+	assert.Error(t, err)
+}
+
+// TestParseInformationElements_VendorElements runs complete Vendor Specific elements through
+// the registry, the way they appear in a Beacon.
+func TestParseInformationElements_VendorElements(t *testing.T) {
+	var ies []byte
+	ies = append(ies, element(t, 221, "0050f2 01 0100 0050f202 0100 0050f202 0100 0050f202")...)
+	ies = append(ies, element(t, 221, "0050f2 02 "+wmmParameterBody)...)
+	ies = append(ies, element(t, 221, "0050f2 04 "+wpsBody)...)
+	ies = append(ies, element(t, 221, "004096 03 05")...)
+	ies = append(ies, element(t, 221, "004096 0b 0102")...)
+	ies = append(ies, element(t, 221, "0017f2 0a 0001")...)
+	ies = append(ies, element(t, 221, "0050f2 02 0101")...) // Truncated WMM
+
+	info := &ParsedFrameInfo{}
+	parseInformationElements(info, ies)
+	var names []string
+	for _, elem := range info.Elements {
+		names = append(names, elem.Name)
+	}
+	assert.Equal(t, []string{
+		"Vendor Specific: Microsoft WPA",
+		"Vendor Specific: Microsoft WMM/WME",
+		"Vendor Specific: Microsoft WPS",
+		"Vendor Specific: Cisco CCX Version",
+		"Vendor Specific: Cisco",
+		"Vendor Specific: Apple",
+		"Vendor Specific: Microsoft WMM/WME",
+	}, names)
+	assert.Equal(t, []string{"PSK"}, info.WPA.AKMs)
+	assert.Equal(t, "NETGEAR", info.WPS.Manufacturer)
+	assert.Equal(t, uint8(5), info.Cisco.CCXVersion)
+	assert.Equal(t, &VendorIEInfo{Vendor: "Cisco", VendorType: 0x0b, Data: []byte{1, 2}}, info.Elements[4].Decoded)
+	assert.Equal(t, &VendorIEInfo{Vendor: "Apple", VendorType: 0x0a, Data: []byte{0, 1}}, info.Elements[5].Decoded)
+
+	truncated := info.Elements[6]
+	assert.NotEmpty(t, truncated.Error)
+	assert.Equal(t, mustHex(t, "0050f2 02 0101"), truncated.Raw)
+	require.NotNil(t, info.WMM, "the complete WMM element decoded earlier is kept")
+	assert.Len(t, info.WMM.ACParameters, 4)
+}
@@ -84,13 +84,15 @@ const BssItem: React.FC<BssItemProps> = ({ bss, isSelectedForStaList, isExpanded
             <div className={styles.bssDetailRow}>
               <div className={styles.bssDetailLabel}>Security:</div>
               <div className={styles.bssDetailValue}>
-                {bss.security === "WPA2-PSK" ? "WPA2 Personal" :
-                 bss.security === "WPA3-SAE" ? "WPA3 Personal" :
-                 bss.security === "RSN/WPA2/WPA3" ? "WPA2/WPA3 Mixed" :
-                 bss.security === "Open" ? "Open Network" :
+                {bss.security === "Open" ? "Open Network" :
                  bss.security === "WEP" ? "WEP (Insecure)" :
-                 `${bss.security} (Raw Value)`}
-                <span style={{color: 'gray', fontSize: '0.8em', marginLeft: '4px'}}>[{bss.security}]</span>
+                 bss.security}
+                {bss.security_profile && (
+                  <span style={{color: 'gray', fontSize: '0.8em', marginLeft: '4px'}}>
+                    [{bss.security_profile.akms?.join('/')} / {bss.security_profile.pairwise_ciphers?.join('/')}
+                    {bss.security_profile.mfp_required ? ', MFP required' : bss.security_profile.mfp_capable ? ', MFP capable' : ''}]
+                  </span>
+                )}
               </div>
             </div>
             
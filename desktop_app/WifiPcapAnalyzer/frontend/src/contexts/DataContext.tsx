@@ -1,9 +1,11 @@
-import React, { createContext, useContext, useReducer, ReactNode, useEffect } from 'react';
+import React, { createContext, useContext, useReducer, ReactNode, useEffect, useRef } from 'react';
 import { BSS, STA } from '../types/data'; // Keep BSS, STA types if still relevant
 // Remove WebSocket imports
 // import { connectWebSocket, addMessageListener, removeMessageListener, getWebSocketState, sendMessage } from '../services/websocketService';
 import { EventsOn } from '../../wailsjs/runtime'; // Import Wails runtime
 import { state_manager } from '../../wailsjs/go/models'; // Import generated Go models
+import { ResyncState } from '../../wailsjs/go/main/App';
+import { StateDelta, applyStateDelta, newDeltaState, toSnapshot } from '../services/stateDelta';
 
 interface AppState {
   bssList: BSS[]; // Consider if BSS/STA types need update based on wailsjs/go/models.ts
@@ -137,6 +139,7 @@ const transformBssData = (bss: state_manager.BSSInfo): BSS => {
     channel: bss.channel,
     bandwidth: bss.bandwidth,
     security: bss.security,
+    security_profile: bss.security_profile,
     signal_strength: bss.signal_strength,
     last_seen: new Date(bss.last_seen).toISOString(),
     ht_capabilities: htCapabilities,
@@ -251,15 +254,19 @@ const appReducer = (state: AppState, action: Action): AppState => {
 
 export const DataProvider: React.FC<{ children: ReactNode }> = ({ children }) => {
   const [state, dispatch] = useReducer(appReducer, initialState);
+  // Raw BSS and STA fields that the "state_delta" events are applied to
+  const deltaState = useRef(newDeltaState());
 
   // Effect for Wails event listeners
   useEffect(() => {
     console.log("Setting up Wails event listeners...");
 
-    const cleanupSnapshot = EventsOn('state_snapshot', (snapshot: state_manager.Snapshot) => {
-      // console.log("Received state_snapshot event:", snapshot);
-      dispatch({ type: 'SET_SNAPSHOT_DATA', payload: snapshot });
+    const cleanupSnapshot = EventsOn('state_delta', (delta: StateDelta) => {
+      applyStateDelta(deltaState.current, delta);
+      dispatch({ type: 'SET_SNAPSHOT_DATA', payload: toSnapshot(deltaState.current) });
     });
+    // Deltas only make sense on top of what we have: start from the full state
+    ResyncState().catch(err => console.error("Error requesting a state resync:", err));
 
     // Listen for capture status events
     const cleanupCaptureStatus = EventsOn('capture_status', (status: string) => {
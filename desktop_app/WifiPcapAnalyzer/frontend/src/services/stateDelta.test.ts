@@ -0,0 +1,45 @@
+import { applyStateDelta, newDeltaState, toSnapshot } from './stateDelta';
+
+const reasons = [{ reason_code: 7, reason: 'Class 3 frame received from nonassociated STA', count: 2, ap_initiated: 2, sta_initiated: 0 }];
+const channels = [{ band: '5GHz', channel: 36, frames: 120, bad_fcs_frames: 6, phy_error_rate: 0.05 }];
+
+test('applies added, updated and removed entities', () => {
+  const state = newDeltaState();
+  applyStateDelta(state, {
+    version: 1,
+    full: true,
+    added_bsss: [{ bssid: 'aa:aa:aa:aa:aa:aa', ssid: 'lab', channel: 6, associated_sta_macs: ['02:00:00:00:00:01'] }],
+    added_stas: [{ mac_address: '02:00:00:00:00:01', signal_strength: -50 }],
+  });
+  applyStateDelta(state, {
+    version: 2,
+    full: false,
+    updated_bsss: [{ bssid: 'aa:aa:aa:aa:aa:aa', channel: 36 }],
+    removed_stas: ['02:00:00:00:00:01'],
+  });
+
+  const snapshot = toSnapshot(state);
+  expect(snapshot.bsss).toHaveLength(1);
+  expect(snapshot.bsss[0].ssid).toBe('lab');
+  expect(snapshot.bsss[0].channel).toBe(36);
+  expect(snapshot.bsss[0].associated_stas).toEqual({});
+  expect(snapshot.stas).toHaveLength(0);
+});
+
+test('keeps the disconnect reasons and channel counters until they change', () => {
+  const state = newDeltaState();
+  applyStateDelta(state, { version: 1, full: true, disconnect_reasons: reasons, channel_frames: channels });
+  applyStateDelta(state, { version: 2, full: false, updated_bsss: [] });
+  expect(toSnapshot(state).disconnect_reasons).toEqual(reasons);
+  expect(toSnapshot(state).channel_frames).toEqual(channels);
+
+  const busier = [{ ...channels[0], frames: 240 }];
+  applyStateDelta(state, { version: 3, full: false, channel_frames: busier });
+  expect(toSnapshot(state).disconnect_reasons).toEqual(reasons);
+  expect(toSnapshot(state).channel_frames).toEqual(busier);
+
+  // A full delta without them means there are none
+  applyStateDelta(state, { version: 4, full: true });
+  expect(toSnapshot(state).disconnect_reasons).toEqual([]);
+  expect(toSnapshot(state).channel_frames).toEqual([]);
+});
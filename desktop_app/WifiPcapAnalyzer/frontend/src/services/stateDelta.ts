@@ -0,0 +1,96 @@
+// Applies the "state_delta" events sent by the Go StateManager (see state_manager/deltas.go).
+// A full delta replaces the state; otherwise it holds the BSSs and STAs added, only the
+// fields that changed on the others, and the keys of those removed, plus the disconnect
+// reasons and channel counters whenever they changed.
+import { state_manager } from '../../wailsjs/go/models';
+
+type EntityFields = { [field: string]: any };
+
+export interface StateDelta {
+  version: number;
+  full: boolean;
+  added_bsss?: EntityFields[];
+  updated_bsss?: EntityFields[];
+  removed_bsss?: string[];
+  added_stas?: EntityFields[];
+  updated_stas?: EntityFields[];
+  removed_stas?: string[];
+  disconnect_reasons?: state_manager.DisconnectReasonCount[];
+  channel_frames?: state_manager.ChannelFrameStats[];
+}
+
+// DeltaState holds the raw BSS and STA fields by BSSID and MAC address, and the latest
+// disconnect reasons and channel counters.
+export interface DeltaState {
+  bsss: Map<string, EntityFields>;
+  stas: Map<string, EntityFields>;
+  disconnectReasons: state_manager.DisconnectReasonCount[];
+  channelFrames: state_manager.ChannelFrameStats[];
+}
+
+export const newDeltaState = (): DeltaState => ({
+  bsss: new Map(),
+  stas: new Map(),
+  disconnectReasons: [],
+  channelFrames: [],
+});
+
+const applyEntities = (
+  entities: Map<string, EntityFields>,
+  key: string,
+  added: EntityFields[] = [],
+  updated: EntityFields[] = [],
+  removed: string[] = [],
+) => {
+  added.forEach(fields => entities.set(fields[key], { ...fields }));
+  updated.forEach(fields => {
+    const entity = entities.get(fields[key]);
+    if (entity) {
+      Object.assign(entity, fields);
+    } else {
+      entities.set(fields[key], { ...fields });
+    }
+  });
+  removed.forEach(k => entities.delete(k));
+};
+
+// applyStateDelta updates state in place.
+export const applyStateDelta = (state: DeltaState, delta: StateDelta) => {
+  if (delta.full) {
+    state.bsss.clear();
+    state.stas.clear();
+    state.disconnectReasons = [];
+    state.channelFrames = [];
+  }
+  applyEntities(state.bsss, 'bssid', delta.added_bsss, delta.updated_bsss, delta.removed_bsss);
+  applyEntities(state.stas, 'mac_address', delta.added_stas, delta.updated_stas, delta.removed_stas);
+  // Sent whole, and only when changed
+  if (delta.disconnect_reasons) {
+    state.disconnectReasons = delta.disconnect_reasons;
+  }
+  if (delta.channel_frames) {
+    state.channelFrames = delta.channel_frames;
+  }
+};
+
+// toSnapshot rebuilds the snapshot shape, with each BSS's associated STAs looked up by MAC.
+export const toSnapshot = (state: DeltaState): state_manager.Snapshot => {
+  const bsss = Array.from(state.bsss.values()).map(fields => {
+    const { associated_sta_macs, ...bss } = fields;
+    const associatedStas: { [mac: string]: any } = {};
+    (associated_sta_macs || []).forEach((mac: string) => {
+      const sta = state.stas.get(mac);
+      if (sta) {
+        associatedStas[mac] = sta;
+      }
+    });
+    return { ...bss, associated_stas: associatedStas };
+  });
+  const stas = Array.from(state.stas.values()).map(sta => ({ ...sta }));
+  return {
+    bsss,
+    stas,
+    disconnect_reasons: [...state.disconnectReasons],
+    channel_frames: [...state.channelFrames],
+  } as state_manager.Snapshot;
+};
@@ -24,6 +24,52 @@ export interface STA { // Renamed from Station to STA
   util: number;
   thrpt: number;
   bitrate?: number; // BitRate in Mbps
+  disconnect_history?: DisconnectEvent[];
+  btm_history?: BTMEvent[];
+  bad_fcs_frames?: number; // Frames from this STA that failed the FCS check
+  aggregation?: AggregationStats;
+  // Learned from unencrypted (or decrypted) data frames
+  ip_addresses?: string[];
+  hostname?: string;
+  hostname_source?: string; // "DHCP" or "mDNS"
+  device_names?: string[]; // mDNS service instances, SSDP SERVER, DHCP vendor class
+  dhcp_events?: DHCPEvent[];
+  recent_dns_queries?: string[];
+  recent_flows?: FlowRecord[]; // Least recently active first
+}
+
+// A DHCP message sent by or to a STA
+export interface DHCPEvent {
+  timestamp: number; // Unix milliseconds
+  message_type: string; // "Discover", "Offer", "Request", "Ack", "Nak", "Release", ...
+  ip?: string;
+  lease_seconds?: number;
+}
+
+// An IP flow of a STA, seen from the STA (local) side
+export interface FlowRecord {
+  protocol: string;
+  local_ip: string;
+  local_port?: number;
+  remote_ip: string;
+  remote_port?: number;
+  packets: number;
+  bytes: number;
+  first_seen: number; // Unix milliseconds
+  last_seen: number; // Unix milliseconds
+}
+
+// A-MPDU lengths and A-MSDU usage of a STA (A-MPDUs need the radiotap A-MPDU status field)
+export interface AggregationStats {
+  ampdus: number;
+  ampdu_mpdus: number;
+  avg_ampdu_length: number; // MPDUs per A-MPDU
+  max_ampdu_length: number;
+  data_frames: number;
+  amsdu_frames: number;
+  amsdu_subframes: number;
+  avg_amsdu_length: number; // MSDUs per decoded A-MSDU
+  amsdu_usage: number; // 0.0 - 1.0
 }
 
 export interface BSS {
@@ -32,6 +78,7 @@ export interface BSS {
   channel: number;
   bandwidth: string;
   security: string;
+  security_profile?: SecurityProfile;
   signal_strength: number | null; // Match backend field name
   last_seen: string; // Match backend field name (ISO string)
   ht_capabilities?: HTCabilities;
@@ -39,11 +86,123 @@ export interface BSS {
   associated_stas: { [mac: string]: STA }; // Match backend structure (map)
   // Performance Metrics
   channel_utilization_percent: number;
+  ap_reported_load?: APReportedLoad; // From the AP's BSS Load element
   total_throughput_mbps: number; // Combined UL/DL throughput for the BSS
   historical_channel_utilization: number[]; // Match backend data structure (array of numbers)
   historical_total_throughput: number[]; // Match backend data structure (array of numbers)
   util: number;
   thrpt: number;
+  disconnect_history?: DisconnectEvent[];
+  roaming?: RoamingSupport;
+  action_frames?: ActionFrameStats;
+  regulatory?: RegulatoryInfo;
+  bad_fcs_frames?: number; // Frames from this AP that failed the FCS check
+}
+
+// A Deauthentication or Disassociation between a STA and an AP
+export interface DisconnectEvent {
+  timestamp: number; // Unix milliseconds
+  sta: string;
+  bssid: string;
+  type: string; // "Deauthentication" or "Disassociation"
+  reason_code: number;
+  reason: string; // IEEE 802.11 Table 9-49 meaning
+  initiator: string; // "AP" or "STA"
+}
+
+// Load advertised in an AP's BSS Load element
+export interface APReportedLoad {
+  station_count: number;
+  channel_utilization: number; // Percentage (0.0 - 100.0)
+  available_admission_capacity: number;
+}
+
+// Country, power limits and country/channel consistency advertised by an AP
+export interface RegulatoryInfo {
+  country?: string;
+  environment?: string;
+  max_tx_power_dbm: number; // 0 if unknown
+  power_constraint_db: number;
+  transmit_power_envelopes?: { interpretation: string; category: string; values: number[] }[];
+  channel_mismatch: boolean;
+  mismatch_detail?: string;
+}
+
+// Action frames of a BSS by category
+export interface ActionFrameStats {
+  spectrum_management: number;
+  block_ack: number;
+  public: number;
+  radio_measurement: number;
+  sa_query: number;
+  protected_dual_of_public: number;
+  wnm: number;
+  other: number;
+  protected: number; // Encrypted robust action frames
+  channel_switches: number;
+}
+
+// 802.11k/v/r support advertised by an AP
+export interface RoamingSupport {
+  radio_measurement: boolean; // 802.11k
+  neighbor_report: boolean;
+  bss_transition: boolean; // 802.11v
+  fast_transition: boolean; // 802.11r
+  mobility_domain_id?: string;
+  ft_over_ds: boolean;
+}
+
+// An 802.11v BSS Transition Management Query/Request/Response
+export interface BTMEvent {
+  timestamp: number; // Unix milliseconds
+  bssid: string;
+  type: string; // "Query", "Request" or "Response"
+  from_ap: boolean;
+  dialog_token: number;
+  query_reason?: string;
+  disassociation_imminent?: boolean;
+  disassociation_timer?: number; // In TBTTs
+  status_code?: number;
+  status?: string;
+  target_bssid?: string;
+  candidates?: { bssid: string; channel: number; preference: number }[];
+}
+
+// Number of disconnect events per reason code (snapshot "disconnect_reasons")
+export interface DisconnectReasonCount {
+  reason_code: number;
+  reason: string;
+  count: number;
+  ap_initiated: number;
+  sta_initiated: number;
+}
+
+// Frame and bad-FCS counts per channel (snapshot "channel_frames")
+export interface ChannelFrameStats {
+  band?: string; // Empty when unknown
+  channel: number; // 0 when unknown
+  frames: number;
+  bad_fcs_frames: number;
+  phy_error_rate: number; // 0.0 - 1.0
+}
+
+export interface SecurityProfile {
+  protocol: string; // "RSN", "WPA" or "Open"
+  group_cipher?: string;
+  pairwise_ciphers?: string[];
+  akms?: string[];
+  group_mgmt_cipher?: string;
+  mfp_capable: boolean;
+  mfp_required: boolean;
+  pre_auth: boolean;
+  pmkid_count: number;
+  fast_transition: boolean;
+  suite_b: boolean;
+  wpa3_transition: boolean;
+  enterprise_transition: boolean;
+  wpa_mixed: boolean;
+  owe_transition: boolean;
+  label: string; // e.g. "WPA3-Personal transition"
 }
 
 interface HTCabilities {
@@ -70,6 +229,8 @@ export interface WebSocketData {
   data: {
     bsss: BSS[]; // Now includes performance metrics
     stas: STA[]; // Now includes performance metrics
+    disconnect_reasons?: DisconnectReasonCount[];
+    channel_frames?: ChannelFrameStats[];
   };
 }
 
@@ -1,6 +1,7 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {config} from '../models';
+import {frame_parser} from '../models';
 import {state_manager} from '../models';
 
 export function ConnectToAgent(arg1:string):Promise<void>;
@@ -11,10 +12,40 @@ export function GetAppConfig():Promise<config.AppConfig>;
 
 export function GetCurrentSnapshot():Promise<state_manager.Snapshot>;
 
+export function GetReplayStatus():Promise<frame_parser.ReplayStatus>;
+
 export function IsConnected():Promise<boolean>;
 
+export function PauseReplay():Promise<void>;
+
+export function PlayReplay():Promise<void>;
+
+export function ProcessPcapFile(arg1:string):Promise<string>;
+
+export function QueryState(arg1:state_manager.Query):Promise<state_manager.QueryResult>;
+
+export function ResyncState():Promise<void>;
+
+export function SeekReplay(arg1:number):Promise<void>;
+
 export function SelectPcapFileAndProcess():Promise<string>;
 
+export function SelectPcapFileAndReplay():Promise<string>;
+
+export function SelectPcapngExportFile():Promise<string>;
+
+export function SetReplaySpeed(arg1:number):Promise<void>;
+
 export function StartCapture(arg1:string,arg2:number,arg3:string,arg4:string):Promise<void>;
 
+export function StartPcapngExport(arg1:string):Promise<void>;
+
+export function StartReplay(arg1:string):Promise<void>;
+
+export function StepReplay():Promise<void>;
+
 export function StopCapture():Promise<void>;
+
+export function StopPcapngExport():Promise<void>;
+
+export function StopReplay():Promise<void>;
@@ -18,18 +18,78 @@ export function GetCurrentSnapshot() {
   return window['go']['main']['App']['GetCurrentSnapshot']();
 }
 
+export function GetReplayStatus() {
+  return window['go']['main']['App']['GetReplayStatus']();
+}
+
 export function IsConnected() {
   return window['go']['main']['App']['IsConnected']();
 }
 
+export function PauseReplay() {
+  return window['go']['main']['App']['PauseReplay']();
+}
+
+export function PlayReplay() {
+  return window['go']['main']['App']['PlayReplay']();
+}
+
+export function ProcessPcapFile(arg1) {
+  return window['go']['main']['App']['ProcessPcapFile'](arg1);
+}
+
+export function QueryState(arg1) {
+  return window['go']['main']['App']['QueryState'](arg1);
+}
+
+export function ResyncState() {
+  return window['go']['main']['App']['ResyncState']();
+}
+
+export function SeekReplay(arg1) {
+  return window['go']['main']['App']['SeekReplay'](arg1);
+}
+
 export function SelectPcapFileAndProcess() {
   return window['go']['main']['App']['SelectPcapFileAndProcess']();
 }
 
+export function SelectPcapFileAndReplay() {
+  return window['go']['main']['App']['SelectPcapFileAndReplay']();
+}
+
+export function SelectPcapngExportFile() {
+  return window['go']['main']['App']['SelectPcapngExportFile']();
+}
+
+export function SetReplaySpeed(arg1) {
+  return window['go']['main']['App']['SetReplaySpeed'](arg1);
+}
+
 export function StartCapture(arg1, arg2, arg3, arg4) {
   return window['go']['main']['App']['StartCapture'](arg1, arg2, arg3, arg4);
 }
 
+export function StartPcapngExport(arg1) {
+  return window['go']['main']['App']['StartPcapngExport'](arg1);
+}
+
+export function StartReplay(arg1) {
+  return window['go']['main']['App']['StartReplay'](arg1);
+}
+
+export function StepReplay() {
+  return window['go']['main']['App']['StepReplay']();
+}
+
 export function StopCapture() {
   return window['go']['main']['App']['StopCapture']();
 }
+
+export function StopPcapngExport() {
+  return window['go']['main']['App']['StopPcapngExport']();
+}
+
+export function StopReplay() {
+  return window['go']['main']['App']['StopReplay']();
+}
@@ -59,6 +59,35 @@ export namespace config {
 
 }
 
+export namespace frame_parser {
+	
+	export class ReplayStatus {
+	    state: string;
+	    speed: number;
+	    frame: number;
+	    total_frames: number;
+	    position_ms: number;
+	    start_ms: number;
+	    end_ms: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReplayStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = source["state"];
+	        this.speed = source["speed"];
+	        this.frame = source["frame"];
+	        this.total_frames = source["total_frames"];
+	        this.position_ms = source["position_ms"];
+	        this.start_ms = source["start_ms"];
+	        this.end_ms = source["end_ms"];
+	    }
+	}
+
+}
+
 export namespace state_manager {
 	
 	export class STAInfo {
@@ -279,10 +308,93 @@ export namespace state_manager {
 	        this.primary_channel = source["primary_channel"];
 	    }
 	}
+	export class ChannelFrameStats {
+	    band?: string;
+	    channel: number;
+	    frames: number;
+	    bad_fcs_frames: number;
+	    phy_error_rate: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChannelFrameStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.band = source["band"];
+	        this.channel = source["channel"];
+	        this.frames = source["frames"];
+	        this.bad_fcs_frames = source["bad_fcs_frames"];
+	        this.phy_error_rate = source["phy_error_rate"];
+	    }
+	}
+	export class DisconnectReasonCount {
+	    reason_code: number;
+	    reason: string;
+	    count: number;
+	    ap_initiated: number;
+	    sta_initiated: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DisconnectReasonCount(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.reason_code = source["reason_code"];
+	        this.reason = source["reason"];
+	        this.count = source["count"];
+	        this.ap_initiated = source["ap_initiated"];
+	        this.sta_initiated = source["sta_initiated"];
+	    }
+	}
+	export class SecurityProfile {
+	    protocol: string;
+	    group_cipher?: string;
+	    pairwise_ciphers?: string[];
+	    akms?: string[];
+	    group_mgmt_cipher?: string;
+	    mfp_capable: boolean;
+	    mfp_required: boolean;
+	    pre_auth: boolean;
+	    pmkid_count: number;
+	    fast_transition: boolean;
+	    suite_b: boolean;
+	    wpa3_transition: boolean;
+	    enterprise_transition: boolean;
+	    wpa_mixed: boolean;
+	    owe_transition: boolean;
+	    label: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SecurityProfile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.protocol = source["protocol"];
+	        this.group_cipher = source["group_cipher"];
+	        this.pairwise_ciphers = source["pairwise_ciphers"];
+	        this.akms = source["akms"];
+	        this.group_mgmt_cipher = source["group_mgmt_cipher"];
+	        this.mfp_capable = source["mfp_capable"];
+	        this.mfp_required = source["mfp_required"];
+	        this.pre_auth = source["pre_auth"];
+	        this.pmkid_count = source["pmkid_count"];
+	        this.fast_transition = source["fast_transition"];
+	        this.suite_b = source["suite_b"];
+	        this.wpa3_transition = source["wpa3_transition"];
+	        this.enterprise_transition = source["enterprise_transition"];
+	        this.wpa_mixed = source["wpa_mixed"];
+	        this.owe_transition = source["owe_transition"];
+	        this.label = source["label"];
+	    }
+	}
 	export class BSSInfo {
 	    bssid: string;
 	    ssid: string;
 	    channel: number;
+	    band?: string;
 	    bandwidth: string;
 	    security: string;
 	    signal_strength: number;
@@ -291,6 +403,7 @@ export namespace state_manager {
 	    vht_capabilities?: VHTCapabilities;
 	    he_capabilities?: HECapabilities;
 	    associated_stas: Record<string, STAInfo>;
+	    security_profile?: SecurityProfile;
 	    channel_utilization: number;
 	    throughput: number;
 	    historical_channel_utilization: number[];
@@ -308,6 +421,7 @@ export namespace state_manager {
 	        this.bssid = source["bssid"];
 	        this.ssid = source["ssid"];
 	        this.channel = source["channel"];
+	        this.band = source["band"];
 	        this.bandwidth = source["bandwidth"];
 	        this.security = source["security"];
 	        this.signal_strength = source["signal_strength"];
@@ -316,6 +430,7 @@ export namespace state_manager {
 	        this.vht_capabilities = this.convertValues(source["vht_capabilities"], VHTCapabilities);
 	        this.he_capabilities = this.convertValues(source["he_capabilities"], HECapabilities);
 	        this.associated_stas = this.convertValues(source["associated_stas"], STAInfo, true);
+	        this.security_profile = this.convertValues(source["security_profile"], SecurityProfile);
 	        this.channel_utilization = source["channel_utilization"];
 	        this.throughput = source["throughput"];
 	        this.historical_channel_utilization = source["historical_channel_utilization"];
@@ -346,9 +461,66 @@ export namespace state_manager {
 	
 	
 	
+	export class Query {
+	    entity: string;
+	    band?: string;
+	    channels?: number[];
+	    ssid_pattern?: string;
+	    security?: string[];
+	    min_rssi?: number;
+	    max_rssi?: number;
+	    last_seen_within_ms?: number;
+	    associated?: boolean;
+	    vendor?: string;
+	    sort_by?: string;
+	    descending?: boolean;
+	    offset?: number;
+	    limit?: number;
+	    fields?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Query(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.entity = source["entity"];
+	        this.band = source["band"];
+	        this.channels = source["channels"];
+	        this.ssid_pattern = source["ssid_pattern"];
+	        this.security = source["security"];
+	        this.min_rssi = source["min_rssi"];
+	        this.max_rssi = source["max_rssi"];
+	        this.last_seen_within_ms = source["last_seen_within_ms"];
+	        this.associated = source["associated"];
+	        this.vendor = source["vendor"];
+	        this.sort_by = source["sort_by"];
+	        this.descending = source["descending"];
+	        this.offset = source["offset"];
+	        this.limit = source["limit"];
+	        this.fields = source["fields"];
+	    }
+	}
+	export class QueryResult {
+	    total: number;
+	    items: Record<string, any>[];
+	
+	    static createFrom(source: any = {}) {
+	        return new QueryResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.total = source["total"];
+	        this.items = source["items"];
+	    }
+	}
+	
 	export class Snapshot {
 	    bsss: BSSInfo[];
 	    stas: STAInfo[];
+	    disconnect_reasons: DisconnectReasonCount[];
+	    channel_frames: ChannelFrameStats[];
 	
 	    static createFrom(source: any = {}) {
 	        return new Snapshot(source);
@@ -358,6 +530,8 @@ export namespace state_manager {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.bsss = this.convertValues(source["bsss"], BSSInfo);
 	        this.stas = this.convertValues(source["stas"], STAInfo);
+	        this.disconnect_reasons = this.convertValues(source["disconnect_reasons"], DisconnectReasonCount);
+	        this.channel_frames = this.convertValues(source["channel_frames"], ChannelFrameStats);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
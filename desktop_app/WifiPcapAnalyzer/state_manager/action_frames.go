@@ -0,0 +1,44 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+)
+
+// updateActionFrameStats counts Action frames by category for the confirmed BSS they belong to.
+// Retransmissions are not counted. Caller must hold sm.mutex.
+func (sm *StateManager) updateActionFrameStats(parsedInfo *frame_parser.ParsedFrameInfo) {
+	if parsedInfo.BSSID == nil || parsedInfo.RetryFlag {
+		return
+	}
+	bss, exists := sm.bssInfos[parsedInfo.BSSID.String()]
+	if !exists {
+		return
+	}
+	stats := &bss.ActionFrames
+	action := parsedInfo.Action
+	if action.Protected {
+		stats.Protected++
+		return
+	}
+	switch action.Category {
+	case frame_parser.ActionCategorySpectrumManagement:
+		stats.SpectrumManagement++
+	case frame_parser.ActionCategoryBlockAck:
+		stats.BlockAck++
+	case frame_parser.ActionCategoryPublic:
+		stats.Public++
+	case frame_parser.ActionCategoryRadioMeasurement:
+		stats.RadioMeasurement++
+	case frame_parser.ActionCategorySAQuery:
+		stats.SAQuery++
+	case frame_parser.ActionCategoryProtectedDualOfPublic:
+		stats.ProtectedDualOfPublic++
+	case frame_parser.ActionCategoryWNM:
+		stats.WNM++
+	default:
+		stats.Other++
+	}
+	if action.ChannelSwitch != nil {
+		stats.ChannelSwitches++
+	}
+}
@@ -0,0 +1,91 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+)
+
+// ampduGroup collects the MPDUs of the A-MPDU currently on the air. MPDUs of one PPDU are
+// captured back to back with the same radiotap reference number.
+type ampduGroup struct {
+	reference uint32
+	sta       string // STA the A-MPDU is accounted to, "" if none is known
+	mpdus     int
+}
+
+// trackAMPDU groups MPDUs into A-MPDUs by their reference number and reports whether the frame
+// continues an A-MPDU that is already being counted, i.e. it shares the PPDU (and NAV) of an
+// earlier frame. Caller must hold sm.mutex.
+func (sm *StateManager) trackAMPDU(parsedInfo *frame_parser.ParsedFrameInfo) bool {
+	status := parsedInfo.AMPDU
+	if status == nil {
+		sm.closeAMPDU()
+		return false
+	}
+
+	continued := sm.currentAMPDU != nil && sm.currentAMPDU.reference == status.Reference
+	if continued {
+		sm.currentAMPDU.mpdus++
+	} else {
+		sm.closeAMPDU()
+		sm.currentAMPDU = &ampduGroup{reference: status.Reference, sta: sm.aggregationSTA(parsedInfo), mpdus: 1}
+	}
+	if status.LastKnown && status.IsLast {
+		sm.closeAMPDU()
+	}
+	return continued
+}
+
+// closeAMPDU adds the A-MPDU being collected to its STA's statistics.
+func (sm *StateManager) closeAMPDU() {
+	group := sm.currentAMPDU
+	sm.currentAMPDU = nil
+	if group == nil {
+		return
+	}
+	sta, exists := sm.staInfos[group.sta]
+	if !exists {
+		return
+	}
+	sm.touchSTA(group.sta) // Closed by a later frame, which may not be addressed to the STA
+	stats := &sta.Aggregation
+	stats.AMPDUs++
+	stats.AMPDUMPDUs += int64(group.mpdus)
+	stats.AvgAMPDULength = float64(stats.AMPDUMPDUs) / float64(stats.AMPDUs)
+	if group.mpdus > stats.MaxAMPDULength {
+		stats.MaxAMPDULength = group.mpdus
+	}
+}
+
+// recordAMSDU updates A-MSDU usage of the STA a data frame is accounted to.
+// Caller must hold sm.mutex.
+func (sm *StateManager) recordAMSDU(parsedInfo *frame_parser.ParsedFrameInfo) {
+	sta, exists := sm.staInfos[sm.aggregationSTA(parsedInfo)]
+	if !exists {
+		return
+	}
+	stats := &sta.Aggregation
+	stats.DataFrames++
+	if parsedInfo.AMSDU {
+		stats.AMSDUFrames++
+		if n := len(parsedInfo.AMSDUSubframes); n > 0 {
+			stats.decodedAMSDUs++
+			stats.AMSDUSubframes += int64(n)
+			stats.AvgAMSDULength = float64(stats.AMSDUSubframes) / float64(stats.decodedAMSDUs)
+		}
+	}
+	stats.AMSDUUsage = float64(stats.AMSDUFrames) / float64(stats.DataFrames)
+}
+
+// aggregationSTA returns the STA end of a frame: the transmitter for uplink, the receiver for
+// downlink. It returns "" when neither is a confirmed STA.
+func (sm *StateManager) aggregationSTA(parsedInfo *frame_parser.ParsedFrameInfo) string {
+	for _, addr := range []string{macString(parsedInfo.TA), macString(parsedInfo.RA)} {
+		if _, isBSS := sm.bssInfos[addr]; isBSS {
+			continue
+		}
+		if _, exists := sm.staInfos[addr]; exists {
+			return addr
+		}
+	}
+	return ""
+}
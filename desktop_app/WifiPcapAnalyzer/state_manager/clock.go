@@ -0,0 +1,46 @@
+package state_manager
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the StateManager's notion of the current time. Confirmation windows, metric
+// windows, pruning and join attempt timeouts are all measured against it.
+type Clock interface {
+	Now() time.Time
+}
+
+// WallClock is the clock for live capture: the time of day.
+type WallClock struct{}
+
+func (WallClock) Now() time.Time { return time.Now() }
+
+// VirtualClock is the clock for replaying a capture file. It stands still between frames and
+// is advanced by the StateManager to the capture timestamp of every frame it processes, so
+// a file read in seconds is analysed as if it were captured live.
+type VirtualClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewVirtualClock returns a VirtualClock that reads the zero time until the first frame.
+func NewVirtualClock() *VirtualClock {
+	return &VirtualClock{}
+}
+
+func (c *VirtualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward to t. Capture timestamps can step back a little
+// (e.g. between interfaces of a pcapng file); the clock never does.
+func (c *VirtualClock) Advance(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
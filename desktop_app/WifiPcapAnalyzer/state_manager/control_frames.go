@@ -0,0 +1,138 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"net"
+)
+
+// updateControlFrameStats counts control frames per confirmed STA.
+// Unicast frames are attributed to the STA found at TA or RA; Trigger and NDPA
+// frames address STAs by AID, which is resolved within the transmitting BSS.
+// Caller must hold sm.mutex.
+func (sm *StateManager) updateControlFrameStats(parsedInfo *frame_parser.ParsedFrameInfo) {
+	ctrl := parsedInfo.Control
+	stas := sm.controlFrameSTAs(parsedInfo.TA, parsedInfo.RA)
+
+	switch ctrl.Kind {
+	case "RTS":
+		for _, sta := range stas {
+			sta.ControlFrames.RTS++
+		}
+	case "CTS":
+		for _, sta := range stas {
+			sta.ControlFrames.CTS++
+		}
+	case "ACK":
+		for _, sta := range stas {
+			sta.ControlFrames.ACK++
+		}
+	case "BlockAckReq":
+		for _, sta := range stas {
+			sta.ControlFrames.BlockAckReq++
+		}
+	case "BlockAck":
+		if ctrl.BlockAck == nil {
+			return
+		}
+		if ctrl.BlockAck.Variant == "Multi-STA" {
+			// Multi-STA BlockAck is sent by the AP; every record names a STA by AID.
+			for _, rec := range ctrl.BlockAck.Records {
+				if sta := sm.staByAID(parsedInfo.TA, rec.AID); sta != nil {
+					sta.ControlFrames.BlockAck++
+					addBlockAckRecord(&sta.ControlFrames, rec)
+				}
+			}
+			return
+		}
+		for _, sta := range stas {
+			sta.ControlFrames.BlockAck++
+			for _, rec := range ctrl.BlockAck.Records {
+				addBlockAckRecord(&sta.ControlFrames, rec)
+			}
+		}
+	case "Trigger":
+		if ctrl.Trigger == nil {
+			return
+		}
+		ofdma := len(ctrl.Trigger.Users) > 1
+		for _, u := range ctrl.Trigger.Users {
+			if u.RUTones > 0 && u.RUTones < 242 {
+				ofdma = true
+			}
+		}
+		if bss, ok := sm.bssInfos[macString(parsedInfo.TA)]; ok {
+			bss.TriggerFrames++
+			if ofdma {
+				bss.OFDMAObserved = true
+			}
+		}
+		for _, u := range ctrl.Trigger.Users {
+			if sta := sm.staByAID(parsedInfo.TA, u.AID); sta != nil {
+				sta.ControlFrames.Trigger++
+				sta.ControlFrames.LastRU = u.RU
+				if ofdma {
+					sta.ControlFrames.OFDMATriggers++
+				}
+			}
+		}
+	case "NDPA":
+		if ctrl.NDPA == nil {
+			return
+		}
+		for _, target := range ctrl.NDPA.Targets {
+			if sta := sm.staByAID(parsedInfo.TA, target.AID); sta != nil {
+				sta.ControlFrames.NDPA++
+			}
+		}
+	}
+}
+
+// addBlockAckRecord adds the acknowledged/missing MPDUs of one BlockAck record and refreshes the loss estimate.
+// Repeated BlockAcks for the same window are counted again, so the totals are an estimate.
+func addBlockAckRecord(stats *ControlFrameStats, rec frame_parser.BlockAckRecord) {
+	stats.BAAckedMPDUs += int64(rec.AckedMPDUs)
+	stats.BAMissingMPDUs += int64(rec.MissingMPDUs)
+	if total := stats.BAAckedMPDUs + stats.BAMissingMPDUs; total > 0 {
+		stats.BALossPercent = float64(stats.BAMissingMPDUs) / float64(total) * 100
+	}
+}
+
+// controlFrameSTAs returns the confirmed STAs among the transmitter and receiver of a control frame.
+func (sm *StateManager) controlFrameSTAs(ta, ra net.HardwareAddr) []*STAInfo {
+	var stas []*STAInfo
+	for _, addr := range []net.HardwareAddr{ta, ra} {
+		if addr == nil || !isUnicastMAC(addr) {
+			continue
+		}
+		if sta, ok := sm.staInfos[addr.String()]; ok && (len(stas) == 0 || stas[0] != sta) {
+			stas = append(stas, sta)
+		}
+	}
+	return stas
+}
+
+// staByAID finds the STA with the given AID among the STAs associated with bssid, and marks
+// it as changed, as callers update it although the frame is not addressed to it.
+func (sm *StateManager) staByAID(bssid net.HardwareAddr, aid uint16) *STAInfo {
+	if aid == 0 {
+		return nil
+	}
+	bss, ok := sm.bssInfos[macString(bssid)]
+	if !ok {
+		return nil
+	}
+	for _, sta := range bss.AssociatedSTAs {
+		if sta.AID == aid {
+			sm.touchSTA(sta.MACAddress)
+			return sta
+		}
+	}
+	return nil
+}
+
+func macString(mac net.HardwareAddr) string {
+	if mac == nil {
+		return ""
+	}
+	return mac.String()
+}
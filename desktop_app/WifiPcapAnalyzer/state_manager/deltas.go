@@ -0,0 +1,361 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"bytes"
+	"encoding/json"
+	"log"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Deltas let clients follow the state without a full snapshot every time. Entities that may
+// have changed are marked dirty while frames are processed. Refreshing re-encodes only the
+// dirty entities and gives each field whose encoding changed a new state version, so a
+// client that has version v gets the entities added, the fields updated and the entities
+// removed since v.
+
+// deltaRemovalVersions is how many versions removals are remembered for. Clients that are
+// further behind get a full resync.
+const deltaRemovalVersions = 1024
+
+// EntityFields is a BSS or STA as its JSON fields. It always holds the key field ("bssid" or
+// "mac_address"). A BSS lists its associated STAs by MAC, as "associated_sta_macs", rather
+// than embedding them as snapshots do.
+type EntityFields map[string]json.RawMessage
+
+// StateDelta brings a client from one state version to the next. A full delta replaces the
+// client's state: every entity is in the Added lists, and the disconnect reasons and
+// channel counters are set. Otherwise Updated entities hold only their fields that changed,
+// and the disconnect reasons and channel counters are set only when they changed.
+type StateDelta struct {
+	Version           uint64          `json:"version"`
+	Full              bool            `json:"full"`
+	AddedBSSs         []EntityFields  `json:"added_bsss,omitempty"`
+	UpdatedBSSs       []EntityFields  `json:"updated_bsss,omitempty"`
+	RemovedBSSs       []string        `json:"removed_bsss,omitempty"`
+	AddedSTAs         []EntityFields  `json:"added_stas,omitempty"`
+	UpdatedSTAs       []EntityFields  `json:"updated_stas,omitempty"`
+	RemovedSTAs       []string        `json:"removed_stas,omitempty"`
+	DisconnectReasons json.RawMessage `json:"disconnect_reasons,omitempty"` // []DisconnectReasonCount
+	ChannelFrames     json.RawMessage `json:"channel_frames,omitempty"`     // []ChannelFrameStats
+}
+
+// Empty reports whether the delta changes nothing.
+func (d *StateDelta) Empty() bool {
+	return !d.Full && len(d.AddedBSSs) == 0 && len(d.UpdatedBSSs) == 0 && len(d.RemovedBSSs) == 0 &&
+		len(d.AddedSTAs) == 0 && len(d.UpdatedSTAs) == 0 && len(d.RemovedSTAs) == 0 &&
+		d.DisconnectReasons == nil && d.ChannelFrames == nil
+}
+
+// trackedField is the latest encoding of a field and the version it changed at.
+type trackedField struct {
+	value   json.RawMessage
+	version uint64
+}
+
+type trackedEntity struct {
+	added   uint64 // Version the entity appeared at
+	changed uint64 // Latest version any of its fields changed at
+	fields  map[string]trackedField
+}
+
+// entityTracker keeps the versioned fields of one kind of entity.
+type entityTracker struct {
+	keyField string
+	entities map[string]*trackedEntity
+	dirty    map[string]bool
+	removed  map[string]uint64 // Version each removed entity was removed at
+}
+
+func newEntityTracker(keyField string) entityTracker {
+	return entityTracker{
+		keyField: keyField,
+		entities: make(map[string]*trackedEntity),
+		dirty:    make(map[string]bool),
+		removed:  make(map[string]uint64),
+	}
+}
+
+// refresh re-encodes the dirty entities at version. encode returns nil for an entity that no
+// longer exists. It reports whether anything changed.
+func (t *entityTracker) refresh(version uint64, encode func(key string) EntityFields) bool {
+	changed := false
+	for key := range t.dirty {
+		delete(t.dirty, key)
+		fields := encode(key)
+		entity, tracked := t.entities[key]
+		if fields == nil {
+			if tracked {
+				delete(t.entities, key)
+				t.removed[key] = version
+				changed = true
+			}
+			continue
+		}
+		if !tracked {
+			entity = &trackedEntity{added: version, fields: make(map[string]trackedField, len(fields))}
+			t.entities[key] = entity
+			delete(t.removed, key)
+		}
+		for name, value := range fields {
+			if old, ok := entity.fields[name]; ok && bytes.Equal(old.value, value) {
+				continue
+			}
+			entity.fields[name] = trackedField{value: value, version: version}
+			entity.changed = version
+			changed = true
+		}
+		// Fields left out when empty (omitempty) are sent as null once they go
+		for name, old := range entity.fields {
+			if _, ok := fields[name]; !ok && !bytes.Equal(old.value, jsonNull) {
+				entity.fields[name] = trackedField{value: jsonNull, version: version}
+				entity.changed = version
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+var jsonNull = json.RawMessage("null")
+
+// collect returns the entities added, the fields updated and the entities removed after
+// version since, or every entity when full.
+func (t *entityTracker) collect(since uint64, full bool) (added, updated []EntityFields, removed []string) {
+	for _, entity := range t.entities {
+		switch {
+		case full || entity.added > since:
+			fields := make(EntityFields, len(entity.fields))
+			for name, f := range entity.fields {
+				fields[name] = f.value
+			}
+			added = append(added, fields)
+		case entity.changed > since:
+			fields := EntityFields{t.keyField: entity.fields[t.keyField].value}
+			for name, f := range entity.fields {
+				if f.version > since {
+					fields[name] = f.value
+				}
+			}
+			updated = append(updated, fields)
+		}
+	}
+	if !full {
+		for key, version := range t.removed {
+			if version > since {
+				removed = append(removed, key)
+			}
+		}
+		sort.Strings(removed)
+	}
+	return added, updated, removed
+}
+
+// forgetRemovals drops the removals before floor.
+func (t *entityTracker) forgetRemovals(floor uint64) {
+	for key, version := range t.removed {
+		if version < floor {
+			delete(t.removed, key)
+		}
+	}
+}
+
+// deltaTracker holds the versioned state deltas are made from.
+type deltaTracker struct {
+	version           uint64 // Current state version
+	floor             uint64 // Deltas since versions before this must be full
+	bsss              entityTracker
+	stas              entityTracker
+	disconnectReasons trackedField
+	channelFrames     trackedField
+}
+
+func newDeltaTracker() *deltaTracker {
+	// Versions start at 1, so that 0 always asks for the full state
+	return &deltaTracker{version: 1, floor: 1, bsss: newEntityTracker("bssid"), stas: newEntityTracker("mac_address")}
+}
+
+// reset forgets every entity, and makes every client resync.
+func (d *deltaTracker) reset() {
+	d.version++
+	*d = deltaTracker{version: d.version, floor: d.version, bsss: newEntityTracker("bssid"), stas: newEntityTracker("mac_address")}
+}
+
+func (d *deltaTracker) since(version uint64) StateDelta {
+	full := version < d.floor || version > d.version
+	delta := StateDelta{Version: d.version, Full: full}
+	delta.AddedBSSs, delta.UpdatedBSSs, delta.RemovedBSSs = d.bsss.collect(version, full)
+	delta.AddedSTAs, delta.UpdatedSTAs, delta.RemovedSTAs = d.stas.collect(version, full)
+	if full || d.disconnectReasons.version > version {
+		delta.DisconnectReasons = d.disconnectReasons.value
+	}
+	if full || d.channelFrames.version > version {
+		delta.ChannelFrames = d.channelFrames.value
+	}
+	return delta
+}
+
+// touchBSS marks a BSS as possibly changed, added or removed. Keys that are neither in the
+// state nor known to clients are ignored, so the dirty set stays bounded without clients.
+// Caller must hold sm.mutex.
+func (sm *StateManager) touchBSS(bssid string) {
+	if _, exists := sm.bssInfos[bssid]; exists {
+		sm.deltas.bsss.dirty[bssid] = true
+	} else if _, tracked := sm.deltas.bsss.entities[bssid]; tracked {
+		sm.deltas.bsss.dirty[bssid] = true
+	}
+}
+
+// touchSTA is touchBSS for a STA. Caller must hold sm.mutex.
+func (sm *StateManager) touchSTA(mac string) {
+	if _, exists := sm.staInfos[mac]; exists {
+		sm.deltas.stas.dirty[mac] = true
+	} else if _, tracked := sm.deltas.stas.entities[mac]; tracked {
+		sm.deltas.stas.dirty[mac] = true
+	}
+}
+
+// touchFrame marks the BSSs and STAs a frame is addressed from, to or about.
+// Caller must hold sm.mutex.
+func (sm *StateManager) touchFrame(parsedInfo *frame_parser.ParsedFrameInfo) {
+	for _, addr := range []net.HardwareAddr{parsedInfo.BSSID, parsedInfo.SA, parsedInfo.DA, parsedInfo.TA, parsedInfo.RA} {
+		if len(addr) == 0 {
+			continue
+		}
+		key := addr.String()
+		sm.touchBSS(key)
+		sm.touchSTA(key)
+	}
+}
+
+// refreshDeltas encodes what changed since the last refresh as the next version.
+// Caller must hold sm.mutex.
+func (sm *StateManager) refreshDeltas() {
+	d := sm.deltas
+	next := d.version + 1
+	now := sm.clock.Now()
+	changed := d.bsss.refresh(next, func(bssid string) EntityFields {
+		bss, exists := sm.bssInfos[bssid]
+		if !exists || bss == nil {
+			return nil
+		}
+		return encodeBSSFields(bss)
+	})
+	if d.stas.refresh(next, func(mac string) EntityFields {
+		sta, exists := sm.staInfos[mac]
+		if !exists || sta == nil {
+			return nil
+		}
+		return encodeFields(sm.copySTA(mac, sta, now))
+	}) {
+		changed = true
+	}
+	if refreshField(&d.disconnectReasons, sm.disconnectReasonsSnapshot(), next) {
+		changed = true
+	}
+	if refreshField(&d.channelFrames, sm.channelFramesSnapshot(), next) {
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	d.version = next
+	if d.version > deltaRemovalVersions && d.floor < d.version-deltaRemovalVersions {
+		d.floor = d.version - deltaRemovalVersions
+		d.bsss.forgetRemovals(d.floor)
+		d.stas.forgetRemovals(d.floor)
+	}
+}
+
+func refreshField(f *trackedField, v interface{}, version uint64) bool {
+	value, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("WARN_DELTA: Error encoding state field: %v", err)
+		return false
+	}
+	if f.version != 0 && bytes.Equal(f.value, value) {
+		return false
+	}
+	*f = trackedField{value: value, version: version}
+	return true
+}
+
+// encodeBSSFields encodes a BSS without its associated STAs, which are listed by MAC.
+func encodeBSSFields(bss *BSSInfo) EntityFields {
+	bssCopy := *bss
+	bssCopy.AssociatedSTAs = nil
+	fields := encodeFields(&bssCopy)
+	if fields == nil {
+		return nil
+	}
+	delete(fields, "associated_stas")
+	macs := make([]string, 0, len(bss.AssociatedSTAs))
+	for mac := range bss.AssociatedSTAs {
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+	fields["associated_sta_macs"], _ = json.Marshal(macs)
+	return fields
+}
+
+func encodeFields(v interface{}) EntityFields {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("WARN_DELTA: Error encoding entity: %v", err)
+		return nil
+	}
+	var fields EntityFields
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		log.Printf("WARN_DELTA: Error splitting entity fields: %v", err)
+		return nil
+	}
+	return fields
+}
+
+// DeltaSince returns the delta from state version to the current state. Version 0, or a
+// version too old to have its removals remembered, gets the full state.
+func (sm *StateManager) DeltaSince(version uint64) StateDelta {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.refreshDeltas()
+	return sm.deltas.since(version)
+}
+
+// DeltaSubscriber follows the state for one client: each delta starts from the version the
+// previous one brought the client to. It is not safe for concurrent use, except Resync.
+type DeltaSubscriber struct {
+	sm             *StateManager
+	version        uint64
+	resyncInterval time.Duration
+	lastFull       time.Time
+	resync         atomic.Bool
+}
+
+// NewDeltaSubscriber returns a subscriber whose first delta is full. A positive
+// resyncInterval also sends a full delta that often, in case a client dropped a delta.
+func (sm *StateManager) NewDeltaSubscriber(resyncInterval time.Duration) *DeltaSubscriber {
+	return &DeltaSubscriber{sm: sm, resyncInterval: resyncInterval}
+}
+
+// Next returns the delta that brings the client up to date. ok is false when there is
+// nothing to send.
+func (s *DeltaSubscriber) Next() (delta StateDelta, ok bool) {
+	since := s.version
+	if s.resync.Swap(false) || (s.resyncInterval > 0 && time.Since(s.lastFull) >= s.resyncInterval) {
+		since = 0
+	}
+	delta = s.sm.DeltaSince(since)
+	if delta.Full {
+		s.lastFull = time.Now()
+	}
+	s.version = delta.Version
+	return delta, !delta.Empty()
+}
+
+// Resync makes the next delta full, for a client that lost its state or missed a delta.
+func (s *DeltaSubscriber) Resync() {
+	s.resync.Store(true)
+}
@@ -0,0 +1,171 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fieldNames(fields EntityFields) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestDeltaSince_AddedUpdatedRemoved(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+	bssid, _ := net.ParseMAC("02:00:00:00:00:aa")
+	staMAC, _ := net.ParseMAC("02:00:00:00:00:01")
+	otherMAC, _ := net.ParseMAC("02:00:00:00:00:02")
+	seen := time.Now()
+	sm.UpdateBSS(bssid, "lab", 36, -45, "WPA2-Personal", seen)
+	sm.UpdateSTA(staMAC, bssid, -51, seen)
+	sm.UpdateSTA(otherMAC, bssid, -60, seen)
+
+	full := sm.DeltaSince(0)
+	assert.True(t, full.Full)
+	require.Len(t, full.AddedBSSs, 1)
+	assert.Len(t, full.AddedSTAs, 2)
+	assert.NotContains(t, full.AddedBSSs[0], "associated_stas", "STAs are not embedded in their BSS")
+	assert.JSONEq(t, `["02:00:00:00:00:01","02:00:00:00:00:02"]`, string(full.AddedBSSs[0]["associated_sta_macs"]))
+	assert.NotNil(t, full.ChannelFrames)
+
+	unchanged := sm.DeltaSince(full.Version)
+	assert.True(t, unchanged.Empty())
+	assert.Equal(t, full.Version, unchanged.Version)
+
+	// Only the fields that changed are sent
+	sm.UpdateSTA(staMAC, bssid, -48, seen)
+	delta := sm.DeltaSince(full.Version)
+	assert.False(t, delta.Full)
+	assert.Greater(t, delta.Version, full.Version)
+	assert.Empty(t, delta.UpdatedBSSs)
+	require.Len(t, delta.UpdatedSTAs, 1)
+	assert.ElementsMatch(t, []string{"mac_address", "signal_strength"}, fieldNames(delta.UpdatedSTAs[0]))
+	assert.JSONEq(t, `-48`, string(delta.UpdatedSTAs[0]["signal_strength"]))
+	assert.Nil(t, delta.ChannelFrames, "unchanged channel counters are left out")
+
+	// A frame from a new BSS adds it once confirmed; pruning the STA removes it from its BSS
+	sm.mutex.Lock()
+	for _, sta := range sm.staInfos {
+		if sta.MACAddress == otherMAC.String() {
+			sta.LastSeen = seen.Add(-time.Hour).UnixMilli()
+		}
+	}
+	sm.mutex.Unlock()
+	sm.PruneOldEntries(2 * time.Minute)
+	delta = sm.DeltaSince(delta.Version)
+	assert.Equal(t, []string{"02:00:00:00:00:02"}, delta.RemovedSTAs)
+	require.Len(t, delta.UpdatedBSSs, 1)
+	assert.JSONEq(t, `["02:00:00:00:00:01"]`, string(delta.UpdatedBSSs[0]["associated_sta_macs"]))
+
+	// Deltas since older versions add up the changes in between
+	fromStart := sm.DeltaSince(full.Version)
+	assert.Equal(t, []string{"02:00:00:00:00:02"}, fromStart.RemovedSTAs)
+	require.Len(t, fromStart.UpdatedSTAs, 1)
+	assert.Contains(t, fromStart.UpdatedSTAs[0], "signal_strength")
+
+	// Clearing the state makes every client resync
+	sm.ClearState()
+	cleared := sm.DeltaSince(delta.Version)
+	assert.True(t, cleared.Full)
+	assert.Empty(t, cleared.AddedBSSs)
+	assert.Empty(t, cleared.RemovedSTAs)
+}
+
+func TestDeltaSince_FramesAndMetrics(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+	sm.SetClock(NewVirtualClock())
+	bssid, _ := net.ParseMAC("02:00:00:00:00:aa")
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{Timestamp: start, FrameType: "Data", WlanFcType: 2}) // Starts the clock
+	sm.UpdateBSS(bssid, "lab", 6, -40, "", start)
+	version := sm.DeltaSince(0).Version
+
+	// Frames mark the BSS they belong to
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{Timestamp: start.Add(100 * time.Millisecond), FrameType: "MgmtAction",
+		WlanFcType: 0, BSSID: bssid})
+	delta := sm.DeltaSince(version)
+	require.Len(t, delta.UpdatedBSSs, 1)
+	assert.Contains(t, delta.UpdatedBSSs[0], "last_seen")
+	assert.NotContains(t, delta.UpdatedBSSs[0], "historical_throughput")
+	version = delta.Version
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{Timestamp: start.Add(200 * time.Millisecond), FrameType: "Data",
+		WlanFcType: 2, BSSID: bssid, TransportPayloadLength: 1000})
+
+	// Closing a metrics window changes the history of a BSS that saw traffic
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{Timestamp: start.Add(1500 * time.Millisecond), FrameType: "Data",
+		WlanFcType: 2, BSSID: net.HardwareAddr{2, 0, 0, 0, 0, 0xbb}})
+	delta = sm.DeltaSince(version)
+	require.Len(t, delta.UpdatedBSSs, 1)
+	var history []int64
+	require.NoError(t, json.Unmarshal(delta.UpdatedBSSs[0]["historical_throughput"], &history))
+	assert.Equal(t, []int64{8000}, history)
+
+	// Once an idle BSS's history is flat, closing a window no longer marks it
+	for i := 2; i <= 8; i++ {
+		sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{Timestamp: start.Add(time.Duration(i)*time.Second + 500*time.Millisecond),
+			FrameType: "Data", WlanFcType: 2})
+	}
+	delta = sm.DeltaSince(delta.Version)
+	require.Len(t, delta.UpdatedBSSs, 1)
+	require.NoError(t, json.Unmarshal(delta.UpdatedBSSs[0]["historical_throughput"], &history))
+	assert.Equal(t, []int64{0, 0, 0, 0, 0}, history)
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{Timestamp: start.Add(9500 * time.Millisecond), FrameType: "Data", WlanFcType: 2})
+	assert.Empty(t, sm.DeltaSince(delta.Version).UpdatedBSSs)
+}
+
+func TestAppendHistory(t *testing.T) {
+	history, changed := appendHistory([]int64{1, 2}, 3, 3)
+	assert.True(t, changed)
+	assert.Equal(t, []int64{1, 2, 3}, history)
+
+	history, changed = appendHistory(history, 0, 3)
+	assert.True(t, changed)
+	assert.Equal(t, []int64{2, 3, 0}, history)
+
+	history, changed = appendHistory([]int64{0, 0}, 0, 3)
+	assert.True(t, changed, "a history that is not full yet still grows")
+	assert.Equal(t, []int64{0, 0, 0}, history)
+
+	history, changed = appendHistory(history, 0, 3)
+	assert.False(t, changed)
+	assert.Equal(t, []int64{0, 0, 0}, history)
+}
+
+func TestDeltaSubscriber(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+	bssid, _ := net.ParseMAC("02:00:00:00:00:aa")
+	sm.UpdateBSS(bssid, "lab", 6, -40, "", time.Now())
+
+	sub := sm.NewDeltaSubscriber(time.Hour)
+	delta, ok := sub.Next()
+	assert.True(t, ok)
+	assert.True(t, delta.Full)
+	_, ok = sub.Next()
+	assert.False(t, ok, "nothing changed")
+
+	sm.UpdateBSS(bssid, "", 0, -42, "", time.Now())
+	delta, ok = sub.Next()
+	assert.True(t, ok)
+	assert.False(t, delta.Full)
+	assert.Len(t, delta.UpdatedBSSs, 1)
+
+	sub.Resync()
+	delta, ok = sub.Next()
+	assert.True(t, ok)
+	assert.True(t, delta.Full)
+	assert.Len(t, delta.AddedBSSs, 1)
+
+	sub = sm.NewDeltaSubscriber(time.Nanosecond)
+	sub.Next()
+	delta, _ = sub.Next()
+	assert.True(t, delta.Full, "resynced every interval")
+}
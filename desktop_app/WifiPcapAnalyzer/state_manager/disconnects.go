@@ -0,0 +1,92 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"sort"
+	"time"
+)
+
+const (
+	maxDisconnectEventsPerSTA = 20  // Oldest events are dropped beyond this
+	maxDisconnectEventsPerBSS = 100 // Oldest events are dropped beyond this
+)
+
+// recordDisconnect adds a Deauthentication/Disassociation frame to the disconnect history of
+// the STA and BSS (when confirmed) and to the reason code breakdown.
+// Caller must hold sm.mutex.
+func (sm *StateManager) recordDisconnect(parsedInfo *frame_parser.ParsedFrameInfo, now time.Time) {
+	if parsedInfo.BSSID == nil || parsedInfo.TA == nil || parsedInfo.RA == nil {
+		return
+	}
+	if parsedInfo.Reason == "" || parsedInfo.RetryFlag {
+		return // Protected frame (reason encrypted) or retransmission
+	}
+	bssid := parsedInfo.BSSID.String()
+	event := DisconnectEvent{
+		BSSID:      bssid,
+		Type:       "Deauthentication",
+		ReasonCode: parsedInfo.ReasonCode,
+		Reason:     parsedInfo.Reason,
+	}
+	if parsedInfo.FrameType == "MgmtDisassociation" {
+		event.Type = "Disassociation"
+	}
+	switch bssid {
+	case parsedInfo.TA.String():
+		event.Initiator = "AP"
+		event.STA = parsedInfo.RA.String()
+	case parsedInfo.RA.String():
+		event.Initiator = "STA"
+		event.STA = parsedInfo.TA.String()
+	default:
+		return
+	}
+	eventTime := parsedInfo.Timestamp
+	if eventTime.IsZero() {
+		eventTime = now
+	}
+	event.Timestamp = eventTime.UnixMilli()
+
+	if sta, exists := sm.staInfos[event.STA]; exists {
+		sta.DisconnectHistory = appendDisconnectEvent(sta.DisconnectHistory, event, maxDisconnectEventsPerSTA)
+	}
+	if bss, exists := sm.bssInfos[bssid]; exists {
+		bss.DisconnectHistory = appendDisconnectEvent(bss.DisconnectHistory, event, maxDisconnectEventsPerBSS)
+	}
+
+	count, exists := sm.disconnectReasons[event.ReasonCode]
+	if !exists {
+		count = &DisconnectReasonCount{ReasonCode: event.ReasonCode, Reason: event.Reason}
+		sm.disconnectReasons[event.ReasonCode] = count
+	}
+	count.Count++
+	if event.Initiator == "AP" {
+		count.APInitiated++
+	} else {
+		count.STAInitiated++
+	}
+}
+
+func appendDisconnectEvent(history []DisconnectEvent, event DisconnectEvent, limit int) []DisconnectEvent {
+	history = append(history, event)
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history
+}
+
+// disconnectReasonsSnapshot returns the reason code breakdown, most frequent first.
+// Caller must hold sm.mutex (read lock is sufficient).
+func (sm *StateManager) disconnectReasonsSnapshot() []DisconnectReasonCount {
+	reasons := make([]DisconnectReasonCount, 0, len(sm.disconnectReasons))
+	for _, count := range sm.disconnectReasons {
+		reasons = append(reasons, *count)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Count != reasons[j].Count {
+			return reasons[i].Count > reasons[j].Count
+		}
+		return reasons[i].ReasonCode < reasons[j].ReasonCode
+	})
+	return reasons
+}
@@ -0,0 +1,204 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"net"
+	"time"
+)
+
+const (
+	maxIPAddressesPerSTA = 8
+	maxDeviceNamesPerSTA = 10
+	maxDHCPEventsPerSTA  = 20 // Oldest events are dropped beyond this
+	maxDNSQueriesPerSTA  = 20 // Oldest names are dropped beyond this
+	maxFlowsPerSTA       = 20 // Least recently active flows are dropped beyond this
+)
+
+// updateHostInfo learns IP addresses, host and device names, DHCP lease events, DNS queries
+// and flows of STAs from the upper layers of data frames. Only confirmed STAs are updated.
+// Caller must hold sm.mutex.
+func (sm *StateManager) updateHostInfo(parsedInfo *frame_parser.ParsedFrameInfo, now time.Time) {
+	eventTime := parsedInfo.Timestamp
+	if eventTime.IsZero() {
+		eventTime = now
+	}
+
+	if dhcp := parsedInfo.DHCP; dhcp != nil {
+		if sta := sm.hostSTA(dhcp.ClientMAC); sta != nil {
+			sm.recordDHCP(sta, dhcp, eventTime)
+		}
+	}
+	if arp := parsedInfo.ARP; arp != nil {
+		if sta := sm.hostSTA(arp.SenderMAC); sta != nil {
+			sta.IPAddresses = addHostIP(sta.IPAddresses, arp.SenderIP)
+		}
+	}
+
+	// The STA end of the frame: the source for uplink, the destination for downlink.
+	local, outbound := sm.hostSTA(parsedInfo.SA), true
+	if local == nil {
+		local, outbound = sm.hostSTA(parsedInfo.DA), false
+	}
+	if local == nil {
+		return
+	}
+	if flow := parsedInfo.Flow; flow != nil {
+		if outbound {
+			local.IPAddresses = addHostIP(local.IPAddresses, flow.SrcIP)
+		} else {
+			local.IPAddresses = addHostIP(local.IPAddresses, flow.DstIP)
+		}
+		recordFlow(local, flow, outbound, parsedInfo.TransportPayloadLength, eventTime)
+	}
+	if !outbound {
+		return // Names below are announced or asked for by the sender
+	}
+	if dns := parsedInfo.DNS; dns != nil {
+		if !dns.Response {
+			for _, name := range dns.Queries {
+				local.RecentDNSQueries = appendRecentName(local.RecentDNSQueries, name, maxDNSQueriesPerSTA)
+			}
+		}
+		if dns.MDNS && dns.Response {
+			if len(dns.Hostnames) > 0 && local.HostnameSource != "DHCP" {
+				local.Hostname = dns.Hostnames[0]
+				local.HostnameSource = "mDNS"
+			}
+			for _, instance := range dns.Instances {
+				local.DeviceNames = addDeviceName(local.DeviceNames, instance)
+			}
+		}
+	}
+	if ssdp := parsedInfo.SSDP; ssdp != nil && ssdp.Server != "" {
+		local.DeviceNames = addDeviceName(local.DeviceNames, ssdp.Server)
+	}
+}
+
+// hostSTA returns the confirmed STA with the given MAC address, or nil. APs are not STAs here.
+func (sm *StateManager) hostSTA(mac net.HardwareAddr) *STAInfo {
+	if !isUnicastMAC(mac) {
+		return nil
+	}
+	macStr := mac.String()
+	if _, isBSS := sm.bssInfos[macStr]; isBSS {
+		return nil
+	}
+	return sm.staInfos[macStr]
+}
+
+func (sm *StateManager) recordDHCP(sta *STAInfo, dhcp *frame_parser.DHCPInfo, eventTime time.Time) {
+	if dhcp.Hostname != "" {
+		sta.Hostname = dhcp.Hostname
+		sta.HostnameSource = "DHCP"
+	}
+	if dhcp.VendorClass != "" {
+		sta.DeviceNames = addDeviceName(sta.DeviceNames, dhcp.VendorClass)
+	}
+	if dhcp.MessageType == "" {
+		return // BOOTP
+	}
+	event := DHCPEvent{
+		Timestamp:    eventTime.UnixMilli(),
+		MessageType:  dhcp.MessageType,
+		LeaseSeconds: dhcp.LeaseSeconds,
+	}
+	switch {
+	case dhcp.YourIP != nil:
+		event.IP = dhcp.YourIP.String()
+	case dhcp.RequestedIP != nil:
+		event.IP = dhcp.RequestedIP.String()
+	case dhcp.ClientIP != nil:
+		event.IP = dhcp.ClientIP.String()
+	}
+	if dhcp.MessageType == "Ack" && dhcp.YourIP != nil {
+		sta.IPAddresses = addHostIP(sta.IPAddresses, dhcp.YourIP)
+	}
+	sta.DHCPEvents = append(sta.DHCPEvents, event)
+	if len(sta.DHCPEvents) > maxDHCPEventsPerSTA {
+		sta.DHCPEvents = sta.DHCPEvents[len(sta.DHCPEvents)-maxDHCPEventsPerSTA:]
+	}
+}
+
+// recordFlow counts the frame on the STA's flow, oriented from the STA (local) side, and keeps
+// the flow list ordered from least to most recently active.
+func recordFlow(sta *STAInfo, flow *frame_parser.FlowInfo, outbound bool, payloadBytes int, eventTime time.Time) {
+	record := FlowRecord{
+		Protocol:   flow.Protocol,
+		LocalIP:    flow.SrcIP.String(),
+		LocalPort:  flow.SrcPort,
+		RemoteIP:   flow.DstIP.String(),
+		RemotePort: flow.DstPort,
+	}
+	if !outbound {
+		record.LocalIP, record.RemoteIP = record.RemoteIP, record.LocalIP
+		record.LocalPort, record.RemotePort = record.RemotePort, record.LocalPort
+	}
+	for i, existing := range sta.RecentFlows {
+		if existing.sameFlow(record) {
+			record = existing
+			sta.RecentFlows = append(sta.RecentFlows[:i], sta.RecentFlows[i+1:]...)
+			break
+		}
+	}
+	if record.FirstSeen == 0 {
+		record.FirstSeen = eventTime.UnixMilli()
+	}
+	record.LastSeen = eventTime.UnixMilli()
+	record.Packets++
+	record.Bytes += int64(payloadBytes)
+	sta.RecentFlows = append(sta.RecentFlows, record)
+	if len(sta.RecentFlows) > maxFlowsPerSTA {
+		sta.RecentFlows = sta.RecentFlows[len(sta.RecentFlows)-maxFlowsPerSTA:]
+	}
+}
+
+func (f FlowRecord) sameFlow(other FlowRecord) bool {
+	return f.Protocol == other.Protocol &&
+		f.LocalIP == other.LocalIP && f.LocalPort == other.LocalPort &&
+		f.RemoteIP == other.RemoteIP && f.RemotePort == other.RemotePort
+}
+
+// addHostIP adds a unicast address of the STA itself; unspecified, broadcast and multicast
+// addresses are skipped.
+func addHostIP(addresses []string, ip net.IP) []string {
+	if ip == nil || ip.IsUnspecified() || ip.IsMulticast() || ip.Equal(net.IPv4bcast) {
+		return addresses
+	}
+	ipStr := ip.String()
+	for _, existing := range addresses {
+		if existing == ipStr {
+			return addresses
+		}
+	}
+	if len(addresses) >= maxIPAddressesPerSTA {
+		addresses = addresses[1:]
+	}
+	return append(addresses, ipStr)
+}
+
+func addDeviceName(names []string, name string) []string {
+	for _, existing := range names {
+		if existing == name {
+			return names
+		}
+	}
+	if len(names) >= maxDeviceNamesPerSTA {
+		return names
+	}
+	return append(names, name)
+}
+
+// appendRecentName moves name to the end of the list, dropping the oldest names beyond limit.
+func appendRecentName(names []string, name string, limit int) []string {
+	for i, existing := range names {
+		if existing == name {
+			names = append(names[:i], names[i+1:]...)
+			break
+		}
+	}
+	names = append(names, name)
+	if len(names) > limit {
+		names = names[len(names)-limit:]
+	}
+	return names
+}
@@ -0,0 +1,238 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	joinAttemptTimeout    = 10 * time.Second // An attempt without progress for this long is reported as timed out
+	maxJoinAttemptsPerSTA = 20               // Oldest attempts are dropped beyond this
+)
+
+// SAE status codes that continue the exchange rather than reject it
+// (ANTI_CLOGGING_TOKEN_REQUIRED, SAE_HASH_TO_ELEMENT, SAE_PK).
+var saeContinueStatus = map[uint16]bool{76: true, 126: true, 127: true}
+
+// isJoinFrame reports whether a frame takes part in authentication, association or key setup.
+func isJoinFrame(parsedInfo *frame_parser.ParsedFrameInfo) bool {
+	if parsedInfo.Auth != nil || parsedInfo.AssocResponse != nil || parsedInfo.EAPOLKey != nil || parsedInfo.EAP != nil {
+		return true
+	}
+	switch parsedInfo.FrameType {
+	case "MgmtAssociationReq", "MgmtReassociationReq", "MgmtDeauthentication", "MgmtDisassociation":
+		return true
+	}
+	return false
+}
+
+// trackJoinEvent adds a join related frame to the timeline of the STA it belongs to.
+// STAs are tracked whether or not they are confirmed yet, since a failing STA may never be.
+// Caller must hold sm.mutex.
+func (sm *StateManager) trackJoinEvent(parsedInfo *frame_parser.ParsedFrameInfo, now time.Time) {
+	if parsedInfo.BSSID == nil || parsedInfo.TA == nil || parsedInfo.RA == nil {
+		return
+	}
+	if parsedInfo.RetryFlag {
+		return // Retransmissions repeat an event that is already on the timeline
+	}
+	bssid := parsedInfo.BSSID.String()
+	fromAP := parsedInfo.TA.String() == bssid
+	var staMAC net.HardwareAddr
+	if fromAP {
+		staMAC = parsedInfo.RA
+	} else if parsedInfo.RA.String() == bssid {
+		staMAC = parsedInfo.TA
+	} else {
+		return
+	}
+	if !isUnicastMAC(staMAC) {
+		return
+	}
+	eventTime := parsedInfo.Timestamp
+	if eventTime.IsZero() {
+		eventTime = now
+	}
+
+	staKey := staMAC.String()
+	attempt := sm.activeJoinAttempt(staKey, bssid, eventTime)
+
+	switch {
+	case parsedInfo.Auth != nil:
+		auth := parsedInfo.Auth
+		stage := "Authentication"
+		if auth.SAEMessage != "" {
+			stage = "SAE " + auth.SAEMessage
+		}
+		detail := fmt.Sprintf("%s seq %d, status %d", auth.AlgorithmName, auth.Sequence, auth.StatusCode)
+		if !fromAP {
+			// The STA's first frame starts a new attempt; SAE Confirm (and an SAE Commit
+			// repeated with an anti-clogging token) continues the current one.
+			startsAttempt := auth.Sequence == 1 && (attempt == nil || auth.SAEMessage == "" || attempt.AuthAlgorithm != auth.AlgorithmName)
+			if startsAttempt {
+				attempt = sm.startJoinAttempt(staKey, bssid, eventTime)
+				attempt.AuthAlgorithm = auth.AlgorithmName
+			}
+		}
+		if attempt == nil {
+			return
+		}
+		attempt.addEvent(eventTime, stage, detail, fromAP)
+		if fromAP && auth.StatusCode != 0 && !(auth.SAEMessage != "" && saeContinueStatus[auth.StatusCode]) {
+			attempt.finish(eventTime, JoinResultFailed, fmt.Sprintf("Authentication rejected by AP (status %d: %s)", auth.StatusCode, auth.Status))
+		}
+
+	case parsedInfo.FrameType == "MgmtAssociationReq" || parsedInfo.FrameType == "MgmtReassociationReq":
+		if fromAP {
+			return
+		}
+		if attempt == nil {
+			attempt = sm.startJoinAttempt(staKey, bssid, eventTime)
+		}
+		stage := "Association Request"
+		if parsedInfo.FrameType == "MgmtReassociationReq" {
+			stage = "Reassociation Request"
+		}
+		attempt.addEvent(eventTime, stage, parsedInfo.Security, false)
+		// FT and FILS derive keys during authentication, so no 4-way handshake follows.
+		attempt.keyHandshakeExpected = parsedInfo.SecurityProfile != nil && parsedInfo.SecurityProfile.Protocol != "Open" &&
+			attempt.AuthAlgorithm != "FT" && attempt.AuthAlgorithm != "FILS-SK" && attempt.AuthAlgorithm != "FILS-SK-PFS" && attempt.AuthAlgorithm != "FILS-PK"
+
+	case parsedInfo.AssocResponse != nil:
+		if attempt == nil || !fromAP {
+			return
+		}
+		stage := "Association Response"
+		if parsedInfo.FrameType == "MgmtReassociationResp" {
+			stage = "Reassociation Response"
+		}
+		status := parsedInfo.AssocResponse.StatusCode
+		attempt.addEvent(eventTime, stage, fmt.Sprintf("status %d, AID %d", status, parsedInfo.AssocResponse.AID), true)
+		if status != 0 {
+			attempt.finish(eventTime, JoinResultFailed, fmt.Sprintf("Association rejected (status %d: %s)", status, parsedInfo.AssocResponse.Status))
+		} else if !attempt.keyHandshakeExpected {
+			attempt.finish(eventTime, JoinResultSuccess, "")
+		}
+
+	case parsedInfo.EAP != nil:
+		if attempt == nil {
+			return
+		}
+		detail := parsedInfo.EAP.TypeName
+		attempt.addEvent(eventTime, "EAP "+parsedInfo.EAP.Code, detail, fromAP)
+		if parsedInfo.EAP.Code == "Failure" {
+			attempt.finish(eventTime, JoinResultFailed, "802.1X authentication failed (EAP-Failure)")
+		}
+
+	case parsedInfo.EAPOLKey != nil:
+		key := parsedInfo.EAPOLKey
+		if attempt == nil || key.GroupHandshake || key.Message == 0 {
+			return
+		}
+		attempt.addEvent(eventTime, fmt.Sprintf("EAPOL-Key M%d", key.Message), fmt.Sprintf("replay counter %d", key.ReplayCounter), fromAP)
+		if key.Message == 4 {
+			attempt.finish(eventTime, JoinResultSuccess, "")
+		}
+
+	case parsedInfo.FrameType == "MgmtDeauthentication" || parsedInfo.FrameType == "MgmtDisassociation":
+		if attempt == nil {
+			return
+		}
+		stage, verb := "Deauthentication", "Deauthenticated"
+		if parsedInfo.FrameType == "MgmtDisassociation" {
+			stage, verb = "Disassociation", "Disassociated"
+		}
+		by := "STA"
+		if fromAP {
+			by = "AP"
+		}
+		lastStage := attempt.Events[len(attempt.Events)-1].Stage
+		detail := ""
+		if parsedInfo.Reason != "" {
+			detail = fmt.Sprintf("reason %d: %s", parsedInfo.ReasonCode, parsedInfo.Reason)
+		}
+		attempt.addEvent(eventTime, stage, detail, fromAP)
+		reason := fmt.Sprintf("%s by %s after %s", verb, by, lastStage)
+		if detail != "" {
+			reason += " (" + detail + ")"
+		}
+		attempt.finish(eventTime, JoinResultFailed, reason)
+	}
+	if attempt != nil {
+		attempt.lastUpdated = now
+	}
+}
+
+// activeJoinAttempt returns the in-progress attempt of a STA towards bssid.
+// An attempt that has made no progress within joinAttemptTimeout is closed as timed out.
+func (sm *StateManager) activeJoinAttempt(staKey, bssid string, eventTime time.Time) *JoinAttempt {
+	attempts := sm.joinAttempts[staKey]
+	if len(attempts) == 0 {
+		return nil
+	}
+	last := attempts[len(attempts)-1]
+	if last.Result != JoinResultInProgress {
+		return nil
+	}
+	if eventTime.Sub(last.lastEventTime) > joinAttemptTimeout {
+		last.finish(last.lastEventTime, JoinResultTimeout, "No response after "+last.Events[len(last.Events)-1].Stage)
+		return nil
+	}
+	if last.BSSID != bssid {
+		return nil
+	}
+	return last
+}
+
+// startJoinAttempt opens a new attempt, abandoning a still running one (e.g. to another BSS).
+func (sm *StateManager) startJoinAttempt(staKey, bssid string, eventTime time.Time) *JoinAttempt {
+	attempts := sm.joinAttempts[staKey]
+	if n := len(attempts); n > 0 && attempts[n-1].Result == JoinResultInProgress {
+		attempts[n-1].finish(attempts[n-1].lastEventTime, JoinResultFailed, "Abandoned, STA started a new attempt")
+	}
+	attempt := &JoinAttempt{
+		BSSID:     bssid,
+		StartTime: eventTime.UnixMilli(),
+		Result:    JoinResultInProgress,
+	}
+	attempts = append(attempts, attempt)
+	if len(attempts) > maxJoinAttemptsPerSTA {
+		attempts = attempts[len(attempts)-maxJoinAttemptsPerSTA:]
+	}
+	sm.joinAttempts[staKey] = attempts
+	return attempt
+}
+
+func (a *JoinAttempt) addEvent(t time.Time, stage, detail string, fromAP bool) {
+	a.Events = append(a.Events, JoinEvent{Timestamp: t.UnixMilli(), Stage: stage, Detail: detail, FromAP: fromAP})
+	a.lastEventTime = t
+}
+
+func (a *JoinAttempt) finish(t time.Time, result, reason string) {
+	a.Result = result
+	a.FailureReason = reason
+	a.EndTime = t.UnixMilli()
+}
+
+// joinAttemptsSnapshot copies the timeline of a STA. Attempts that have timed out but were
+// not closed by a later frame are reported as timed out without modifying the state.
+// Caller must hold sm.mutex (read lock is sufficient).
+func (sm *StateManager) joinAttemptsSnapshot(staKey string, now time.Time) []JoinAttempt {
+	attempts := sm.joinAttempts[staKey]
+	if len(attempts) == 0 {
+		return nil
+	}
+	out := make([]JoinAttempt, len(attempts))
+	for i, a := range attempts {
+		out[i] = *a
+		out[i].Events = append([]JoinEvent(nil), a.Events...)
+		if a.Result == JoinResultInProgress && now.Sub(a.lastUpdated) > joinAttemptTimeout {
+			out[i].Result = JoinResultTimeout
+			out[i].FailureReason = "No response after " + a.Events[len(a.Events)-1].Stage
+			out[i].EndTime = a.lastEventTime.UnixMilli()
+		}
+	}
+	return out
+}
@@ -3,7 +3,6 @@ package state_manager
 import (
 	"WifiPcapAnalyzer/config"       // Import for config.GlobalConfig
 	"WifiPcapAnalyzer/frame_parser" // Import for ParsedFrameInfo
-	"WifiPcapAnalyzer/logger"
 	"log"
 	"net"
 	"sync"
@@ -21,9 +20,29 @@ type StateManager struct {
 	pendingBSSInfos map[string]time.Time // Key: BSSID, Value: First seen time
 	pendingSTAInfos map[string]time.Time // Key: STA MAC, Value: First seen time
 
+	// Join attempt timelines, keyed by STA MAC (also for STAs not yet confirmed)
+	joinAttempts map[string][]*JoinAttempt
+
+	// Deauthentication/Disassociation counts per reason code
+	disconnectReasons map[uint16]*DisconnectReasonCount
+
+	// Frame and bad-FCS counts per channel
+	channelFrames map[channelKey]*ChannelFrameStats
+
+	// A-MPDU whose MPDUs are being received
+	currentAMPDU *ampduGroup
+
 	// Metrics calculation parameters
 	metricsCalcInterval time.Duration // How often to calculate metrics
 	maxHistoryPoints    int           // Max number of historical data points
+
+	// Source of the current time; a VirtualClock when replaying a capture file
+	clock Clock
+	// End of the last metrics window calculated on the virtual clock
+	lastMetricsCalc time.Time
+
+	// Versioned state for DeltaSince (see deltas.go)
+	deltas *deltaTracker
 }
 
 // NewStateManager creates a new StateManager.
@@ -36,11 +55,27 @@ func NewStateManager(metricsInterval time.Duration, historyPoints int) *StateMan
 		staInfos:            make(map[string]*STAInfo),
 		pendingBSSInfos:     make(map[string]time.Time),
 		pendingSTAInfos:     make(map[string]time.Time),
+		joinAttempts:        make(map[string][]*JoinAttempt),
+		disconnectReasons:   make(map[uint16]*DisconnectReasonCount),
+		channelFrames:       make(map[channelKey]*ChannelFrameStats),
 		metricsCalcInterval: metricsInterval,
 		maxHistoryPoints:    historyPoints,
+		clock:               WallClock{},
+		deltas:              newDeltaTracker(),
 	}
 }
 
+// SetClock sets the clock the state is measured against: a WallClock for live capture, or
+// a VirtualClock for replaying a file. With a VirtualClock, the clock follows the frame
+// timestamps and metrics are calculated whenever it passes the end of a metrics window, so
+// PeriodicallyCalculateMetrics does nothing.
+func (sm *StateManager) SetClock(clock Clock) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.clock = clock
+	sm.lastMetricsCalc = time.Time{}
+}
+
 // ProcessParsedFrame is the main entry point for updating state based on a parsed frame.
 func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameInfo) {
 	if parsedInfo == nil {
@@ -51,8 +86,20 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
+	defer sm.touchFrame(parsedInfo)
+
+	sm.advanceVirtualClock(parsedInfo.Timestamp)
+
+	// MPDUs after the first of an A-MPDU share its PPDU and carry the same Duration/ID, so
+	// only the first one adds to the NAV.
+	continuesPPDU := sm.trackAMPDU(parsedInfo)
+
+	// Corrupted frames only feed the bad-frame counters; their addresses and body can't be trusted.
+	if sm.recordFrameFCS(parsedInfo) {
+		return
+	}
 
-	now := time.Now()
+	now := sm.clock.Now()
 	nowMilli := now.UnixMilli()
 	confirmationWindow := 1 * time.Minute // 1 minute confirmation window
 
@@ -80,6 +127,12 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 		}
 	}
 
+	// An A-MSDU carries several MSDUs (packets) in one data frame
+	msduCount := int64(1)
+	if n := len(parsedInfo.AMSDUSubframes); n > 0 {
+		msduCount = int64(n)
+	}
+
 	// --- Helper function to handle STA update/creation ---
 	handleSTA := func(mac net.HardwareAddr, isSource bool) {
 		if mac == nil || !isUnicastMAC(mac) {
@@ -113,6 +166,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 					delete(sm.pendingSTAInfos, macStr) // Remove from pending
 					sta = NewSTAInfo(macStr)           // Create new STA
 					sta.LastSeen = nowMilli
+					sta.lastCalcTime = now
 					if parsedInfo.SignalStrength != 0 {
 						sta.SignalStrength = parsedInfo.SignalStrength
 					}
@@ -188,9 +242,18 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 					if parsedInfo.Channel != 0 {
 						bss.Channel = parsedInfo.Channel
 					}
+					if parsedInfo.Band != "" {
+						bss.Band = parsedInfo.Band
+					}
 					// 带宽识别：优先使用parsedInfo.Bandwidth，该字段已经经过优化的带宽识别逻辑处理
 					// 先更新capabilities然后再根据优先级确定带宽，避免capabilities信息丢失
 					updateBSSCapabilities(bss, parsedInfo)
+					if len(parsedInfo.Elements) > 0 {
+						bss.InformationElements = parsedInfo.Elements
+					}
+					updateBSSVendorInfo(bss, parsedInfo)
+					updateBSSRoamingSupport(bss, parsedInfo)
+					updateBSSLoadAndRegulatory(bss, parsedInfo)
 
 					if parsedInfo.Bandwidth != "" {
 						// 优先使用Parse阶段计算的带宽
@@ -222,25 +285,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 					}
 
 					// Update Security
-					if len(parsedInfo.RSNRaw) > 0 {
-						logger.Log.Info().Msgf("INFO_BSS_SECURITY_UPDATE: RSN elements found for BSS %s: RSNRaw=%v", bssidStr, parsedInfo.RSNRaw)
-						logger.Log.Info().Msgf("INFO_BSS_SECURITY_DETAIL: parsedInfo.Security='%s', RSNRaw length=%d",
-							parsedInfo.Security, len(parsedInfo.RSNRaw))
-
-						// 检查Security字段是否包含有效的安全类型
-						if parsedInfo.Security != "" && parsedInfo.Security != "Open" {
-							logger.Log.Info().Msgf("INFO_BSS_SECURITY_UPDATE: Using security type from frame parser: %s -> %s", bss.Security, parsedInfo.Security)
-							bss.Security = parsedInfo.Security
-						} else {
-							logger.Log.Info().Msgf("INFO_BSS_SECURITY_UPDATE: Using default RSN/WPA2/WPA3 for BSS %s", bssidStr)
-							bss.Security = "RSN/WPA2/WPA3"
-						}
-					} else {
-						if bss.Security == "" {
-							logger.Log.Info().Msgf("INFO_BSS_SECURITY_UPDATE: No RSN elements for BSS %s, setting security to: %s", bssidStr, parsedInfo.Security)
-							bss.Security = parsedInfo.Security
-						}
-					}
+					updateBSSSecurity(bss, parsedInfo)
 				}
 				// log.Printf("DEBUG_SM_UPDATE: BSS %s updated. LastSeen: %v, SSID: %s, Signal: %d", bssidStr, time.UnixMilli(bss.LastSeen), bss.SSID, bss.SignalStrength)
 				// log.Printf("DEBUG_SM_BSS_UPDATE: BSS %s created/updated. SSID: '%s', Channel: %d, Signal: %d, LastSeen: %v", bss.BSSID, bss.SSID, bss.Channel, bss.SignalStrength, time.UnixMilli(bss.LastSeen))
@@ -262,7 +307,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 								// log.Printf("DEBUG_STATE_MANAGER: Confirmation failed for BSS %s. Signal %d dBm < threshold %d dBm.", bssidStr, parsedInfo.SignalStrength, minRSSI)
 							} else {
 								isSsidMissing := (parsedInfo.SSID == "" || parsedInfo.SSID == "[N/A]" || parsedInfo.SSID == "<Hidden SSID>" || parsedInfo.SSID == "<Invalid SSID Encoding>")
-								isSecurityMissing := len(parsedInfo.RSNRaw) == 0
+								isSecurityMissing := parsedInfo.SecurityProfile == nil
 								areCapsMissing := parsedInfo.ParsedHTCaps == nil && parsedInfo.ParsedVHTCaps == nil
 								passCompleteness := !(isSsidMissing && isSecurityMissing && areCapsMissing)
 								// log.Printf("DEBUG_SM_BSS_FILTER_COMPLETE: BSSID: %s, SSIDMissing: %t, SecurityMissing: %t, CapsMissing: %t, Pass: %t", bssidStr, isSsidMissing, isSecurityMissing, areCapsMissing, passCompleteness)
@@ -272,6 +317,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 									// Passed filters, create and add to confirmed list
 									bss = NewBSSInfo(bssidStr)
 									bss.LastSeen = nowMilli
+									bss.lastCalcTime = now
 									// Populate initial data (same logic as update block above)
 									if parsedInfo.SignalStrength != 0 {
 										bss.SignalStrength = parsedInfo.SignalStrength
@@ -288,26 +334,23 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 									if parsedInfo.Channel != 0 {
 										bss.Channel = parsedInfo.Channel
 									}
+									if parsedInfo.Band != "" {
+										bss.Band = parsedInfo.Band
+									}
 									if parsedInfo.Bandwidth != "" {
 										bss.Bandwidth = parsedInfo.Bandwidth
 									}
 									// Update Capabilities
 									updateBSSCapabilities(bss, parsedInfo)
-									if len(parsedInfo.RSNRaw) > 0 {
-										isWPA := false
-										for _, rsnElem := range parsedInfo.RSNRaw {
-											if rsnElem > 0 {
-												isWPA = true
-												break
-											}
-										}
-										if isWPA {
-											bss.Security = "RSN/WPA2/WPA3"
-										}
-									} else {
-										if bss.Security == "" {
-											bss.Security = "Open"
-										}
+									if len(parsedInfo.Elements) > 0 {
+										bss.InformationElements = parsedInfo.Elements
+									}
+									updateBSSVendorInfo(bss, parsedInfo)
+									updateBSSRoamingSupport(bss, parsedInfo)
+									updateBSSLoadAndRegulatory(bss, parsedInfo)
+									updateBSSSecurity(bss, parsedInfo)
+									if bss.Security == "" {
+										bss.Security = "Open"
 									}
 									sm.bssInfos[bssidStr] = bss // Add to confirmed map
 									// log.Printf("DEBUG_SM_UPDATE: BSS %s created/confirmed. SSID: %s, Channel: %d, Signal: %d", bss.BSSID, bss.SSID, bss.Channel, bss.SignalStrength)
@@ -334,21 +377,24 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 
 			if bssExists { // Proceed with association logic only if BSS is confirmed
 				switch parsedInfo.FrameType { // Compare with string representations
-				case "MgmtAssocReq", "MgmtReassocReq":
+				case "MgmtAssociationReq", "MgmtReassociationReq":
 					staMAC := parsedInfo.SA.String()
 					// Associate only if STA is also confirmed
 					if sta, staExists := sm.staInfos[staMAC]; staExists {
 						sta.AssociatedBSSID = bssidStr
 						bss.AssociatedSTAs[staMAC] = sta
 					}
-				case "MgmtAssocResp", "MgmtReassocResp":
+				case "MgmtAssociationResp", "MgmtReassociationResp":
 					staMAC := parsedInfo.DA.String()
 					// Associate only if STA is also confirmed
 					if sta, staExists := sm.staInfos[staMAC]; staExists {
 						sta.AssociatedBSSID = bssidStr // BSSID is the SA in Resp frames
 						bss.AssociatedSTAs[staMAC] = sta
+						if parsedInfo.AssociationID != 0 {
+							sta.AID = parsedInfo.AssociationID
+						}
 					}
-				case "MgmtDisassoc", "MgmtDeauth":
+				case "MgmtDisassociation", "MgmtDeauthentication":
 					if parsedInfo.SA != nil && parsedInfo.DA != nil {
 						saStr := parsedInfo.SA.String()
 						daStr := parsedInfo.DA.String()
@@ -408,6 +454,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 					if sta.AssociatedBSSID != "" {
 						if oldBss, oldBssExists := sm.bssInfos[sta.AssociatedBSSID]; oldBssExists {
 							delete(oldBss.AssociatedSTAs, staMAC)
+							sm.touchBSS(sta.AssociatedBSSID)
 						}
 					}
 					sta.AssociatedBSSID = apMAC
@@ -435,6 +482,41 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 		}
 	} // End if Data frame
 
+	// --- Control frame statistics (BAR/BA, Trigger, NDPA, RTS/CTS/ACK) ---
+	if parsedInfo.Control != nil {
+		sm.updateControlFrameStats(parsedInfo)
+	}
+
+	// --- Join attempt timeline (Authentication, (Re)Association, EAPOL handshake) ---
+	if isJoinFrame(parsedInfo) {
+		sm.trackJoinEvent(parsedInfo, now)
+	}
+
+	// --- Disconnect history and reason code breakdown ---
+	if parsedInfo.FrameType == "MgmtDeauthentication" || parsedInfo.FrameType == "MgmtDisassociation" {
+		sm.recordDisconnect(parsedInfo, now)
+	}
+
+	// --- IP addresses, names and flows from upper layers ---
+	if parsedInfo.Flow != nil || parsedInfo.DHCP != nil || parsedInfo.ARP != nil {
+		sm.updateHostInfo(parsedInfo, now)
+	}
+
+	// --- A-MSDU usage ---
+	if parsedInfo.IsQoSData && parsedInfo.FrameType != "DataQOSNull" {
+		sm.recordAMSDU(parsedInfo)
+	}
+
+	// --- Action frame counters by category ---
+	if parsedInfo.Action != nil {
+		sm.updateActionFrameStats(parsedInfo)
+	}
+
+	// --- 802.11v BSS Transition Management exchanges ---
+	if parsedInfo.RoamingAction != nil {
+		sm.recordBTMEvent(parsedInfo, now)
+	}
+
 	// Accumulate metrics for confirmed BSS and STA
 	if parsedInfo.BSSID != nil {
 		bssidStr := parsedInfo.BSSID.String()
@@ -454,7 +536,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 				log.Printf("DEBUG_NAV_SKIP: Skipping NAV accumulation for PS-Poll frame. BSSID: %s, SA: %s, DurationID: %d", bssidStr, parsedInfo.SA, parsedInfo.MACDurationID)
 			}
 
-			if !isCtrlPSPoll {
+			if !isCtrlPSPoll && !continuesPPDU {
 				bss.AccumulatedNavMicroseconds += uint64(parsedInfo.MACDurationID)
 				log.Printf("DEBUG_METRIC_ACCUM: BSSID: %s, Added NAV Microseconds: %d, Total NAV Microseconds: %d for Channel Utilization", bssidStr, parsedInfo.MACDurationID, bss.AccumulatedNavMicroseconds)
 			}
@@ -484,7 +566,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 						// 更新累积下行统计 - 接收字节数
 						staDest.RxBytes += int64(frameDataLength)
 						// 更新累积下行统计 - 接收包数
-						staDest.RxPackets++
+						staDest.RxPackets += msduCount
 						// 如果是重传包，更新重传计数
 						if parsedInfo.RetryFlag {
 							staDest.RxRetries++
@@ -499,7 +581,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 						// 更新累积上行统计 - 发送字节数
 						sta.TxBytes += int64(frameDataLength)
 						// 更新累积上行统计 - 发送包数
-						sta.TxPackets++
+						sta.TxPackets += msduCount
 						// 如果是重传包，更新重传计数
 						if parsedInfo.RetryFlag {
 							sta.TxRetries++
@@ -517,7 +599,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 								// 更新累积上行统计 - 发送字节数
 								sta.TxBytes += int64(frameDataLength)
 								// 更新累积上行统计 - 发送包数
-								sta.TxPackets++
+								sta.TxPackets += msduCount
 								// 如果是重传包，更新重传计数
 								if parsedInfo.RetryFlag {
 									sta.TxRetries++
@@ -530,7 +612,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 								// 更新累积上行统计 - 发送字节数
 								sta.TxBytes += int64(frameDataLength)
 								// 更新累积上行统计 - 发送包数
-								sta.TxPackets++
+								sta.TxPackets += msduCount
 								// 如果是重传包，更新重传计数
 								if parsedInfo.RetryFlag {
 									sta.TxRetries++
@@ -544,7 +626,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 							// 更新累积上行统计 - 发送字节数
 							sta.TxBytes += int64(frameDataLength)
 							// 更新累积上行统计 - 发送包数
-							sta.TxPackets++
+							sta.TxPackets += msduCount
 							// 如果是重传包，更新重传计数
 							if parsedInfo.RetryFlag {
 								sta.TxRetries++
@@ -570,7 +652,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 				// log.Printf("DEBUG_NAV_SKIP: Skipping NAV accumulation for PS-Poll frame. STA: %s, DurationID: %d", saStr, parsedInfo.MACDurationID)
 			}
 
-			if !isCtrlPSPoll {
+			if !isCtrlPSPoll && !continuesPPDU {
 				sta.AccumulatedNavMicroseconds += uint64(parsedInfo.MACDurationID)
 				// log.Printf("DEBUG_METRIC_ACCUM: STA: %s, Added NAV Microseconds: %d, Total NAV Microseconds: %d for Channel Utilization", saStr, parsedInfo.MACDurationID, sta.AccumulatedNavMicroseconds)
 			}
@@ -596,7 +678,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 					// 更新累积下行统计 - 接收字节数
 					staDest.RxBytes += int64(frameDataLength)
 					// 更新累积下行统计 - 接收包数
-					staDest.RxPackets++
+					staDest.RxPackets += msduCount
 					// 如果是重传包，更新重传计数
 					if parsedInfo.RetryFlag {
 						staDest.RxRetries++
@@ -620,7 +702,7 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 					// 更新累积下行统计 - 接收字节数
 					staDest.RxBytes += int64(frameDataLength)
 					// 更新累积下行统计 - 接收包数
-					staDest.RxPackets++
+					staDest.RxPackets += msduCount
 					// 如果是重传包，更新重传计数
 					if parsedInfo.RetryFlag {
 						staDest.RxRetries++
@@ -647,11 +729,45 @@ func (sm *StateManager) ProcessParsedFrame(parsedInfo *frame_parser.ParsedFrameI
 }
 
 // PeriodicallyCalculateMetrics calculates and updates metrics for all confirmed BSSs and STAs.
+// It is called every metrics interval in live mode; on a VirtualClock, metrics are calculated
+// as frames advance the clock instead, and this does nothing.
 func (sm *StateManager) PeriodicallyCalculateMetrics() {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
-	now := time.Now()
+	if _, virtual := sm.clock.(*VirtualClock); virtual {
+		return
+	}
+	sm.calculateMetrics(sm.clock.Now())
+}
+
+// advanceVirtualClock moves a VirtualClock to a frame's capture timestamp and calculates the
+// metrics of every window that ended before it. The frame itself belongs to the next window.
+// Caller must hold sm.mutex.
+func (sm *StateManager) advanceVirtualClock(timestamp time.Time) {
+	clock, virtual := sm.clock.(*VirtualClock)
+	if !virtual || timestamp.IsZero() {
+		return
+	}
+	clock.Advance(timestamp)
+	now := clock.Now()
+	if sm.lastMetricsCalc.IsZero() || sm.metricsCalcInterval <= 0 {
+		sm.lastMetricsCalc = now
+		return
+	}
+	for windowEnd := sm.lastMetricsCalc.Add(sm.metricsCalcInterval); !windowEnd.After(now); windowEnd = sm.lastMetricsCalc.Add(sm.metricsCalcInterval) {
+		sm.calculateMetrics(windowEnd)
+		sm.lastMetricsCalc = windowEnd
+		// After a long gap in the capture, only the idle windows that still fit in the
+		// history are worth calculating.
+		if idle := int(now.Sub(sm.lastMetricsCalc) / sm.metricsCalcInterval); idle > sm.maxHistoryPoints {
+			sm.lastMetricsCalc = sm.lastMetricsCalc.Add(time.Duration(idle-sm.maxHistoryPoints) * sm.metricsCalcInterval)
+		}
+	}
+}
+
+// calculateMetrics closes the metrics window ending at now. Caller must hold sm.mutex.
+func (sm *StateManager) calculateMetrics(now time.Time) {
 	// log.Printf("DEBUG_METRIC_CALC_PERIODIC_START: Initiating periodic metrics calculation. CurrentTime: %s, LastCalcInterval: %v", now.Format(time.RFC3339), sm.metricsCalcInterval)
 	calculationWindowSeconds := sm.metricsCalcInterval.Seconds()
 	if calculationWindowSeconds <= 0 {
@@ -706,13 +822,14 @@ func (sm *StateManager) PeriodicallyCalculateMetrics() {
 		log.Printf("DEBUG_METRIC_CALC_BSS_POST: BSSID: %s, Calculated ChannelUtilization: %.2f%% (was %.2f%%), Throughput: %d bps (was %d bps)", bssID, bss.ChannelUtilization, originalChannelUtilization, bss.Throughput, originalThroughput)
 		log.Printf("DEBUG_METRIC_UPDATE_BSS: Updating BSS %s: ChannelUtil=%.2f, Throughput=%d", bssID, bss.ChannelUtilization, bss.Throughput)
 
-		bss.HistoricalChannelUtilization = append(bss.HistoricalChannelUtilization, bss.ChannelUtilization)
-		if len(bss.HistoricalChannelUtilization) > sm.maxHistoryPoints {
-			bss.HistoricalChannelUtilization = bss.HistoricalChannelUtilization[1:]
-		}
-		bss.HistoricalThroughput = append(bss.HistoricalThroughput, bss.Throughput)
-		if len(bss.HistoricalThroughput) > sm.maxHistoryPoints {
-			bss.HistoricalThroughput = bss.HistoricalThroughput[1:]
+		var utilHistoryChanged, throughputHistoryChanged bool
+		bss.HistoricalChannelUtilization, utilHistoryChanged = appendHistory(bss.HistoricalChannelUtilization, bss.ChannelUtilization, sm.maxHistoryPoints)
+		bss.HistoricalThroughput, throughputHistoryChanged = appendHistory(bss.HistoricalThroughput, bss.Throughput, sm.maxHistoryPoints)
+		// An idle BSS with a full, flat history encodes the same as before, so it is left out of the next delta
+		if utilHistoryChanged || throughputHistoryChanged || bss.AccumulatedNavMicroseconds != 0 ||
+			bss.ChannelUtilization != originalChannelUtilization || bss.Throughput != originalThroughput ||
+			bss.Util != bss.ChannelUtilization || bss.Thrpt != bss.Throughput {
+			sm.touchBSS(bssID)
 		}
 
 		bss.totalAirtime = 0
@@ -766,20 +883,16 @@ func (sm *StateManager) PeriodicallyCalculateMetrics() {
 		log.Printf("DEBUG_METRIC_CALC_STA_POST: STA: %s, Calculated CU: %.2f%% (was %.2f%%), UL: %d bps (was %d), DL: %d bps (was %d)", staMAC, sta.ChannelUtilization, originalSTAChannelUtilization, sta.UplinkThroughput, originalSTAUplinkThroughput, sta.DownlinkThroughput, originalSTADownlinkThroughput)
 		log.Printf("DEBUG_METRIC_UPDATE_STA: Updating STA %s: ChannelUtil=%.2f, UplinkTput=%d, DownlinkTput=%d", staMAC, sta.ChannelUtilization, sta.UplinkThroughput, sta.DownlinkThroughput)
 
-		// Update history
-		sta.HistoricalChannelUtilization = append(sta.HistoricalChannelUtilization, sta.ChannelUtilization)
-		sta.HistoricalUplinkThroughput = append(sta.HistoricalUplinkThroughput, sta.UplinkThroughput)
-		sta.HistoricalDownlinkThroughput = append(sta.HistoricalDownlinkThroughput, sta.DownlinkThroughput)
-
-		// Maintain history limit
-		if len(sta.HistoricalChannelUtilization) > sm.maxHistoryPoints {
-			sta.HistoricalChannelUtilization = sta.HistoricalChannelUtilization[1:]
-		}
-		if len(sta.HistoricalUplinkThroughput) > sm.maxHistoryPoints {
-			sta.HistoricalUplinkThroughput = sta.HistoricalUplinkThroughput[1:]
-		}
-		if len(sta.HistoricalDownlinkThroughput) > sm.maxHistoryPoints {
-			sta.HistoricalDownlinkThroughput = sta.HistoricalDownlinkThroughput[1:]
+		// Update history, keeping it within the limit
+		var utilHistoryChanged, uplinkHistoryChanged, downlinkHistoryChanged bool
+		sta.HistoricalChannelUtilization, utilHistoryChanged = appendHistory(sta.HistoricalChannelUtilization, sta.ChannelUtilization, sm.maxHistoryPoints)
+		sta.HistoricalUplinkThroughput, uplinkHistoryChanged = appendHistory(sta.HistoricalUplinkThroughput, sta.UplinkThroughput, sm.maxHistoryPoints)
+		sta.HistoricalDownlinkThroughput, downlinkHistoryChanged = appendHistory(sta.HistoricalDownlinkThroughput, sta.DownlinkThroughput, sm.maxHistoryPoints)
+		if utilHistoryChanged || uplinkHistoryChanged || downlinkHistoryChanged || sta.AccumulatedNavMicroseconds != 0 ||
+			sta.ChannelUtilization != originalSTAChannelUtilization || sta.UplinkThroughput != originalSTAUplinkThroughput ||
+			sta.DownlinkThroughput != originalSTADownlinkThroughput ||
+			sta.Util != sta.ChannelUtilization || sta.Thrpt != sta.UplinkThroughput+sta.DownlinkThroughput {
+			sm.touchSTA(staMAC)
 		}
 
 		// Reset counters for next calculation cycle
@@ -792,42 +905,42 @@ func (sm *StateManager) PeriodicallyCalculateMetrics() {
 	// log.Printf("DEBUG_METRIC_CALC_PERIODIC_END: Metrics calculation finished for %d BSSs and %d STAs.", len(sm.bssInfos), len(sm.staInfos))
 }
 
+// appendHistory appends v to history, dropping the oldest point beyond limit, and reports
+// whether the history changed. A full history that already holds only v is returned as is.
+func appendHistory[T comparable](history []T, v T, limit int) ([]T, bool) {
+	if len(history) >= limit {
+		flat := true
+		for _, h := range history {
+			if h != v {
+				flat = false
+				break
+			}
+		}
+		if flat {
+			return history, false
+		}
+	}
+	history = append(history, v)
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history, true
+}
+
 // GetSnapshot returns a deep copy of the current BSS and STA information.
 // Only includes confirmed entries.
 func (sm *StateManager) GetSnapshot() Snapshot {
 	sm.mutex.RLock()
 	defer sm.mutex.RUnlock()
 
+	now := sm.clock.Now()
 	bssList := make([]*BSSInfo, 0, len(sm.bssInfos))
 	for bssidKey, bssOriginal := range sm.bssInfos {
 		if bssOriginal == nil {
 			log.Printf("WARN_SNAPSHOT: Skipping nil BSS in main map for BSSID: %s", bssidKey)
 			continue
 		}
-		bssCopy := *bssOriginal
-		bssCopy.AssociatedSTAs = make(map[string]*STAInfo)
-		bssCopy.HistoricalChannelUtilization = append([]float64(nil), bssOriginal.HistoricalChannelUtilization...)
-		bssCopy.HistoricalThroughput = append([]int64(nil), bssOriginal.HistoricalThroughput...)
-
-		// log.Printf("DEBUG_SNAPSHOT_BSS: BSSID: %s, SSID: %s, ChannelUtil: %.2f, Throughput: %d, NumAssocSTAsInOrig: %d",
-		// 	bssCopy.BSSID, bssCopy.SSID, bssCopy.ChannelUtilization, bssCopy.Throughput, len(bssOriginal.AssociatedSTAs))
-
-		for staMAC, _ := range bssOriginal.AssociatedSTAs {
-			if mainSta, mainStaExists := sm.staInfos[staMAC]; mainStaExists && mainSta != nil {
-				staCopyForBss := *mainSta
-				staCopyForBss.HistoricalChannelUtilization = append([]float64(nil), mainSta.HistoricalChannelUtilization...)
-				staCopyForBss.HistoricalUplinkThroughput = append([]int64(nil), mainSta.HistoricalUplinkThroughput...)
-				staCopyForBss.HistoricalDownlinkThroughput = append([]int64(nil), mainSta.HistoricalDownlinkThroughput...)
-				if _, bssStillExists := sm.bssInfos[staCopyForBss.AssociatedBSSID]; !bssStillExists && staCopyForBss.AssociatedBSSID != "" {
-					staCopyForBss.AssociatedBSSID = ""
-				}
-				bssCopy.AssociatedSTAs[staMAC] = &staCopyForBss
-				// log.Printf("DEBUG_SNAPSHOT_BSS_STA: Associated STA %s to BSS %s in snapshot. STA CU: %.2f, UL: %d, DL: %d", staMAC, bssCopy.BSSID, staCopyForBss.ChannelUtilization, staCopyForBss.UplinkThroughput, staCopyForBss.DownlinkThroughput)
-			} else {
-				log.Printf("WARN_SNAPSHOT: STA %s associated with BSS %s not found in main STA list or is nil.", staMAC, bssOriginal.BSSID)
-			}
-		}
-		bssList = append(bssList, &bssCopy)
+		bssList = append(bssList, sm.copyBSS(bssOriginal, now))
 	}
 
 	staList := make([]*STAInfo, 0, len(sm.staInfos))
@@ -836,29 +949,86 @@ func (sm *StateManager) GetSnapshot() Snapshot {
 			log.Printf("WARN_SNAPSHOT: Skipping nil STA in main map for MAC: %s", staMAC)
 			continue
 		}
-		staCopy := *staOriginal
-		staCopy.HistoricalChannelUtilization = append([]float64(nil), staOriginal.HistoricalChannelUtilization...)
-		staCopy.HistoricalUplinkThroughput = append([]int64(nil), staOriginal.HistoricalUplinkThroughput...)
-		staCopy.HistoricalDownlinkThroughput = append([]int64(nil), staOriginal.HistoricalDownlinkThroughput...)
-
-		if staCopy.AssociatedBSSID != "" {
-			if _, bssExists := sm.bssInfos[staCopy.AssociatedBSSID]; !bssExists {
-				staCopy.AssociatedBSSID = ""
-			}
-		}
-		staList = append(staList, &staCopy)
+		staList = append(staList, sm.copySTA(staMAC, staOriginal, now))
 		// log.Printf("DEBUG_SNAPSHOT_STA: STA: %s, AssociatedBSSID: %s, CU: %.2f, UL: %d, DL: %d",
 		// 	staCopy.MACAddress, staCopy.AssociatedBSSID, staCopy.ChannelUtilization, staCopy.UplinkThroughput, staCopy.DownlinkThroughput)
 	}
 	// log.Printf("DEBUG_SM_EVENT_EMIT: Preparing state snapshot. BSS count: %d, STA count: %d", len(bssList), len(staList))
-	return Snapshot{BSSs: bssList, STAs: staList}
+	return Snapshot{BSSs: bssList, STAs: staList, DisconnectReasons: sm.disconnectReasonsSnapshot(), ChannelFrames: sm.channelFramesSnapshot()}
+}
+
+// GetBSS returns a deep copy of one confirmed BSS, with its associated STAs.
+func (sm *StateManager) GetBSS(bssid string) (*BSSInfo, bool) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	bss, exists := sm.bssInfos[bssid]
+	if !exists || bss == nil {
+		return nil, false
+	}
+	return sm.copyBSS(bss, sm.clock.Now()), true
+}
+
+// GetSTA returns a deep copy of one confirmed STA.
+func (sm *StateManager) GetSTA(mac string) (*STAInfo, bool) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	sta, exists := sm.staInfos[mac]
+	if !exists || sta == nil {
+		return nil, false
+	}
+	return sm.copySTA(mac, sta, sm.clock.Now()), true
+}
+
+// copyBSS returns a deep copy of a BSS for a snapshot, with copies of its associated STAs.
+// Caller must hold sm.mutex.
+func (sm *StateManager) copyBSS(bssOriginal *BSSInfo, now time.Time) *BSSInfo {
+	bssCopy := *bssOriginal
+	bssCopy.AssociatedSTAs = make(map[string]*STAInfo)
+	bssCopy.HistoricalChannelUtilization = append([]float64(nil), bssOriginal.HistoricalChannelUtilization...)
+	bssCopy.HistoricalThroughput = append([]int64(nil), bssOriginal.HistoricalThroughput...)
+	bssCopy.DisconnectHistory = append([]DisconnectEvent(nil), bssOriginal.DisconnectHistory...)
+
+	// log.Printf("DEBUG_SNAPSHOT_BSS: BSSID: %s, SSID: %s, ChannelUtil: %.2f, Throughput: %d, NumAssocSTAsInOrig: %d",
+	// 	bssCopy.BSSID, bssCopy.SSID, bssCopy.ChannelUtilization, bssCopy.Throughput, len(bssOriginal.AssociatedSTAs))
+
+	for staMAC := range bssOriginal.AssociatedSTAs {
+		if mainSta, mainStaExists := sm.staInfos[staMAC]; mainStaExists && mainSta != nil {
+			bssCopy.AssociatedSTAs[staMAC] = sm.copySTA(staMAC, mainSta, now)
+		} else {
+			log.Printf("WARN_SNAPSHOT: STA %s associated with BSS %s not found in main STA list or is nil.", staMAC, bssOriginal.BSSID)
+		}
+	}
+	return &bssCopy
+}
+
+// copySTA returns a deep copy of a STA for a snapshot. Caller must hold sm.mutex.
+func (sm *StateManager) copySTA(staMAC string, staOriginal *STAInfo, now time.Time) *STAInfo {
+	staCopy := *staOriginal
+	staCopy.HistoricalChannelUtilization = append([]float64(nil), staOriginal.HistoricalChannelUtilization...)
+	staCopy.HistoricalUplinkThroughput = append([]int64(nil), staOriginal.HistoricalUplinkThroughput...)
+	staCopy.HistoricalDownlinkThroughput = append([]int64(nil), staOriginal.HistoricalDownlinkThroughput...)
+	staCopy.JoinAttempts = sm.joinAttemptsSnapshot(staMAC, now)
+	staCopy.DisconnectHistory = append([]DisconnectEvent(nil), staOriginal.DisconnectHistory...)
+	staCopy.BTMHistory = append([]BTMEvent(nil), staOriginal.BTMHistory...)
+	staCopy.IPAddresses = append([]string(nil), staOriginal.IPAddresses...)
+	staCopy.DeviceNames = append([]string(nil), staOriginal.DeviceNames...)
+	staCopy.DHCPEvents = append([]DHCPEvent(nil), staOriginal.DHCPEvents...)
+	staCopy.RecentDNSQueries = append([]string(nil), staOriginal.RecentDNSQueries...)
+	staCopy.RecentFlows = append([]FlowRecord(nil), staOriginal.RecentFlows...)
+
+	if staCopy.AssociatedBSSID != "" {
+		if _, bssExists := sm.bssInfos[staCopy.AssociatedBSSID]; !bssExists {
+			staCopy.AssociatedBSSID = ""
+		}
+	}
+	return &staCopy
 }
 
 func (sm *StateManager) PruneOldEntries(timeout time.Duration) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
-	now := time.Now()
+	now := sm.clock.Now()
 	nowMilli := now.UnixMilli()
 	confirmationWindow := 1 * time.Minute                         // Use the same window for pruning pending
 	pendingPruneTimeout := confirmationWindow + (1 * time.Minute) // Prune pending if not seen again after 2 mins total
@@ -872,11 +1042,13 @@ func (sm *StateManager) PruneOldEntries(timeout time.Duration) {
 				if sta, exists := sm.staInfos[staMAC]; exists {
 					if sta.AssociatedBSSID == bssidStr {
 						sta.AssociatedBSSID = ""
+						sm.touchSTA(staMAC)
 						log.Printf("STA %s unassociated due to BSS %s pruning.", staMAC, bssidStr)
 					}
 				}
 			}
 			delete(sm.bssInfos, bssidStr)
+			sm.touchBSS(bssidStr)
 		}
 	}
 
@@ -888,10 +1060,12 @@ func (sm *StateManager) PruneOldEntries(timeout time.Duration) {
 			if sta.AssociatedBSSID != "" {
 				if bss, exists := sm.bssInfos[sta.AssociatedBSSID]; exists {
 					delete(bss.AssociatedSTAs, staMAC)
+					sm.touchBSS(sta.AssociatedBSSID)
 					log.Printf("STA %s removed from BSS %s's association list due to STA pruning.", staMAC, sta.AssociatedBSSID)
 				}
 			}
 			delete(sm.staInfos, staMAC)
+			sm.touchSTA(staMAC)
 		}
 	}
 
@@ -910,13 +1084,25 @@ func (sm *StateManager) PruneOldEntries(timeout time.Duration) {
 			delete(sm.pendingSTAInfos, staMAC)
 		}
 	}
+
+	// Prune join timelines whose latest event is older than the timeout
+	for staMAC, attempts := range sm.joinAttempts {
+		if last := attempts[len(attempts)-1]; now.Sub(last.lastUpdated) > timeout {
+			delete(sm.joinAttempts, staMAC)
+		}
+	}
 }
 
+// UpdateBSS creates or updates a confirmed BSS directly, without frames.
 func (sm *StateManager) UpdateBSS(bssid net.HardwareAddr, ssid string, channel int, signal int, security string, lastSeen time.Time) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	bssidStr := bssid.String()
+	defer sm.touchBSS(bssidStr)
 	bss, exists := sm.bssInfos[bssidStr]
 	if !exists {
 		bss = NewBSSInfo(bssidStr)
+		bss.lastCalcTime = sm.clock.Now()
 		sm.bssInfos[bssidStr] = bss
 	}
 	if ssid != "" {
@@ -934,11 +1120,16 @@ func (sm *StateManager) UpdateBSS(bssid net.HardwareAddr, ssid string, channel i
 	bss.LastSeen = lastSeen.UnixMilli()
 }
 
+// UpdateSTA creates or updates a confirmed STA and its association directly, without frames.
 func (sm *StateManager) UpdateSTA(mac net.HardwareAddr, associatedBSSID net.HardwareAddr, signal int, lastSeen time.Time) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
 	macStr := mac.String()
+	defer sm.touchSTA(macStr)
 	sta, exists := sm.staInfos[macStr]
 	if !exists {
 		sta = NewSTAInfo(macStr)
+		sta.lastCalcTime = sm.clock.Now()
 		sm.staInfos[macStr] = sta
 	}
 	if signal != 0 {
@@ -956,12 +1147,14 @@ func (sm *StateManager) UpdateSTA(mac net.HardwareAddr, associatedBSSID net.Hard
 		if sta.AssociatedBSSID != "" {
 			if oldBss, bssExists := sm.bssInfos[sta.AssociatedBSSID]; bssExists {
 				delete(oldBss.AssociatedSTAs, macStr)
+				sm.touchBSS(sta.AssociatedBSSID)
 			}
 		}
 		// Add to new BSS association if it exists
 		if assocBSSIDStr != "" {
 			if newBss, bssExists := sm.bssInfos[assocBSSIDStr]; bssExists {
 				newBss.AssociatedSTAs[macStr] = sta
+				sm.touchBSS(assocBSSIDStr)
 			}
 			// Note: We don't create a new BSS here if it doesn't exist based on UpdateSTA call
 		}
@@ -976,6 +1169,12 @@ func (sm *StateManager) ClearState() {
 	defer sm.mutex.Unlock()
 	sm.bssInfos = make(map[string]*BSSInfo)
 	sm.staInfos = make(map[string]*STAInfo)
+	sm.joinAttempts = make(map[string][]*JoinAttempt)
+	sm.disconnectReasons = make(map[uint16]*DisconnectReasonCount)
+	sm.channelFrames = make(map[channelKey]*ChannelFrameStats)
+	sm.currentAMPDU = nil
+	sm.lastMetricsCalc = time.Time{}
+	sm.deltas.reset()
 	// log.Println("State Manager: All BSS and STA information has been cleared.")
 }
 
@@ -1171,3 +1370,67 @@ func updateBSSCapabilities(bss *BSSInfo, parsedInfo *frame_parser.ParsedFrameInf
 		bss.HECapabilities.ChannelWidth40_80MHzIn5G = parsedInfo.ParsedHECaps.ChannelWidth40_80MHzIn5G
 	}
 }
+
+// Update BSS vendor specific information (WMM, WPS, Cisco AP name)
+func updateBSSVendorInfo(bss *BSSInfo, parsedInfo *frame_parser.ParsedFrameInfo) {
+	if parsedInfo.WMM != nil && len(parsedInfo.WMM.ACParameters) > 0 {
+		bss.WMMParameters = make([]WMMACParameters, 0, len(parsedInfo.WMM.ACParameters))
+		for _, ac := range parsedInfo.WMM.ACParameters {
+			bss.WMMParameters = append(bss.WMMParameters, WMMACParameters{
+				AC:              ac.AC,
+				ACM:             ac.ACM,
+				AIFSN:           ac.AIFSN,
+				CWMin:           ac.CWMin,
+				CWMax:           ac.CWMax,
+				TXOPLimitMicros: ac.TXOPLimitMicros,
+			})
+		}
+	}
+
+	if parsedInfo.WPS != nil {
+		bss.WPS = &WPSDeviceInfo{
+			State:         parsedInfo.WPS.State,
+			APSetupLocked: parsedInfo.WPS.APSetupLocked,
+			DeviceName:    parsedInfo.WPS.DeviceName,
+			Manufacturer:  parsedInfo.WPS.Manufacturer,
+			ModelName:     parsedInfo.WPS.ModelName,
+			ModelNumber:   parsedInfo.WPS.ModelNumber,
+			SerialNumber:  parsedInfo.WPS.SerialNumber,
+		}
+	}
+
+	if parsedInfo.Cisco != nil && parsedInfo.Cisco.APName != "" {
+		bss.APName = parsedInfo.Cisco.APName
+	}
+}
+
+// updateBSSSecurity copies the structured security profile and its label from a Beacon/Probe Response.
+// Frames without RSN/WPA elements only fill in the label if none is known yet.
+func updateBSSSecurity(bss *BSSInfo, parsedInfo *frame_parser.ParsedFrameInfo) {
+	p := parsedInfo.SecurityProfile
+	if p == nil {
+		if bss.Security == "" {
+			bss.Security = parsedInfo.Security
+		}
+		return
+	}
+	bss.Security = p.Label
+	bss.SecurityProfile = &SecurityProfile{
+		Protocol:             p.Protocol,
+		GroupCipher:          p.GroupCipher,
+		PairwiseCiphers:      append([]string(nil), p.PairwiseCiphers...),
+		AKMs:                 append([]string(nil), p.AKMs...),
+		GroupMgmtCipher:      p.GroupMgmtCipher,
+		MFPCapable:           p.MFPCapable,
+		MFPRequired:          p.MFPRequired,
+		PreAuth:              p.PreAuth,
+		PMKIDCount:           p.PMKIDCount,
+		FastTransition:       p.FastTransition,
+		SuiteB:               p.SuiteB,
+		WPA3Transition:       p.WPA3Transition,
+		EnterpriseTransition: p.EnterpriseTransition,
+		WPAMixed:             p.WPAMixed,
+		OWETransition:        p.OWETransition,
+		Label:                p.Label,
+	}
+}
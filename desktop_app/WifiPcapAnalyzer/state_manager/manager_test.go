@@ -1,10 +1,14 @@
 package state_manager
 
 import (
+	"WifiPcapAnalyzer/frame_parser"
+	"net"
 	"testing"
 	"time"
 
+	"github.com/google/gopacket/layers"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPeriodicallyCalculateMetrics_Basic(t *testing.T) {
@@ -166,3 +170,577 @@ func TestPeriodicallyCalculateMetrics_ZeroAccumulation(t *testing.T) {
 	assert.Equal(t, time.Duration(0), bssInfo.totalAirtime)
 	assert.Equal(t, int64(0), bssInfo.totalTxBytes)
 }
+
+func TestProcessParsedFrame_ControlFrameStats(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+
+	bssid := "02:00:00:00:00:aa"
+	staMAC := "02:00:00:00:00:01"
+	bssInfo := NewBSSInfo(bssid)
+	staInfo := NewSTAInfo(staMAC)
+	staInfo.AssociatedBSSID = bssid
+	staInfo.AID = 1
+	bssInfo.AssociatedSTAs[staMAC] = staInfo
+	sm.bssInfos[bssid] = bssInfo
+	sm.staInfos[staMAC] = staInfo
+
+	apAddr, _ := net.ParseMAC(bssid)
+	staAddr, _ := net.ParseMAC(staMAC)
+	broadcast, _ := net.ParseMAC("ff:ff:ff:ff:ff:ff")
+
+	// Compressed BlockAck from the AP for the STA's uplink: 8 acked, 1 missing
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		FrameType: "CtrlBlockAck", WlanFcType: 1, TA: apAddr, SA: apAddr, RA: staAddr, DA: staAddr,
+		Control: &frame_parser.ControlFrameInfo{Kind: "BlockAck", BlockAck: &frame_parser.BlockAckInfo{
+			Variant: "Compressed",
+			Records: []frame_parser.BlockAckRecord{{TID: 0, AckedMPDUs: 8, MissingMPDUs: 1}},
+		}},
+	})
+	// Basic trigger scheduling AID 1 and another STA on 106-tone RUs
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		FrameType: "CtrlTrigger", WlanFcType: 1, TA: apAddr, SA: apAddr, RA: broadcast, DA: broadcast,
+		Control: &frame_parser.ControlFrameInfo{Kind: "Trigger", Trigger: &frame_parser.TriggerInfo{
+			TypeName: "Basic",
+			Users: []frame_parser.TriggerUserInfo{
+				{AID: 1, RU: "106-tone RU 1", RUTones: 106},
+				{AID: 2, RU: "106-tone RU 2", RUTones: 106},
+			},
+		}},
+	})
+	// CTS to the STA (no TA)
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		FrameType: "CtrlCTS", WlanFcType: 1, RA: staAddr, DA: staAddr,
+		Control: &frame_parser.ControlFrameInfo{Kind: "CTS"},
+	})
+
+	stats := staInfo.ControlFrames
+	assert.Equal(t, int64(1), stats.BlockAck)
+	assert.Equal(t, int64(8), stats.BAAckedMPDUs)
+	assert.Equal(t, int64(1), stats.BAMissingMPDUs)
+	assert.InDelta(t, 100.0/9.0, stats.BALossPercent, 0.01)
+	assert.Equal(t, int64(1), stats.Trigger)
+	assert.Equal(t, int64(1), stats.OFDMATriggers)
+	assert.Equal(t, "106-tone RU 1", stats.LastRU)
+	assert.Equal(t, int64(1), stats.CTS)
+	assert.Equal(t, int64(1), bssInfo.TriggerFrames)
+	assert.True(t, bssInfo.OFDMAObserved)
+}
+
+func TestProcessParsedFrame_JoinTimeline(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+
+	apAddr, _ := net.ParseMAC("02:00:00:00:00:aa")
+	staAddr, _ := net.ParseMAC("02:00:00:00:00:01")
+	otherSTA, _ := net.ParseMAC("02:00:00:00:00:02")
+	roamingSTA, _ := net.ParseMAC("02:00:00:00:00:03")
+	start := time.Now()
+	// Frame types named as the parser names them
+	frame := func(offset time.Duration, fromAP bool, sta net.HardwareAddr, frameType layers.Dot11Type) *frame_parser.ParsedFrameInfo {
+		info := &frame_parser.ParsedFrameInfo{Timestamp: start.Add(offset), FrameType: frameType.String(), BSSID: apAddr, TA: sta, RA: apAddr}
+		if fromAP {
+			info.TA, info.RA = apAddr, sta
+		}
+		return info
+	}
+
+	// Successful SAE join: Commit/Commit, Confirm/Confirm, Assoc, 4-way handshake
+	steps := []*frame_parser.ParsedFrameInfo{}
+	f := frame(0, false, staAddr, layers.Dot11TypeMgmtAuthentication)
+	f.Auth = &frame_parser.AuthInfo{Algorithm: 3, AlgorithmName: "SAE", Sequence: 1, SAEMessage: "Commit"}
+	steps = append(steps, f)
+	f = frame(1*time.Millisecond, true, staAddr, layers.Dot11TypeMgmtAuthentication)
+	f.Auth = &frame_parser.AuthInfo{Algorithm: 3, AlgorithmName: "SAE", Sequence: 1, SAEMessage: "Commit"}
+	steps = append(steps, f)
+	f = frame(2*time.Millisecond, false, staAddr, layers.Dot11TypeMgmtAuthentication)
+	f.Auth = &frame_parser.AuthInfo{Algorithm: 3, AlgorithmName: "SAE", Sequence: 2, SAEMessage: "Confirm"}
+	steps = append(steps, f)
+	f = frame(3*time.Millisecond, true, staAddr, layers.Dot11TypeMgmtAuthentication)
+	f.Auth = &frame_parser.AuthInfo{Algorithm: 3, AlgorithmName: "SAE", Sequence: 2, SAEMessage: "Confirm"}
+	steps = append(steps, f)
+	f = frame(4*time.Millisecond, false, staAddr, layers.Dot11TypeMgmtAssociationReq)
+	f.SecurityProfile = &frame_parser.SecurityProfile{Protocol: "RSN", AKMs: []string{"SAE"}}
+	steps = append(steps, f)
+	f = frame(5*time.Millisecond, true, staAddr, layers.Dot11TypeMgmtAssociationResp)
+	f.AssocResponse = &frame_parser.AssocResponseInfo{StatusCode: 0, AID: 1}
+	steps = append(steps, f)
+	for i := 1; i <= 4; i++ {
+		f = frame(time.Duration(5+i)*time.Millisecond, i%2 == 1, staAddr, layers.Dot11TypeData)
+		f.EAPOLKey = &frame_parser.EAPOLKeyInfo{Message: i, Pairwise: true, ReplayCounter: 1}
+		steps = append(steps, f)
+	}
+	// Rejected association of a second STA
+	f = frame(0, false, otherSTA, layers.Dot11TypeMgmtAuthentication)
+	f.Auth = &frame_parser.AuthInfo{AlgorithmName: "Open", Sequence: 1}
+	steps = append(steps, f)
+	f = frame(1*time.Millisecond, true, otherSTA, layers.Dot11TypeMgmtAuthentication)
+	f.Auth = &frame_parser.AuthInfo{AlgorithmName: "Open", Sequence: 2}
+	steps = append(steps, f)
+	f = frame(2*time.Millisecond, false, otherSTA, layers.Dot11TypeMgmtAssociationReq)
+	steps = append(steps, f)
+	f = frame(3*time.Millisecond, true, otherSTA, layers.Dot11TypeMgmtAssociationResp)
+	f.AssocResponse = &frame_parser.AssocResponseInfo{StatusCode: 17, Status: frame_parser.StatusCodeString(17)}
+	steps = append(steps, f)
+	// Reassociation of a third STA, ended by the AP
+	steps = append(steps, frame(0, false, roamingSTA, layers.Dot11TypeMgmtReassociationReq))
+	steps = append(steps, frame(1*time.Millisecond, true, roamingSTA, layers.Dot11TypeMgmtDeauthentication))
+
+	for _, step := range steps {
+		sm.ProcessParsedFrame(step)
+	}
+
+	attempts := sm.joinAttemptsSnapshot(staAddr.String(), time.Now())
+	if assert.Len(t, attempts, 1) {
+		assert.Equal(t, JoinResultSuccess, attempts[0].Result)
+		assert.Equal(t, "SAE", attempts[0].AuthAlgorithm)
+		assert.Len(t, attempts[0].Events, 10)
+		assert.Equal(t, "EAPOL-Key M4", attempts[0].Events[9].Stage)
+	}
+
+	attempts = sm.joinAttemptsSnapshot(otherSTA.String(), time.Now())
+	if assert.Len(t, attempts, 1) {
+		assert.Equal(t, JoinResultFailed, attempts[0].Result)
+		assert.Equal(t, "Association rejected (status 17: AP is unable to handle additional associated STAs)", attempts[0].FailureReason)
+	}
+
+	attempts = sm.joinAttemptsSnapshot(roamingSTA.String(), time.Now())
+	if assert.Len(t, attempts, 1) && assert.Len(t, attempts[0].Events, 2) {
+		assert.Equal(t, "Reassociation Request", attempts[0].Events[0].Stage)
+		assert.Equal(t, "Deauthentication", attempts[0].Events[1].Stage)
+		assert.Equal(t, JoinResultFailed, attempts[0].Result)
+		assert.Equal(t, "Deauthenticated by AP after Reassociation Request", attempts[0].FailureReason)
+	}
+
+	// An attempt without progress is reported as timed out
+	f = frame(time.Second, false, staAddr, layers.Dot11TypeMgmtAuthentication)
+	f.Auth = &frame_parser.AuthInfo{AlgorithmName: "Open", Sequence: 1}
+	sm.ProcessParsedFrame(f)
+	attempts = sm.joinAttemptsSnapshot(staAddr.String(), time.Now().Add(joinAttemptTimeout+time.Second))
+	if assert.Len(t, attempts, 2) {
+		assert.Equal(t, JoinResultTimeout, attempts[1].Result)
+		assert.Equal(t, "No response after Authentication", attempts[1].FailureReason)
+	}
+}
+
+func TestProcessParsedFrame_DisconnectHistory(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+
+	bssid := "02:00:00:00:00:aa"
+	staMAC := "02:00:00:00:00:01"
+	bssInfo := NewBSSInfo(bssid)
+	staInfo := NewSTAInfo(staMAC)
+	staInfo.AssociatedBSSID = bssid
+	bssInfo.AssociatedSTAs[staMAC] = staInfo
+	sm.bssInfos[bssid] = bssInfo
+	sm.staInfos[staMAC] = staInfo
+
+	apAddr, _ := net.ParseMAC(bssid)
+	staAddr, _ := net.ParseMAC(staMAC)
+	broadcast, _ := net.ParseMAC("ff:ff:ff:ff:ff:ff")
+	ts := time.UnixMilli(1700000000000)
+
+	// STA leaves (Disassociation, reason 8), AP deauthenticates it (reason 15), then a broadcast deauth (reason 3)
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		Timestamp: ts, FrameType: layers.Dot11TypeMgmtDisassociation.String(), BSSID: apAddr, TA: staAddr, SA: staAddr, RA: apAddr, DA: apAddr,
+		ReasonCode: 8, Reason: frame_parser.ReasonCodeString(8),
+	})
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		Timestamp: ts.Add(time.Second), FrameType: layers.Dot11TypeMgmtDeauthentication.String(), BSSID: apAddr, TA: apAddr, SA: apAddr, RA: staAddr, DA: staAddr,
+		ReasonCode: 15, Reason: frame_parser.ReasonCodeString(15),
+	})
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		Timestamp: ts.Add(2 * time.Second), FrameType: layers.Dot11TypeMgmtDeauthentication.String(), BSSID: apAddr, TA: apAddr, SA: apAddr, RA: broadcast, DA: broadcast,
+		ReasonCode: 3, Reason: frame_parser.ReasonCodeString(3),
+	})
+
+	assert.Empty(t, bssInfo.AssociatedSTAs, "Disassociation should remove the STA from the BSS")
+	assert.Equal(t, "", staInfo.AssociatedBSSID)
+
+	if assert.Len(t, staInfo.DisconnectHistory, 2) {
+		assert.Equal(t, DisconnectEvent{
+			Timestamp: ts.UnixMilli(), STA: staMAC, BSSID: bssid, Type: "Disassociation",
+			ReasonCode: 8, Reason: "Disassociated because sending STA is leaving (or has left) BSS", Initiator: "STA",
+		}, staInfo.DisconnectHistory[0])
+		assert.Equal(t, "AP", staInfo.DisconnectHistory[1].Initiator)
+		assert.Equal(t, "4-way handshake timeout", staInfo.DisconnectHistory[1].Reason)
+	}
+	if assert.Len(t, bssInfo.DisconnectHistory, 3) {
+		assert.Equal(t, "ff:ff:ff:ff:ff:ff", bssInfo.DisconnectHistory[2].STA)
+	}
+
+	snapshot := sm.GetSnapshot()
+	if assert.Len(t, snapshot.DisconnectReasons, 3) {
+		assert.Equal(t, uint16(3), snapshot.DisconnectReasons[0].ReasonCode)
+		assert.Equal(t, int64(1), snapshot.DisconnectReasons[0].APInitiated)
+		assert.Equal(t, uint16(8), snapshot.DisconnectReasons[1].ReasonCode)
+		assert.Equal(t, int64(1), snapshot.DisconnectReasons[1].STAInitiated)
+	}
+}
+
+func TestProcessParsedFrame_RoamingSupportAndBTMHistory(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+
+	bssid := "02:00:00:00:00:aa"
+	staMAC := "02:00:00:00:00:01"
+	bssInfo := NewBSSInfo(bssid)
+	sm.bssInfos[bssid] = bssInfo
+	sm.staInfos[staMAC] = NewSTAInfo(staMAC)
+
+	apAddr, _ := net.ParseMAC(bssid)
+	staAddr, _ := net.ParseMAC(staMAC)
+	broadcast, _ := net.ParseMAC("ff:ff:ff:ff:ff:ff")
+	ts := time.UnixMilli(1700000000000)
+
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		Timestamp: ts, FrameType: "MgmtBeacon", WlanFcType: 0, BSSID: apAddr, TA: apAddr, SA: apAddr, RA: broadcast, DA: broadcast,
+		RMCapabilities:       &frame_parser.RMCapabilitiesInfo{NeighborReport: true},
+		ExtendedCapabilities: &frame_parser.ExtendedCapabilitiesInfo{BSSTransition: true},
+		MobilityDomain:       &frame_parser.MobilityDomainInfo{MDID: 0xa1b2, FTOverDS: true},
+	})
+	assert.Equal(t, &RoamingSupport{
+		RadioMeasurement: true, NeighborReport: true, BSSTransition: true,
+		FastTransition: true, MobilityDomainID: "a1b2", FTOverDS: true,
+	}, bssInfo.Roaming)
+
+	// A Probe Response without roaming elements keeps the previous value
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		Timestamp: ts, FrameType: "MgmtProbeResp", WlanFcType: 0, BSSID: apAddr, TA: apAddr, SA: apAddr, RA: staAddr, DA: staAddr,
+	})
+	assert.True(t, bssInfo.Roaming.FastTransition)
+
+	// BTM Request from the AP, retransmission, Response from the STA, then a Neighbor Report Request
+	request := &frame_parser.ParsedFrameInfo{
+		Timestamp: ts, FrameType: "MgmtAction", BSSID: apAddr, TA: apAddr, SA: apAddr, RA: staAddr, DA: staAddr,
+		RoamingAction: &frame_parser.RoamingActionInfo{
+			Kind: frame_parser.RoamingActionBTMRequest, DialogToken: 3, DisassociationImminent: true, DisassociationTimer: 50,
+			Candidates: []frame_parser.NeighborReport{{BSSID: "02:00:00:00:00:bb", Channel: 36, Preference: 255, HasPreference: true}},
+		},
+	}
+	sm.ProcessParsedFrame(request)
+	retry := *request
+	retry.RetryFlag = true
+	sm.ProcessParsedFrame(&retry)
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		Timestamp: ts.Add(20 * time.Millisecond), FrameType: "MgmtAction", BSSID: apAddr, TA: staAddr, SA: staAddr, RA: apAddr, DA: apAddr,
+		RoamingAction: &frame_parser.RoamingActionInfo{
+			Kind: frame_parser.RoamingActionBTMResponse, DialogToken: 3, StatusCode: 7, Status: frame_parser.BTMStatusString(7),
+		},
+	})
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		Timestamp: ts, FrameType: "MgmtAction", BSSID: apAddr, TA: staAddr, SA: staAddr, RA: apAddr, DA: apAddr,
+		RoamingAction: &frame_parser.RoamingActionInfo{Kind: frame_parser.RoamingActionNeighborReportRequest, DialogToken: 4},
+	})
+
+	var history []BTMEvent
+	for _, sta := range sm.GetSnapshot().STAs {
+		if sta.MACAddress == staMAC {
+			history = sta.BTMHistory
+		}
+	}
+	require.Len(t, history, 2)
+	assert.Equal(t, BTMEvent{
+		Timestamp: ts.UnixMilli(), BSSID: bssid, Type: "Request", FromAP: true, DialogToken: 3,
+		DisassociationImminent: true, DisassociationTimer: 50,
+		Candidates: []BTMCandidate{{BSSID: "02:00:00:00:00:bb", Channel: 36, Preference: 255}},
+	}, history[0])
+	assert.Equal(t, "Response", history[1].Type)
+	assert.False(t, history[1].FromAP)
+	assert.Equal(t, "Reject - No suitable BSS transition candidates", history[1].Status)
+}
+
+func TestProcessParsedFrame_ActionFrameStats(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+
+	bssid := "02:00:00:00:00:aa"
+	bssInfo := NewBSSInfo(bssid)
+	sm.bssInfos[bssid] = bssInfo
+
+	apAddr, _ := net.ParseMAC(bssid)
+	staAddr, _ := net.ParseMAC("02:00:00:00:00:01")
+	otherBSSID, _ := net.ParseMAC("02:00:00:00:00:bb")
+	frame := func(bssid net.HardwareAddr, action *frame_parser.ActionFrameInfo, retry bool) *frame_parser.ParsedFrameInfo {
+		return &frame_parser.ParsedFrameInfo{
+			FrameType: "MgmtAction", BSSID: bssid, TA: apAddr, SA: apAddr, RA: staAddr, DA: staAddr,
+			Action: action, RetryFlag: retry,
+		}
+	}
+
+	sm.ProcessParsedFrame(frame(apAddr, &frame_parser.ActionFrameInfo{Category: frame_parser.ActionCategoryBlockAck, CategoryName: "Block Ack"}, false))
+	sm.ProcessParsedFrame(frame(apAddr, &frame_parser.ActionFrameInfo{Category: frame_parser.ActionCategoryBlockAck, CategoryName: "Block Ack"}, true))
+	sm.ProcessParsedFrame(frame(apAddr, &frame_parser.ActionFrameInfo{
+		Category: frame_parser.ActionCategorySpectrumManagement, CategoryName: "Spectrum Management", ChannelSwitch: &frame_parser.ChannelSwitchInfo{NewChannel: 36},
+	}, false))
+	sm.ProcessParsedFrame(frame(apAddr, &frame_parser.ActionFrameInfo{Category: frame_parser.ActionCategorySAQuery, CategoryName: "SA Query"}, false))
+	sm.ProcessParsedFrame(frame(apAddr, &frame_parser.ActionFrameInfo{Category: 15, CategoryName: "Self-protected"}, false))
+	sm.ProcessParsedFrame(frame(apAddr, &frame_parser.ActionFrameInfo{Protected: true, CategoryName: "Protected"}, false))
+	sm.ProcessParsedFrame(frame(otherBSSID, &frame_parser.ActionFrameInfo{Category: frame_parser.ActionCategoryPublic, CategoryName: "Public"}, false))
+
+	assert.Equal(t, ActionFrameStats{
+		SpectrumManagement: 1, BlockAck: 1, SAQuery: 1, Other: 1, Protected: 1, ChannelSwitches: 1,
+	}, bssInfo.ActionFrames)
+}
+
+func TestProcessParsedFrame_LoadAndRegulatory(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+
+	bssid := "02:00:00:00:00:aa"
+	bssInfo := NewBSSInfo(bssid)
+	sm.bssInfos[bssid] = bssInfo
+
+	apAddr, _ := net.ParseMAC(bssid)
+	broadcast, _ := net.ParseMAC("ff:ff:ff:ff:ff:ff")
+	us := &frame_parser.CountryInfo{Code: "US", Environment: "Any", Subbands: []frame_parser.CountrySubband{
+		{FirstChannel: 1, NumChannels: 11, MaxTxPowerDBm: 30},
+	}}
+	beacon := func(channel int, country *frame_parser.CountryInfo) *frame_parser.ParsedFrameInfo {
+		return &frame_parser.ParsedFrameInfo{
+			FrameType: "MgmtBeacon", WlanFcType: 0, BSSID: apAddr, TA: apAddr, SA: apAddr, RA: broadcast, DA: broadcast,
+			Channel: channel, Frequency: 2407 + 5*channel, Band: "2.4GHz",
+			BSSLoad:           &frame_parser.BSSLoadInfo{StationCount: 7, ChannelUtilization: 64, ChannelUtilizationPercent: 25.1},
+			Country:           country,
+			PowerConstraintDB: 3,
+		}
+	}
+
+	sm.ProcessParsedFrame(beacon(6, us))
+	assert.Equal(t, &APReportedLoad{StationCount: 7, ChannelUtilization: 25.1}, bssInfo.APReportedLoad)
+	require.NotNil(t, bssInfo.Regulatory)
+	assert.Equal(t, "US", bssInfo.Regulatory.Country)
+	assert.Equal(t, 30, bssInfo.Regulatory.MaxTxPowerDBm)
+	assert.Equal(t, uint8(3), bssInfo.Regulatory.PowerConstraintDB)
+	assert.False(t, bssInfo.Regulatory.ChannelMismatch)
+
+	// Channel 13 is not allowed by the advertised US channel list
+	sm.ProcessParsedFrame(beacon(13, us))
+	assert.True(t, bssInfo.Regulatory.ChannelMismatch)
+	assert.Equal(t, `Channel 13 is not in the channel list of country "US"`, bssInfo.Regulatory.MismatchDetail)
+
+	// A frame without a Country element keeps the previous country and flag
+	sm.ProcessParsedFrame(beacon(13, nil))
+	assert.Equal(t, "US", bssInfo.Regulatory.Country)
+	assert.True(t, bssInfo.Regulatory.ChannelMismatch)
+}
+
+func TestProcessParsedFrame_BadFCSQuarantine(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+
+	staMAC := "02:00:00:00:00:01"
+	staInfo := NewSTAInfo(staMAC)
+	sm.staInfos[staMAC] = staInfo
+	bssid := "02:00:00:00:00:aa"
+	sm.bssInfos[bssid] = NewBSSInfo(bssid)
+
+	staAddr, _ := net.ParseMAC(staMAC)
+	apAddr, _ := net.ParseMAC(bssid)
+	garbage, _ := net.ParseMAC("5e:13:07:aa:91:0c")
+	frame := func(ta net.HardwareAddr, channel int, band string, bad bool) *frame_parser.ParsedFrameInfo {
+		return &frame_parser.ParsedFrameInfo{
+			FrameType: "Data", WlanFcType: 2, TA: ta, SA: ta, RA: apAddr, DA: apAddr, BSSID: apAddr,
+			Channel: channel, Band: band, BadFCS: bad,
+		}
+	}
+
+	sm.ProcessParsedFrame(frame(staAddr, 36, "5GHz", false))
+	sm.ProcessParsedFrame(frame(staAddr, 36, "5GHz", true))
+	sm.ProcessParsedFrame(frame(garbage, 36, "5GHz", true))
+	sm.ProcessParsedFrame(frame(garbage, 36, "5GHz", true))
+	sm.ProcessParsedFrame(frame(staAddr, 6, "2.4GHz", false))
+	sm.ProcessParsedFrame(frame(nil, 0, "", true))
+
+	assert.Equal(t, int64(1), staInfo.BadFCSFrames)
+	assert.Equal(t, int64(0), sm.bssInfos[bssid].BadFCSFrames)
+	_, pending := sm.pendingSTAInfos[garbage.String()]
+	assert.False(t, pending, "bad-FCS frames must not create STAs")
+	assert.NotContains(t, sm.staInfos, garbage.String())
+
+	snapshot := sm.GetSnapshot()
+	assert.Equal(t, []ChannelFrameStats{
+		{Band: "", Channel: 0, Frames: 1, BadFCSFrames: 1, PHYErrorRate: 1},
+		{Band: "2.4GHz", Channel: 6, Frames: 1},
+		{Band: "5GHz", Channel: 36, Frames: 4, BadFCSFrames: 3, PHYErrorRate: 0.75},
+	}, snapshot.ChannelFrames)
+
+	sm.ClearState()
+	assert.Empty(t, sm.GetSnapshot().ChannelFrames)
+}
+
+func TestProcessParsedFrame_Aggregation(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+
+	staMAC := "02:00:00:00:00:01"
+	bssid := "02:00:00:00:00:aa"
+	staInfo := NewSTAInfo(staMAC)
+	staInfo.AssociatedBSSID = bssid
+	bssInfo := NewBSSInfo(bssid)
+	bssInfo.AssociatedSTAs[staMAC] = staInfo
+	sm.staInfos[staMAC] = staInfo
+	sm.bssInfos[bssid] = bssInfo
+
+	staAddr, _ := net.ParseMAC(staMAC)
+	apAddr, _ := net.ParseMAC(bssid)
+	uplink := func(ampdu *frame_parser.AMPDUInfo, subframes int) *frame_parser.ParsedFrameInfo {
+		info := &frame_parser.ParsedFrameInfo{
+			FrameType: "DataQOSData", WlanFcType: 2, IsQoSData: true,
+			TA: staAddr, SA: staAddr, RA: apAddr, DA: apAddr, BSSID: apAddr,
+			MACDurationID: 100, TransportPayloadLength: 100, AMPDU: ampdu,
+		}
+		if subframes > 0 {
+			info.AMSDU = true
+			info.AMSDUSubframes = make([]frame_parser.AMSDUSubframe, subframes)
+		}
+		return info
+	}
+
+	// A-MPDU 1: three MPDUs, the last one flagged.
+	sm.ProcessParsedFrame(uplink(&frame_parser.AMPDUInfo{Reference: 1}, 0))
+	sm.ProcessParsedFrame(uplink(&frame_parser.AMPDUInfo{Reference: 1}, 3))
+	sm.ProcessParsedFrame(uplink(&frame_parser.AMPDUInfo{Reference: 1, LastKnown: true, IsLast: true}, 0))
+	// A-MPDU 2: two MPDUs without a last flag, closed by the next non-aggregated frame.
+	sm.ProcessParsedFrame(uplink(&frame_parser.AMPDUInfo{Reference: 2}, 0))
+	sm.ProcessParsedFrame(uplink(&frame_parser.AMPDUInfo{Reference: 2}, 0))
+	sm.ProcessParsedFrame(uplink(nil, 0))
+	// An encrypted A-MSDU counts towards usage but not towards the subframe average.
+	sm.ProcessParsedFrame(uplink(nil, 0))
+	encrypted := uplink(nil, 0)
+	encrypted.AMSDU = true
+	sm.ProcessParsedFrame(encrypted)
+
+	stats := staInfo.Aggregation
+	assert.Equal(t, int64(2), stats.AMPDUs)
+	assert.Equal(t, int64(5), stats.AMPDUMPDUs)
+	assert.InDelta(t, 2.5, stats.AvgAMPDULength, 0.001)
+	assert.Equal(t, 3, stats.MaxAMPDULength)
+	assert.Equal(t, int64(8), stats.DataFrames)
+	assert.Equal(t, int64(2), stats.AMSDUFrames)
+	assert.Equal(t, int64(3), stats.AMSDUSubframes)
+	assert.InDelta(t, 3.0, stats.AvgAMSDULength, 0.001)
+	assert.InDelta(t, 0.25, stats.AMSDUUsage, 0.001)
+
+	// NAV is counted once per PPDU: 2 A-MPDUs and 3 single frames.
+	assert.Equal(t, uint64(500), bssInfo.AccumulatedNavMicroseconds)
+	assert.Equal(t, uint64(500), staInfo.AccumulatedNavMicroseconds)
+	// The A-MSDU carried three packets.
+	assert.Equal(t, int64(10), staInfo.TxPackets)
+}
+
+func TestProcessParsedFrame_HostInfo(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+
+	staMAC := "02:00:00:00:00:01"
+	bssid := "02:00:00:00:00:aa"
+	staInfo := NewSTAInfo(staMAC)
+	sm.staInfos[staMAC] = staInfo
+	sm.bssInfos[bssid] = NewBSSInfo(bssid)
+
+	staAddr, _ := net.ParseMAC(staMAC)
+	apAddr, _ := net.ParseMAC(bssid)
+	broadcast, _ := net.ParseMAC("ff:ff:ff:ff:ff:ff")
+	ts := time.UnixMilli(1700000000000)
+	uplink := func(info *frame_parser.ParsedFrameInfo) *frame_parser.ParsedFrameInfo {
+		info.Timestamp, info.FrameType, info.WlanFcType = ts, "Data", 2
+		info.TA, info.SA, info.RA, info.BSSID = staAddr, staAddr, apAddr, apAddr
+		if info.DA == nil {
+			info.DA = apAddr
+		}
+		return info
+	}
+	udp := func(src, dst string, srcPort, dstPort uint16) *frame_parser.FlowInfo {
+		return &frame_parser.FlowInfo{Protocol: "UDP", SrcIP: net.ParseIP(src), DstIP: net.ParseIP(dst), SrcPort: srcPort, DstPort: dstPort}
+	}
+
+	// DHCP Request (from 0.0.0.0) and the broadcast Ack from the AP
+	sm.ProcessParsedFrame(uplink(&frame_parser.ParsedFrameInfo{
+		DA:   broadcast,
+		Flow: udp("0.0.0.0", "255.255.255.255", 68, 67),
+		DHCP: &frame_parser.DHCPInfo{MessageType: "Request", ClientMAC: staAddr, Hostname: "meeting-room-tv", RequestedIP: net.ParseIP("192.168.1.50")},
+	}))
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		Timestamp: ts, FrameType: "Data", WlanFcType: 2, TA: apAddr, SA: apAddr, RA: broadcast, DA: broadcast, BSSID: apAddr,
+		Flow: udp("192.168.1.1", "255.255.255.255", 67, 68),
+		DHCP: &frame_parser.DHCPInfo{MessageType: "Ack", ClientMAC: staAddr, YourIP: net.ParseIP("192.168.1.50"), LeaseSeconds: 86400},
+	})
+	// ARP reply announcing a second address
+	sm.ProcessParsedFrame(uplink(&frame_parser.ParsedFrameInfo{
+		ARP: &frame_parser.ARPInfo{Operation: "Reply", SenderMAC: staAddr, SenderIP: net.ParseIP("192.168.1.51")},
+	}))
+	// DNS query, then a downlink answer on the same flow
+	sm.ProcessParsedFrame(uplink(&frame_parser.ParsedFrameInfo{
+		Flow: udp("192.168.1.50", "192.168.1.1", 53000, 53), TransportPayloadLength: 30,
+		DNS: &frame_parser.DNSInfo{Queries: []string{"example.com"}},
+	}))
+	sm.ProcessParsedFrame(&frame_parser.ParsedFrameInfo{
+		Timestamp: ts.Add(time.Second), FrameType: "Data", WlanFcType: 2, TA: apAddr, SA: apAddr, RA: staAddr, DA: staAddr, BSSID: apAddr,
+		Flow: udp("192.168.1.1", "192.168.1.50", 53, 53000), TransportPayloadLength: 60,
+		DNS: &frame_parser.DNSInfo{Response: true, Queries: []string{"example.com"}},
+	})
+	// mDNS announcement and SSDP NOTIFY: the mDNS host name does not override DHCP
+	sm.ProcessParsedFrame(uplink(&frame_parser.ParsedFrameInfo{
+		DA: broadcast, Flow: udp("192.168.1.50", "224.0.0.251", 5353, 5353),
+		DNS: &frame_parser.DNSInfo{MDNS: true, Response: true, Hostnames: []string{"Meeting-Room-TV"}, Instances: []string{"Meeting Room TV"}},
+	}))
+	sm.ProcessParsedFrame(uplink(&frame_parser.ParsedFrameInfo{
+		DA: broadcast, Flow: udp("192.168.1.50", "239.255.255.250", 1900, 1900),
+		SSDP: &frame_parser.SSDPInfo{Method: "NOTIFY", Server: "Linux/4.9 UPnP/1.0 Sony-BRAVIA/1.0"},
+	}))
+
+	sta := sm.staInfos[staMAC]
+	assert.Equal(t, []string{"192.168.1.50", "192.168.1.51"}, sta.IPAddresses)
+	assert.Equal(t, "meeting-room-tv", sta.Hostname)
+	assert.Equal(t, "DHCP", sta.HostnameSource)
+	assert.Equal(t, []string{"Meeting Room TV", "Linux/4.9 UPnP/1.0 Sony-BRAVIA/1.0"}, sta.DeviceNames)
+	assert.Equal(t, []DHCPEvent{
+		{Timestamp: ts.UnixMilli(), MessageType: "Request", IP: "192.168.1.50"},
+		{Timestamp: ts.UnixMilli(), MessageType: "Ack", IP: "192.168.1.50", LeaseSeconds: 86400},
+	}, sta.DHCPEvents)
+	assert.Equal(t, []string{"example.com"}, sta.RecentDNSQueries)
+
+	require.Len(t, sta.RecentFlows, 4)
+	assert.Equal(t, FlowRecord{
+		Protocol: "UDP", LocalIP: "192.168.1.50", LocalPort: 53000, RemoteIP: "192.168.1.1", RemotePort: 53,
+		Packets: 2, Bytes: 90, FirstSeen: ts.UnixMilli(), LastSeen: ts.Add(time.Second).UnixMilli(),
+	}, sta.RecentFlows[1])
+	assert.Equal(t, "239.255.255.250", sta.RecentFlows[3].RemoteIP)
+
+	for _, snapshotSTA := range sm.GetSnapshot().STAs {
+		if snapshotSTA.MACAddress == staMAC {
+			snapshotSTA.RecentFlows[0].Packets = 99
+		}
+	}
+	assert.Equal(t, int64(1), sta.RecentFlows[0].Packets, "snapshot must not share flows with the state")
+}
+
+func TestVirtualClock_ReplayDrivesMetricsAndPruning(t *testing.T) {
+	sm := NewStateManager(1*time.Second, 5)
+	sm.SetClock(NewVirtualClock())
+
+	bssid, _ := net.ParseMAC("02:00:00:00:00:aa")
+	otherBSSID, _ := net.ParseMAC("02:00:00:00:00:bb")
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) // Long before the wall clock
+	data := func(bssid net.HardwareAddr, at time.Duration) *frame_parser.ParsedFrameInfo {
+		return &frame_parser.ParsedFrameInfo{Timestamp: start.Add(at), FrameType: "Data", WlanFcType: 2, BSSID: bssid, TransportPayloadLength: 1000}
+	}
+
+	sm.ProcessParsedFrame(data(otherBSSID, 0))
+	sm.UpdateBSS(bssid, "lab", 6, -40, "", start)
+	for i := 1; i <= 10; i++ {
+		sm.ProcessParsedFrame(data(bssid, time.Duration(i)*100*time.Millisecond))
+	}
+
+	// Nine frames fell in the first one-second window; the tenth opens the next one
+	bss := sm.bssInfos[bssid.String()]
+	require.NotNil(t, bss)
+	assert.Equal(t, []int64{9 * 1000 * 8}, bss.HistoricalThroughput)
+	sm.PeriodicallyCalculateMetrics() // Wall clock ticks don't close windows of a replay
+	assert.Len(t, bss.HistoricalThroughput, 1)
+
+	sm.PruneOldEntries(2 * time.Minute)
+	assert.Contains(t, sm.bssInfos, bssid.String(), "the BSS was seen a second ago in capture time")
+
+	// An hour later in the capture: the window with the tenth frame is closed, but only as
+	// many of the idle windows after it as the history holds
+	sm.ProcessParsedFrame(data(otherBSSID, time.Hour))
+	assert.Equal(t, []int64{0, 0, 0, 0, 0}, bss.HistoricalThroughput)
+	assert.Equal(t, start.Add(time.Hour), sm.lastMetricsCalc)
+
+	sm.PruneOldEntries(2 * time.Minute)
+	assert.NotContains(t, sm.bssInfos, bssid.String(), "the BSS hasn't been seen for an hour of capture time")
+}
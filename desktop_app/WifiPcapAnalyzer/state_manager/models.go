@@ -1,14 +1,18 @@
 package state_manager
 
-import "time"
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"time"
+)
 
 // BSS (Basic Service Set) information
 type BSSInfo struct {
 	BSSID          string `json:"bssid"`
 	SSID           string `json:"ssid"`
 	Channel        int    `json:"channel"`
+	Band           string `json:"band,omitempty"`  // e.g., "2.4GHz", "5GHz", "6GHz"; empty when the capture header gave no frequency
 	Bandwidth      string `json:"bandwidth"`       // e.g., "20MHz", "40MHz", "80MHz"
-	Security       string `json:"security"`        // e.g., "Open", "WPA2-PSK", "WPA3-SAE"
+	Security       string `json:"security"`        // e.g., "Open", "WPA2-Personal", "WPA3-Personal transition"
 	SignalStrength int    `json:"signal_strength"` // dBm
 	LastSeen       int64  `json:"last_seen"`       // Unix milliseconds
 	// Capabilities (HT, VHT, HE, EHT) can be added as booleans or more detailed structs
@@ -16,13 +20,34 @@ type BSSInfo struct {
 	VHTCapabilities *VHTCapabilities `json:"vht_capabilities,omitempty"`
 	HECapabilities  *HECapabilities  `json:"he_capabilities,omitempty"`
 	// EHTCapabilities *EHTCapabilities `json:"eht_capabilities,omitempty"` // If needed
-	AssociatedSTAs map[string]*STAInfo `json:"associated_stas"` // Keyed by STA MAC
+	AssociatedSTAs  map[string]*STAInfo `json:"associated_stas"`            // Keyed by STA MAC
+	SecurityProfile *SecurityProfile    `json:"security_profile,omitempty"` // Structured RSN/WPA details behind Security
+	// InformationElements holds all IEs from the latest Beacon/Probe Response, for the "all IEs" tree view.
+	InformationElements []frame_parser.InformationElement `json:"information_elements,omitempty"`
+	// Vendor specific information advertised by the AP
+	WMMParameters []WMMACParameters `json:"wmm_parameters,omitempty"` // WMM EDCA parameters per access category
+	WPS           *WPSDeviceInfo    `json:"wps,omitempty"`            // WPS state and device info
+	APName        string            `json:"ap_name,omitempty"`        // AP name from Cisco CCX element
+	// HE Trigger frames sent by this AP; OFDMAObserved is set once a trigger schedules multiple users or a partial RU
+	TriggerFrames int64 `json:"trigger_frames"`
+	OFDMAObserved bool  `json:"ofdma_observed"`
+	// Recent Deauthentication/Disassociation events in this BSS, oldest first
+	DisconnectHistory []DisconnectEvent `json:"disconnect_history,omitempty"`
+	// 802.11k/v/r support advertised in Beacons/Probe Responses
+	Roaming *RoamingSupport `json:"roaming,omitempty"`
+	// Country, power limits and country/channel consistency advertised in Beacons/Probe Responses
+	Regulatory *RegulatoryInfo `json:"regulatory,omitempty"`
+	// Action frames exchanged in this BSS, by category
+	ActionFrames ActionFrameStats `json:"action_frames"`
+	// Frames transmitted by this AP that failed the FCS check (TA intact enough to match a known BSS)
+	BadFCSFrames int64 `json:"bad_fcs_frames"`
 
 	// New metrics for channel utilization and throughput
-	ChannelUtilization           float64   `json:"channel_utilization"`            // Current channel utilization percentage (0.0 - 100.0)
-	Throughput                   int64     `json:"throughput"`                     // Current throughput in bps
-	HistoricalChannelUtilization []float64 `json:"historical_channel_utilization"` // Historical channel utilization data
-	HistoricalThroughput         []int64   `json:"historical_throughput"`          // Historical throughput data
+	ChannelUtilization           float64         `json:"channel_utilization"`            // Current channel utilization percentage (0.0 - 100.0)
+	APReportedLoad               *APReportedLoad `json:"ap_reported_load,omitempty"`     // Station count and utilization from the AP's BSS Load element
+	Throughput                   int64           `json:"throughput"`                     // Current throughput in bps
+	HistoricalChannelUtilization []float64       `json:"historical_channel_utilization"` // Historical channel utilization data
+	HistoricalThroughput         []int64         `json:"historical_throughput"`          // Historical throughput data
 	// Internal fields for metric calculation (not marshalled to JSON)
 	lastCalcTime               time.Time
 	totalAirtime               time.Duration // In a calculation window
@@ -42,6 +67,28 @@ type STAInfo struct {
 	HTCapabilities  *HTCapabilities  `json:"ht_capabilities,omitempty"`
 	VHTCapabilities *VHTCapabilities `json:"vht_capabilities,omitempty"`
 	HECapabilities  *HECapabilities  `json:"he_capabilities,omitempty"`
+	AID             uint16           `json:"aid,omitempty"` // Association ID assigned by the AP
+
+	// Control frame counters, Block Ack loss estimate and OFDMA scheduling
+	ControlFrames ControlFrameStats `json:"control_frames"`
+	// A-MPDU lengths and A-MSDU usage of frames to and from this STA
+	Aggregation AggregationStats `json:"aggregation"`
+	// Recent join attempts (auth -> assoc -> 4-way handshake), oldest first
+	JoinAttempts []JoinAttempt `json:"join_attempts,omitempty"`
+	// Recent Deauthentication/Disassociation events of this STA, oldest first
+	DisconnectHistory []DisconnectEvent `json:"disconnect_history,omitempty"`
+	// Recent 802.11v BSS Transition Management frames exchanged with this STA, oldest first
+	BTMHistory []BTMEvent `json:"btm_history,omitempty"`
+	// Frames transmitted by this STA that failed the FCS check (TA intact enough to match a known STA)
+	BadFCSFrames int64 `json:"bad_fcs_frames"`
+	// Identity and traffic learned from unencrypted (or decrypted) data frames
+	IPAddresses      []string     `json:"ip_addresses,omitempty"`
+	Hostname         string       `json:"hostname,omitempty"`
+	HostnameSource   string       `json:"hostname_source,omitempty"` // "DHCP" or "mDNS"
+	DeviceNames      []string     `json:"device_names,omitempty"`    // mDNS service instances, SSDP SERVER, DHCP vendor class
+	DHCPEvents       []DHCPEvent  `json:"dhcp_events,omitempty"`     // Oldest first
+	RecentDNSQueries []string     `json:"recent_dns_queries,omitempty"`
+	RecentFlows      []FlowRecord `json:"recent_flows,omitempty"` // Least recently active first
 
 	// New metrics for channel utilization and throughput
 	ChannelUtilization           float64   `json:"channel_utilization"`            // Current channel utilization percentage (0.0 - 100.0) by this STA
@@ -151,6 +198,124 @@ type HECapabilities struct {
 	TxHEMCSMap       uint16 `json:"tx_he_mcs_map"`
 }
 
+// Control frame statistics of a STA
+type ControlFrameStats struct {
+	RTS            int64   `json:"rts"`
+	CTS            int64   `json:"cts"`
+	ACK            int64   `json:"ack"`
+	BlockAckReq    int64   `json:"block_ack_req"`
+	BlockAck       int64   `json:"block_ack"`
+	Trigger        int64   `json:"trigger"` // Trigger frames with a User Info field for this STA
+	NDPA           int64   `json:"ndpa"`    // NDP Announcements naming this STA as a sounding target
+	BAAckedMPDUs   int64   `json:"ba_acked_mpdus"`
+	BAMissingMPDUs int64   `json:"ba_missing_mpdus"`
+	BALossPercent  float64 `json:"ba_loss_percent"` // Missing / (Acked + Missing) * 100
+	OFDMATriggers  int64   `json:"ofdma_triggers"`  // Triggers that scheduled this STA alongside others or on a partial RU
+	LastRU         string  `json:"last_ru,omitempty"`
+}
+
+// Aggregation statistics of a STA. A-MPDUs are grouped by the radiotap A-MPDU reference
+// number, so they are only counted for captures that carry the A-MPDU status field.
+type AggregationStats struct {
+	AMPDUs         int64   `json:"ampdus"`
+	AMPDUMPDUs     int64   `json:"ampdu_mpdus"`      // MPDUs received in A-MPDUs
+	AvgAMPDULength float64 `json:"avg_ampdu_length"` // MPDUs per A-MPDU
+	MaxAMPDULength int     `json:"max_ampdu_length"`
+	DataFrames     int64   `json:"data_frames"`      // QoS data frames, aggregated or not
+	AMSDUFrames    int64   `json:"amsdu_frames"`     // Data frames carrying an A-MSDU
+	AMSDUSubframes int64   `json:"amsdu_subframes"`  // MSDUs in decoded (unencrypted) A-MSDUs
+	AvgAMSDULength float64 `json:"avg_amsdu_length"` // MSDUs per decoded A-MSDU
+	AMSDUUsage     float64 `json:"amsdu_usage"`      // AMSDUFrames / DataFrames (0.0 - 1.0)
+	decodedAMSDUs  int64
+}
+
+// ActionFrameStats counts the Action frames of a BSS by category.
+type ActionFrameStats struct {
+	SpectrumManagement    int64 `json:"spectrum_management"`
+	BlockAck              int64 `json:"block_ack"`
+	Public                int64 `json:"public"`
+	RadioMeasurement      int64 `json:"radio_measurement"`
+	SAQuery               int64 `json:"sa_query"`
+	ProtectedDualOfPublic int64 `json:"protected_dual_of_public"`
+	WNM                   int64 `json:"wnm"`
+	Other                 int64 `json:"other"`            // Categories without a dedicated counter
+	Protected             int64 `json:"protected"`        // Encrypted robust action frames (category unknown)
+	ChannelSwitches       int64 `json:"channel_switches"` // (Extended) Channel Switch Announcements
+}
+
+// Join attempt results
+const (
+	JoinResultInProgress = "in_progress"
+	JoinResultSuccess    = "success"
+	JoinResultFailed     = "failed"
+	JoinResultTimeout    = "timeout"
+)
+
+// JoinAttempt is one attempt of a STA to join a BSS, from the first Authentication
+// (or (Re)Association Request) until success, failure or timeout.
+type JoinAttempt struct {
+	BSSID         string      `json:"bssid"`
+	StartTime     int64       `json:"start_time"`         // Unix milliseconds
+	EndTime       int64       `json:"end_time,omitempty"` // Unix milliseconds, 0 while in progress
+	Result        string      `json:"result"`             // JoinResult* constants
+	FailureReason string      `json:"failure_reason,omitempty"`
+	AuthAlgorithm string      `json:"auth_algorithm,omitempty"` // "Open", "SAE", "FT", ...
+	Events        []JoinEvent `json:"events"`
+	// Internal fields
+	keyHandshakeExpected bool      // STA requested RSN/WPA in its (Re)Association Request
+	lastEventTime        time.Time // Capture time of the latest event, for timeouts between frames
+	lastUpdated          time.Time // Wall clock time of the latest event, for snapshots and pruning
+}
+
+// JoinEvent is a single frame of a join attempt.
+type JoinEvent struct {
+	Timestamp int64  `json:"timestamp"` // Unix milliseconds
+	Stage     string `json:"stage"`     // e.g. "Authentication", "Association Response", "EAPOL-Key M1"
+	Detail    string `json:"detail,omitempty"`
+	FromAP    bool   `json:"from_ap"`
+}
+
+// Security (RSN/WPA) configuration of a BSS
+type SecurityProfile struct {
+	Protocol             string   `json:"protocol"` // "RSN", "WPA" or "Open"
+	GroupCipher          string   `json:"group_cipher,omitempty"`
+	PairwiseCiphers      []string `json:"pairwise_ciphers,omitempty"`
+	AKMs                 []string `json:"akms,omitempty"`
+	GroupMgmtCipher      string   `json:"group_mgmt_cipher,omitempty"`
+	MFPCapable           bool     `json:"mfp_capable"`
+	MFPRequired          bool     `json:"mfp_required"`
+	PreAuth              bool     `json:"pre_auth"`
+	PMKIDCount           int      `json:"pmkid_count"`
+	FastTransition       bool     `json:"fast_transition"`
+	SuiteB               bool     `json:"suite_b"`
+	WPA3Transition       bool     `json:"wpa3_transition"`
+	EnterpriseTransition bool     `json:"enterprise_transition"`
+	WPAMixed             bool     `json:"wpa_mixed"`
+	OWETransition        bool     `json:"owe_transition"`
+	Label                string   `json:"label"` // e.g. "WPA3-Personal transition"
+}
+
+// WMM EDCA parameters for one access category
+type WMMACParameters struct {
+	AC              string `json:"ac"` // "AC_BE", "AC_BK", "AC_VI", "AC_VO"
+	ACM             bool   `json:"acm"`
+	AIFSN           uint8  `json:"aifsn"`
+	CWMin           uint16 `json:"cw_min"`
+	CWMax           uint16 `json:"cw_max"`
+	TXOPLimitMicros uint32 `json:"txop_limit_us"`
+}
+
+// WPS (Wi-Fi Protected Setup) state and device information
+type WPSDeviceInfo struct {
+	State         string `json:"state,omitempty"` // "Not configured" / "Configured"
+	APSetupLocked bool   `json:"ap_setup_locked"`
+	DeviceName    string `json:"device_name,omitempty"`
+	Manufacturer  string `json:"manufacturer,omitempty"`
+	ModelName     string `json:"model_name,omitempty"`
+	ModelNumber   string `json:"model_number,omitempty"`
+	SerialNumber  string `json:"serial_number,omitempty"`
+}
+
 // Helper function to create a new BSSInfo
 func NewBSSInfo(bssid string) *BSSInfo {
 	return &BSSInfo{
@@ -180,6 +345,123 @@ func NewSTAInfo(mac string) *STAInfo {
 
 // Snapshot represents a snapshot of all BSS and STA information
 type Snapshot struct {
-	BSSs []*BSSInfo `json:"bsss"`
-	STAs []*STAInfo `json:"stas"`
+	BSSs              []*BSSInfo              `json:"bsss"`
+	STAs              []*STAInfo              `json:"stas"`
+	DisconnectReasons []DisconnectReasonCount `json:"disconnect_reasons"` // Sorted by count, highest first
+	ChannelFrames     []ChannelFrameStats     `json:"channel_frames"`     // Sorted by band, then channel
+}
+
+// ChannelFrameStats counts captured frames and frames that failed the FCS check on one channel.
+// A rising PHYErrorRate is a sign of interference or collisions on the channel.
+type ChannelFrameStats struct {
+	Band         string  `json:"band,omitempty"` // Empty when the capture header gave no frequency
+	Channel      int     `json:"channel"`        // 0 when the channel is unknown
+	Frames       int64   `json:"frames"`
+	BadFCSFrames int64   `json:"bad_fcs_frames"`
+	PHYErrorRate float64 `json:"phy_error_rate"` // BadFCSFrames / Frames (0.0 - 1.0)
+}
+
+// DisconnectEvent is a Deauthentication or Disassociation frame between a STA and an AP.
+type DisconnectEvent struct {
+	Timestamp  int64  `json:"timestamp"` // Unix milliseconds
+	STA        string `json:"sta"`       // ff:ff:ff:ff:ff:ff for broadcast deauthentication
+	BSSID      string `json:"bssid"`
+	Type       string `json:"type"` // "Deauthentication" or "Disassociation"
+	ReasonCode uint16 `json:"reason_code"`
+	Reason     string `json:"reason"`
+	Initiator  string `json:"initiator"` // "AP" or "STA"
+}
+
+// DisconnectReasonCount is the number of disconnect events observed with one reason code.
+type DisconnectReasonCount struct {
+	ReasonCode   uint16 `json:"reason_code"`
+	Reason       string `json:"reason"`
+	Count        int64  `json:"count"`
+	APInitiated  int64  `json:"ap_initiated"`
+	STAInitiated int64  `json:"sta_initiated"`
+}
+
+// APReportedLoad is the load an AP advertises in its BSS Load element, as a cross-check
+// for the NAV-derived ChannelUtilization.
+type APReportedLoad struct {
+	StationCount               uint16  `json:"station_count"`
+	ChannelUtilization         float64 `json:"channel_utilization"`          // Percentage (0.0 - 100.0)
+	AvailableAdmissionCapacity uint16  `json:"available_admission_capacity"` // In units of 32 us/s
+}
+
+// RegulatoryInfo holds the regulatory domain and transmit power limits an AP advertises.
+type RegulatoryInfo struct {
+	Country                string                  `json:"country,omitempty"`     // ISO 3166-1 alpha-2
+	Environment            string                  `json:"environment,omitempty"` // "Any", "Indoor", "Outdoor", ...
+	MaxTxPowerDBm          int                     `json:"max_tx_power_dbm"`      // Country limit for the operating channel, 0 if unknown
+	PowerConstraintDB      uint8                   `json:"power_constraint_db"`   // Local reduction below MaxTxPowerDBm
+	TransmitPowerEnvelopes []TransmitPowerEnvelope `json:"transmit_power_envelopes,omitempty"`
+	ChannelMismatch        bool                    `json:"channel_mismatch"` // Operating channel is outside the Country element's channel list
+	MismatchDetail         string                  `json:"mismatch_detail,omitempty"`
+}
+
+// TransmitPowerEnvelope is one Transmit Power Envelope element.
+type TransmitPowerEnvelope struct {
+	Interpretation string    `json:"interpretation"` // e.g. "Local EIRP", "Regulatory client EIRP PSD"
+	Category       string    `json:"category"`       // "Default" or "Subordinate Device"
+	Values         []float64 `json:"values"`         // dBm per bandwidth (EIRP) or dBm/MHz (PSD)
+}
+
+// RoamingSupport records which of 802.11k/v/r an AP advertises.
+type RoamingSupport struct {
+	RadioMeasurement bool   `json:"radio_measurement"` // 802.11k: RM Enabled Capabilities element present
+	NeighborReport   bool   `json:"neighbor_report"`   // 802.11k Neighbor Report capability bit
+	BSSTransition    bool   `json:"bss_transition"`    // 802.11v: BSS Transition bit in Extended Capabilities
+	FastTransition   bool   `json:"fast_transition"`   // 802.11r: Mobility Domain element present
+	MobilityDomainID string `json:"mobility_domain_id,omitempty"`
+	FTOverDS         bool   `json:"ft_over_ds"`
+}
+
+// BTMEvent is a BSS Transition Management Query, Request or Response between a STA and an AP.
+type BTMEvent struct {
+	Timestamp   int64  `json:"timestamp"` // Unix milliseconds
+	BSSID       string `json:"bssid"`
+	Type        string `json:"type"` // "Query", "Request" or "Response"
+	FromAP      bool   `json:"from_ap"`
+	DialogToken uint8  `json:"dialog_token"`
+	// Query
+	QueryReason string `json:"query_reason,omitempty"`
+	// Request
+	DisassociationImminent bool   `json:"disassociation_imminent,omitempty"`
+	DisassociationTimer    uint16 `json:"disassociation_timer,omitempty"` // In TBTTs
+	// Response
+	StatusCode  uint8  `json:"status_code,omitempty"`
+	Status      string `json:"status,omitempty"`
+	TargetBSSID string `json:"target_bssid,omitempty"`
+	// Candidate list carried by the frame, in frame order
+	Candidates []BTMCandidate `json:"candidates,omitempty"`
+}
+
+// BTMCandidate is one entry of a BSS transition candidate list.
+type BTMCandidate struct {
+	BSSID      string `json:"bssid"`
+	Channel    uint8  `json:"channel"`
+	Preference uint8  `json:"preference"` // 0 = excluded, 255 = most preferred; 0 when not given
+}
+
+// DHCPEvent is a DHCP message sent by or to a STA.
+type DHCPEvent struct {
+	Timestamp    int64  `json:"timestamp"`    // Unix milliseconds
+	MessageType  string `json:"message_type"` // "Discover", "Offer", "Request", "Ack", "Nak", "Release", ...
+	IP           string `json:"ip,omitempty"` // Offered/assigned, requested or current address
+	LeaseSeconds uint32 `json:"lease_seconds,omitempty"`
+}
+
+// FlowRecord is an IP flow of a STA, seen from the STA (local) side. Ports are 0 for
+// protocols other than TCP and UDP.
+type FlowRecord struct {
+	Protocol   string `json:"protocol"`
+	LocalIP    string `json:"local_ip"`
+	LocalPort  uint16 `json:"local_port,omitempty"`
+	RemoteIP   string `json:"remote_ip"`
+	RemotePort uint16 `json:"remote_port,omitempty"`
+	Packets    int64  `json:"packets"`
+	Bytes      int64  `json:"bytes"`      // L4+ payload bytes
+	FirstSeen  int64  `json:"first_seen"` // Unix milliseconds
+	LastSeen   int64  `json:"last_seen"`  // Unix milliseconds
 }
@@ -0,0 +1,64 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"WifiPcapAnalyzer/logger"
+	"sort"
+)
+
+// channelKey identifies a channel; channel numbers repeat across bands.
+type channelKey struct {
+	band    string
+	channel int
+}
+
+// recordFrameFCS counts the frame on its channel and, when it failed the FCS check, counts it
+// as a bad frame for the channel and for its transmitter if that is a known BSS or STA.
+// It reports whether the frame is bad and must not update any other state.
+// Caller must hold sm.mutex.
+func (sm *StateManager) recordFrameFCS(parsedInfo *frame_parser.ParsedFrameInfo) bool {
+	key := channelKey{band: parsedInfo.Band, channel: parsedInfo.Channel}
+	stats, exists := sm.channelFrames[key]
+	if !exists {
+		stats = &ChannelFrameStats{Band: key.band, Channel: key.channel}
+		sm.channelFrames[key] = stats
+	}
+	stats.Frames++
+	if !parsedInfo.BadFCS {
+		return false
+	}
+	stats.BadFCSFrames++
+
+	// A corrupted TA is rarely a known address, so only attribute to BSSs/STAs we already track.
+	if parsedInfo.TA != nil {
+		ta := parsedInfo.TA.String()
+		if bss, exists := sm.bssInfos[ta]; exists {
+			bss.BadFCSFrames++
+		} else if sta, exists := sm.staInfos[ta]; exists {
+			sta.BadFCSFrames++
+		}
+	}
+	logger.Log.Debug().Str("frameType", parsedInfo.FrameType).Int("channel", parsedInfo.Channel).Stringer("ta", parsedInfo.TA).
+		Msg("Quarantined bad-FCS frame")
+	return true
+}
+
+// channelFramesSnapshot returns the per-channel frame counts with their PHY error rate, sorted
+// by band and channel. Caller must hold sm.mutex (read lock is sufficient).
+func (sm *StateManager) channelFramesSnapshot() []ChannelFrameStats {
+	channels := make([]ChannelFrameStats, 0, len(sm.channelFrames))
+	for _, stats := range sm.channelFrames {
+		entry := *stats
+		if entry.Frames > 0 {
+			entry.PHYErrorRate = float64(entry.BadFCSFrames) / float64(entry.Frames)
+		}
+		channels = append(channels, entry)
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		if channels[i].Band != channels[j].Band {
+			return channels[i].Band < channels[j].Band
+		}
+		return channels[i].Channel < channels[j].Channel
+	})
+	return channels
+}
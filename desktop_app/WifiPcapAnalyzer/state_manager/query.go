@@ -0,0 +1,368 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"cmp"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Query entity kinds.
+const (
+	QueryBSSs = "bss"
+	QuerySTAs = "sta"
+)
+
+// Query selects, sorts, pages and projects BSSs or STAs, so that large environments can
+// fetch only what is shown instead of the whole snapshot. Zero values do not filter.
+// Band, channel, SSID and security filters apply to a STA through its associated BSS, so
+// they leave out unassociated STAs.
+type Query struct {
+	Entity           string   `json:"entity"`                        // QueryBSSs or QuerySTAs
+	Band             string   `json:"band,omitempty"`                // e.g. "2.4GHz", "5GHz", "6GHz"
+	Channels         []int    `json:"channels,omitempty"`            // Any of these channels
+	SSIDPattern      string   `json:"ssid_pattern,omitempty"`        // Regular expression (RE2 syntax)
+	Security         []string `json:"security,omitempty"`            // Any of these, matched case-insensitively within the security label, e.g. "WPA3" or "Open"
+	MinRSSI          *int     `json:"min_rssi,omitempty"`            // dBm, inclusive
+	MaxRSSI          *int     `json:"max_rssi,omitempty"`            // dBm, inclusive
+	LastSeenWithinMs int64    `json:"last_seen_within_ms,omitempty"` // Seen at most this long before the current (capture) time
+	Associated       *bool    `json:"associated,omitempty"`          // STAs: associated with a known BSS; BSSs: with at least one STA
+	Vendor           string   `json:"vendor,omitempty"`              // Matched case-insensitively within vendor names and the MAC address OUI
+	SortBy           string   `json:"sort_by,omitempty"`             // A field name from the JSON encoding; defaults to the BSSID or MAC address
+	Descending       bool     `json:"descending,omitempty"`
+	Offset           int      `json:"offset,omitempty"`
+	Limit            int      `json:"limit,omitempty"`  // 0 returns every match from Offset on
+	Fields           []string `json:"fields,omitempty"` // Fields to return; all when empty. The BSSID or MAC address is always included
+}
+
+// QueryResult is one page of query results.
+type QueryResult struct {
+	Total int            `json:"total"` // Matches before pagination
+	Items []EntityFields `json:"items"` // Encoded like deltas: BSSs list their STAs in "associated_sta_macs"
+}
+
+// bssSortFields and staSortFields are the fields a query can sort by. Values are strings or float64s.
+var bssSortFields = map[string]func(*BSSInfo) any{
+	"bssid":               func(b *BSSInfo) any { return b.BSSID },
+	"ssid":                func(b *BSSInfo) any { return b.SSID },
+	"band":                func(b *BSSInfo) any { return b.Band },
+	"channel":             func(b *BSSInfo) any { return float64(b.Channel) },
+	"bandwidth":           func(b *BSSInfo) any { return b.Bandwidth },
+	"security":            func(b *BSSInfo) any { return b.Security },
+	"signal_strength":     func(b *BSSInfo) any { return float64(b.SignalStrength) },
+	"last_seen":           func(b *BSSInfo) any { return float64(b.LastSeen) },
+	"channel_utilization": func(b *BSSInfo) any { return b.ChannelUtilization },
+	"throughput":          func(b *BSSInfo) any { return float64(b.Throughput) },
+	"associated_sta_macs": func(b *BSSInfo) any { return float64(len(b.AssociatedSTAs)) }, // By STA count
+}
+
+var staSortFields = map[string]func(*STAInfo) any{
+	"mac_address":         func(s *STAInfo) any { return s.MACAddress },
+	"associated_bssid":    func(s *STAInfo) any { return s.AssociatedBSSID },
+	"signal_strength":     func(s *STAInfo) any { return float64(s.SignalStrength) },
+	"last_seen":           func(s *STAInfo) any { return float64(s.LastSeen) },
+	"hostname":            func(s *STAInfo) any { return s.Hostname },
+	"channel_utilization": func(s *STAInfo) any { return s.ChannelUtilization },
+	"uplink_throughput":   func(s *STAInfo) any { return float64(s.UplinkThroughput) },
+	"downlink_throughput": func(s *STAInfo) any { return float64(s.DownlinkThroughput) },
+	"bitrate":             func(s *STAInfo) any { return s.BitRate },
+	"rx_bytes":            func(s *STAInfo) any { return float64(s.RxBytes) },
+	"tx_bytes":            func(s *STAInfo) any { return float64(s.TxBytes) },
+	"rx_packets":          func(s *STAInfo) any { return float64(s.RxPackets) },
+	"tx_packets":          func(s *STAInfo) any { return float64(s.TxPackets) },
+	"rx_retries":          func(s *STAInfo) any { return float64(s.RxRetries) },
+	"tx_retries":          func(s *STAInfo) any { return float64(s.TxRetries) },
+}
+
+// bssFieldNames and staFieldNames are the fields a query can return.
+var (
+	bssFieldNames = jsonFieldNames(reflect.TypeOf(BSSInfo{}), "associated_stas", "associated_sta_macs")
+	staFieldNames = jsonFieldNames(reflect.TypeOf(STAInfo{}), "", "")
+)
+
+// jsonFieldNames returns the names encoding/json gives the exported fields of t, with
+// replaced renamed to replacement.
+func jsonFieldNames(t reflect.Type, replaced, replacement string) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if name == replaced {
+			name = replacement
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// queryFilter is a validated Query.
+type queryFilter struct {
+	Query
+	ssid     *regexp.Regexp
+	channels map[int]bool
+	seenFrom int64 // Unix milliseconds; 0 for no limit
+}
+
+func newQueryFilter(q Query, now time.Time) (*queryFilter, error) {
+	if q.Entity != QueryBSSs && q.Entity != QuerySTAs {
+		return nil, fmt.Errorf("unknown query entity %q, want %q or %q", q.Entity, QueryBSSs, QuerySTAs)
+	}
+	f := &queryFilter{Query: q}
+	if q.SSIDPattern != "" {
+		ssid, err := regexp.Compile(q.SSIDPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SSID pattern: %w", err)
+		}
+		f.ssid = ssid
+	}
+	if len(q.Channels) > 0 {
+		f.channels = make(map[int]bool, len(q.Channels))
+		for _, ch := range q.Channels {
+			f.channels[ch] = true
+		}
+	}
+	if q.LastSeenWithinMs > 0 {
+		f.seenFrom = now.Add(-time.Duration(q.LastSeenWithinMs) * time.Millisecond).UnixMilli()
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return nil, fmt.Errorf("offset and limit must not be negative")
+	}
+	fieldNames := bssFieldNames
+	if q.Entity == QuerySTAs {
+		fieldNames = staFieldNames
+	}
+	for _, field := range q.Fields {
+		if !fieldNames[field] {
+			return nil, fmt.Errorf("unknown %s field %q", q.Entity, field)
+		}
+	}
+	return f, nil
+}
+
+// matchBSS reports whether a BSS passes the filters that apply to BSSs and, through their
+// association, to STAs.
+func (f *queryFilter) matchBSS(bss *BSSInfo) bool {
+	if f.Band != "" && !strings.EqualFold(bss.Band, f.Band) {
+		return false
+	}
+	if f.channels != nil && !f.channels[bss.Channel] {
+		return false
+	}
+	if f.ssid != nil && !f.ssid.MatchString(bss.SSID) {
+		return false
+	}
+	if len(f.Security) > 0 {
+		matched := false
+		for _, security := range f.Security {
+			if containsFold(bss.Security, security) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCommon checks the filters that apply to BSSs and STAs alike.
+func (f *queryFilter) matchCommon(rssi int, lastSeen int64, associated bool, vendors func() []string) bool {
+	if f.MinRSSI != nil && rssi < *f.MinRSSI {
+		return false
+	}
+	if f.MaxRSSI != nil && rssi > *f.MaxRSSI {
+		return false
+	}
+	if lastSeen < f.seenFrom {
+		return false
+	}
+	if f.Associated != nil && associated != *f.Associated {
+		return false
+	}
+	if f.Vendor != "" {
+		for _, vendor := range vendors() {
+			if containsFold(vendor, f.Vendor) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// macVendors returns the OUI of a MAC address and, if it is a known one, its vendor name.
+// Locally administered (e.g. randomized) addresses have no vendor.
+func macVendors(mac string) []string {
+	if len(mac) < 8 || strings.IndexByte("2367abefABEF", mac[1]) >= 0 {
+		return nil
+	}
+	oui := strings.ToUpper(mac[:8])
+	if vendor := frame_parser.VendorNameForOUI(oui); vendor != "" {
+		return []string{oui, vendor}
+	}
+	return []string{oui}
+}
+
+// bssVendors returns the vendor names a BSS is known by: from its MAC address, its WPS
+// manufacturer and the vendor specific elements it advertises.
+func bssVendors(bss *BSSInfo) []string {
+	vendors := macVendors(bss.BSSID)
+	if bss.WPS != nil && bss.WPS.Manufacturer != "" {
+		vendors = append(vendors, bss.WPS.Manufacturer)
+	}
+	for _, elem := range bss.InformationElements {
+		if vendor := frame_parser.VendorNameForOUI(elem.OUI); vendor != "" {
+			vendors = append(vendors, vendor)
+		}
+	}
+	return vendors
+}
+
+// staVendors returns the vendor names a STA is known by: from its MAC address and the
+// device names it announced (which include the DHCP vendor class).
+func staVendors(sta *STAInfo) []string {
+	return append(macVendors(sta.MACAddress), sta.DeviceNames...)
+}
+
+// Query returns the page of BSSs or STAs that match q. Times are relative to the state
+// manager's clock, so last-seen windows follow capture time during file replays.
+func (sm *StateManager) Query(q Query) (QueryResult, error) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	now := sm.clock.Now()
+	f, err := newQueryFilter(q, now)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	if q.Entity == QuerySTAs {
+		return sm.querySTAs(f, now)
+	}
+	return sm.queryBSSs(f)
+}
+
+// Caller must hold sm.mutex (read lock is sufficient).
+func (sm *StateManager) queryBSSs(f *queryFilter) (QueryResult, error) {
+	sortBy := cmp.Or(f.SortBy, "bssid")
+	sortValue, ok := bssSortFields[sortBy]
+	if !ok {
+		return QueryResult{}, fmt.Errorf("cannot sort BSSs by %q", sortBy)
+	}
+	var matches []*BSSInfo
+	for _, bss := range sm.bssInfos {
+		if bss == nil || !f.matchBSS(bss) ||
+			!f.matchCommon(bss.SignalStrength, bss.LastSeen, len(bss.AssociatedSTAs) > 0, func() []string { return bssVendors(bss) }) {
+			continue
+		}
+		matches = append(matches, bss)
+	}
+	sortEntities(matches, sortValue, func(b *BSSInfo) string { return b.BSSID }, f.Descending)
+
+	result := QueryResult{Total: len(matches), Items: []EntityFields{}}
+	for _, bss := range page(matches, f.Offset, f.Limit) {
+		if fields := encodeBSSFields(bss); fields != nil {
+			result.Items = append(result.Items, project(fields, f.Fields, "bssid"))
+		}
+	}
+	return result, nil
+}
+
+// Caller must hold sm.mutex (read lock is sufficient).
+func (sm *StateManager) querySTAs(f *queryFilter, now time.Time) (QueryResult, error) {
+	sortBy := cmp.Or(f.SortBy, "mac_address")
+	sortValue, ok := staSortFields[sortBy]
+	if !ok {
+		return QueryResult{}, fmt.Errorf("cannot sort STAs by %q", sortBy)
+	}
+	filtersOnBSS := f.Band != "" || f.channels != nil || f.ssid != nil || len(f.Security) > 0
+	var matches []*STAInfo
+	for _, sta := range sm.staInfos {
+		if sta == nil {
+			continue
+		}
+		bss := sm.bssInfos[sta.AssociatedBSSID]
+		if filtersOnBSS && (bss == nil || !f.matchBSS(bss)) {
+			continue
+		}
+		if !f.matchCommon(sta.SignalStrength, sta.LastSeen, bss != nil, func() []string { return staVendors(sta) }) {
+			continue
+		}
+		matches = append(matches, sta)
+	}
+	sortEntities(matches, sortValue, func(s *STAInfo) string { return s.MACAddress }, f.Descending)
+
+	result := QueryResult{Total: len(matches), Items: []EntityFields{}}
+	for _, sta := range page(matches, f.Offset, f.Limit) {
+		if fields := encodeFields(sm.copySTA(sta.MACAddress, sta, now)); fields != nil {
+			result.Items = append(result.Items, project(fields, f.Fields, "mac_address"))
+		}
+	}
+	return result, nil
+}
+
+// sortEntities sorts by value, then by key so that pages are stable.
+func sortEntities[T any](entities []T, value func(T) any, key func(T) string, descending bool) {
+	sort.Slice(entities, func(i, j int) bool {
+		c := compareSortValues(value(entities[i]), value(entities[j]))
+		if c == 0 {
+			c = strings.Compare(key(entities[i]), key(entities[j]))
+		}
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+func compareSortValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	}
+	return 0
+}
+
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// project keeps only the requested fields, and the key field.
+func project(fields EntityFields, names []string, keyField string) EntityFields {
+	if len(names) == 0 {
+		return fields
+	}
+	projected := EntityFields{keyField: fields[keyField]}
+	for _, name := range names {
+		if value, ok := fields[name]; ok {
+			projected[name] = value
+		}
+	}
+	return projected
+}
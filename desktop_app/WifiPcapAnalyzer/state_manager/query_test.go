@@ -0,0 +1,123 @@
+package state_manager
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newQueryTestState(t *testing.T) *StateManager {
+	sm := NewStateManager(1*time.Second, 5)
+	now := time.Now()
+	bss := func(mac, ssid, band string, channel, rssi int, security string, seen time.Time) net.HardwareAddr {
+		bssid, err := net.ParseMAC(mac)
+		require.NoError(t, err)
+		sm.UpdateBSS(bssid, ssid, channel, rssi, security, seen)
+		sm.mutex.Lock()
+		sm.bssInfos[bssid.String()].Band = band
+		sm.mutex.Unlock()
+		return bssid
+	}
+	sta := func(mac string, bssid net.HardwareAddr, rssi int, seen time.Time) {
+		staMAC, err := net.ParseMAC(mac)
+		require.NoError(t, err)
+		sm.UpdateSTA(staMAC, bssid, rssi, seen)
+	}
+	hall := bss("00:17:f2:00:00:01", "Hall-A", "5GHz", 36, -50, "WPA3-Personal", now)
+	lobby := bss("02:00:00:00:00:02", "Lobby", "2.4GHz", 6, -70, "Open", now.Add(-10*time.Minute))
+	bss("02:00:00:00:00:03", "hall-guest", "", 1, -80, "WPA2-Personal", now) // Band unknown
+	sta("00:17:f2:00:10:01", hall, -40, now)
+	sta("02:00:00:00:10:02", hall, -65, now)
+	sta("02:00:00:00:10:03", lobby, -75, now)
+	sta("02:00:00:00:10:04", nil, -85, now.Add(-10*time.Minute))
+	return sm
+}
+
+func queryKeys(t *testing.T, result QueryResult, keyField string) []string {
+	keys := make([]string, 0, len(result.Items))
+	for _, item := range result.Items {
+		var key string
+		require.NoError(t, json.Unmarshal(item[keyField], &key))
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestQuery_Filters(t *testing.T) {
+	sm := newQueryTestState(t)
+	rssi := func(dBm int) *int { return &dBm }
+	yes, no := true, false
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"all BSSs", Query{Entity: QueryBSSs}, []string{"00:17:f2:00:00:01", "02:00:00:00:00:02", "02:00:00:00:00:03"}},
+		{"band", Query{Entity: QueryBSSs, Band: "2.4ghz"}, []string{"02:00:00:00:00:02"}},
+		{"channels", Query{Entity: QueryBSSs, Channels: []int{1, 36}}, []string{"00:17:f2:00:00:01", "02:00:00:00:00:03"}},
+		{"SSID pattern", Query{Entity: QueryBSSs, SSIDPattern: "(?i)^hall"}, []string{"00:17:f2:00:00:01", "02:00:00:00:00:03"}},
+		{"security", Query{Entity: QueryBSSs, Security: []string{"wpa3", "open"}}, []string{"00:17:f2:00:00:01", "02:00:00:00:00:02"}},
+		{"RSSI range", Query{Entity: QueryBSSs, MinRSSI: rssi(-75), MaxRSSI: rssi(-60)}, []string{"02:00:00:00:00:02"}},
+		{"last seen", Query{Entity: QueryBSSs, LastSeenWithinMs: 60_000}, []string{"00:17:f2:00:00:01", "02:00:00:00:00:03"}},
+		{"BSSs with STAs", Query{Entity: QueryBSSs, Associated: &yes}, []string{"00:17:f2:00:00:01", "02:00:00:00:00:02"}},
+		{"vendor", Query{Entity: QueryBSSs, Vendor: "apple"}, []string{"00:17:f2:00:00:01"}},
+		{"STAs through their BSS", Query{Entity: QuerySTAs, Band: "5GHz"}, []string{"00:17:f2:00:10:01", "02:00:00:00:10:02"}},
+		{"unassociated STAs", Query{Entity: QuerySTAs, Associated: &no}, []string{"02:00:00:00:10:04"}},
+		{"STA vendor by OUI", Query{Entity: QuerySTAs, Vendor: "00:17:F2"}, []string{"00:17:f2:00:10:01"}},
+		{"STA RSSI", Query{Entity: QuerySTAs, MaxRSSI: rssi(-70)}, []string{"02:00:00:00:10:03", "02:00:00:00:10:04"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sm.Query(tt.query)
+			require.NoError(t, err)
+			keyField := "bssid"
+			if tt.query.Entity == QuerySTAs {
+				keyField = "mac_address"
+			}
+			assert.Equal(t, tt.want, queryKeys(t, result, keyField))
+			assert.Equal(t, len(tt.want), result.Total)
+		})
+	}
+}
+
+func TestQuery_SortPageAndProject(t *testing.T) {
+	sm := newQueryTestState(t)
+
+	result, err := sm.Query(Query{Entity: QuerySTAs, SortBy: "signal_strength", Descending: true, Offset: 1, Limit: 2,
+		Fields: []string{"signal_strength"}})
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Total)
+	assert.Equal(t, []string{"02:00:00:00:10:02", "02:00:00:00:10:03"}, queryKeys(t, result, "mac_address"))
+	assert.Len(t, result.Items[0], 2, "only the key and the requested fields")
+	assert.JSONEq(t, `-65`, string(result.Items[0]["signal_strength"]))
+
+	result, err = sm.Query(Query{Entity: QueryBSSs, SortBy: "associated_sta_macs", Descending: true, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.JSONEq(t, `["00:17:f2:00:10:01","02:00:00:00:10:02"]`, string(result.Items[0]["associated_sta_macs"]))
+	assert.NotContains(t, result.Items[0], "associated_stas")
+
+	result, err = sm.Query(Query{Entity: QueryBSSs, Offset: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Total)
+	assert.Empty(t, result.Items)
+}
+
+func TestQuery_Errors(t *testing.T) {
+	sm := newQueryTestState(t)
+	for _, q := range []Query{
+		{Entity: "ap"},
+		{Entity: QueryBSSs, SSIDPattern: "("},
+		{Entity: QueryBSSs, SortBy: "hostname"},
+		{Entity: QuerySTAs, Fields: []string{"ssid"}},
+		{Entity: QuerySTAs, Limit: -1},
+	} {
+		_, err := sm.Query(q)
+		assert.Error(t, err, "%+v", q)
+	}
+}
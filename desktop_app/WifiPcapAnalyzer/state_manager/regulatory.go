@@ -0,0 +1,60 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"WifiPcapAnalyzer/utils"
+	"fmt"
+	"log"
+)
+
+// updateBSSLoadAndRegulatory copies the AP-reported load (BSS Load element) and the regulatory
+// elements (Country, Power Constraint, Transmit Power Envelope) of a Beacon/Probe Response, and
+// flags an operating channel that the advertised country does not allow.
+// bss.Channel must already be updated from this frame.
+func updateBSSLoadAndRegulatory(bss *BSSInfo, parsedInfo *frame_parser.ParsedFrameInfo) {
+	if load := parsedInfo.BSSLoad; load != nil {
+		bss.APReportedLoad = &APReportedLoad{
+			StationCount:               load.StationCount,
+			ChannelUtilization:         load.ChannelUtilizationPercent,
+			AvailableAdmissionCapacity: load.AvailableAdmissionCapacity,
+		}
+	}
+
+	country := parsedInfo.Country
+	if country == nil && parsedInfo.PowerConstraintDB == 0 && len(parsedInfo.TransmitPowerEnvelopes) == 0 {
+		return
+	}
+	reg := &RegulatoryInfo{PowerConstraintDB: parsedInfo.PowerConstraintDB}
+	for _, tpe := range parsedInfo.TransmitPowerEnvelopes {
+		reg.TransmitPowerEnvelopes = append(reg.TransmitPowerEnvelopes, TransmitPowerEnvelope{
+			Interpretation: tpe.Interpretation,
+			Category:       tpe.Category,
+			Values:         append([]float64(nil), tpe.Values...),
+		})
+	}
+	if country != nil {
+		reg.Country = country.Code
+		reg.Environment = country.Environment
+		// 6 GHz channel numbers overlap the 2.4 GHz ones and are announced with operating classes
+		if parsedInfo.Band != string(utils.Band6GHz) {
+			allowed, maxTxPower, checked := frame_parser.CountryAllowsChannel(country, bss.Channel)
+			if checked && allowed {
+				reg.MaxTxPowerDBm = int(maxTxPower)
+			} else if checked {
+				reg.ChannelMismatch = true
+				reg.MismatchDetail = fmt.Sprintf("Channel %d is not in the channel list of country %q", bss.Channel, country.Code)
+				if bss.Regulatory == nil || !bss.Regulatory.ChannelMismatch {
+					log.Printf("WARN_STATE_MANAGER: BSS %s: %s", bss.BSSID, reg.MismatchDetail)
+				}
+			}
+		}
+	} else if bss.Regulatory != nil {
+		// Country is usually only in Beacons; keep it when a Probe Response omits it
+		reg.Country = bss.Regulatory.Country
+		reg.Environment = bss.Regulatory.Environment
+		reg.MaxTxPowerDBm = bss.Regulatory.MaxTxPowerDBm
+		reg.ChannelMismatch = bss.Regulatory.ChannelMismatch
+		reg.MismatchDetail = bss.Regulatory.MismatchDetail
+	}
+	bss.Regulatory = reg
+}
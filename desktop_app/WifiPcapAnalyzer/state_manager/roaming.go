@@ -0,0 +1,93 @@
+package state_manager
+
+import (
+	"WifiPcapAnalyzer/frame_parser"
+	"fmt"
+	"time"
+)
+
+const maxBTMEventsPerSTA = 20 // Oldest events are dropped beyond this
+
+// updateBSSRoamingSupport records the 802.11k/v/r support advertised in a Beacon/Probe Response.
+// Frames that carry none of the relevant elements leave the previous value untouched.
+func updateBSSRoamingSupport(bss *BSSInfo, parsedInfo *frame_parser.ParsedFrameInfo) {
+	if parsedInfo.RMCapabilities == nil && parsedInfo.ExtendedCapabilities == nil && parsedInfo.MobilityDomain == nil {
+		return
+	}
+	roaming := &RoamingSupport{}
+	if rm := parsedInfo.RMCapabilities; rm != nil {
+		roaming.RadioMeasurement = true
+		roaming.NeighborReport = rm.NeighborReport
+	}
+	if ext := parsedInfo.ExtendedCapabilities; ext != nil {
+		roaming.BSSTransition = ext.BSSTransition
+	}
+	if md := parsedInfo.MobilityDomain; md != nil {
+		roaming.FastTransition = true
+		roaming.MobilityDomainID = fmt.Sprintf("%04x", md.MDID)
+		roaming.FTOverDS = md.FTOverDS
+	}
+	bss.Roaming = roaming
+}
+
+// recordBTMEvent adds a BSS Transition Management frame to the BTM history of the (confirmed) STA.
+// Caller must hold sm.mutex.
+func (sm *StateManager) recordBTMEvent(parsedInfo *frame_parser.ParsedFrameInfo, now time.Time) {
+	action := parsedInfo.RoamingAction
+	if action == nil || parsedInfo.BSSID == nil || parsedInfo.TA == nil || parsedInfo.RA == nil || parsedInfo.RetryFlag {
+		return
+	}
+	event := BTMEvent{
+		BSSID:       parsedInfo.BSSID.String(),
+		DialogToken: action.DialogToken,
+	}
+	switch action.Kind {
+	case frame_parser.RoamingActionBTMQuery:
+		event.Type = "Query"
+		event.QueryReason = action.QueryReason
+	case frame_parser.RoamingActionBTMRequest:
+		event.Type = "Request"
+		event.DisassociationImminent = action.DisassociationImminent
+		event.DisassociationTimer = action.DisassociationTimer
+	case frame_parser.RoamingActionBTMResponse:
+		event.Type = "Response"
+		event.StatusCode = action.StatusCode
+		event.Status = action.Status
+		event.TargetBSSID = action.TargetBSSID
+	default:
+		return // Neighbor Report exchanges are not tracked per STA
+	}
+
+	var staMAC string
+	switch event.BSSID {
+	case parsedInfo.TA.String():
+		event.FromAP = true
+		staMAC = parsedInfo.RA.String()
+	case parsedInfo.RA.String():
+		staMAC = parsedInfo.TA.String()
+	default:
+		return
+	}
+	sta, exists := sm.staInfos[staMAC]
+	if !exists {
+		return
+	}
+
+	for _, c := range action.Candidates {
+		event.Candidates = append(event.Candidates, BTMCandidate{
+			BSSID:      c.BSSID,
+			Channel:    c.Channel,
+			Preference: c.Preference,
+		})
+	}
+	eventTime := parsedInfo.Timestamp
+	if eventTime.IsZero() {
+		eventTime = now
+	}
+	event.Timestamp = eventTime.UnixMilli()
+
+	sta.BTMHistory = append(sta.BTMHistory, event)
+	if len(sta.BTMHistory) > maxBTMEventsPerSTA {
+		sta.BTMHistory = sta.BTMHistory[len(sta.BTMHistory)-maxBTMEventsPerSTA:]
+	}
+}
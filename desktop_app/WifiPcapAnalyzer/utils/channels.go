@@ -0,0 +1,362 @@
+package utils
+
+import "sort"
+
+// Band is an 802.11 frequency band.
+type Band string
+
+const (
+	BandUnknown Band = ""
+	Band900MHz  Band = "900MHz" // 802.11ah (S1G)
+	Band2_4GHz  Band = "2.4GHz"
+	Band4_9GHz  Band = "4.9GHz" // Public safety / Japan 4.9 GHz
+	Band5GHz    Band = "5GHz"
+	Band5_9GHz  Band = "5.9GHz" // 802.11p / ITS 10 MHz channels (even numbers 170-184)
+	Band6GHz    Band = "6GHz"
+	Band60GHz   Band = "60GHz" // 802.11ad/ay (DMG)
+)
+
+// Channel is a channel number together with its band, center frequency and the global
+// operating class (IEEE 802.11-2020, Table E-4) of its 20 MHz channel, if there is one.
+type Channel struct {
+	Band           Band
+	Number         int
+	CenterFreqKHz  int   // S1G channels are 500 kHz apart, so the center is kept in kHz
+	OperatingClass uint8 // 0 if the channel has no global operating class
+}
+
+// CenterFreqMHz returns the center frequency in MHz (rounded down for S1G half-MHz centers).
+func (c Channel) CenterFreqMHz() int {
+	return c.CenterFreqKHz / 1000
+}
+
+// Channel starting frequencies, in kHz (channel n is at start + n * spacing).
+const (
+	s1gStartKHz      = 902000 // US S1G channelization, as used by Linux
+	s1gSpacingKHz    = 500
+	band2_4StartKHz  = 2407000
+	channel14FreqKHz = 2484000
+	band4_9StartKHz  = 4000000
+	band5StartKHz    = 5000000
+	band6StartKHz    = 5950000
+	channel6G2KHz    = 5935000 // 6 GHz channel 2 (operating class 136)
+	dmgStartKHz      = 56160000
+	dmgSpacingKHz    = 2160000
+	wifiSpacingKHz   = 5000
+)
+
+// The router agent validates channels against a copy of channels5GHz and blocks5GHz: run
+// 'go generate' in router_agent after changing them.
+
+// 5 GHz 20 MHz channels usable for Wi-Fi (U-NII-1 to U-NII-4).
+var channels5GHz = []int{
+	36, 40, 44, 48, 52, 56, 60, 64,
+	100, 104, 108, 112, 116, 120, 124, 128, 132, 136, 140, 144,
+	149, 153, 157, 161, 165, 169, 173, 177,
+}
+
+// 5 GHz channel blocks by width: the lowest 20 MHz channel of each block.
+var blocks5GHz = map[int][]int{
+	40:  {36, 44, 52, 60, 100, 108, 116, 124, 132, 140, 149, 157, 165, 173},
+	80:  {36, 52, 100, 116, 132, 149, 165},
+	160: {36, 100, 149},
+}
+
+func isChannel5GHz(number int) bool {
+	i := sort.SearchInts(channels5GHz, number)
+	return i < len(channels5GHz) && channels5GHz[i] == number
+}
+
+// ChannelFromFrequencyKHz maps a center frequency in kHz to its band and channel number.
+// It returns false for frequencies outside every 802.11 band or off the channel raster.
+func ChannelFromFrequencyKHz(freqKHz int) (Channel, bool) {
+	ch := Channel{CenterFreqKHz: freqKHz}
+	start, spacing := 0, wifiSpacingKHz
+	switch {
+	case freqKHz >= s1gStartKHz && freqKHz <= 928000:
+		ch.Band, start, spacing = Band900MHz, s1gStartKHz, s1gSpacingKHz
+	case freqKHz == channel14FreqKHz:
+		ch.Band, ch.Number = Band2_4GHz, 14
+	case freqKHz >= 2412000 && freqKHz <= 2472000:
+		ch.Band, start = Band2_4GHz, band2_4StartKHz
+	case freqKHz >= 4910000 && freqKHz <= 4980000:
+		ch.Band, start = Band4_9GHz, band4_9StartKHz
+	case freqKHz > band5StartKHz && freqKHz <= 5925000:
+		ch.Band, start = Band5GHz, band5StartKHz
+		// Wi-Fi channels and wide channel centers are odd above 5850 MHz; ITS channels are even
+		if freqKHz >= 5850000 && (freqKHz-band5StartKHz)/wifiSpacingKHz%2 == 0 {
+			ch.Band = Band5_9GHz
+		}
+	case freqKHz == channel6G2KHz:
+		ch.Band, ch.Number = Band6GHz, 2
+	case freqKHz >= 5955000 && freqKHz <= 7115000:
+		ch.Band, start = Band6GHz, band6StartKHz
+	case freqKHz >= 58320000 && freqKHz <= 70200000:
+		ch.Band, start, spacing = Band60GHz, dmgStartKHz, dmgSpacingKHz
+	default:
+		return Channel{}, false
+	}
+	if ch.Number == 0 {
+		if (freqKHz-start)%spacing != 0 {
+			return Channel{}, false // Off the channel raster
+		}
+		ch.Number = (freqKHz - start) / spacing
+	}
+	width := 20
+	if ch.Band == Band60GHz {
+		width = 2160
+	}
+	ch.OperatingClass, _ = OperatingClassForChannel(ch.Band, ch.Number, width)
+	return ch, true
+}
+
+// ChannelFromFrequency maps a center frequency in MHz (e.g. from radiotap) to its band and channel.
+func ChannelFromFrequency(freqMHz int) (Channel, bool) {
+	return ChannelFromFrequencyKHz(freqMHz * 1000)
+}
+
+// ChannelToFrequencyKHz returns the center frequency in kHz of a channel number in a band.
+// Center channel indices of wide channels (e.g. 42 for 80 MHz at 36-48) are accepted too.
+func ChannelToFrequencyKHz(band Band, number int) (int, bool) {
+	switch band {
+	case Band900MHz:
+		if number >= 1 && number <= 52 {
+			return s1gStartKHz + number*s1gSpacingKHz, true
+		}
+	case Band2_4GHz:
+		if number == 14 {
+			return channel14FreqKHz, true
+		}
+		if number >= 1 && number <= 13 {
+			return band2_4StartKHz + number*wifiSpacingKHz, true
+		}
+	case Band4_9GHz:
+		if number >= 182 && number <= 196 {
+			return band4_9StartKHz + number*wifiSpacingKHz, true
+		}
+	case Band5GHz:
+		if number >= 1 && number <= 181 {
+			return band5StartKHz + number*wifiSpacingKHz, true
+		}
+	case Band5_9GHz:
+		if number >= 170 && number <= 184 && number%2 == 0 {
+			return band5StartKHz + number*wifiSpacingKHz, true
+		}
+	case Band6GHz:
+		if number == 2 {
+			return channel6G2KHz, true
+		}
+		if number >= 1 && number <= 233 {
+			return band6StartKHz + number*wifiSpacingKHz, true
+		}
+	case Band60GHz:
+		if number >= 1 && number <= 6 {
+			return dmgStartKHz + number*dmgSpacingKHz, true
+		}
+	}
+	return 0, false
+}
+
+// ChannelToFrequency returns the center frequency in MHz of a channel number in a band.
+func ChannelToFrequency(band Band, number int) (int, bool) {
+	freqKHz, ok := ChannelToFrequencyKHz(band, number)
+	return freqKHz / 1000, ok
+}
+
+// IsValidPrimaryChannel reports whether number is a 20 MHz (DMG: 2.16 GHz, S1G: 1 MHz)
+// channel that can be used as a primary channel in the band.
+func IsValidPrimaryChannel(band Band, number int) bool {
+	switch band {
+	case Band900MHz:
+		return number >= 1 && number <= 51 && number%2 == 1
+	case Band2_4GHz:
+		return number >= 1 && number <= 14
+	case Band4_9GHz:
+		return number >= 182 && number <= 196
+	case Band5GHz:
+		return isChannel5GHz(number)
+	case Band5_9GHz:
+		return number >= 172 && number <= 184 && number%2 == 0
+	case Band6GHz:
+		return number == 2 || (number >= 1 && number <= 233 && number%4 == 1)
+	case Band60GHz:
+		return number >= 1 && number <= 6
+	}
+	return false
+}
+
+// CenterChannel returns the center channel index of the widthMHz wide channel (40, 80, 160
+// or 320) that contains the primary channel. 20 MHz returns the primary channel itself.
+// In 2.4 GHz, 40 MHz channels put the secondary channel above primaries 1-7 and below
+// primaries 8-13. 320 MHz channels in 6 GHz use the 320MHz-1 channelization.
+func CenterChannel(band Band, primary, widthMHz int) (int, bool) {
+	if !IsValidPrimaryChannel(band, primary) {
+		return 0, false
+	}
+	if widthMHz == 20 {
+		return primary, true
+	}
+	switch band {
+	case Band2_4GHz:
+		if widthMHz != 40 || primary == 14 {
+			return 0, false
+		}
+		if primary <= 7 {
+			return primary + 2, true
+		}
+		return primary - 2, true
+	case Band5GHz:
+		subchannels := widthMHz / 20
+		for _, start := range blocks5GHz[widthMHz] {
+			if primary >= start && primary < start+4*subchannels {
+				return start + 2*(subchannels-1), true
+			}
+		}
+	case Band6GHz:
+		if primary == 2 {
+			return 0, false // Channel 2 is a 20 MHz only channel
+		}
+		switch widthMHz {
+		case 40, 80, 160, 320:
+			subchannels := widthMHz / 20
+			group := (primary - 1) / 4 / subchannels
+			center := group*subchannels*4 + 2*subchannels - 1
+			if center+2*subchannels-1 > 233 {
+				return 0, false // Wide channel would extend past channel 233
+			}
+			return center, true
+		}
+	}
+	return 0, false
+}
+
+// CenterFrequency returns the center frequency in MHz of the widthMHz wide channel that
+// contains the primary channel.
+func CenterFrequency(band Band, primary, widthMHz int) (int, bool) {
+	center, ok := CenterChannel(band, primary, widthMHz)
+	if !ok {
+		return 0, false
+	}
+	return ChannelToFrequency(band, center)
+}
+
+// operatingClass is one row of the global operating class table (IEEE 802.11-2020, Table E-4).
+// For 80 MHz and wider classes, channels lists channel center frequency indices.
+type operatingClass struct {
+	class    uint8
+	band     Band
+	startKHz int
+	widthMHz int
+	channels []int
+}
+
+var globalOperatingClasses = []operatingClass{
+	{81, Band2_4GHz, 2407000, 20, channelRange(1, 13, 1)},
+	{82, Band2_4GHz, 2414000, 20, []int{14}},
+	{83, Band2_4GHz, 2407000, 40, channelRange(1, 9, 1)},  // Secondary channel above
+	{84, Band2_4GHz, 2407000, 40, channelRange(5, 13, 1)}, // Secondary channel below
+	{115, Band5GHz, 5000000, 20, []int{36, 40, 44, 48}},
+	{116, Band5GHz, 5000000, 40, []int{36, 44}},
+	{117, Band5GHz, 5000000, 40, []int{40, 48}},
+	{118, Band5GHz, 5000000, 20, []int{52, 56, 60, 64}},
+	{119, Band5GHz, 5000000, 40, []int{52, 60}},
+	{120, Band5GHz, 5000000, 40, []int{56, 64}},
+	{121, Band5GHz, 5000000, 20, channelRange(100, 144, 4)},
+	{122, Band5GHz, 5000000, 40, []int{100, 108, 116, 124, 132, 140}},
+	{123, Band5GHz, 5000000, 40, []int{104, 112, 120, 128, 136, 144}},
+	{124, Band5GHz, 5000000, 20, []int{149, 153, 157, 161}},
+	{125, Band5GHz, 5000000, 20, channelRange(149, 177, 4)},
+	{126, Band5GHz, 5000000, 40, []int{149, 157, 165, 173}},
+	{127, Band5GHz, 5000000, 40, []int{153, 161, 169, 177}},
+	{128, Band5GHz, 5000000, 80, []int{42, 58, 106, 122, 138, 155, 171}},
+	{129, Band5GHz, 5000000, 160, []int{50, 114, 163}},
+	{130, Band5GHz, 5000000, 80, []int{42, 58, 106, 122, 138, 155, 171}}, // 80+80
+	{131, Band6GHz, 5950000, 20, channelRange(1, 233, 4)},
+	{132, Band6GHz, 5950000, 40, channelRange(3, 227, 8)},
+	{133, Band6GHz, 5950000, 80, channelRange(7, 215, 16)},
+	{134, Band6GHz, 5950000, 160, channelRange(15, 207, 32)},
+	{135, Band6GHz, 5950000, 80, channelRange(7, 215, 16)}, // 80+80
+	{136, Band6GHz, 5925000, 20, []int{2}},
+	{137, Band6GHz, 5950000, 320, channelRange(31, 191, 32)},
+	{180, Band60GHz, 56160000, 2160, channelRange(1, 6, 1)},
+}
+
+func channelRange(first, last, step int) []int {
+	var channels []int
+	for ch := first; ch <= last; ch += step {
+		channels = append(channels, ch)
+	}
+	return channels
+}
+
+func findOperatingClass(class uint8) (operatingClass, bool) {
+	for _, oc := range globalOperatingClasses {
+		if oc.class == class {
+			return oc, true
+		}
+	}
+	return operatingClass{}, false
+}
+
+// OperatingClassChannel maps a global operating class and channel number (the primary channel
+// for 20/40 MHz classes, the center index for 80 MHz and wider) to a Channel. The returned
+// Channel carries the class it was resolved from.
+func OperatingClassChannel(class uint8, number int) (Channel, bool) {
+	oc, ok := findOperatingClass(class)
+	if !ok {
+		return Channel{}, false
+	}
+	for _, ch := range oc.channels {
+		if ch != number {
+			continue
+		}
+		spacing := wifiSpacingKHz
+		if oc.band == Band60GHz {
+			spacing = dmgSpacingKHz
+		}
+		return Channel{Band: oc.band, Number: number, CenterFreqKHz: oc.startKHz + number*spacing, OperatingClass: class}, true
+	}
+	return Channel{}, false
+}
+
+// OperatingClassWidth returns the band and channel width in MHz of a global operating class.
+func OperatingClassWidth(class uint8) (Band, int, bool) {
+	oc, ok := findOperatingClass(class)
+	return oc.band, oc.widthMHz, ok
+}
+
+// OperatingClassForChannel returns the global operating class of a BSS with the given primary
+// channel and width. 80+80 MHz BSSs report the 80 MHz class. Classes that list center
+// frequency indices (80 MHz and wider, and every wide 6 GHz class) are matched on the center.
+func OperatingClassForChannel(band Band, primary, widthMHz int) (uint8, bool) {
+	if !IsValidPrimaryChannel(band, primary) {
+		return 0, false
+	}
+	lookup := primary
+	if (widthMHz >= 80 && band != Band60GHz) || (band == Band6GHz && widthMHz == 40) {
+		center, ok := CenterChannel(band, primary, widthMHz)
+		if !ok {
+			return 0, false
+		}
+		lookup = center
+	}
+	if band == Band2_4GHz && widthMHz == 40 {
+		// Classes 83 and 84 overlap; pick the one matching CenterChannel's secondary side
+		if primary <= 7 {
+			return 83, true
+		}
+		return 84, primary != 14
+	}
+	for _, oc := range globalOperatingClasses {
+		if oc.band != band || oc.widthMHz != widthMHz || oc.class == 130 || oc.class == 135 {
+			continue
+		}
+		for _, ch := range oc.channels {
+			if ch == lookup {
+				// Classes are ordered so 149-161 resolve to 124 before the wider class 125
+				return oc.class, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,280 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelFromFrequency(t *testing.T) {
+	cases := []struct {
+		freqMHz int
+		expect  Channel
+	}{
+		{2412, Channel{Band: Band2_4GHz, Number: 1, CenterFreqKHz: 2412000, OperatingClass: 81}},
+		{2437, Channel{Band: Band2_4GHz, Number: 6, CenterFreqKHz: 2437000, OperatingClass: 81}},
+		{2472, Channel{Band: Band2_4GHz, Number: 13, CenterFreqKHz: 2472000, OperatingClass: 81}},
+		{2484, Channel{Band: Band2_4GHz, Number: 14, CenterFreqKHz: 2484000, OperatingClass: 82}},
+		{4920, Channel{Band: Band4_9GHz, Number: 184, CenterFreqKHz: 4920000}},
+		{4980, Channel{Band: Band4_9GHz, Number: 196, CenterFreqKHz: 4980000}},
+		{5180, Channel{Band: Band5GHz, Number: 36, CenterFreqKHz: 5180000, OperatingClass: 115}},
+		{5260, Channel{Band: Band5GHz, Number: 52, CenterFreqKHz: 5260000, OperatingClass: 118}},
+		{5720, Channel{Band: Band5GHz, Number: 144, CenterFreqKHz: 5720000, OperatingClass: 121}},
+		{5745, Channel{Band: Band5GHz, Number: 149, CenterFreqKHz: 5745000, OperatingClass: 124}},
+		{5825, Channel{Band: Band5GHz, Number: 165, CenterFreqKHz: 5825000, OperatingClass: 125}},
+		{5885, Channel{Band: Band5GHz, Number: 177, CenterFreqKHz: 5885000, OperatingClass: 125}},
+		{5860, Channel{Band: Band5_9GHz, Number: 172, CenterFreqKHz: 5860000}},
+		{5920, Channel{Band: Band5_9GHz, Number: 184, CenterFreqKHz: 5920000}},
+		{5935, Channel{Band: Band6GHz, Number: 2, CenterFreqKHz: 5935000, OperatingClass: 136}},
+		{5955, Channel{Band: Band6GHz, Number: 1, CenterFreqKHz: 5955000, OperatingClass: 131}},
+		{6415, Channel{Band: Band6GHz, Number: 93, CenterFreqKHz: 6415000, OperatingClass: 131}},
+		{7115, Channel{Band: Band6GHz, Number: 233, CenterFreqKHz: 7115000, OperatingClass: 131}},
+		{58320, Channel{Band: Band60GHz, Number: 1, CenterFreqKHz: 58320000, OperatingClass: 180}},
+		{69120, Channel{Band: Band60GHz, Number: 6, CenterFreqKHz: 69120000, OperatingClass: 180}},
+		{903, Channel{Band: Band900MHz, Number: 2, CenterFreqKHz: 903000}},
+		{927, Channel{Band: Band900MHz, Number: 50, CenterFreqKHz: 927000}},
+	}
+	for _, tc := range cases {
+		ch, ok := ChannelFromFrequency(tc.freqMHz)
+		require.True(t, ok, "%d MHz", tc.freqMHz)
+		assert.Equal(t, tc.expect, ch, "%d MHz", tc.freqMHz)
+	}
+
+	for _, freq := range []int{0, 800, 2400, 2413, 2477, 2483, 4900, 5000, 5182, 5930, 5940, 7120, 58000, 58321, 70300} {
+		_, ok := ChannelFromFrequency(freq)
+		assert.False(t, ok, "%d MHz", freq)
+	}
+}
+
+func TestChannelFromFrequencyKHz_S1G(t *testing.T) {
+	ch, ok := ChannelFromFrequencyKHz(902500)
+	require.True(t, ok)
+	assert.Equal(t, Channel{Band: Band900MHz, Number: 1, CenterFreqKHz: 902500}, ch)
+	assert.Equal(t, 902, ch.CenterFreqMHz())
+
+	_, ok = ChannelFromFrequencyKHz(902750)
+	assert.False(t, ok, "Off the 500 kHz raster")
+}
+
+// Every channel of every band maps to a frequency and back to the same band and channel.
+func TestChannelFrequencyRoundTrip(t *testing.T) {
+	bands := []struct {
+		band        Band
+		first, last int
+		step        int
+	}{
+		{Band900MHz, 1, 51, 2},
+		{Band2_4GHz, 1, 14, 1},
+		{Band4_9GHz, 182, 196, 1},
+		{Band5GHz, 36, 64, 4},
+		{Band5GHz, 100, 144, 4},
+		{Band5GHz, 149, 177, 4},
+		{Band5_9GHz, 172, 184, 2},
+		{Band6GHz, 1, 233, 4},
+		{Band6GHz, 2, 2, 1},
+		{Band60GHz, 1, 6, 1},
+	}
+	for _, b := range bands {
+		for number := b.first; number <= b.last; number += b.step {
+			require.True(t, IsValidPrimaryChannel(b.band, number), "%s channel %d", b.band, number)
+			freqKHz, ok := ChannelToFrequencyKHz(b.band, number)
+			require.True(t, ok, "%s channel %d", b.band, number)
+			ch, ok := ChannelFromFrequencyKHz(freqKHz)
+			require.True(t, ok, "%s channel %d (%d kHz)", b.band, number, freqKHz)
+			assert.Equal(t, b.band, ch.Band, "%s channel %d", b.band, number)
+			assert.Equal(t, number, ch.Number, "%s channel %d", b.band, number)
+		}
+	}
+}
+
+func TestChannelToFrequency(t *testing.T) {
+	freq, ok := ChannelToFrequency(Band6GHz, 37)
+	assert.True(t, ok)
+	assert.Equal(t, 6135, freq)
+
+	freq, ok = ChannelToFrequency(Band5GHz, 42) // 80 MHz center index
+	assert.True(t, ok)
+	assert.Equal(t, 5210, freq)
+
+	for _, tc := range []struct {
+		band   Band
+		number int
+	}{
+		{Band2_4GHz, 0}, {Band2_4GHz, 15}, {Band6GHz, 234}, {Band60GHz, 7}, {Band4_9GHz, 181}, {Band5_9GHz, 175}, {BandUnknown, 1},
+	} {
+		_, ok := ChannelToFrequency(tc.band, tc.number)
+		assert.False(t, ok, "%s channel %d", tc.band, tc.number)
+	}
+}
+
+func TestIsValidPrimaryChannel(t *testing.T) {
+	assert.False(t, IsValidPrimaryChannel(Band5GHz, 38))
+	assert.False(t, IsValidPrimaryChannel(Band5GHz, 68))
+	assert.False(t, IsValidPrimaryChannel(Band6GHz, 3))
+	assert.False(t, IsValidPrimaryChannel(Band900MHz, 2))
+	assert.False(t, IsValidPrimaryChannel(Band5_9GHz, 173))
+	assert.True(t, IsValidPrimaryChannel(Band5_9GHz, 174))
+}
+
+func TestCenterChannel(t *testing.T) {
+	cases := []struct {
+		band           Band
+		primary, width int
+		center         int
+		centerMHz      int
+	}{
+		{Band2_4GHz, 6, 20, 6, 2437},
+		{Band2_4GHz, 1, 40, 3, 2422},
+		{Band2_4GHz, 11, 40, 9, 2452},
+		{Band5GHz, 36, 40, 38, 5190},
+		{Band5GHz, 48, 40, 46, 5230},
+		{Band5GHz, 165, 40, 167, 5835},
+		{Band5GHz, 36, 80, 42, 5210},
+		{Band5GHz, 64, 80, 58, 5290},
+		{Band5GHz, 112, 80, 106, 5530},
+		{Band5GHz, 144, 80, 138, 5690},
+		{Band5GHz, 157, 80, 155, 5775},
+		{Band5GHz, 177, 80, 171, 5855},
+		{Band5GHz, 52, 160, 50, 5250},
+		{Band5GHz, 128, 160, 114, 5570},
+		{Band5GHz, 161, 160, 163, 5815},
+		{Band6GHz, 1, 40, 3, 5965},
+		{Band6GHz, 37, 80, 39, 6145},
+		{Band6GHz, 37, 160, 47, 6185},
+		{Band6GHz, 1, 320, 31, 6105},
+		{Band6GHz, 93, 320, 95, 6425},
+		{Band6GHz, 189, 320, 159, 6745},
+	}
+	for _, tc := range cases {
+		center, ok := CenterChannel(tc.band, tc.primary, tc.width)
+		require.True(t, ok, "%s %d @ %d MHz", tc.band, tc.primary, tc.width)
+		assert.Equal(t, tc.center, center, "%s %d @ %d MHz", tc.band, tc.primary, tc.width)
+		freq, ok := CenterFrequency(tc.band, tc.primary, tc.width)
+		require.True(t, ok)
+		assert.Equal(t, tc.centerMHz, freq, "%s %d @ %d MHz", tc.band, tc.primary, tc.width)
+	}
+
+	invalid := []struct {
+		band           Band
+		primary, width int
+	}{
+		{Band2_4GHz, 14, 40},
+		{Band2_4GHz, 6, 80},
+		{Band5GHz, 38, 40},
+		{Band5GHz, 36, 320},
+		{Band5GHz, 144, 160},
+		{Band6GHz, 2, 40},
+		{Band6GHz, 225, 320},
+		{Band6GHz, 233, 40},
+		{Band60GHz, 1, 40},
+	}
+	for _, tc := range invalid {
+		_, ok := CenterChannel(tc.band, tc.primary, tc.width)
+		assert.False(t, ok, "%s %d @ %d MHz", tc.band, tc.primary, tc.width)
+	}
+}
+
+// Every 6 GHz primary resolves to the wide channel of Table E-4 that contains it.
+func TestCenterChannel_6GHzMatchesOperatingClasses(t *testing.T) {
+	for _, width := range []int{40, 80, 160, 320} {
+		for primary := 1; primary <= 233; primary += 4 {
+			center, ok := CenterChannel(Band6GHz, primary, width)
+			if !ok {
+				continue
+			}
+			assert.LessOrEqual(t, center-width/10+2, primary, "primary %d @ %d MHz", primary, width)
+			assert.GreaterOrEqual(t, center+width/10-2, primary, "primary %d @ %d MHz", primary, width)
+			class, ok := OperatingClassForChannel(Band6GHz, primary, width)
+			require.True(t, ok, "primary %d @ %d MHz", primary, width)
+			_, ok = OperatingClassChannel(class, center)
+			assert.True(t, ok, "primary %d @ %d MHz in class %d", primary, width, class)
+		}
+	}
+}
+
+func TestOperatingClassForChannel(t *testing.T) {
+	cases := []struct {
+		band           Band
+		primary, width int
+		class          uint8
+	}{
+		{Band2_4GHz, 6, 20, 81},
+		{Band2_4GHz, 14, 20, 82},
+		{Band2_4GHz, 1, 40, 83},
+		{Band2_4GHz, 13, 40, 84},
+		{Band5GHz, 36, 20, 115},
+		{Band5GHz, 36, 40, 116},
+		{Band5GHz, 40, 40, 117},
+		{Band5GHz, 60, 40, 119},
+		{Band5GHz, 64, 40, 120},
+		{Band5GHz, 100, 20, 121},
+		{Band5GHz, 140, 40, 122},
+		{Band5GHz, 144, 40, 123},
+		{Band5GHz, 161, 20, 124},
+		{Band5GHz, 169, 20, 125},
+		{Band5GHz, 165, 40, 126},
+		{Band5GHz, 177, 40, 127},
+		{Band5GHz, 149, 80, 128},
+		{Band5GHz, 100, 160, 129},
+		{Band6GHz, 5, 20, 131},
+		{Band6GHz, 5, 40, 132},
+		{Band6GHz, 5, 80, 133},
+		{Band6GHz, 5, 160, 134},
+		{Band6GHz, 2, 20, 136},
+		{Band6GHz, 5, 320, 137},
+		{Band60GHz, 2, 2160, 180},
+	}
+	for _, tc := range cases {
+		class, ok := OperatingClassForChannel(tc.band, tc.primary, tc.width)
+		require.True(t, ok, "%s %d @ %d MHz", tc.band, tc.primary, tc.width)
+		assert.Equal(t, tc.class, class, "%s %d @ %d MHz", tc.band, tc.primary, tc.width)
+	}
+
+	_, ok := OperatingClassForChannel(Band5GHz, 38, 20)
+	assert.False(t, ok)
+	_, ok = OperatingClassForChannel(Band4_9GHz, 184, 20)
+	assert.False(t, ok)
+	_, ok = OperatingClassForChannel(Band2_4GHz, 14, 40)
+	assert.False(t, ok)
+}
+
+func TestOperatingClassChannel(t *testing.T) {
+	ch, ok := OperatingClassChannel(81, 6)
+	require.True(t, ok)
+	assert.Equal(t, Channel{Band: Band2_4GHz, Number: 6, CenterFreqKHz: 2437000, OperatingClass: 81}, ch)
+
+	ch, ok = OperatingClassChannel(128, 155)
+	require.True(t, ok)
+	assert.Equal(t, 5775, ch.CenterFreqMHz())
+
+	ch, ok = OperatingClassChannel(136, 2)
+	require.True(t, ok)
+	assert.Equal(t, 5935, ch.CenterFreqMHz())
+
+	ch, ok = OperatingClassChannel(180, 3)
+	require.True(t, ok)
+	assert.Equal(t, 62640, ch.CenterFreqMHz())
+
+	_, ok = OperatingClassChannel(115, 52)
+	assert.False(t, ok)
+	_, ok = OperatingClassChannel(200, 1)
+	assert.False(t, ok)
+
+	band, width, ok := OperatingClassWidth(134)
+	assert.True(t, ok)
+	assert.Equal(t, Band6GHz, band)
+	assert.Equal(t, 160, width)
+
+	// Every channel listed in the table maps back to the class's band
+	for _, oc := range globalOperatingClasses {
+		for _, number := range oc.channels {
+			ch, ok := OperatingClassChannel(oc.class, number)
+			require.True(t, ok, "class %d channel %d", oc.class, number)
+			fromFreq, ok := ChannelFromFrequencyKHz(ch.CenterFreqKHz)
+			require.True(t, ok, "class %d channel %d", oc.class, number)
+			assert.Equal(t, oc.band, fromFreq.Band, "class %d channel %d", oc.class, number)
+			assert.Equal(t, number, fromFreq.Number, "class %d channel %d", oc.class, number)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:generate go run ./tools/genchannels
+
+// The channel tables (channels5GHz, blocks5GHz) are generated from WifiPcapAnalyzer/utils/channels.go.
+// Only the bands that 'iw dev <iface> set channel' addresses by channel number are accepted
+// (2.4 GHz for 1-14, 5 GHz above).
+
+// parseBandwidth parses an iw style bandwidth ("HT20", "HT40+", "HT40-", "VHT80", "80MHz", ...)
+// into its width in MHz and the secondary channel side (+1 above, -1 below, 0 unspecified).
+func parseBandwidth(bandwidth string) (widthMHz int, secondary int, err error) {
+	s := strings.ToUpper(strings.TrimSpace(bandwidth))
+	if s == "" {
+		return 20, 0, nil
+	}
+	if strings.HasSuffix(s, "+") {
+		secondary = 1
+	} else if strings.HasSuffix(s, "-") {
+		secondary = -1
+	}
+	s = strings.TrimRight(s, "+-")
+	for _, prefix := range []string{"VHT", "HT", "HE", "EHT"} {
+		if strings.HasPrefix(s, prefix) {
+			s = strings.TrimPrefix(s, prefix)
+			break
+		}
+	}
+	s = strings.TrimSuffix(s, "MHZ")
+	widthMHz, err = strconv.Atoi(s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unrecognized bandwidth %q", bandwidth)
+	}
+	switch widthMHz {
+	case 20, 40, 80, 160:
+	default:
+		return 0, 0, fmt.Errorf("unsupported bandwidth %q", bandwidth)
+	}
+	if secondary != 0 && widthMHz != 40 {
+		return 0, 0, fmt.Errorf("secondary channel offset is only valid for 40 MHz, got %q", bandwidth)
+	}
+	return widthMHz, secondary, nil
+}
+
+// validateChannel checks that channel is a 2.4 GHz or 5 GHz primary channel and that a
+// channel of the requested bandwidth can be formed around it.
+func validateChannel(channel int32, bandwidth string) error {
+	widthMHz, secondary, err := parseBandwidth(bandwidth)
+	if err != nil {
+		return err
+	}
+	ch := int(channel)
+	if ch >= 1 && ch <= 14 {
+		switch {
+		case widthMHz == 20:
+			return nil
+		case widthMHz > 40 || ch == 14:
+			return fmt.Errorf("channel %d does not support %d MHz", ch, widthMHz)
+		case secondary > 0 && ch > 9:
+			return fmt.Errorf("channel %d has no secondary channel above it", ch)
+		case secondary < 0 && ch < 5:
+			return fmt.Errorf("channel %d has no secondary channel below it", ch)
+		}
+		return nil
+	}
+
+	valid := false
+	for _, c := range channels5GHz {
+		if c == ch {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("channel %d is not a valid 2.4 GHz or 5 GHz channel", ch)
+	}
+	if widthMHz == 20 {
+		return nil
+	}
+	for _, start := range blocks5GHz[widthMHz] {
+		if ch < start || ch >= start+widthMHz/5 {
+			continue
+		}
+		if (secondary > 0 && ch != start) || (secondary < 0 && ch == start) {
+			return fmt.Errorf("channel %d cannot use %s", ch, bandwidth)
+		}
+		return nil
+	}
+	return fmt.Errorf("channel %d does not support %d MHz", ch, widthMHz)
+}
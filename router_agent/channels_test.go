@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestParseBandwidth(t *testing.T) {
+	cases := []struct {
+		bandwidth string
+		width     int
+		secondary int
+		wantErr   bool
+	}{
+		{"", 20, 0, false},
+		{"HT20", 20, 0, false},
+		{"ht40+", 40, 1, false},
+		{"HT40-", 40, -1, false},
+		{"VHT80", 80, 0, false},
+		{"80MHz", 80, 0, false},
+		{" 160MHz ", 160, 0, false},
+		{"HE160", 160, 0, false},
+		{"EHT320", 0, 0, true},
+		{"VHT80+", 0, 0, true},
+		{"HT30", 0, 0, true},
+		{"wide", 0, 0, true},
+	}
+	for _, tc := range cases {
+		width, secondary, err := parseBandwidth(tc.bandwidth)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseBandwidth(%q): expected an error, got %d MHz", tc.bandwidth, width)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseBandwidth(%q): %v", tc.bandwidth, err)
+			continue
+		}
+		if width != tc.width || secondary != tc.secondary {
+			t.Errorf("parseBandwidth(%q) = %d, %d; want %d, %d", tc.bandwidth, width, secondary, tc.width, tc.secondary)
+		}
+	}
+}
+
+func TestValidateChannel(t *testing.T) {
+	cases := []struct {
+		channel   int32
+		bandwidth string
+		valid     bool
+	}{
+		{1, "", true},
+		{14, "HT20", true},
+		{6, "HT40+", true},
+		{6, "HT40-", true},
+		{1, "HT40-", false},  // No secondary channel below 1
+		{11, "HT40+", false}, // No secondary channel above 11
+		{14, "HT40+", false},
+		{6, "VHT80", false},
+		{0, "", false},
+		{15, "", false},
+		{36, "VHT80", true},
+		{48, "VHT80", true},
+		{36, "HT40+", true},
+		{40, "HT40-", true},
+		{40, "HT40+", false}, // 40 is the upper channel of its 40 MHz block
+		{36, "HT40-", false},
+		{100, "160MHz", true},
+		{144, "VHT80", true},
+		{165, "VHT80", true},
+		{165, "160MHz", true},
+		{144, "160MHz", false},
+		{38, "", false}, // Not a 20 MHz channel
+		{200, "", false},
+		{36, "HT30", false},
+	}
+	for _, tc := range cases {
+		err := validateChannel(tc.channel, tc.bandwidth)
+		if tc.valid && err != nil {
+			t.Errorf("validateChannel(%d, %q): unexpected error: %v", tc.channel, tc.bandwidth, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("validateChannel(%d, %q): expected an error", tc.channel, tc.bandwidth)
+		}
+	}
+}
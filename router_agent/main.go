@@ -121,12 +121,21 @@ func (s *server) SendControlCommand(ctx context.Context, req *ControlRequest) (*
 		return &ControlResponse{Success: true, Message: "Capture stopped successfully"}, nil
 
 	case ControlCommandType_SET_CHANNEL:
-		// Placeholder for future implementation
-		// s.currentChannel = req.Channel
-		// err := s.setInterfaceParams(req.InterfaceName, req.Channel, s.currentBandwidth)
-		// if err != nil { ... }
-		log.Printf("SET_CHANNEL command received for channel %d (not fully implemented)", req.Channel)
-		return &ControlResponse{Success: true, Message: "SET_CHANNEL command received (not fully implemented)"}, nil
+		if err := validateChannel(req.Channel, s.currentBandwidth); err != nil {
+			log.Printf("Rejecting SET_CHANNEL for channel %d: %v", req.Channel, err)
+			return &ControlResponse{Success: false, Message: fmt.Sprintf("Invalid channel: %v", err)}, nil
+		}
+		iface := req.InterfaceName
+		if iface == "" {
+			iface = s.currentInterface
+		}
+		if iface == "" {
+			return &ControlResponse{Success: false, Message: "Interface name cannot be empty for SET_CHANNEL"}, nil
+		}
+		if err := s.setInterfaceParams(iface, req.Channel, s.currentBandwidth); err != nil {
+			return &ControlResponse{Success: false, Message: fmt.Sprintf("Failed to set channel %d: %v", req.Channel, err)}, nil
+		}
+		return &ControlResponse{Success: true, Message: fmt.Sprintf("Channel set to %d on %s", req.Channel, iface)}, nil
 
 	case ControlCommandType_SET_BANDWIDTH:
 		// Placeholder for future implementation
@@ -221,8 +230,8 @@ func (s *server) StreamPackets(req *ControlRequest, stream CaptureAgent_StreamPa
 	}
 }
 
-// setInterfaceParams (Optional, for future use with SET_CHANNEL/SET_BANDWIDTH)
-// This function would use 'iw' command to set channel and bandwidth.
+// setInterfaceParams uses the 'iw' command to set channel and bandwidth (SET_CHANNEL, and
+// SET_BANDWIDTH in the future).
 // Example: iw dev ath1 set channel <channel> [HT20|HT40|VHT20|VHT40|VHT80|VHT160]
 func (s *server) setInterfaceParams(iface string, channel int32, bandwidth string) error {
 	if channel <= 0 && bandwidth == "" {
@@ -0,0 +1,69 @@
+// Command genchannels copies the 5 GHz channel tables of the desktop app
+// (WifiPcapAnalyzer/utils/channels.go) into the router agent, so both validate
+// channels against the same data. Run it with 'go generate' in router_agent.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+)
+
+// tables are the package level variables copied from the source file, in output order.
+var tables = []string{"channels5GHz", "blocks5GHz"}
+
+func main() {
+	src := flag.String("src", "../desktop_app/WifiPcapAnalyzer/utils/channels.go", "Go file declaring the channel tables")
+	out := flag.String("out", "channel_tables.go", "generated file")
+	pkg := flag.String("package", "main", "package of the generated file")
+	flag.Parse()
+
+	code, err := generate(*src, *pkg)
+	if err != nil {
+		log.Fatalf("genchannels: %v", err)
+	}
+	if err := os.WriteFile(*out, code, 0o644); err != nil {
+		log.Fatalf("genchannels: %v", err)
+	}
+}
+
+// generate returns the source of a file declaring the channel tables of src in package pkg.
+func generate(src, pkg string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, src, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	decls := make(map[string]*ast.GenDecl)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR || len(gen.Specs) != 1 {
+			continue
+		}
+		if spec, ok := gen.Specs[0].(*ast.ValueSpec); ok && len(spec.Names) == 1 {
+			decls[spec.Names[0].Name] = gen
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by genchannels from WifiPcapAnalyzer/utils/channels.go; DO NOT EDIT.\n\npackage %s\n", pkg)
+	for _, name := range tables {
+		decl, ok := decls[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: no var declaration of %s", src, name)
+		}
+		buf.WriteString("\n")
+		if err := printer.Fprint(&buf, fset, &printer.CommentedNode{Node: decl, Comments: file.Comments}); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+	}
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestGeneratedTablesUpToDate fails when the desktop app's channel tables changed without
+// re-running 'go generate' in router_agent.
+func TestGeneratedTablesUpToDate(t *testing.T) {
+	want, err := generate("../../../desktop_app/WifiPcapAnalyzer/utils/channels.go", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../../channel_tables.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("channel_tables.go is out of date, run 'go generate' in router_agent")
+	}
+}